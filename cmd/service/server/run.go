@@ -12,22 +12,32 @@ import (
 
 	clusterclient "github.com/argoproj/argo-cd/v2/pkg/apiclient/cluster"
 	clusterpkg "github.com/argoproj/argo-cd/v2/pkg/apiclient/cluster"
+	argocdclientset "github.com/argoproj/argo-cd/v2/pkg/client/clientset/versioned"
 	"github.com/gin-gonic/gin"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"k8s.io/client-go/discovery"
 
+	"github.com/squidflow/service/pkg/appstatus"
 	"github.com/squidflow/service/pkg/argocd"
+	"github.com/squidflow/service/pkg/audit"
+	"github.com/squidflow/service/pkg/auth"
 	"github.com/squidflow/service/pkg/config"
 	"github.com/squidflow/service/pkg/fs"
 	"github.com/squidflow/service/pkg/git"
 	"github.com/squidflow/service/pkg/handler"
+	"github.com/squidflow/service/pkg/idempotency"
 	"github.com/squidflow/service/pkg/kube"
 	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/metrics"
 	"github.com/squidflow/service/pkg/middleware"
+	"github.com/squidflow/service/pkg/operation"
+	"github.com/squidflow/service/pkg/ratelimit"
+	"github.com/squidflow/service/pkg/rbac"
 	repowriter "github.com/squidflow/service/pkg/repo/writer"
 	"github.com/squidflow/service/pkg/store"
+	"github.com/squidflow/service/pkg/tracing"
 )
 
 func NewRunCommand() *cobra.Command {
@@ -68,7 +78,7 @@ func runServer(cmd *cobra.Command, args []string) {
 		log.G().Fatalf("Failed to get config file: %v", err)
 	}
 
-	_, err = config.ParseConfig(configFile)
+	cfg, err := config.ParseConfig(configFile)
 	if err != nil {
 		log.G().Fatalf("Failed to load config: %v", err)
 	}
@@ -141,7 +151,61 @@ func runServer(cmd *cobra.Command, args []string) {
 		log.G().Fatalf("failed to initialize repo writer: %v", err)
 	}
 
-	r := setupRouter()
+	// 3. init request authenticator
+	authenticator, err := auth.NewAuthenticator(context.Background(), cfg.AuthConfig())
+	if err != nil {
+		log.G().Fatalf("failed to initialize authenticator: %v", err)
+	}
+
+	// 4. init rbac policy enforcer
+	enforcer, err := buildEnforcer(cfg, factory)
+	if err != nil {
+		log.G().Fatalf("failed to initialize rbac enforcer: %v", err)
+	}
+
+	// 5. init audit sink
+	auditSink, err := audit.NewSink(cfg.Audit.Sink, cfg.Audit.File)
+	if err != nil {
+		log.G().Fatalf("failed to initialize audit sink: %v", err)
+	}
+
+	// 6. start the workers of asynchronous operations
+	operations := operation.NewManager(&operation.ManagerOptions{
+		Workers:   cfg.Operations.Workers,
+		QueueSize: cfg.Operations.QueueSize,
+		Retention: cfg.Operations.Retention,
+		OnFinish:  auditOperation(auditSink),
+	})
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	operations.Start(backgroundCtx)
+
+	// 7. watch argocd applications for the status event streams
+	argocdClientset, err := argocdclientset.NewForConfig(restConfig)
+	if err != nil {
+		log.G().Fatalf("failed to create argocd clientset: %v", err)
+	}
+	appWatcher := appstatus.NewWatcher(argocdClientset, store.Default.ArgoCDNamespace, 10*time.Minute)
+	if err := appWatcher.Start(backgroundCtx); err != nil {
+		log.G().Fatalf("failed to start argocd application watcher: %v", err)
+	}
+
+	// 8. export trace spans to the otlp collector
+	shutdownTracing, err := tracing.Setup(backgroundCtx, cfg.TracingConfig())
+	if err != nil {
+		log.G().Fatalf("failed to initialize tracing: %v", err)
+	}
+
+	tenantLimiter, repoLimiter := buildLimiters(cfg)
+
+	// 9. keep the responses of requests with an Idempotency-Key header
+	idempotencyStore := idempotency.NewMemoryStore(cfg.Idempotency.TTL)
+	idempotencyStore.Start(backgroundCtx)
+
+	// 10. drop the ended freeze windows from the sync windows of the projects
+	handler.StartFreezeWindowSync(backgroundCtx, repoLimiter, cfg.FreezeWindows.SyncInterval)
+
+	r := setupRouter(authenticator, enforcer, auditSink, operations, appWatcher, tenantLimiter, repoLimiter, idempotencyStore)
 
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%d", viper.GetInt("server.port")),
@@ -168,60 +232,132 @@ func runServer(cmd *cobra.Command, args []string) {
 		log.G().Errorf("Server forced to shutdown: %v", err)
 	}
 
+	if err := shutdownTracing(ctx); err != nil {
+		log.G().Errorf("Failed to flush trace spans: %v", err)
+	}
+
 	log.G().Info("Server exiting")
 }
 
-func setupRouter() *gin.Engine {
+func setupRouter(authenticator auth.Authenticator, enforcer *rbac.Enforcer, auditSink audit.Sink, operations *operation.Manager, appWatcher *appstatus.Watcher,
+	tenantLimiter *ratelimit.TenantLimiter, repoLimiter *ratelimit.RepoLimiter, idempotencyStore idempotency.Store) *gin.Engine {
 	r := gin.Default()
 
 	r.Use(gin.Recovery())
 	r.Use(middleware.CorsMiddleware())
 	r.Use(middleware.RequestIDMiddleware())
-	r.Use(middleware.AuthMiddleware())
+	r.Use(middleware.TracingMiddleware())
+	r.Use(middleware.LoggerMiddleware())
+	r.Use(middleware.MetricsMiddleware())
+
+	// scraped by prometheus, registered ahead of the auth middleware
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	r.Use(middleware.AuditMiddleware(auditSink))
+	r.Use(middleware.AuthMiddleware(authenticator))
+	r.Use(middleware.RBACMiddleware(enforcer))
+	r.Use(middleware.RateLimitMiddleware(tenantLimiter, repoLimiter))
 	r.Use(middleware.KubeFactoryMiddleware())
+	r.Use(middleware.OperationMiddleware(operations))
+	r.Use(middleware.AppWatcherMiddleware(appWatcher))
+	r.Use(middleware.IdempotencyMiddleware(idempotencyStore))
 
 	v1 := r.Group("/api/v1")
 	{
 		v1.GET("/healthz", handler.Healthz)
 	}
 
-	// app code
+	// audit records of mutating calls
+	{
+		v1.GET("/audit", handler.AuditList)
+	}
+
+	// asynchronous operations, mutating calls opt in with `?async=true` or `Prefer: respond-async`
+	{
+		v1.GET("/operations/:id", handler.OperationGet)
+		v1.POST("/operations/:id/cancel", handler.OperationCancel)
+	}
+
+	// app code registry, stored in the meta repo
+	// applications can only be created with an active appcode visible to the tenant
 	{
-		v1.GET("/appcode", handler.AppCodeList)
+		// deprecated: use GET /appcodes
+		v1.GET("/appcode", middleware.Authorize(rbac.ResourceAppCodes, rbac.VerbRead), handler.AppCodeList)
+	}
+	appCodes := v1.Group("/appcodes")
+	{
+		appCodes.POST("", middleware.Authorize(rbac.ResourceAppCodes, rbac.VerbCreate), middleware.Idempotent(), handler.AppCodeCreate)
+		appCodes.GET("", middleware.Authorize(rbac.ResourceAppCodes, rbac.VerbRead), handler.AppCodeList)
+		appCodes.GET("/:name", middleware.Authorize(rbac.ResourceAppCodes, rbac.VerbRead), handler.AppCodeGet)
+		appCodes.PATCH("/:name", middleware.Authorize(rbac.ResourceAppCodes, rbac.VerbUpdate), handler.AppCodeUpdate)
+		appCodes.POST("/:name/deactivate", middleware.Authorize(rbac.ResourceAppCodes, rbac.VerbUpdate), handler.AppCodeDeactivate)
 	}
 
 	// the target cluster of argo application
 	// cluster name is required, immutable, unique
+	// ClusterRegister enforces the rbac policy itself
 	clusters := v1.Group("/clusters")
 	{
 		clusters.POST("", handler.ClusterRegister)
-		clusters.GET("", handler.ClusterList)
-		clusters.GET("/:name", handler.ClusterGet)
-		clusters.DELETE("/:name", handler.ClusterDeregister)
-		clusters.PATCH("/:name", handler.ClusterUpdate)
+		clusters.GET("", middleware.Authorize(rbac.ResourceClusters, rbac.VerbRead), handler.ClusterList)
+		clusters.GET("/:name", middleware.Authorize(rbac.ResourceClusters, rbac.VerbRead), handler.ClusterGet)
+		clusters.DELETE("/:name", middleware.Authorize(rbac.ResourceClusters, rbac.VerbDelete), handler.ClusterDeregister)
+		clusters.PATCH("/:name", middleware.Authorize(rbac.ResourceClusters, rbac.VerbUpdate), handler.ClusterUpdate)
 	}
 
 	// real api, to manage the lifecycle of ArgoApplication
 	applications := v1.Group("/deploy/applications")
 	{
-		applications.POST("", handler.ApplicationCreate)
-		applications.GET("", handler.ApplicationsList)
-		applications.POST("/sync", handler.ApplicationSync)
-		applications.POST("/validate", handler.ApplicationSourceValidate)
+		applications.POST("", middleware.Authorize(rbac.ResourceApplications, rbac.VerbCreate), middleware.Idempotent(), handler.ApplicationCreate)
+		applications.GET("", middleware.Authorize(rbac.ResourceApplications, rbac.VerbRead), handler.ApplicationsList)
+		applications.GET("/events", middleware.Authorize(rbac.ResourceApplications, rbac.VerbRead), handler.ApplicationsEvents)
+		applications.POST("/sync", middleware.Authorize(rbac.ResourceApplications, rbac.VerbSync), handler.ApplicationSync)
+		applications.POST("/validate", middleware.Authorize(rbac.ResourceApplications, rbac.VerbRead), handler.ApplicationSourceValidate)
 
 		app := applications.Group("/:name")
 		{
-			app.GET("", handler.ApplicationGet)
-			app.PATCH("", handler.ApplicationUpdate)
-			app.DELETE("", handler.ApplicationDelete)
+			app.GET("", middleware.Authorize(rbac.ResourceApplications, rbac.VerbRead), handler.ApplicationGet)
+			app.GET("/events", middleware.Authorize(rbac.ResourceApplications, rbac.VerbRead), handler.ApplicationEvents)
+			app.PATCH("", middleware.Authorize(rbac.ResourceApplications, rbac.VerbUpdate), handler.ApplicationUpdate)
+			app.GET("/diff", middleware.Authorize(rbac.ResourceApplications, rbac.VerbRead), handler.ApplicationDiff)
+			app.GET("/resources", middleware.Authorize(rbac.ResourceApplications, rbac.VerbRead), handler.ApplicationResources)
+			app.GET("/logs", middleware.Authorize(rbac.ResourceApplications, rbac.VerbRead), handler.ApplicationLogs)
+			app.GET("/k8s-events", middleware.Authorize(rbac.ResourceApplications, rbac.VerbRead), handler.ApplicationKubeEvents)
+			app.GET("/history", middleware.Authorize(rbac.ResourceApplications, rbac.VerbRead), handler.ApplicationHistory)
+			app.POST("/rollback", middleware.Authorize(rbac.ResourceApplications, rbac.VerbUpdate), handler.ApplicationRollback)
+			app.POST("/promote", middleware.Authorize(rbac.ResourceApplications, rbac.VerbUpdate), handler.ApplicationPromote)
+			app.DELETE("/promote", middleware.Authorize(rbac.ResourceApplications, rbac.VerbUpdate), handler.ApplicationUnpin)
+			app.DELETE("", middleware.Authorize(rbac.ResourceApplications, rbac.VerbDelete), handler.ApplicationDelete)
 		}
 	}
 
+	// changes of applications that wait for the approvals required by the environment they target
+	// the approvers hold the approver role of the change request, checked by the decision handlers
+	changeRequests := v1.Group("/changerequests")
+	{
+		changeRequests.GET("", middleware.Authorize(rbac.ResourceApplications, rbac.VerbRead), handler.ChangeRequestList)
+		changeRequests.GET("/:id", middleware.Authorize(rbac.ResourceApplications, rbac.VerbRead), handler.ChangeRequestGet)
+		changeRequests.POST("/:id/approve", middleware.Authorize(rbac.ResourceApplications, rbac.VerbRead), handler.ChangeRequestApprove)
+		changeRequests.POST("/:id/reject", middleware.Authorize(rbac.ResourceApplications, rbac.VerbRead), handler.ChangeRequestReject)
+	}
+
+	// freeze windows, stored in the meta repo and written as the sync windows of the tenants
+	// the mutating application calls are rejected during a freeze window, unless overridden with a reason
+	freezeWindows := v1.Group("/freezewindows")
+	{
+		freezeWindows.POST("", middleware.Authorize(rbac.ResourceFreezeWindows, rbac.VerbCreate), middleware.Idempotent(), handler.FreezeWindowCreate)
+		freezeWindows.GET("", middleware.Authorize(rbac.ResourceFreezeWindows, rbac.VerbRead), handler.FreezeWindowList)
+		freezeWindows.GET("/:name", middleware.Authorize(rbac.ResourceFreezeWindows, rbac.VerbRead), handler.FreezeWindowGet)
+		freezeWindows.PUT("/:name", middleware.Authorize(rbac.ResourceFreezeWindows, rbac.VerbUpdate), handler.FreezeWindowUpdate)
+		freezeWindows.DELETE("/:name", middleware.Authorize(rbac.ResourceFreezeWindows, rbac.VerbDelete), handler.FreezeWindowDelete)
+	}
+
 	// one tenant : one ArgoCD Project
+	// TenantDelete and TenantGet enforce the rbac policy on the tenant of the path
 	tenants := v1.Group("/tenants")
 	{
-		tenants.POST("", handler.TenantCreate)
-		tenants.GET("", handler.TenantsList)
+		tenants.POST("", middleware.Authorize(rbac.ResourceTenants, rbac.VerbCreate), middleware.Idempotent(), handler.TenantCreate)
+		tenants.GET("", middleware.Authorize(rbac.ResourceTenants, rbac.VerbRead), handler.TenantsList)
 		tenantsOne := tenants.Group("/:name")
 		{
 			tenantsOne.DELETE("", handler.TenantDelete)
@@ -230,6 +366,7 @@ func setupRouter() *gin.Engine {
 	}
 
 	// integrated with ExternalSecrets
+	// the secret store handlers enforce the rbac policy themselves
 	security := v1.Group("/security")
 	{
 		secretStore := security.Group("/externalsecrets/secretstore")
@@ -248,6 +385,100 @@ func setupRouter() *gin.Engine {
 	return r
 }
 
+// buildLimiters returns the tenant and repository limiters, both are nil and allow everything
+// when rate limiting is disabled
+func buildLimiters(cfg *config.Config) (*ratelimit.TenantLimiter, *ratelimit.RepoLimiter) {
+	if !cfg.RateLimit.Enabled {
+		return nil, nil
+	}
+
+	log.G().WithFields(log.Fields{
+		"read_per_minute":   cfg.RateLimit.ReadPerMinute,
+		"mutate_per_minute": cfg.RateLimit.MutatePerMinute,
+		"repo_concurrency":  cfg.RateLimit.RepoConcurrency,
+	}).Info("rate limiting enabled")
+
+	read := ratelimit.Budget{PerMinute: cfg.RateLimit.ReadPerMinute, Burst: cfg.RateLimit.ReadBurst}
+	mutate := ratelimit.Budget{PerMinute: cfg.RateLimit.MutatePerMinute, Burst: cfg.RateLimit.MutateBurst}
+
+	return ratelimit.NewTenantLimiter(read, mutate), ratelimit.NewRepoLimiter(cfg.RateLimit.RepoConcurrency)
+}
+
+// buildEnforcer loads the rbac policy from the policy file or the ConfigMap
+func buildEnforcer(cfg *config.Config, factory kube.Factory) (*rbac.Enforcer, error) {
+	if !cfg.RBAC.Enabled {
+		// the approvers of change requests are the holders of a role, without rbac nobody could approve
+		if len(viper.GetStringMap("approvals")) > 0 {
+			return nil, fmt.Errorf("approvals are configured but rbac is disabled, approvals require the roles of the rbac policy")
+		}
+
+		log.G().Warn("rbac is disabled, every authenticated request is allowed")
+		return rbac.NewEnforcer(nil)
+	}
+
+	var (
+		policy *rbac.Policy
+		err    error
+	)
+	if cfg.RBAC.PolicyFile != "" {
+		policy, err = rbac.LoadPolicyFile(cfg.RBAC.PolicyFile)
+	} else {
+		if cfg.RBAC.ConfigMap.Name == "" {
+			return nil, fmt.Errorf("rbac is enabled but neither a policy file nor a configmap is configured")
+		}
+
+		cs, csErr := factory.KubernetesClientSet()
+		if csErr != nil {
+			return nil, csErr
+		}
+
+		policy, err = rbac.LoadPolicyConfigMap(context.Background(), cs, cfg.RBAC.ConfigMap.Namespace, cfg.RBAC.ConfigMap.Name, cfg.RBAC.ConfigMap.Key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.G().WithFields(log.Fields{
+		"policy_file":  cfg.RBAC.PolicyFile,
+		"configmap":    cfg.RBAC.ConfigMap.Name,
+		"default_role": policy.DefaultRole,
+		"bindings":     len(policy.Bindings),
+	}).Info("rbac policy loaded")
+
+	return rbac.NewEnforcer(policy)
+}
+
+// auditOperation records the outcome of asynchronous operations, the audit middleware only sees their submission
+func auditOperation(sink audit.Sink) func(op *operation.Operation) {
+	return func(op *operation.Operation) {
+		record := &audit.Record{
+			Timestamp: time.Now().UTC(),
+			RequestID: op.RequestID,
+			Actor:     op.Actor,
+			Tenant:    op.Tenant,
+			Action:    op.Action,
+			Target:    audit.Target{Name: op.Target},
+			Operation: op.ID,
+			Outcome:   audit.OutcomeSuccess,
+			Error:     op.Error,
+			Revisions: op.Revisions,
+		}
+		if kind, _, found := strings.Cut(op.Action, "."); found {
+			record.Target.Kind = kind
+		}
+		if op.Phase != operation.PhaseSucceeded {
+			record.Outcome = audit.OutcomeFailure
+		}
+
+		if err := sink.Write(context.Background(), record); err != nil {
+			log.G().WithFields(log.Fields{
+				"operation": op.ID,
+				"error":     err,
+			}).Error("failed to write audit record")
+		}
+	}
+}
+
 // new repo writer
 func buildRepoWriter() error {
 	// 1. init meta repo
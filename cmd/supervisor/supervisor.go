@@ -24,6 +24,7 @@ func main() {
 
 	ctx := context.Background()
 	lgr := log.FromLogrus(logrus.NewEntry(logrus.New()), &log.LogrusConfig{Level: "info"})
+	log.SetDefault(lgr)
 	ctx = log.WithLogger(ctx, lgr)
 	ctx = util.ContextWithCancelOnSignals(ctx, syscall.SIGINT, syscall.SIGTERM)
 
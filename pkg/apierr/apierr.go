@@ -0,0 +1,203 @@
+// Package apierr is the error model of the API. Every error response has the same shape,
+// a stable machine code that clients branch on, a message for humans and optional details:
+//
+//	{"error": "application 'guestbook' not found", "code": "not_found", "details": {...}}
+package apierr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Code is the machine readable kind of an error, codes are part of the API and never change
+type Code string
+
+const (
+	CodeValidation      Code = "validation_failed"
+	CodeUnauthorized    Code = "unauthorized"
+	CodeForbidden       Code = "forbidden"
+	CodeNotFound        Code = "not_found"
+	CodeConflict        Code = "conflict"
+	CodeUnprocessable   Code = "unprocessable_entity"
+	CodeTooManyRequests Code = "too_many_requests"
+	CodeFrozen          Code = "deployment_frozen"
+	CodeUpstreamGit     Code = "upstream_git"
+	CodeUpstreamArgoCD  Code = "upstream_argocd"
+	CodeUnavailable     Code = "unavailable"
+	CodeNotImplemented  Code = "not_implemented"
+	CodeInternal        Code = "internal"
+)
+
+// statuses are the HTTP statuses of the codes
+var statuses = map[Code]int{
+	CodeValidation:      http.StatusBadRequest,
+	CodeUnauthorized:    http.StatusUnauthorized,
+	CodeForbidden:       http.StatusForbidden,
+	CodeNotFound:        http.StatusNotFound,
+	CodeConflict:        http.StatusConflict,
+	CodeUnprocessable:   http.StatusUnprocessableEntity,
+	CodeTooManyRequests: http.StatusTooManyRequests,
+	CodeFrozen:          http.StatusLocked,
+	CodeUpstreamGit:     http.StatusBadGateway,
+	CodeUpstreamArgoCD:  http.StatusBadGateway,
+	CodeUnavailable:     http.StatusServiceUnavailable,
+	CodeNotImplemented:  http.StatusNotImplemented,
+	CodeInternal:        http.StatusInternalServerError,
+}
+
+// Error is an API error, Err is the cause and is not part of the response
+type Error struct {
+	Code    Code
+	Status  int
+	Message string
+	Details map[string]interface{}
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors of the same code and message, so that sentinel errors can be compared
+// with errors.Is after they were copied by WithDetails or Wrap
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code && t.Message == e.Message
+}
+
+// WithDetails returns a copy of the error with the details added
+func (e *Error) WithDetails(details map[string]interface{}) *Error {
+	copied := *e
+	copied.Details = make(map[string]interface{}, len(e.Details)+len(details))
+	for k, v := range e.Details {
+		copied.Details[k] = v
+	}
+	for k, v := range details {
+		copied.Details[k] = v
+	}
+
+	return &copied
+}
+
+// Wrap returns a copy of the error caused by err
+func (e *Error) Wrap(err error) *Error {
+	copied := *e
+	copied.Err = err
+	return &copied
+}
+
+// New returns an error of code with a formatted message
+func New(code Code, format string, args ...interface{}) *Error {
+	status, ok := statuses[code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	return &Error{
+		Code:    code,
+		Status:  status,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func Validation(format string, args ...interface{}) *Error {
+	return New(CodeValidation, format, args...)
+}
+
+func Unauthorized(format string, args ...interface{}) *Error {
+	return New(CodeUnauthorized, format, args...)
+}
+
+func Forbidden(format string, args ...interface{}) *Error {
+	return New(CodeForbidden, format, args...)
+}
+
+func NotFound(format string, args ...interface{}) *Error {
+	return New(CodeNotFound, format, args...)
+}
+
+func Conflict(format string, args ...interface{}) *Error {
+	return New(CodeConflict, format, args...)
+}
+
+func Unprocessable(format string, args ...interface{}) *Error {
+	return New(CodeUnprocessable, format, args...)
+}
+
+func TooManyRequests(format string, args ...interface{}) *Error {
+	return New(CodeTooManyRequests, format, args...)
+}
+
+// Frozen is a change rejected by an active freeze window
+func Frozen(format string, args ...interface{}) *Error {
+	return New(CodeFrozen, format, args...)
+}
+
+func Unavailable(format string, args ...interface{}) *Error {
+	return New(CodeUnavailable, format, args...)
+}
+
+func NotImplemented(format string, args ...interface{}) *Error {
+	return New(CodeNotImplemented, format, args...)
+}
+
+// UpstreamGit is a failure of the git provider, err is the cause
+func UpstreamGit(err error, format string, args ...interface{}) *Error {
+	return New(CodeUpstreamGit, format, args...).Wrap(err)
+}
+
+// UpstreamArgoCD is a failure of the ArgoCD API, err is the cause
+func UpstreamArgoCD(err error, format string, args ...interface{}) *Error {
+	return New(CodeUpstreamArgoCD, format, args...).Wrap(err)
+}
+
+// Internal is an unexpected failure, err is the cause
+func Internal(err error, format string, args ...interface{}) *Error {
+	return New(CodeInternal, format, args...).Wrap(err)
+}
+
+// From returns the API error in the chain of err, errors that carry no API error are internal
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	return New(CodeInternal, "%s", err.Error())
+}
+
+// Ensure returns err when it carries an API error, otherwise an error of code with the message of err
+func Ensure(err error, code Code) error {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return err
+	}
+
+	return New(code, "%s", err.Error())
+}
+
+// CodeOf returns the code of the API error in the chain of err
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+
+	return From(err).Code
+}
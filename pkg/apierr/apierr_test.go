@@ -0,0 +1,101 @@
+package apierr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseOf(t *testing.T) {
+	tests := map[string]struct {
+		err        error
+		wantStatus int
+		wantBody   *Response
+	}{
+		"API error": {
+			err:        NotFound("application '%s' not found", "app1"),
+			wantStatus: http.StatusNotFound,
+			wantBody:   &Response{Error: "application 'app1' not found", Code: CodeNotFound},
+		},
+		"wrapped API error keeps the outer message": {
+			err:        fmt.Errorf("failed to create application: %w", UpstreamGit(errors.New("connection reset"), "failed to push to repository")),
+			wantStatus: http.StatusBadGateway,
+			wantBody:   &Response{Error: "failed to create application: failed to push to repository: connection reset", Code: CodeUpstreamGit},
+		},
+		"details": {
+			err:        Forbidden("denied").WithDetails(map[string]interface{}{"verb": "create"}),
+			wantStatus: http.StatusForbidden,
+			wantBody:   &Response{Error: "denied", Code: CodeForbidden, Details: map[string]interface{}{"verb": "create"}},
+		},
+		"plain error is internal": {
+			err:        errors.New("boom"),
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   &Response{Error: "boom", Code: CodeInternal},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			status, body := ResponseOf(tt.err)
+			assert.Equal(t, tt.wantStatus, status)
+			assert.Equal(t, tt.wantBody, body)
+		})
+	}
+}
+
+func TestEnsure(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		code     Code
+		wantCode Code
+		wantMsg  string
+	}{
+		"keeps the code of an API error": {
+			err:      fmt.Errorf("failed: %w", Conflict("already exists")),
+			code:     CodeValidation,
+			wantCode: CodeConflict,
+			wantMsg:  "failed: already exists",
+		},
+		"plain error gets the code": {
+			err:      errors.New("bad source"),
+			code:     CodeValidation,
+			wantCode: CodeValidation,
+			wantMsg:  "bad source",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := Ensure(tt.err, tt.code)
+			assert.Equal(t, tt.wantCode, CodeOf(err))
+			assert.EqualError(t, err, tt.wantMsg)
+		})
+	}
+}
+
+func TestError_Is(t *testing.T) {
+	sentinel := Conflict("application already installed")
+	err := fmt.Errorf("failed to create application: %w", sentinel.WithDetails(map[string]interface{}{"app": "app1"}))
+
+	assert.True(t, errors.Is(err, sentinel))
+	assert.False(t, errors.Is(err, Conflict("project already exists")))
+	assert.False(t, errors.Is(err, NotFound("application already installed")))
+}
+
+func TestAbort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	Abort(c, Unauthorized("missing token"))
+
+	assert.True(t, c.IsAborted())
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	body := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, map[string]interface{}{"error": "missing token", "code": "unauthorized"}, body)
+}
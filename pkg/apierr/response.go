@@ -0,0 +1,33 @@
+package apierr
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Response is the body of every error response
+type Response struct {
+	Error   string                 `json:"error"`
+	Code    Code                   `json:"code"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// ResponseOf returns the response body of err. The message of the outermost error is used,
+// so wrapping an API error with fmt.Errorf("...: %w") adds context to the message
+func ResponseOf(err error) (int, *Response) {
+	apiErr := From(err)
+	return apiErr.Status, &Response{
+		Error:   err.Error(),
+		Code:    apiErr.Code,
+		Details: apiErr.Details,
+	}
+}
+
+// Write writes the error response of err
+func Write(c *gin.Context, err error) {
+	c.JSON(ResponseOf(err))
+}
+
+// Abort writes the error response of err and stops the handler chain
+func Abort(c *gin.Context, err error) {
+	c.AbortWithStatusJSON(ResponseOf(err))
+}
@@ -8,6 +8,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 
 	"github.com/squidflow/service/pkg/fs"
+	"github.com/squidflow/service/pkg/kube"
 	reporeader "github.com/squidflow/service/pkg/source"
 	"github.com/squidflow/service/pkg/store"
 )
@@ -41,6 +42,7 @@ type (
 		SrcTargetRevision string            `json:"srcTargetRevision"`
 		Labels            map[string]string `json:"labels"`
 		Annotations       map[string]string `json:"annotations"`
+		DestClusterName   string            `json:"destClusterName,omitempty"`
 	}
 
 	ClusterResConfig struct {
@@ -118,6 +120,21 @@ func createNamespaceManifest(repofs fs.FS, clusterName string, namespace *v1.Nam
 	return nil
 }
 
+// CreateNamespaceManifest writes the manifest of namespace into the cluster resources of the
+// cluster that serves destServer, the cluster must be configured already
+func CreateNamespaceManifest(repofs fs.FS, destServer, namespace string) error {
+	if namespace == "" || namespace == "default" {
+		return nil
+	}
+
+	clusterName, err := getClusterName(repofs, destServer)
+	if err != nil {
+		return err
+	}
+
+	return createNamespaceManifest(repofs, clusterName, kube.GenerateNamespace(namespace, nil))
+}
+
 var getAppRepo = func(repofs fs.FS, appName string) (string, error) {
 	overlays, err := billyUtils.Glob(repofs, repofs.Join(store.Default.AppsDir, appName, store.Default.OverlaysDir, "**", "config.json"))
 	if err != nil {
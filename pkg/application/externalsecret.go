@@ -0,0 +1,186 @@
+package application
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/fs"
+	"github.com/squidflow/service/pkg/store"
+	"github.com/squidflow/service/pkg/types"
+	"github.com/squidflow/service/pkg/util"
+)
+
+const (
+	// ExternalSecretsFile is the file of the external secrets in the overlay of a target of the app
+	ExternalSecretsFile = "externalsecrets.yaml"
+
+	// AnnotationKeySecretStoreID is the id of the secret store that an external secret is filled from
+	AnnotationKeySecretStoreID = "squidflow.github.io/secret-store-id"
+
+	// DefaultRefreshInterval is the interval that the secrets are read again from the secret store
+	DefaultRefreshInterval = time.Hour
+)
+
+// GenerateExternalSecrets returns an ExternalSecret for each secret of the config, filled from the keys of the secret store
+func GenerateExternalSecrets(appName, tenant string, ss *esv1beta1.SecretStore, conf *types.ExternalSecretConfig) ([]*esv1beta1.ExternalSecret, error) {
+	interval := DefaultRefreshInterval
+	if conf.RefreshInterval != "" {
+		var err error
+		if interval, err = time.ParseDuration(conf.RefreshInterval); err != nil {
+			return nil, apierr.Validation("invalid refresh interval '%s': %v", conf.RefreshInterval, err)
+		}
+	}
+
+	secrets := make([]*esv1beta1.ExternalSecret, 0, len(conf.Secrets))
+	for _, secret := range conf.Secrets {
+		es := &esv1beta1.ExternalSecret{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: esv1beta1.SchemeGroupVersion.String(),
+				Kind:       esv1beta1.ExtSecretKind,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: secret.Name,
+				Labels: map[string]string{
+					store.Default.LabelKeyAppName: appName,
+					store.Default.LabelKeyTenant:  tenant,
+				},
+				Annotations: map[string]string{
+					AnnotationKeySecretStoreID: conf.SecretStoreRef.ID,
+				},
+			},
+			Spec: esv1beta1.ExternalSecretSpec{
+				SecretStoreRef: esv1beta1.SecretStoreRef{
+					Name: ss.Name,
+					Kind: esv1beta1.SecretStoreKind,
+				},
+				Target: esv1beta1.ExternalSecretTarget{
+					Name:           secret.Name,
+					CreationPolicy: esv1beta1.CreatePolicyOwner,
+				},
+				RefreshInterval: &metav1.Duration{Duration: interval},
+			},
+		}
+
+		for _, data := range secret.Data {
+			es.Spec.Data = append(es.Spec.Data, esv1beta1.ExternalSecretData{
+				SecretKey: data.SecretKey,
+				RemoteRef: esv1beta1.ExternalSecretDataRemoteRef{
+					Key:      data.RemoteKey,
+					Property: data.Property,
+				},
+			})
+		}
+
+		secrets = append(secrets, es)
+	}
+
+	return secrets, nil
+}
+
+// ExternalSecretConfigOf returns the external secret config of the app from its generated external secrets
+func ExternalSecretConfigOf(secrets []esv1beta1.ExternalSecret) types.ExternalSecretConfig {
+	conf := types.ExternalSecretConfig{}
+	for _, es := range secrets {
+		conf.SecretStoreRef.ID = es.Annotations[AnnotationKeySecretStoreID]
+		if es.Spec.RefreshInterval != nil {
+			conf.RefreshInterval = es.Spec.RefreshInterval.Duration.String()
+		}
+
+		secret := types.ExternalSecretTemplate{Name: es.Spec.Target.Name}
+		for _, data := range es.Spec.Data {
+			secret.Data = append(secret.Data, types.ExternalSecretKeyData{
+				SecretKey: data.SecretKey,
+				RemoteKey: data.RemoteRef.Key,
+				Property:  data.RemoteRef.Property,
+			})
+		}
+		conf.Secrets = append(conf.Secrets, secret)
+	}
+
+	return conf
+}
+
+// ReadExternalSecrets returns the external secrets of the overlay
+func ReadExternalSecrets(appsfs fs.FS, overlayPath string) ([]esv1beta1.ExternalSecret, error) {
+	secretsPath := appsfs.Join(overlayPath, ExternalSecretsFile)
+	if !appsfs.ExistsOrDie(secretsPath) {
+		return nil, nil
+	}
+
+	data, err := appsfs.ReadFile(secretsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read app external secrets: %w", err)
+	}
+
+	secrets := []esv1beta1.ExternalSecret{}
+	for _, manifest := range util.SplitManifests(data) {
+		if strings.TrimSpace(string(manifest)) == "" {
+			continue
+		}
+
+		es := esv1beta1.ExternalSecret{}
+		if err = yaml.Unmarshal(manifest, &es); err != nil {
+			return nil, fmt.Errorf("failed to read app external secrets: %w", err)
+		}
+		secrets = append(secrets, es)
+	}
+
+	return secrets, nil
+}
+
+// WriteTargetExternalSecrets writes the external secrets of the app to the overlay of every target of config.json.
+// Every target must be able to use the secret store, see checkSecretStoreTarget.
+// Without external secrets, the external secrets of every target are removed
+func WriteTargetExternalSecrets(configfs fs.FS, configPath string, appsfs fs.FS, overlayPath string, ss *esv1beta1.SecretStore, secrets []*esv1beta1.ExternalSecret) error {
+	confs, paths, err := TargetOverlays(configfs, configPath, appsfs, overlayPath)
+	if err != nil {
+		return err
+	}
+
+	objs := make([]interface{}, 0, len(secrets))
+	for _, es := range secrets {
+		objs = append(objs, es)
+	}
+
+	for i := range confs {
+		if len(objs) > 0 {
+			if err := checkSecretStoreTarget(ss, &confs[i]); err != nil {
+				return err
+			}
+		}
+
+		if err := writeOverlayResource(appsfs, paths[i], ExternalSecretsFile, objs...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkSecretStoreTarget returns a validation error when the target cannot use the secret store. The secret store is
+// written to the cluster resources of the in-cluster only, and it is namespaced, the target must be deployed to the
+// namespace of the secret store when it has one. A target without namespace is deployed to the default namespace
+func checkSecretStoreTarget(ss *esv1beta1.SecretStore, conf *Config) error {
+	cluster := TargetClusterName(conf)
+	if cluster != store.Default.ClusterContextName {
+		return apierr.Validation("secret store '%s' is on cluster '%s', the target on cluster '%s' cannot use it",
+			ss.Name, store.Default.ClusterContextName, cluster)
+	}
+
+	namespace := conf.DestNamespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	if ss.Namespace != "" && namespace != ss.Namespace {
+		return apierr.Validation("secret store '%s' is in namespace '%s', the target on cluster '%s' is deployed to namespace '%s'",
+			ss.Name, ss.Namespace, cluster, namespace)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package application
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/fs"
+	"github.com/squidflow/service/pkg/store"
+	"github.com/squidflow/service/pkg/types"
+)
+
+func TestGenerateExternalSecrets(t *testing.T) {
+	ss := &esv1beta1.SecretStore{ObjectMeta: metav1.ObjectMeta{Name: "vault"}}
+	conf := &types.ExternalSecretConfig{
+		SecretStoreRef: types.SecretStoreRefConfig{ID: "ss1"},
+		Secrets: []types.ExternalSecretTemplate{{
+			Name: "db",
+			Data: []types.ExternalSecretKeyData{
+				{SecretKey: "username", RemoteKey: "app/db", Property: "username"},
+				{SecretKey: "password", RemoteKey: "app/db-password"},
+			},
+		}},
+	}
+
+	secrets, err := GenerateExternalSecrets("app", "tenant", ss, conf)
+	assert.NoError(t, err)
+	assert.Len(t, secrets, 1)
+
+	es := secrets[0]
+	assert.Equal(t, "db", es.Name)
+	assert.Equal(t, "ss1", es.Annotations[AnnotationKeySecretStoreID])
+	assert.Equal(t, "tenant", es.Labels[store.Default.LabelKeyTenant])
+	assert.Equal(t, esv1beta1.SecretStoreRef{Name: "vault", Kind: esv1beta1.SecretStoreKind}, es.Spec.SecretStoreRef)
+	assert.Equal(t, "db", es.Spec.Target.Name)
+	assert.Equal(t, DefaultRefreshInterval, es.Spec.RefreshInterval.Duration)
+	assert.Equal(t, []esv1beta1.ExternalSecretData{
+		{SecretKey: "username", RemoteRef: esv1beta1.ExternalSecretDataRemoteRef{Key: "app/db", Property: "username"}},
+		{SecretKey: "password", RemoteRef: esv1beta1.ExternalSecretDataRemoteRef{Key: "app/db-password"}},
+	}, es.Spec.Data)
+
+	conf.RefreshInterval = "often"
+	_, err = GenerateExternalSecrets("app", "tenant", ss, conf)
+	assert.Equal(t, apierr.CodeValidation, apierr.CodeOf(err))
+}
+
+func TestWriteTargetExternalSecrets(t *testing.T) {
+	overlayPath := filepath.Join(store.Default.AppsDir, "app", store.Default.OverlaysDir, "project")
+	configPath := filepath.Join(overlayPath, "config.json")
+	targetPath := filepath.Join(overlayPath, TargetsDir, "prod")
+	conf := types.ExternalSecretConfig{
+		SecretStoreRef:  types.SecretStoreRefConfig{ID: "ss1"},
+		RefreshInterval: (30 * time.Minute).String(),
+		Secrets: []types.ExternalSecretTemplate{
+			{Name: "db", Data: []types.ExternalSecretKeyData{{SecretKey: "password", RemoteKey: "app/db", Property: "password"}}},
+			{Name: "api", Data: []types.ExternalSecretKeyData{{SecretKey: "token", RemoteKey: "app/api"}}},
+		},
+	}
+
+	prepareFS := func(confs ...Config) fs.FS {
+		repofs := fs.Create(memfs.New())
+		_ = repofs.WriteJson(configPath, confs)
+		_ = repofs.WriteYamls(filepath.Join(overlayPath, "kustomization.yaml"), &kusttypes.Kustomization{Resources: []string{"../../base"}})
+		_ = repofs.WriteYamls(filepath.Join(targetPath, "kustomization.yaml"), &kusttypes.Kustomization{Resources: []string{"../../../../base"}})
+		return repofs
+	}
+	inCluster := Config{AppName: "app", DestServer: store.Default.DestServer, DestNamespace: "app"}
+	prod := Config{AppName: "app", DestServer: "https://prod:6443", DestClusterName: "prod", DestNamespace: "app"}
+
+	t.Run("Should write the external secrets to the overlay of the target", func(t *testing.T) {
+		repofs := prepareFS(inCluster)
+		ss := &esv1beta1.SecretStore{ObjectMeta: metav1.ObjectMeta{Name: "vault", Namespace: "app"}}
+		secrets, err := GenerateExternalSecrets("app", "project", ss, &conf)
+		assert.NoError(t, err)
+		assert.NoError(t, WriteTargetExternalSecrets(repofs, configPath, repofs, overlayPath, ss, secrets))
+
+		overlay := &kusttypes.Kustomization{}
+		assert.NoError(t, repofs.ReadYamls(filepath.Join(overlayPath, "kustomization.yaml"), overlay))
+		assert.Contains(t, overlay.Resources, ExternalSecretsFile)
+
+		written, err := ReadExternalSecrets(repofs, overlayPath)
+		assert.NoError(t, err)
+		assert.Equal(t, conf, ExternalSecretConfigOf(written))
+
+		assert.NoError(t, WriteTargetExternalSecrets(repofs, configPath, repofs, overlayPath, nil, nil))
+		assert.False(t, repofs.ExistsOrDie(filepath.Join(overlayPath, ExternalSecretsFile)))
+	})
+
+	t.Run("Should remove the external secrets of every target", func(t *testing.T) {
+		repofs := prepareFS(inCluster, prod)
+		for _, path := range []string{overlayPath, targetPath} {
+			_ = repofs.WriteYamls(filepath.Join(path, "kustomization.yaml"), &kusttypes.Kustomization{Resources: []string{ExternalSecretsFile}})
+			_ = repofs.WriteYamls(filepath.Join(path, ExternalSecretsFile), &esv1beta1.ExternalSecret{})
+		}
+
+		assert.NoError(t, WriteTargetExternalSecrets(repofs, configPath, repofs, overlayPath, nil, nil))
+		for _, path := range []string{overlayPath, targetPath} {
+			assert.False(t, repofs.ExistsOrDie(filepath.Join(path, ExternalSecretsFile)))
+		}
+	})
+
+	tests := map[string]struct {
+		confs     []Config
+		namespace string
+		wantErr   string
+	}{
+		"Should fail when a target is not on the cluster of the secret store": {
+			confs:     []Config{inCluster, prod},
+			namespace: "app",
+			wantErr:   "secret store 'vault' is on cluster 'in-cluster', the target on cluster 'prod' cannot use it",
+		},
+		"Should fail when a target is not in the namespace of the secret store": {
+			confs:     []Config{inCluster},
+			namespace: "vault",
+			wantErr:   "secret store 'vault' is in namespace 'vault', the target on cluster 'in-cluster' is deployed to namespace 'app'",
+		},
+		"Should fail when a target without namespace is not in the namespace of the secret store": {
+			confs:     []Config{{AppName: "app", DestServer: store.Default.DestServer}},
+			namespace: "app",
+			wantErr:   "secret store 'vault' is in namespace 'app', the target on cluster 'in-cluster' is deployed to namespace 'default'",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			repofs := prepareFS(tt.confs...)
+			ss := &esv1beta1.SecretStore{ObjectMeta: metav1.ObjectMeta{Name: "vault", Namespace: tt.namespace}}
+			secrets, err := GenerateExternalSecrets("app", "project", ss, &conf)
+			assert.NoError(t, err)
+
+			err = WriteTargetExternalSecrets(repofs, configPath, repofs, overlayPath, ss, secrets)
+			assert.EqualError(t, err, tt.wantErr)
+			assert.Equal(t, apierr.CodeValidation, apierr.CodeOf(err))
+		})
+	}
+}
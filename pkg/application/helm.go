@@ -7,8 +7,6 @@ import (
 	v1 "k8s.io/api/core/v1"
 	kusttypes "sigs.k8s.io/kustomize/api/types"
 
-	"github.com/spf13/viper"
-
 	"github.com/squidflow/service/pkg/fs"
 	"github.com/squidflow/service/pkg/git"
 	"github.com/squidflow/service/pkg/log"
@@ -67,6 +65,7 @@ func newHelmApp(o *CreateOptions, projectName, repoURL, targetRevision, repoRoot
 		return nil, fmt.Errorf("helm app failed to get repository cache")
 	}
 
+	// the writer renders the manifests again with the env of the cluster of each target
 	manifests, err := dryrun.GenerateHelmManifest(appfs, appPath, "/", "default", o.DestNamespace, o.AppName)
 	if err != nil {
 		log.G().WithFields(log.Fields{
@@ -167,20 +166,9 @@ func CreateHelmApp(app *helmApp, repofs fs.FS, appsfs fs.FS, projectName string)
 		}
 	}
 
-	configPath := repofs.Join(overlayPath, "config.json")
-	if repofs != appsfs {
-		configPath = repofs.Join(appPath, projectName, "config.json")
-	}
-
-	if viper.GetString("gitops.mode") == "pull_request" {
-		if err := appsfs.WriteJson(configPath, app.config); err != nil {
-			return fmt.Errorf("appsfs failed to write app config.json: %w", err)
-		}
-		return nil
-	}
-
-	if err := repofs.WriteJson(configPath, app.config); err != nil {
-		return fmt.Errorf("repofs failed to write app config.json: %w", err)
+	configfs, configPath := ConfigPath(repofs, appsfs, app.Name(), projectName)
+	if err := configfs.WriteJson(configPath, app.config); err != nil {
+		return fmt.Errorf("failed to write app config.json: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,189 @@
+package application
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/squidflow/service/pkg/diff"
+	"github.com/squidflow/service/pkg/fs"
+	"github.com/squidflow/service/pkg/store"
+	"github.com/squidflow/service/pkg/types"
+)
+
+// IngressFile is the file of the ingress in the overlay of a target of the app
+const IngressFile = "ingress.yaml"
+
+// FindService returns the backend of the Service of the app in its rendered manifests, the Service named
+// after the app when there are several. The backend uses the first port of the Service
+func FindService(manifests []byte, appName string) (*networkingv1.IngressServiceBackend, error) {
+	objs, err := diff.ParseManifests(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	services := []corev1.Service{}
+	for _, obj := range objs {
+		if obj.GetAPIVersion() != "v1" || obj.GetKind() != "Service" {
+			continue
+		}
+
+		svc := corev1.Service{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &svc); err != nil {
+			return nil, fmt.Errorf("invalid Service '%s': %w", obj.GetName(), err)
+		}
+		services = append(services, svc)
+	}
+
+	if len(services) == 0 {
+		return nil, ErrNoService
+	}
+
+	svc := services[0]
+	if len(services) > 1 {
+		i := slices.IndexFunc(services, func(s corev1.Service) bool { return s.Name == appName })
+		if i < 0 {
+			return nil, fmt.Errorf("application has %d Services and none is named '%s', cannot choose the Service of the ingress", len(services), appName)
+		}
+		svc = services[i]
+	}
+
+	if len(svc.Spec.Ports) == 0 {
+		return nil, fmt.Errorf("Service '%s' has no port", svc.Name)
+	}
+
+	return &networkingv1.IngressServiceBackend{
+		Name: svc.Name,
+		Port: networkingv1.ServiceBackendPort{Number: svc.Spec.Ports[0].Port},
+	}, nil
+}
+
+// TLSSecretName returns the secret of the certificate of the host, the secret of the config
+// or one named after the host
+func TLSSecretName(ingress *types.IngressConfig) string {
+	if ingress.TLS.SecretName != "" {
+		return ingress.TLS.SecretName
+	}
+
+	return strings.ReplaceAll(strings.TrimPrefix(ingress.Host, "*."), ".", "-") + "-tls"
+}
+
+// GenerateIngress returns the ingress of the app, that routes every host to the backend.
+// The labels identify the app of the ingress on the cluster, an empty class uses the default class of the cluster
+func GenerateIngress(appName, tenant, class string, backend *networkingv1.IngressServiceBackend, ingresses []types.IngressConfig) *networkingv1.Ingress {
+	pathType := networkingv1.PathTypePrefix
+	ingress := &networkingv1.Ingress{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: networkingv1.SchemeGroupVersion.String(),
+			Kind:       "Ingress",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: appName,
+			Labels: map[string]string{
+				store.Default.LabelKeyAppName: appName,
+				store.Default.LabelKeyTenant:  tenant,
+			},
+		},
+	}
+
+	if class != "" {
+		ingress.Spec.IngressClassName = &class
+	}
+
+	for i := range ingresses {
+		ingress.Spec.Rules = append(ingress.Spec.Rules, networkingv1.IngressRule{
+			Host: ingresses[i].Host,
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     "/",
+						PathType: &pathType,
+						Backend:  networkingv1.IngressBackend{Service: backend.DeepCopy()},
+					}},
+				},
+			},
+		})
+
+		if ingresses[i].TLS.Enabled {
+			ingress.Spec.TLS = append(ingress.Spec.TLS, networkingv1.IngressTLS{
+				Hosts:      []string{ingresses[i].Host},
+				SecretName: TLSSecretName(&ingresses[i]),
+			})
+		}
+	}
+
+	return ingress
+}
+
+// IngressConfigs returns the ingress config of the app from its generated ingress
+func IngressConfigs(ingress *networkingv1.Ingress) []types.IngressConfig {
+	secrets := make(map[string]string, len(ingress.Spec.TLS))
+	for _, tls := range ingress.Spec.TLS {
+		for _, host := range tls.Hosts {
+			secrets[host] = tls.SecretName
+		}
+	}
+
+	configs := make([]types.IngressConfig, 0, len(ingress.Spec.Rules))
+	for _, rule := range ingress.Spec.Rules {
+		conf := types.IngressConfig{Host: rule.Host}
+		if secret, ok := secrets[rule.Host]; ok {
+			conf.TLS = types.TLSConfig{Enabled: true, SecretName: secret}
+		}
+		configs = append(configs, conf)
+	}
+
+	return configs
+}
+
+// ReadIngress returns the ingress of the overlay, nil when the overlay has no ingress
+func ReadIngress(appsfs fs.FS, overlayPath string) (*networkingv1.Ingress, error) {
+	ingressPath := appsfs.Join(overlayPath, IngressFile)
+	if !appsfs.ExistsOrDie(ingressPath) {
+		return nil, nil
+	}
+
+	ingress := &networkingv1.Ingress{}
+	if err := appsfs.ReadYamls(ingressPath, ingress); err != nil {
+		return nil, fmt.Errorf("failed to read app ingress: %w", err)
+	}
+
+	return ingress, nil
+}
+
+// WriteIngress writes the ingress next to the kustomization of the overlay, and adds it to the resources
+// of the overlay. A nil ingress removes the ingress of the overlay
+func WriteIngress(appsfs fs.FS, overlayPath string, ingress *networkingv1.Ingress) error {
+	if ingress == nil {
+		return writeOverlayResource(appsfs, overlayPath, IngressFile)
+	}
+
+	return writeOverlayResource(appsfs, overlayPath, IngressFile, ingress)
+}
+
+// WriteTargetIngresses writes the ingress of the app to the overlay of every target of config.json, with the
+// ingress class of the cluster of the target. Without ingresses, the ingress of every target is removed
+func WriteTargetIngresses(configfs fs.FS, configPath string, appsfs fs.FS, overlayPath, appName, tenant string, backend *networkingv1.IngressServiceBackend, classes map[string]string, ingresses []types.IngressConfig) error {
+	confs, paths, err := TargetOverlays(configfs, configPath, appsfs, overlayPath)
+	if err != nil {
+		return err
+	}
+
+	for i := range confs {
+		var ingress *networkingv1.Ingress
+		if len(ingresses) > 0 {
+			ingress = GenerateIngress(appName, tenant, classes[TargetClusterName(&confs[i])], backend, ingresses)
+		}
+
+		if err := WriteIngress(appsfs, paths[i], ingress); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
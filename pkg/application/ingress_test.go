@@ -0,0 +1,159 @@
+package application
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
+
+	"github.com/squidflow/service/pkg/fs"
+	"github.com/squidflow/service/pkg/store"
+	"github.com/squidflow/service/pkg/types"
+)
+
+func TestFindService(t *testing.T) {
+	tests := map[string]struct {
+		manifests string
+		want      *networkingv1.IngressServiceBackend
+		wantErr   string
+	}{
+		"Should return the only Service": {
+			manifests: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web-svc
+spec:
+  ports:
+  - port: 8080
+    targetPort: http
+`,
+			want: &networkingv1.IngressServiceBackend{Name: "web-svc", Port: networkingv1.ServiceBackendPort{Number: 8080}},
+		},
+		"Should return the Service named after the app": {
+			manifests: `apiVersion: v1
+kind: Service
+metadata:
+  name: redis
+spec:
+  ports:
+  - port: 6379
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+spec:
+  ports:
+  - port: 80
+`,
+			want: &networkingv1.IngressServiceBackend{Name: "app", Port: networkingv1.ServiceBackendPort{Number: 80}},
+		},
+		"Should fail when no Service is named after the app": {
+			manifests: `apiVersion: v1
+kind: Service
+metadata:
+  name: redis
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`,
+			wantErr: "application has 2 Services and none is named 'app', cannot choose the Service of the ingress",
+		},
+		"Should fail without Service": {
+			manifests: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+`,
+			wantErr: ErrNoService.Error(),
+		},
+		"Should fail when the Service has no port": {
+			manifests: `apiVersion: v1
+kind: Service
+metadata:
+  name: app
+`,
+			wantErr: "Service 'app' has no port",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := FindService([]byte(tt.manifests), "app")
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWriteTargetIngresses(t *testing.T) {
+	overlayPath := filepath.Join(store.Default.AppsDir, "app", store.Default.OverlaysDir, "project")
+	configPath := filepath.Join(overlayPath, "config.json")
+	targetPath := filepath.Join(overlayPath, TargetsDir, "prod")
+	backend := &networkingv1.IngressServiceBackend{Name: "app", Port: networkingv1.ServiceBackendPort{Number: 80}}
+	ingresses := []types.IngressConfig{
+		{Host: "app.example.com", TLS: types.TLSConfig{Enabled: true}},
+		{Host: "app.internal.example.com"},
+	}
+
+	prepareFS := func() fs.FS {
+		repofs := fs.Create(memfs.New())
+		_ = repofs.WriteJson(configPath, []Config{
+			{AppName: "app", DestClusterName: "in-cluster"},
+			{AppName: "app", DestClusterName: "prod"},
+		})
+		_ = repofs.WriteYamls(filepath.Join(overlayPath, "kustomization.yaml"), &kusttypes.Kustomization{Resources: []string{"../../base"}})
+		_ = repofs.WriteYamls(filepath.Join(targetPath, "kustomization.yaml"), &kusttypes.Kustomization{Resources: []string{"../../../../base"}})
+		return repofs
+	}
+
+	t.Run("Should write the ingress of every target with the class of its cluster", func(t *testing.T) {
+		repofs := prepareFS()
+		classes := map[string]string{"in-cluster": "nginx", "prod": "alb"}
+		assert.NoError(t, WriteTargetIngresses(repofs, configPath, repofs, overlayPath, "app", "project", backend, classes, ingresses))
+
+		for path, class := range map[string]string{overlayPath: "nginx", targetPath: "alb"} {
+			overlay := &kusttypes.Kustomization{}
+			assert.NoError(t, repofs.ReadYamls(filepath.Join(path, "kustomization.yaml"), overlay))
+			assert.Contains(t, overlay.Resources, IngressFile)
+
+			ingress, err := ReadIngress(repofs, path)
+			assert.NoError(t, err)
+			assert.Equal(t, class, *ingress.Spec.IngressClassName)
+			assert.Equal(t, "app", ingress.Labels[store.Default.LabelKeyAppName])
+			assert.Equal(t, "project", ingress.Labels[store.Default.LabelKeyTenant])
+			assert.Equal(t, backend, ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service)
+			assert.Equal(t, []networkingv1.IngressTLS{{Hosts: []string{"app.example.com"}, SecretName: "app-example-com-tls"}}, ingress.Spec.TLS)
+			assert.Equal(t, []types.IngressConfig{
+				{Host: "app.example.com", TLS: types.TLSConfig{Enabled: true, SecretName: "app-example-com-tls"}},
+				{Host: "app.internal.example.com"},
+			}, IngressConfigs(ingress))
+		}
+	})
+
+	t.Run("Should remove the ingress of every target without ingresses", func(t *testing.T) {
+		repofs := prepareFS()
+		assert.NoError(t, WriteTargetIngresses(repofs, configPath, repofs, overlayPath, "app", "project", backend, nil, ingresses))
+		assert.NoError(t, WriteTargetIngresses(repofs, configPath, repofs, overlayPath, "app", "project", nil, nil, []types.IngressConfig{}))
+
+		for _, path := range []string{overlayPath, targetPath} {
+			overlay := &kusttypes.Kustomization{}
+			assert.NoError(t, repofs.ReadYamls(filepath.Join(path, "kustomization.yaml"), overlay))
+			assert.NotContains(t, overlay.Resources, IngressFile)
+			assert.False(t, repofs.ExistsOrDie(filepath.Join(path, IngressFile)))
+		}
+	})
+}
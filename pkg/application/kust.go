@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"path"
 
-	"github.com/spf13/viper"
 	v1 "k8s.io/api/core/v1"
 	kusttypes "sigs.k8s.io/kustomize/api/types"
 
@@ -71,6 +70,7 @@ func newKustApp(o *CreateOptions, projectName, repoURL, targetRevision, repoRoot
 			return nil, fmt.Errorf("repo not found in cache")
 		}
 
+		// the writer renders the manifests again with the env of the cluster of each target
 		app.manifests, err = dryrun.GenerateKustomizeManifest(appfs, path, "default")
 		if err != nil {
 			return nil, err
@@ -192,20 +192,9 @@ func kustCreateFiles(app *kustApp, repofs fs.FS, appsfs fs.FS, projectName strin
 		}
 	}
 
-	configPath := repofs.Join(overlayPath, "config.json")
-	if repofs != appsfs {
-		configPath = repofs.Join(appPath, projectName, "config.json")
-	}
-
-	if viper.GetString("gitops.mode") == "pull_request" {
-		if err = appsfs.WriteJson(configPath, app.config); err != nil {
-			return fmt.Errorf("appsfs failed to write app config.json: %w", err)
-		}
-		return nil
-	}
-
-	if err = repofs.WriteJson(configPath, app.config); err != nil {
-		return fmt.Errorf("repofs failed to write app config.json: %w", err)
+	configfs, configPath := ConfigPath(repofs, appsfs, app.Name(), projectName)
+	if err = configfs.WriteJson(configPath, app.config); err != nil {
+		return fmt.Errorf("failed to write app config.json: %w", err)
 	}
 
 	return nil
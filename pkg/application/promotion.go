@@ -0,0 +1,83 @@
+package application
+
+import (
+	"strings"
+	"time"
+
+	"github.com/squidflow/service/pkg/types"
+)
+
+// the promotion of a target is recorded in the annotations of its config
+const (
+	AnnotationKeyPromotedFrom     = "squidflow.github.io/promoted-from"
+	AnnotationKeyPromotedRevision = "squidflow.github.io/promoted-revision"
+	AnnotationKeyPromotedBy       = "squidflow.github.io/promoted-by"
+	AnnotationKeyPromotedAt       = "squidflow.github.io/promoted-at"
+
+	annotationKeyPromotedPrefix = "squidflow.github.io/promoted-"
+)
+
+// Promote deploys the target of the config from the revision of the promotion, and records the promotion.
+// The target stays on the revision, later changes of the app reach it with another promotion, or once it is
+// unpinned, see Unpin
+func Promote(conf *Config, p *types.Promotion) {
+	conf.SrcTargetRevision = p.Revision
+	if conf.Annotations == nil {
+		conf.Annotations = map[string]string{}
+	}
+
+	conf.Annotations[AnnotationKeyPromotedFrom] = p.From
+	conf.Annotations[AnnotationKeyPromotedRevision] = p.Revision
+	conf.Annotations[AnnotationKeyPromotedBy] = p.PromotedBy
+	conf.Annotations[AnnotationKeyPromotedAt] = p.PromotedAt.UTC().Format(time.RFC3339)
+}
+
+// Unpin deploys the target of the config from revision again, the revision that the gitops repo tracks, and
+// removes the record of its promotion
+func Unpin(conf *Config, revision string) {
+	conf.SrcTargetRevision = revision
+	for k := range conf.Annotations {
+		if strings.HasPrefix(k, annotationKeyPromotedPrefix) {
+			delete(conf.Annotations, k)
+		}
+	}
+}
+
+// PromotionOf returns the last promotion of the target of the config, nil if the target was never promoted
+func PromotionOf(conf *Config) *types.Promotion {
+	revision, ok := conf.Annotations[AnnotationKeyPromotedRevision]
+	if !ok {
+		return nil
+	}
+
+	promotedAt, _ := time.Parse(time.RFC3339, conf.Annotations[AnnotationKeyPromotedAt])
+
+	return &types.Promotion{
+		From:       conf.Annotations[AnnotationKeyPromotedFrom],
+		Revision:   revision,
+		PromotedBy: conf.Annotations[AnnotationKeyPromotedBy],
+		PromotedAt: promotedAt,
+	}
+}
+
+// keepPromotion sets the revision and the promotion of a target from its previous config, so that rewriting
+// the targets does not undo a promotion. A new target, previous is nil, is not promoted
+func keepPromotion(conf *Config, previous *Config) {
+	annotations := make(map[string]string, len(conf.Annotations))
+	for k, v := range conf.Annotations {
+		if !strings.HasPrefix(k, annotationKeyPromotedPrefix) {
+			annotations[k] = v
+		}
+	}
+
+	if previous != nil {
+		conf.SrcTargetRevision = previous.SrcTargetRevision
+		for k, v := range previous.Annotations {
+			if strings.HasPrefix(k, annotationKeyPromotedPrefix) {
+				annotations[k] = v
+			}
+		}
+	}
+
+	conf.Annotations = annotations
+}
@@ -0,0 +1,332 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+
+	billyUtils "github.com/go-git/go-billy/v5/util"
+	"github.com/spf13/viper"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
+
+	"github.com/squidflow/service/pkg/fs"
+	"github.com/squidflow/service/pkg/kube"
+	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/store"
+	"github.com/squidflow/service/pkg/types"
+)
+
+const (
+	// TargetsDir is the directory under the project overlay of an app, that holds
+	// one overlay for each additional target of the app
+	TargetsDir = "targets"
+
+	// TargetManifestFile holds the flattened manifests of an additional target, rendered with the env of its
+	// cluster when it differs from the env of the primary target. The overlay of the target uses it instead of the base
+	TargetManifestFile = "manifest.yaml"
+
+	targetBase = "../../../../base"
+)
+
+// ConfigPath returns the filesystem and the path that config.json of the app is written to
+func ConfigPath(repofs fs.FS, appsfs fs.FS, appName, projectName string) (fs.FS, string) {
+	appPath := appsfs.Join(store.Default.AppsDir, appName)
+	configPath := repofs.Join(appPath, store.Default.OverlaysDir, projectName, "config.json")
+	if repofs != appsfs {
+		configPath = repofs.Join(appPath, projectName, "config.json")
+	}
+
+	if viper.GetString("gitops.mode") == "pull_request" {
+		return appsfs, configPath
+	}
+
+	return repofs, configPath
+}
+
+// ReadConfigs reads config.json of an app, the file holds a single config when
+// the app has one target, and a list of configs when the app has several targets
+func ReadConfigs(repofs fs.FS, configPath string) ([]Config, error) {
+	b, err := repofs.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file '%s'", configPath)
+	}
+
+	confs, err := ParseConfigs(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w in file '%s'", err, configPath)
+	}
+
+	return confs, nil
+}
+
+// ParseConfigs parses the content of config.json, see ReadConfigs
+func ParseConfigs(b []byte) ([]Config, error) {
+	confs := []Config{}
+	if err := json.Unmarshal(b, &confs); err == nil {
+		if len(confs) == 0 {
+			return nil, fmt.Errorf("no config found")
+		}
+		return confs, nil
+	}
+
+	conf := Config{}
+	if err := json.Unmarshal(b, &conf); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config")
+	}
+
+	return []Config{conf}, nil
+}
+
+// WriteConfigs writes config.json of an app, see ReadConfigs
+func WriteConfigs(repofs fs.FS, configPath string, confs []Config) error {
+	if len(confs) == 1 {
+		return repofs.WriteJson(configPath, confs[0])
+	}
+
+	return repofs.WriteJson(configPath, confs)
+}
+
+// TargetClusterName returns the name of the cluster that the config is deployed to
+func TargetClusterName(conf *Config) string {
+	if conf.DestClusterName != "" {
+		return conf.DestClusterName
+	}
+
+	if conf.DestServer == store.Default.DestServer {
+		return store.Default.ClusterContextName
+	}
+
+	return conf.DestServer
+}
+
+// WriteTargets fans the app out to all of the targets. The first target is served by the
+// overlay and the config that the app was created with, every other target gets its own
+// overlay under the project overlay, and its own entry in config.json.
+// The ApplicationSet git generator creates one application for each entry of config.json.
+func WriteTargets(configfs fs.FS, configPath string, appsfs fs.FS, overlayPath string, targets []types.ApplicationTarget) error {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	if !configfs.ExistsOrDie(configPath) {
+		if len(targets) > 1 {
+			return ErrMultipleTargetsNotSupported
+		}
+		return nil
+	}
+
+	confs, err := ReadConfigs(configfs, configPath)
+	if err != nil {
+		return err
+	}
+
+	previous := make(map[string]*Config, len(confs))
+	for i := range confs[1:] {
+		previous[TargetClusterName(&confs[i+1])] = &confs[i+1]
+	}
+
+	primary := confs[0]
+	primary.DestClusterName = targets[0].Cluster
+	if targets[0].Server != "" {
+		primary.DestServer = targets[0].Server
+	}
+	if targets[0].Namespace != "" {
+		primary.DestNamespace = targets[0].Namespace
+	}
+
+	targetsPath := appsfs.Join(overlayPath, TargetsDir)
+	manifests, err := readTargetManifests(appsfs, targetsPath)
+	if err != nil {
+		return err
+	}
+
+	if err = billyUtils.RemoveAll(appsfs, targetsPath); err != nil {
+		return fmt.Errorf("failed to clean targets directory '%s': %w", targetsPath, err)
+	}
+
+	confs = []Config{primary}
+	clusters := map[string]bool{primary.DestClusterName: true}
+	for _, target := range targets[1:] {
+		if clusters[target.Cluster] {
+			return fmt.Errorf("application is deployed to cluster '%s' more than once", target.Cluster)
+		}
+		clusters[target.Cluster] = true
+
+		if err = writeTargetOverlay(appsfs, appsfs.Join(targetsPath, target.Cluster), target.Namespace, manifests[target.Cluster]); err != nil {
+			return err
+		}
+
+		conf := primary
+		conf.UserGivenName = fmt.Sprintf("%s-%s", primary.AppName, target.Cluster)
+		conf.DestClusterName = target.Cluster
+		conf.DestServer = target.Server
+		conf.DestNamespace = target.Namespace
+		conf.SrcPath = path.Join(primary.SrcPath, TargetsDir, target.Cluster)
+		keepPromotion(&conf, previous[target.Cluster])
+		confs = append(confs, conf)
+
+		log.G().WithFields(log.Fields{
+			"app":       primary.AppName,
+			"cluster":   target.Cluster,
+			"server":    target.Server,
+			"namespace": target.Namespace,
+		}).Debug("created app target")
+	}
+
+	if err = WriteConfigs(configfs, configPath, confs); err != nil {
+		return fmt.Errorf("failed to write app config.json: %w", err)
+	}
+
+	return nil
+}
+
+// readTargetManifests returns the flattened manifests of the overlays of the additional targets, by cluster,
+// so the overlays keep them when they are written again
+func readTargetManifests(appsfs fs.FS, targetsPath string) (map[string][]byte, error) {
+	manifests := map[string][]byte{}
+	if !appsfs.ExistsOrDie(targetsPath) {
+		return manifests, nil
+	}
+
+	dirs, err := appsfs.ReadDir(targetsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read targets directory '%s': %w", targetsPath, err)
+	}
+
+	for _, dir := range dirs {
+		manifestPath := appsfs.Join(targetsPath, dir.Name(), TargetManifestFile)
+		if !dir.IsDir() || !appsfs.ExistsOrDie(manifestPath) {
+			continue
+		}
+
+		if manifests[dir.Name()], err = appsfs.ReadFile(manifestPath); err != nil {
+			return nil, fmt.Errorf("failed to read target manifests '%s': %w", manifestPath, err)
+		}
+	}
+
+	return manifests, nil
+}
+
+// writeTargetOverlay writes the overlay of an additional target, the namespace is part of the
+// overlay since the target cluster is not necessarily configured in the cluster resources.
+// With manifests, the overlay uses them instead of the base, see TargetManifestFile
+func writeTargetOverlay(appsfs fs.FS, targetPath, namespace string, manifests []byte) error {
+	overlay := &kusttypes.Kustomization{
+		TypeMeta: kusttypes.TypeMeta{
+			APIVersion: kusttypes.KustomizationVersion,
+			Kind:       kusttypes.KustomizationKind,
+		},
+		Resources: []string{targetBase},
+	}
+
+	if manifests != nil {
+		overlay.Resources = []string{TargetManifestFile}
+		if err := billyUtils.WriteFile(appsfs, appsfs.Join(targetPath, TargetManifestFile), manifests, 0666); err != nil {
+			return fmt.Errorf("failed to write target manifests: %w", err)
+		}
+	}
+
+	if namespace != "" && namespace != "default" {
+		overlay.Namespace = namespace
+		overlay.Resources = append(overlay.Resources, "namespace.yaml")
+		if err := appsfs.WriteYamls(appsfs.Join(targetPath, "namespace.yaml"), kube.GenerateNamespace(namespace, nil)); err != nil {
+			return fmt.Errorf("failed to write target namespace: %w", err)
+		}
+	}
+
+	if err := appsfs.WriteYamls(appsfs.Join(targetPath, "kustomization.yaml"), overlay); err != nil {
+		return fmt.Errorf("failed to write target overlay: %w", err)
+	}
+
+	return nil
+}
+
+// WriteTargetManifest points the overlay of an additional target at its own flattened manifests, rendered with the
+// env of its cluster. Without manifests, the overlay uses the base again
+func WriteTargetManifest(appsfs fs.FS, targetPath string, manifests []byte) error {
+	overlayKustomizationPath := appsfs.Join(targetPath, "kustomization.yaml")
+	overlay := &kusttypes.Kustomization{}
+	if err := appsfs.ReadYamls(overlayKustomizationPath, overlay); err != nil {
+		return fmt.Errorf("failed to read target overlay '%s': %w", targetPath, err)
+	}
+
+	manifestPath := appsfs.Join(targetPath, TargetManifestFile)
+	from, to := TargetManifestFile, targetBase
+	if manifests != nil {
+		from, to = targetBase, TargetManifestFile
+		if err := billyUtils.WriteFile(appsfs, manifestPath, manifests, 0666); err != nil {
+			return fmt.Errorf("failed to write target manifests: %w", err)
+		}
+	} else if appsfs.ExistsOrDie(manifestPath) {
+		if err := appsfs.Remove(manifestPath); err != nil {
+			return fmt.Errorf("failed to delete '%s': %w", manifestPath, err)
+		}
+	}
+
+	if i := slices.Index(overlay.Resources, from); i >= 0 {
+		overlay.Resources[i] = to
+	}
+
+	if err := appsfs.WriteYamls(overlayKustomizationPath, overlay); err != nil {
+		return fmt.Errorf("failed to write target overlay: %w", err)
+	}
+
+	return nil
+}
+
+// TargetOverlays returns the configs of the targets of config.json, and the path of the overlay of each of them.
+// An app without config.json only has the project overlay
+func TargetOverlays(configfs fs.FS, configPath string, appsfs fs.FS, overlayPath string) ([]Config, []string, error) {
+	confs := []Config{{}}
+	if configfs.ExistsOrDie(configPath) {
+		var err error
+		if confs, err = ReadConfigs(configfs, configPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	paths := []string{overlayPath}
+	for _, conf := range confs[1:] {
+		paths = append(paths, appsfs.Join(overlayPath, TargetsDir, conf.DestClusterName))
+	}
+
+	return confs, paths, nil
+}
+
+// writeOverlayResource writes the objects to the file next to the kustomization of the overlay, and adds the
+// file to the resources of the overlay. Without objects, the file is removed from the overlay
+func writeOverlayResource(appsfs fs.FS, overlayPath, file string, objs ...interface{}) error {
+	overlayKustomizationPath := appsfs.Join(overlayPath, "kustomization.yaml")
+	overlay := &kusttypes.Kustomization{}
+	if err := appsfs.ReadYamls(overlayKustomizationPath, overlay); err != nil {
+		return fmt.Errorf("failed to read app overlay '%s': %w", overlayPath, err)
+	}
+
+	filePath := appsfs.Join(overlayPath, file)
+	i := slices.Index(overlay.Resources, file)
+	if len(objs) == 0 {
+		if i < 0 {
+			return nil
+		}
+
+		overlay.Resources = slices.Delete(overlay.Resources, i, i+1)
+		if err := appsfs.Remove(filePath); err != nil {
+			return fmt.Errorf("failed to delete '%s': %w", filePath, err)
+		}
+	} else {
+		if i < 0 {
+			overlay.Resources = append(overlay.Resources, file)
+		}
+
+		if err := appsfs.WriteYamls(filePath, objs...); err != nil {
+			return fmt.Errorf("failed to write '%s': %w", filePath, err)
+		}
+	}
+
+	if err := appsfs.WriteYamls(overlayKustomizationPath, overlay); err != nil {
+		return fmt.Errorf("failed to write app overlay: %w", err)
+	}
+
+	return nil
+}
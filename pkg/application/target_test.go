@@ -0,0 +1,197 @@
+package application
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	billyUtils "github.com/go-git/go-billy/v5/util"
+	"github.com/stretchr/testify/assert"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
+
+	"github.com/squidflow/service/pkg/fs"
+	"github.com/squidflow/service/pkg/store"
+	"github.com/squidflow/service/pkg/types"
+)
+
+func TestWriteTargets(t *testing.T) {
+	overlayPath := filepath.Join(store.Default.AppsDir, "app", store.Default.OverlaysDir, "project")
+	configPath := filepath.Join(overlayPath, "config.json")
+	primary := Config{
+		AppName:       "app",
+		UserGivenName: "app",
+		DestNamespace: "default",
+		DestServer:    store.Default.DestServer,
+		SrcPath:       overlayPath,
+		Annotations: map[string]string{
+			"squidflow.github.io/appcode": "0001",
+		},
+	}
+
+	tests := map[string]struct {
+		targets  []types.ApplicationTarget
+		beforeFn func() fs.FS
+		wantErr  string
+		assertFn func(*testing.T, fs.FS)
+	}{
+		"Should do nothing without targets": {
+			beforeFn: func() fs.FS {
+				return fs.Create(memfs.New())
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				assert.False(t, repofs.ExistsOrDie(configPath))
+			},
+		},
+		"Should fail with several targets when the app has no config.json": {
+			targets: []types.ApplicationTarget{
+				{Cluster: "in-cluster", Namespace: "default"},
+				{Cluster: "prod", Namespace: "app"},
+			},
+			beforeFn: func() fs.FS {
+				return fs.Create(memfs.New())
+			},
+			wantErr: ErrMultipleTargetsNotSupported.Error(),
+		},
+		"Should keep a single config for a single target": {
+			targets: []types.ApplicationTarget{
+				{Cluster: "in-cluster", Namespace: "app", Server: store.Default.DestServer},
+			},
+			beforeFn: func() fs.FS {
+				repofs := fs.Create(memfs.New())
+				_ = repofs.WriteJson(configPath, primary)
+				return repofs
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				conf := &Config{}
+				assert.NoError(t, repofs.ReadJson(configPath, conf))
+				assert.Equal(t, "in-cluster", conf.DestClusterName)
+				assert.Equal(t, "app", conf.DestNamespace)
+				assert.False(t, repofs.ExistsOrDie(filepath.Join(overlayPath, TargetsDir)))
+			},
+		},
+		"Should write an overlay and a config for each additional target": {
+			targets: []types.ApplicationTarget{
+				{Cluster: "in-cluster", Namespace: "app", Server: store.Default.DestServer},
+				{Cluster: "prod", Namespace: "app-prod", Server: "https://prod.example.com"},
+			},
+			beforeFn: func() fs.FS {
+				repofs := fs.Create(memfs.New())
+				_ = repofs.WriteJson(configPath, primary)
+				return repofs
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				confs, err := ReadConfigs(repofs, configPath)
+				assert.NoError(t, err)
+				assert.Len(t, confs, 2)
+				assert.Equal(t, "app", confs[0].UserGivenName)
+				assert.Equal(t, "app-prod", confs[1].UserGivenName)
+				assert.Equal(t, "https://prod.example.com", confs[1].DestServer)
+				assert.Equal(t, "app-prod", confs[1].DestNamespace)
+				assert.Equal(t, filepath.Join(overlayPath, TargetsDir, "prod"), confs[1].SrcPath)
+				assert.Equal(t, "0001", confs[1].Annotations["squidflow.github.io/appcode"])
+
+				overlay := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(filepath.Join(overlayPath, TargetsDir, "prod", "kustomization.yaml"), overlay))
+				assert.Equal(t, "app-prod", overlay.Namespace)
+				assert.Equal(t, []string{"../../../../base", "namespace.yaml"}, overlay.Resources)
+			},
+		},
+		"Should remove the overlays of targets that are gone": {
+			targets: []types.ApplicationTarget{
+				{Cluster: "in-cluster", Namespace: "default", Server: store.Default.DestServer},
+			},
+			beforeFn: func() fs.FS {
+				repofs := fs.Create(memfs.New())
+				second := primary
+				second.UserGivenName = "app-prod"
+				_ = repofs.WriteJson(configPath, []Config{primary, second})
+				_ = repofs.WriteYamls(filepath.Join(overlayPath, TargetsDir, "prod", "kustomization.yaml"), &kusttypes.Kustomization{})
+				return repofs
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				confs, err := ReadConfigs(repofs, configPath)
+				assert.NoError(t, err)
+				assert.Len(t, confs, 1)
+				assert.False(t, repofs.ExistsOrDie(filepath.Join(overlayPath, TargetsDir, "prod")))
+			},
+		},
+		"Should keep the promotion of the targets that remain": {
+			targets: []types.ApplicationTarget{
+				{Cluster: "in-cluster", Namespace: "default", Server: store.Default.DestServer},
+				{Cluster: "prod", Namespace: "app", Server: "https://prod.example.com"},
+				{Cluster: "uat", Namespace: "app", Server: "https://uat.example.com"},
+			},
+			beforeFn: func() fs.FS {
+				repofs := fs.Create(memfs.New())
+				promoted := primary
+				promoted.UserGivenName = "app-prod"
+				promoted.DestClusterName = "prod"
+				Promote(&promoted, &types.Promotion{From: "UAT", Revision: "3992c4a", PromotedBy: "admin"})
+				_ = repofs.WriteJson(configPath, []Config{primary, promoted})
+				return repofs
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				confs, err := ReadConfigs(repofs, configPath)
+				assert.NoError(t, err)
+				assert.Len(t, confs, 3)
+				assert.Equal(t, "3992c4a", confs[1].SrcTargetRevision)
+				assert.Equal(t, "admin", PromotionOf(&confs[1]).PromotedBy)
+				assert.Equal(t, "", confs[2].SrcTargetRevision)
+				assert.Nil(t, PromotionOf(&confs[2]))
+				assert.Equal(t, "0001", confs[2].Annotations["squidflow.github.io/appcode"])
+			},
+		},
+		"Should keep the manifests of the targets that remain": {
+			targets: []types.ApplicationTarget{
+				{Cluster: "in-cluster", Namespace: "default", Server: store.Default.DestServer},
+				{Cluster: "prod", Namespace: "app", Server: "https://prod.example.com"},
+			},
+			beforeFn: func() fs.FS {
+				repofs := fs.Create(memfs.New())
+				_ = repofs.WriteJson(configPath, primary)
+				_ = billyUtils.WriteFile(repofs, filepath.Join(overlayPath, TargetsDir, "prod", TargetManifestFile), []byte("env: prd"), 0666)
+				return repofs
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				overlay := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(filepath.Join(overlayPath, TargetsDir, "prod", "kustomization.yaml"), overlay))
+				assert.Equal(t, []string{TargetManifestFile, "namespace.yaml"}, overlay.Resources)
+
+				data, err := repofs.ReadFile(filepath.Join(overlayPath, TargetsDir, "prod", TargetManifestFile))
+				assert.NoError(t, err)
+				assert.Equal(t, "env: prd", string(data))
+
+				assert.NoError(t, WriteTargetManifest(repofs, filepath.Join(overlayPath, TargetsDir, "prod"), nil))
+				overlay = &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(filepath.Join(overlayPath, TargetsDir, "prod", "kustomization.yaml"), overlay))
+				assert.Equal(t, []string{"../../../../base", "namespace.yaml"}, overlay.Resources)
+				assert.False(t, repofs.ExistsOrDie(filepath.Join(overlayPath, TargetsDir, "prod", TargetManifestFile)))
+			},
+		},
+		"Should fail when a cluster is targeted twice": {
+			targets: []types.ApplicationTarget{
+				{Cluster: "in-cluster", Namespace: "default"},
+				{Cluster: "in-cluster", Namespace: "other"},
+			},
+			beforeFn: func() fs.FS {
+				repofs := fs.Create(memfs.New())
+				_ = repofs.WriteJson(configPath, primary)
+				return repofs
+			},
+			wantErr: "application is deployed to cluster 'in-cluster' more than once",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			repofs := tt.beforeFn()
+			if err := WriteTargets(repofs, configPath, repofs, overlayPath, tt.targets); err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			if tt.assertFn != nil {
+				tt.assertFn(t, repofs)
+			}
+		})
+	}
+}
@@ -1,9 +1,9 @@
 package application
 
 import (
-	"errors"
-
+	"github.com/squidflow/service/pkg/apierr"
 	"github.com/squidflow/service/pkg/kube"
+	"github.com/squidflow/service/pkg/types"
 )
 
 // AppCreateOptions represents options for creating an application
@@ -17,14 +17,27 @@ type AppCreateOptions struct {
 	Include         string
 	Exclude         string
 	DryRun          bool
+	// Targets are the resolved destinations of the application, AppOpts holds the first one
+	Targets []types.ApplicationTarget
+	// Ingress is routed to the Service of the application, with the ingress class of the cluster of each target
+	Ingress        []types.IngressConfig
+	IngressClasses map[string]string
+	// Security holds the external secrets of the application
+	Security types.SecurityConfig
+	// Source is the source of the application, flattened manifests are rendered from it with the env in Envs of the
+	// cluster of each target
+	Source types.ApplicationSourceRequest
+	Envs   map[string]string
 }
 
 // Errors
 var (
-	ErrEmptyAppSpecifier            = errors.New("empty app not allowed")
-	ErrEmptyAppName                 = errors.New("app name can not be empty, please specify application name")
-	ErrEmptyProjectName             = errors.New("project name can not be empty, please specificy project name with: --project")
-	ErrAppAlreadyInstalledOnProject = errors.New("application already installed on project")
-	ErrAppCollisionWithExistingBase = errors.New("an application with the same name and a different base already exists, consider choosing a different name")
-	ErrUnknownAppType               = errors.New("unknown application type")
+	ErrEmptyAppSpecifier            = apierr.Validation("empty app not allowed")
+	ErrEmptyAppName                 = apierr.Validation("app name can not be empty, please specify application name")
+	ErrEmptyProjectName             = apierr.Validation("project name can not be empty, please specificy project name with: --project")
+	ErrAppAlreadyInstalledOnProject = apierr.Conflict("application already installed on project")
+	ErrAppCollisionWithExistingBase = apierr.Conflict("an application with the same name and a different base already exists, consider choosing a different name")
+	ErrUnknownAppType               = apierr.Validation("unknown application type")
+	ErrMultipleTargetsNotSupported  = apierr.Validation("application type does not support multiple targets")
+	ErrNoService                    = apierr.Validation("application has no Service to expose with an ingress")
 )
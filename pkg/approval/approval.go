@@ -0,0 +1,112 @@
+package approval
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/rbac"
+	"github.com/squidflow/service/pkg/types"
+)
+
+// Policy is the number of approvals that the changes of an environment require, and the role
+// that approvers must hold in the tenant of the change. Platform admins can always approve
+type Policy struct {
+	Required int       `mapstructure:"required"`
+	Role     rbac.Role `mapstructure:"role"`
+}
+
+// PolicyOf returns the policy of the environment from the `approvals.<env>` config,
+// changes of an environment without a policy require no approval
+func PolicyOf(env string) Policy {
+	var p Policy
+	if env == "" {
+		return p
+	}
+
+	// viper keys are case insensitive
+	if err := viper.UnmarshalKey("approvals."+strings.ToLower(env), &p); err != nil {
+		return Policy{}
+	}
+	if p.Role == "" {
+		p.Role = rbac.RoleTenantAdmin
+	}
+
+	return p
+}
+
+// Strictest returns the environment that requires the most approvals among envs, and its policy
+func Strictest(envs ...string) (string, Policy) {
+	var (
+		strictest string
+		policy    Policy
+	)
+	for _, env := range envs {
+		if p := PolicyOf(env); p.Required > policy.Required {
+			strictest, policy = env, p
+		}
+	}
+
+	return strictest, policy
+}
+
+// Decide records the decision of the approver on the change request. The change request is rejected
+// by a single rejection, it is approved once it has the approvals required. The requester of a change
+// cannot decide on it, and every approver decides once
+func Decide(cr *types.ChangeRequest, approver, decision, comment string, at time.Time) error {
+	if cr.Status != types.ChangeRequestPending {
+		return apierr.Conflict("change request '%s' is %s", cr.ID, cr.Status)
+	}
+
+	if approver == cr.RequestedBy {
+		return apierr.Forbidden("'%s' requested change request '%s' and cannot decide on it", approver, cr.ID)
+	}
+
+	for _, d := range cr.Decisions {
+		if d.Approver == approver {
+			return apierr.Conflict("'%s' already decided to %s change request '%s'", approver, d.Decision, cr.ID)
+		}
+	}
+
+	cr.Decisions = append(cr.Decisions, types.Decision{
+		Approver: approver,
+		Decision: decision,
+		Comment:  comment,
+		At:       at.UTC(),
+	})
+
+	switch {
+	case decision == types.DecisionReject:
+		cr.Status = types.ChangeRequestRejected
+	case cr.Approvals() >= cr.Required:
+		cr.Status = types.ChangeRequestApproved
+	}
+
+	return nil
+}
+
+// Trailers returns the git trailers that record the change request and its approvals
+// on the commit or the pull request that executes it
+func Trailers(cr *types.ChangeRequest) []string {
+	trailers := []string{
+		"Change-Request: " + cr.ID,
+		"Requested-by: " + cr.RequestedBy,
+	}
+	for _, d := range cr.Decisions {
+		if d.Decision != types.DecisionApprove {
+			continue
+		}
+
+		trailer := fmt.Sprintf("Approved-by: %s (%s)", d.Approver, d.At.UTC().Format(time.RFC3339))
+		if d.Comment != "" {
+			// a trailer is a single line
+			trailer += ": " + strings.Join(strings.Fields(d.Comment), " ")
+		}
+		trailers = append(trailers, trailer)
+	}
+
+	return trailers
+}
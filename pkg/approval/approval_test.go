@@ -0,0 +1,117 @@
+package approval
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/rbac"
+	"github.com/squidflow/service/pkg/types"
+)
+
+func TestPolicyOf(t *testing.T) {
+	viper.Set("approvals.prd", map[string]interface{}{"required": 2})
+	viper.Set("approvals.uat", map[string]interface{}{"required": 1, "role": "developer"})
+	defer viper.Set("approvals", nil)
+
+	assert.Equal(t, Policy{Required: 2, Role: rbac.RoleTenantAdmin}, PolicyOf("PRD"))
+	assert.Equal(t, Policy{Required: 1, Role: rbac.RoleDeveloper}, PolicyOf("UAT"))
+	assert.Equal(t, 0, PolicyOf("DEV").Required)
+	assert.Equal(t, 0, PolicyOf("").Required)
+
+	env, policy := Strictest("DEV", "UAT", "PRD")
+	assert.Equal(t, "PRD", env)
+	assert.Equal(t, 2, policy.Required)
+
+	env, policy = Strictest("DEV", "SIT")
+	assert.Equal(t, "", env)
+	assert.Equal(t, 0, policy.Required)
+}
+
+func TestDecide(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		decisions  []types.Decision
+		approver   string
+		decision   string
+		wantStatus string
+		wantCode   apierr.Code
+	}{
+		"an approval short of the required ones": {
+			approver:   "alice",
+			decision:   types.DecisionApprove,
+			wantStatus: types.ChangeRequestPending,
+		},
+		"the last required approval": {
+			decisions:  []types.Decision{{Approver: "carol", Decision: types.DecisionApprove}},
+			approver:   "alice",
+			decision:   types.DecisionApprove,
+			wantStatus: types.ChangeRequestApproved,
+		},
+		"a rejection": {
+			decisions:  []types.Decision{{Approver: "carol", Decision: types.DecisionApprove}},
+			approver:   "alice",
+			decision:   types.DecisionReject,
+			wantStatus: types.ChangeRequestRejected,
+		},
+		"the requester": {
+			approver: "bob",
+			decision: types.DecisionApprove,
+			wantCode: apierr.CodeForbidden,
+		},
+		"an approver deciding twice": {
+			decisions: []types.Decision{{Approver: "alice", Decision: types.DecisionApprove}},
+			approver:  "alice",
+			decision:  types.DecisionApprove,
+			wantCode:  apierr.CodeConflict,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cr := &types.ChangeRequest{
+				ID:          "3f2a",
+				RequestedBy: "bob",
+				Status:      types.ChangeRequestPending,
+				Required:    2,
+				Decisions:   tt.decisions,
+			}
+
+			err := Decide(cr, tt.approver, tt.decision, "", now)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apierr.CodeOf(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, cr.Status)
+			assert.Equal(t, tt.approver, cr.Decisions[len(cr.Decisions)-1].Approver)
+		})
+	}
+
+	t.Run("a change request that is not pending", func(t *testing.T) {
+		cr := &types.ChangeRequest{ID: "3f2a", Status: types.ChangeRequestExecuted}
+		assert.Equal(t, apierr.CodeConflict, apierr.CodeOf(Decide(cr, "alice", types.DecisionApprove, "", now)))
+	})
+}
+
+func TestTrailers(t *testing.T) {
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	cr := &types.ChangeRequest{
+		ID:          "3f2a",
+		RequestedBy: "bob",
+		Decisions: []types.Decision{
+			{Approver: "alice", Decision: types.DecisionApprove, Comment: "checked the\nrollout plan", At: at},
+			{Approver: "carol", Decision: types.DecisionApprove, At: at},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"Change-Request: 3f2a",
+		"Requested-by: bob",
+		"Approved-by: alice (2024-05-01T10:00:00Z): checked the rollout plan",
+		"Approved-by: carol (2024-05-01T10:00:00Z)",
+	}, Trailers(cr))
+}
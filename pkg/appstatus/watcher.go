@@ -0,0 +1,242 @@
+package appstatus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	argocdclientset "github.com/argoproj/argo-cd/v2/pkg/client/clientset/versioned"
+	argocdinformers "github.com/argoproj/argo-cd/v2/pkg/client/informers/externalversions"
+	argocdlisters "github.com/argoproj/argo-cd/v2/pkg/client/listers/application/v1alpha1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/squidflow/service/pkg/log"
+)
+
+const (
+	EventAdded    = "ADDED"
+	EventModified = "MODIFIED"
+	EventDeleted  = "DELETED"
+
+	// subscriberBuffer is the number of events a slow subscriber can lag behind before events are dropped
+	subscriberBuffer = 64
+)
+
+type (
+	// Event is a change of the status of an ArgoCD application
+	Event struct {
+		Type            string    `json:"type"`
+		Tenant          string    `json:"tenant"`
+		ArgoApplication string    `json:"argocd_application"`
+		Health          string    `json:"health"`
+		SyncStatus      string    `json:"sync_status"`
+		OperationPhase  string    `json:"operation_phase,omitempty"`
+		Revision        string    `json:"revision,omitempty"`
+		Time            time.Time `json:"time"`
+	}
+
+	// Filter selects the applications a subscriber receives events for
+	Filter func(app *argocdv1alpha1.Application) bool
+
+	// Watcher keeps a single shared informer on the ArgoCD applications, and fans status changes out to subscribers
+	Watcher struct {
+		informer cache.SharedIndexInformer
+		lister   argocdlisters.ApplicationNamespaceLister
+
+		mu          sync.RWMutex
+		subscribers map[int]*subscriber
+		nextID      int
+	}
+
+	subscriber struct {
+		filter Filter
+		events chan Event
+	}
+)
+
+// NewWatcher returns a watcher on the applications of the namespace, Start must be called before use
+func NewWatcher(cs argocdclientset.Interface, namespace string, resync time.Duration) *Watcher {
+	factory := argocdinformers.NewSharedInformerFactoryWithOptions(cs, resync, argocdinformers.WithNamespace(namespace))
+	appInformer := factory.Argoproj().V1alpha1().Applications()
+
+	w := &Watcher{
+		informer:    appInformer.Informer(),
+		lister:      appInformer.Lister().Applications(namespace),
+		subscribers: map[int]*subscriber{},
+	}
+
+	_, _ = w.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if app, ok := obj.(*argocdv1alpha1.Application); ok {
+				w.publish(app, EventAdded)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldApp, ok1 := oldObj.(*argocdv1alpha1.Application)
+			newApp, ok2 := newObj.(*argocdv1alpha1.Application)
+			if !ok1 || !ok2 || !statusChanged(oldApp, newApp) {
+				return
+			}
+			w.publish(newApp, EventModified)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if app, ok := obj.(*argocdv1alpha1.Application); ok {
+				w.publish(app, EventDeleted)
+			}
+		},
+	})
+
+	return w
+}
+
+// Start runs the informer until ctx is done, and waits for the initial list
+func (w *Watcher) Start(ctx context.Context) error {
+	go w.informer.Run(ctx.Done())
+
+	if !cache.WaitForCacheSync(ctx.Done(), w.informer.HasSynced) {
+		return fmt.Errorf("failed to sync argocd application informer")
+	}
+
+	log.G().Info("argocd application informer synced")
+	return nil
+}
+
+// Subscribe returns the events of the applications that match the filter, the returned
+// func must be called to release the subscription
+func (w *Watcher) Subscribe(filter Filter) (<-chan Event, func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	id := w.nextID
+	w.nextID++
+	sub := &subscriber{filter: filter, events: make(chan Event, subscriberBuffer)}
+	w.subscribers[id] = sub
+
+	return sub.events, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.subscribers[id]; ok {
+			delete(w.subscribers, id)
+			close(sub.events)
+		}
+	}
+}
+
+// List returns the cached applications that match the filter
+func (w *Watcher) List(filter Filter) ([]*argocdv1alpha1.Application, error) {
+	apps, err := w.lister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*argocdv1alpha1.Application, 0, len(apps))
+	for _, app := range apps {
+		if filter == nil || filter(app) {
+			res = append(res, app)
+		}
+	}
+
+	return res, nil
+}
+
+// Snapshot returns the current status of the applications that match the filter, as ADDED events
+func (w *Watcher) Snapshot(filter Filter) ([]Event, error) {
+	apps, err := w.List(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, 0, len(apps))
+	for _, app := range apps {
+		events = append(events, NewEvent(app, EventAdded))
+	}
+
+	return events, nil
+}
+
+func (w *Watcher) publish(app *argocdv1alpha1.Application, eventType string) {
+	event := NewEvent(app, eventType)
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, sub := range w.subscribers {
+		if !sub.filter(app) {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			log.G().WithFields(log.Fields{
+				"application": app.Name,
+				"type":        eventType,
+			}).Warn("subscriber is too slow, dropped application event")
+		}
+	}
+}
+
+// TenantFilter selects the applications of a tenant, their names are prefixed with `<tenant>-`
+// and they belong to the ArgoCD project of the tenant
+func TenantFilter(tenant string) Filter {
+	return func(app *argocdv1alpha1.Application) bool {
+		return strings.HasPrefix(app.Name, tenant+"-") && app.Spec.Project == tenant
+	}
+}
+
+// NamesFilter selects the applications of a tenant with the given ArgoCD names
+func NamesFilter(tenant string, names ...string) Filter {
+	tenantFilter := TenantFilter(tenant)
+	return func(app *argocdv1alpha1.Application) bool {
+		if !tenantFilter(app) {
+			return false
+		}
+		for _, name := range names {
+			if app.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// NewEvent returns the event of the current status of the application
+func NewEvent(app *argocdv1alpha1.Application, eventType string) Event {
+	event := Event{
+		Type:            eventType,
+		Tenant:          app.Spec.Project,
+		ArgoApplication: app.Name,
+		Health:          string(app.Status.Health.Status),
+		SyncStatus:      string(app.Status.Sync.Status),
+		Revision:        app.Status.Sync.Revision,
+		Time:            time.Now().UTC(),
+	}
+	if app.Status.OperationState != nil {
+		event.OperationPhase = string(app.Status.OperationState.Phase)
+	}
+	if event.Health == "" {
+		event.Health = "Unknown"
+	}
+	if event.SyncStatus == "" {
+		event.SyncStatus = "Unknown"
+	}
+
+	return event
+}
+
+// statusChanged ignores the frequent updates that do not change health, sync or operation phase
+func statusChanged(oldApp, newApp *argocdv1alpha1.Application) bool {
+	oldEvent := NewEvent(oldApp, EventModified)
+	newEvent := NewEvent(newApp, EventModified)
+
+	return oldEvent.Health != newEvent.Health ||
+		oldEvent.SyncStatus != newEvent.SyncStatus ||
+		oldEvent.OperationPhase != newEvent.OperationPhase ||
+		oldEvent.Revision != newEvent.Revision
+}
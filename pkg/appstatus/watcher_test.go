@@ -0,0 +1,136 @@
+package appstatus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/argo-cd/v2/pkg/client/clientset/versioned/fake"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testNamespace = "argocd"
+
+func newApp(name, project string, healthStatus health.HealthStatusCode) *argocdv1alpha1.Application {
+	return &argocdv1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec:       argocdv1alpha1.ApplicationSpec{Project: project},
+		Status: argocdv1alpha1.ApplicationStatus{
+			Health: argocdv1alpha1.HealthStatus{Status: healthStatus},
+			Sync:   argocdv1alpha1.SyncStatus{Status: argocdv1alpha1.SyncStatusCodeSynced},
+		},
+	}
+}
+
+func receive(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return Event{}
+	}
+}
+
+func TestWatcher_Subscribe(t *testing.T) {
+	cs := fake.NewSimpleClientset(
+		newApp("tenant1-app1", "tenant1", health.HealthStatusHealthy),
+		newApp("tenant2-app1", "tenant2", health.HealthStatusHealthy),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := NewWatcher(cs, testNamespace, 0)
+	if err := w.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := w.Snapshot(TenantFilter("tenant1"))
+	assert.NoError(t, err)
+	if assert.Len(t, snapshot, 1) {
+		assert.Equal(t, "tenant1-app1", snapshot[0].ArgoApplication)
+		assert.Equal(t, "Healthy", snapshot[0].Health)
+	}
+
+	events, unsubscribe := w.Subscribe(TenantFilter("tenant1"))
+	defer unsubscribe()
+
+	apps := cs.ArgoprojV1alpha1().Applications(testNamespace)
+
+	// a change of another tenant is not received
+	other := newApp("tenant2-app1", "tenant2", health.HealthStatusDegraded)
+	_, err = apps.Update(ctx, other, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+
+	// a change that does not touch the status is not received
+	unchanged := newApp("tenant1-app1", "tenant1", health.HealthStatusHealthy)
+	unchanged.Labels = map[string]string{"foo": "bar"}
+	_, err = apps.Update(ctx, unchanged, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+
+	degraded := newApp("tenant1-app1", "tenant1", health.HealthStatusDegraded)
+	_, err = apps.Update(ctx, degraded, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+
+	e := receive(t, events)
+	assert.Equal(t, EventModified, e.Type)
+	assert.Equal(t, "tenant1-app1", e.ArgoApplication)
+	assert.Equal(t, "Degraded", e.Health)
+
+	_, err = apps.Create(ctx, newApp("tenant1-app2", "tenant1", health.HealthStatusProgressing), metav1.CreateOptions{})
+	assert.NoError(t, err)
+	e = receive(t, events)
+	assert.Equal(t, EventAdded, e.Type)
+	assert.Equal(t, "tenant1-app2", e.ArgoApplication)
+
+	assert.NoError(t, apps.Delete(ctx, "tenant1-app2", metav1.DeleteOptions{}))
+	e = receive(t, events)
+	assert.Equal(t, EventDeleted, e.Type)
+	assert.Equal(t, "tenant1-app2", e.ArgoApplication)
+
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event %v", e)
+	default:
+	}
+}
+
+func TestFilters(t *testing.T) {
+	tests := map[string]struct {
+		filter Filter
+		app    *argocdv1alpha1.Application
+		want   bool
+	}{
+		"Should match the tenant prefix and project": {
+			filter: TenantFilter("tenant1"),
+			app:    newApp("tenant1-app1", "tenant1", health.HealthStatusHealthy),
+			want:   true,
+		},
+		"Should not match a tenant whose name starts with the tenant": {
+			filter: TenantFilter("tenant1"),
+			app:    newApp("tenant1-x-app1", "tenant1-x", health.HealthStatusHealthy),
+			want:   false,
+		},
+		"Should match the names of the app targets": {
+			filter: NamesFilter("tenant1", "tenant1-app1", "tenant1-app1-cluster2"),
+			app:    newApp("tenant1-app1-cluster2", "tenant1", health.HealthStatusHealthy),
+			want:   true,
+		},
+		"Should not match other apps of the tenant": {
+			filter: NamesFilter("tenant1", "tenant1-app1"),
+			app:    newApp("tenant1-app10", "tenant1", health.HealthStatusHealthy),
+			want:   false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter(tt.app))
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package argocd
+
+import (
+	"context"
+
+	applicationpkg "github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/store"
+)
+
+// ResourceTrees returns the resource trees of the ArgoCD applications by name,
+// applications that are not installed in ArgoCD are not in the result
+func ResourceTrees(ctx context.Context, names ...string) (map[string]*argoappv1.ApplicationTree, error) {
+	trees := make(map[string]*argoappv1.ApplicationTree, len(names))
+	if len(names) == 0 {
+		return trees, nil
+	}
+
+	argocdClient := GetArgoServerClient()
+	closer, appClient := argocdClient.NewApplicationClientOrDie()
+	defer func() {
+		if err := closer.Close(); err != nil {
+			log.G(ctx).Errorf(err.Error())
+		}
+	}()
+
+	for _, name := range names {
+		name, namespace := name, store.Default.ArgoCDNamespace
+		callCtx, done := observeCall(ctx, "application.resource_tree")
+		tree, err := appClient.ResourceTree(callCtx, &applicationpkg.ResourcesQuery{
+			ApplicationName: &name,
+			AppNamespace:    &namespace,
+		})
+		done(err)
+		if err != nil {
+			// like clusters, applications that do not exist are answered with PermissionDenied
+			if code := status.Code(err); code == codes.NotFound || code == codes.PermissionDenied {
+				log.G(ctx).WithField("application", name).Debug("application not installed in argocd")
+				continue
+			}
+			return nil, apierr.UpstreamArgoCD(err, "failed to get the resource tree of application '%s'", name)
+		}
+
+		trees[name] = tree
+	}
+
+	return trees, nil
+}
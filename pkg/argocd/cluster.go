@@ -3,7 +3,6 @@ package argocd
 import (
 	"context"
 	"encoding/base64"
-	"fmt"
 	"io"
 	"strings"
 	"time"
@@ -13,7 +12,10 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 
+	"github.com/squidflow/service/pkg/apierr"
 	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/metrics"
+	"github.com/squidflow/service/pkg/tracing"
 )
 
 const (
@@ -30,19 +32,19 @@ const (
 	AnnotationKeyLastModifiedAt = "squidflow.github.io/last-modified-at"
 )
 
-func RegisterCluster2ArgoCd(name, env, kubeconfig string, ann map[string]string) (*argoappv1.Cluster, error) {
+func RegisterCluster2ArgoCd(ctx context.Context, name, env, kubeconfig string, ann map[string]string) (*argoappv1.Cluster, error) {
 	// parse the kubeConfig
 	kubconfigWithoutBase64, err := base64.StdEncoding.DecodeString(kubeconfig)
 	if err != nil {
 		log.G().Errorf("Failed to decode kubeConfig: %v", err)
-		return nil, err
+		return nil, apierr.Validation("kubeconfig is not base64 encoded").Wrap(err)
 	}
 
 	// 1. get rest config from kubeconfig
 	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubconfigWithoutBase64)
 	if err != nil {
 		log.G().Errorf("failed to parse kubeConfig: %v", err)
-		return nil, err
+		return nil, apierr.Validation("failed to parse kubeconfig").Wrap(err)
 	}
 
 	if ann == nil {
@@ -82,46 +84,64 @@ func RegisterCluster2ArgoCd(name, env, kubeconfig string, ann map[string]string)
 	closer, clusterClient := argocdClient.NewClusterClientOrDie()
 	defer closer.Close()
 
-	cls, err := clusterClient.Create(context.Background(), &clusterpkg.ClusterCreateRequest{
+	// ArgoCD answers InvalidArgument to the create of a cluster whose server is registered with a
+	// different spec, look the server up first to report a conflict
+	callCtx, done := observeCall(ctx, "cluster.get")
+	existing, err := clusterClient.Get(callCtx, &clusterpkg.ClusterQuery{
+		Server: createClusterReq.Server,
+	})
+	done(err)
+	if err == nil {
+		return nil, apierr.Conflict("cluster '%s' already exists, server '%s' is registered as '%s'", name, existing.Server, existing.Name)
+	}
+	if !isClusterNotFound(err) {
+		return nil, clusterError(err, name, "get")
+	}
+
+	callCtx, done = observeCall(ctx, "cluster.create")
+	cls, err := clusterClient.Create(callCtx, &clusterpkg.ClusterCreateRequest{
 		Cluster: createClusterReq,
 	})
+	done(err)
 	if err != nil {
 		log.G().Errorf("failed to create cluster in argo-cd db: %v", err)
 		if strings.Contains(err.Error(), "while trying to verify candidate authority certificate") {
 			log.G().Warn("will force insert cluster to argocd-server cache, please confirm the CA has been mounted to argocd-server pod")
 			log.G().Warn("the argocd-server cache will not be set, if you care about the cache, please update it again")
-			argoDB, err := NewArgoCDDB(context.Background())
+			argoDB, err := NewArgoCDDB(ctx)
 			if err != nil {
 				log.G().Errorf("failed to create argo-cd db: %v", err)
 				return nil, err
 			}
-			cls, err = argoDB.CreateCluster(context.Background(), createClusterReq)
+			cls, err = argoDB.CreateCluster(ctx, createClusterReq)
 			if err != nil {
 				log.G().Errorf("failed to create cluster in argo-cd db: %v", err)
-				return nil, err
+				return nil, clusterError(err, name, "create")
 			}
 			log.G().WithFields(log.Fields{
 				"cluster": cls.Name,
 			}).Debug("cluster created in argo-cd db")
 			return cls, nil
 		}
-		return nil, err
+		return nil, clusterError(err, name, "create")
 	}
 
 	return cls, nil
 }
 
-func DeregisterCluster2ArgoCd(name string) error {
+func DeregisterCluster2ArgoCd(ctx context.Context, name string) error {
 	argocdClient := GetArgoServerClient()
 	closer, clusterClient := argocdClient.NewClusterClientOrDie()
 	defer closer.Close()
 
-	cluster, err := clusterClient.Get(context.Background(), &clusterpkg.ClusterQuery{
+	callCtx, done := observeCall(ctx, "cluster.get")
+	cluster, err := clusterClient.Get(callCtx, &clusterpkg.ClusterQuery{
 		Name: name,
 	})
+	done(err)
 	if err != nil {
 		log.G().Errorf("failed to get cluster %s: %v", name, err)
-		return fmt.Errorf("cluster %s not found", name)
+		return clusterError(err, name, "get")
 	}
 
 	log.G().WithFields(log.Fields{
@@ -129,17 +149,29 @@ func DeregisterCluster2ArgoCd(name string) error {
 		"server": cluster.Server,
 	}).Debug("found cluster, proceeding with deletion")
 
-	_, err = clusterClient.Delete(context.Background(), &clusterpkg.ClusterQuery{
+	callCtx, done = observeCall(ctx, "cluster.delete")
+	_, err = clusterClient.Delete(callCtx, &clusterpkg.ClusterQuery{
 		Name: name,
 	})
+	done(err)
 	if err != nil {
 		log.G().Errorf("Failed to delete cluster %s: %v", name, err)
-		return fmt.Errorf("failed to delete cluster: %v", err)
+		return clusterError(err, name, "delete")
 	}
 
 	return nil
 }
 
+// observeCall starts the span of an ArgoCD API call, the returned func ends it and records its latency
+func observeCall(ctx context.Context, call string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "argocd."+call)
+	return ctx, func(err error) {
+		tracing.End(span, err)
+		metrics.ObserveArgoCD(call, start, err)
+	}
+}
+
 func getClusterInfoFromAnnotations(annotations map[string]string) (environment, vendor string) {
 	if annotations == nil {
 		return "", ""
@@ -149,7 +181,7 @@ func getClusterInfoFromAnnotations(annotations map[string]string) (environment,
 	return
 }
 
-func ListClusters() (*argoappv1.ClusterList, error) {
+func ListClusters(ctx context.Context) (*argoappv1.ClusterList, error) {
 	argocdClient := GetArgoServerClient()
 	closer, clsClient := argocdClient.NewClusterClientOrDie()
 	defer func(closer io.Closer) {
@@ -159,10 +191,12 @@ func ListClusters() (*argoappv1.ClusterList, error) {
 		}
 	}(closer)
 
-	clusterList, err := clsClient.List(context.Background(), &clusterpkg.ClusterQuery{})
+	callCtx, done := observeCall(ctx, "cluster.list")
+	clusterList, err := clsClient.List(callCtx, &clusterpkg.ClusterQuery{})
+	done(err)
 	if err != nil {
 		log.G().Errorf("failed to list clusters: %v", err)
-		return nil, fmt.Errorf("failed to list clusters: %v", err)
+		return nil, apierr.UpstreamArgoCD(err, "failed to list clusters")
 	}
 
 	for i := range clusterList.Items {
@@ -190,7 +224,7 @@ func ListClusters() (*argoappv1.ClusterList, error) {
 	return clusterList, nil
 }
 
-func GetCluster(name string) (*argoappv1.Cluster, error) {
+func GetCluster(ctx context.Context, name string) (*argoappv1.Cluster, error) {
 	argocdClient := GetArgoServerClient()
 	closer, clusterClient := argocdClient.NewClusterClientOrDie()
 	defer func(closer io.Closer) {
@@ -200,12 +234,14 @@ func GetCluster(name string) (*argoappv1.Cluster, error) {
 		}
 	}(closer)
 
-	cluster, err := clusterClient.Get(context.Background(), &clusterpkg.ClusterQuery{
+	callCtx, done := observeCall(ctx, "cluster.get")
+	cluster, err := clusterClient.Get(callCtx, &clusterpkg.ClusterQuery{
 		Name: name,
 	})
+	done(err)
 	if err != nil {
 		log.G().Errorf("Failed to get cluster %s: %v", name, err)
-		return nil, fmt.Errorf("cluster %s not found", name)
+		return nil, clusterError(err, name, "get")
 	}
 
 	env, vendor := getClusterInfoFromAnnotations(cluster.Annotations)
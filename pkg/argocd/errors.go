@@ -0,0 +1,29 @@
+package argocd
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/squidflow/service/pkg/apierr"
+)
+
+// clusterError maps the error of an ArgoCD cluster call to an API error. ArgoCD answers
+// PermissionDenied for clusters that do not exist, so that their existence is not leaked.
+func clusterError(err error, name, action string) error {
+	switch status.Code(err) {
+	case codes.NotFound, codes.PermissionDenied:
+		return apierr.NotFound("cluster '%s' not found", name).Wrap(err)
+	case codes.AlreadyExists:
+		return apierr.Conflict("cluster '%s' already exists", name).Wrap(err)
+	case codes.InvalidArgument:
+		return apierr.Validation("failed to %s cluster '%s'", action, name).Wrap(err)
+	}
+
+	return apierr.UpstreamArgoCD(err, "failed to %s cluster '%s'", action, name)
+}
+
+// isClusterNotFound reports whether err is the answer of ArgoCD to a get of a cluster that does not exist
+func isClusterNotFound(err error) bool {
+	code := status.Code(err)
+	return code == codes.NotFound || code == codes.PermissionDenied
+}
@@ -0,0 +1,55 @@
+package argocd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/squidflow/service/pkg/apierr"
+)
+
+func Test_clusterError(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want apierr.Code
+	}{
+		"not found": {
+			err:  status.Error(codes.NotFound, "cluster not found"),
+			want: apierr.CodeNotFound,
+		},
+		"permission denied hides a missing cluster": {
+			err:  status.Error(codes.PermissionDenied, "permission denied"),
+			want: apierr.CodeNotFound,
+		},
+		"already exists": {
+			err:  status.Error(codes.AlreadyExists, "cluster already exists"),
+			want: apierr.CodeConflict,
+		},
+		"message is not parsed": {
+			err:  status.Error(codes.InvalidArgument, "existing cluster spec is different; use upsert flag to force update"),
+			want: apierr.CodeValidation,
+		},
+		"invalid argument": {
+			err:  status.Error(codes.InvalidArgument, "server is required"),
+			want: apierr.CodeValidation,
+		},
+		"unavailable": {
+			err:  status.Error(codes.Unavailable, "connection refused"),
+			want: apierr.CodeUpstreamArgoCD,
+		},
+		"not a grpc error": {
+			err:  errors.New("boom"),
+			want: apierr.CodeUpstreamArgoCD,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := clusterError(tt.err, "cluster1", "create")
+			assert.Equal(t, tt.want, apierr.CodeOf(err))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Errors
+var (
+	ErrQueryNotSupported = errors.New("audit sink does not support queries")
+)
+
+type (
+	// Record is a single mutating API call
+	Record struct {
+		Timestamp time.Time `json:"timestamp"`
+		RequestID string    `json:"request_id"`
+		Actor     string    `json:"actor"`
+		Tenant    string    `json:"tenant"`
+		Action    string    `json:"action"`
+		Target    Target    `json:"target"`
+		Method    string    `json:"method,omitempty"`
+		Path      string    `json:"path,omitempty"`
+		Status    int       `json:"status,omitempty"`
+		// Operation is the id of the asynchronous operation that the call ran as
+		Operation string `json:"operation,omitempty"`
+		Outcome   string `json:"outcome"`
+		Error     string `json:"error,omitempty"`
+		// Revisions are the commit SHAs or pull request URLs that the call persisted
+		Revisions []string `json:"revisions,omitempty"`
+		// Override is the reason given to change applications during a freeze window
+		Override string `json:"override,omitempty"`
+	}
+
+	// Target is the resource that a call acts on
+	Target struct {
+		Kind string `json:"kind"`
+		Name string `json:"name,omitempty"`
+	}
+
+	// Query filters records, zero values match everything
+	Query struct {
+		Tenant string
+		Since  time.Time
+		Until  time.Time
+		Limit  int
+	}
+
+	// Sink stores audit records
+	Sink interface {
+		Write(ctx context.Context, record *Record) error
+		// Query returns the matching records, newest first, or ErrQueryNotSupported
+		Query(ctx context.Context, q *Query) ([]Record, error)
+	}
+)
+
+// NewSink returns the sink of the given type, "file" writes to path
+func NewSink(sinkType, path string) (Sink, error) {
+	switch sinkType {
+	case "file":
+		return NewFileSink(path)
+	case "stdout", "":
+		return NewStdoutSink(), nil
+	case "none":
+		return &nopSink{}, nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink '%s'", sinkType)
+	}
+}
+
+// Match returns true if the record matches the query
+func (q *Query) Match(r *Record) bool {
+	if q.Tenant != "" && r.Tenant != q.Tenant {
+		return false
+	}
+
+	if !q.Since.IsZero() && r.Timestamp.Before(q.Since) {
+		return false
+	}
+
+	if !q.Until.IsZero() && r.Timestamp.After(q.Until) {
+		return false
+	}
+
+	return true
+}
+
+type nopSink struct{}
+
+func (s *nopSink) Write(context.Context, *Record) error { return nil }
+
+func (s *nopSink) Query(context.Context, *Query) ([]Record, error) {
+	return nil, ErrQueryNotSupported
+}
@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSink_Query(t *testing.T) {
+	base := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	records := []*Record{
+		{Timestamp: base, Tenant: "tenant1", Action: "application.create", Outcome: OutcomeSuccess, Revisions: []string{"0dee45f"}},
+		{Timestamp: base.Add(time.Hour), Tenant: "tenant2", Action: "tenant.delete", Outcome: OutcomeFailure, Error: "forbidden"},
+		{Timestamp: base.Add(2 * time.Hour), Tenant: "tenant1", Action: "application.delete", Outcome: OutcomeSuccess},
+		{Timestamp: base.Add(3 * time.Hour), Tenant: "tenant1", Action: "secretstore.create", Outcome: OutcomeSuccess},
+	}
+
+	tests := map[string]struct {
+		query       *Query
+		wantActions []string
+	}{
+		"Should return all records newest first": {
+			query:       &Query{},
+			wantActions: []string{"secretstore.create", "application.delete", "tenant.delete", "application.create"},
+		},
+		"Should filter by tenant": {
+			query:       &Query{Tenant: "tenant2"},
+			wantActions: []string{"tenant.delete"},
+		},
+		"Should filter by time range": {
+			query:       &Query{Tenant: "tenant1", Since: base.Add(time.Hour), Until: base.Add(2 * time.Hour)},
+			wantActions: []string{"application.delete"},
+		},
+		"Should limit the result": {
+			query:       &Query{Tenant: "tenant1", Limit: 2},
+			wantActions: []string{"secretstore.create", "application.delete"},
+		},
+		"Should return nothing for an unknown tenant": {
+			query:       &Query{Tenant: "tenant9"},
+			wantActions: []string{},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range records {
+		if err = sink.Write(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a partially written line must not break queries
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"timestamp":`)
+	_ = f.Close()
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := sink.Query(context.Background(), tt.query)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			actions := []string{}
+			for _, r := range got {
+				actions = append(actions, r.Action)
+			}
+			assert.Equal(t, tt.wantActions, actions)
+		})
+	}
+}
+
+func TestFileSink_QueryMissingFile(t *testing.T) {
+	sink, err := NewFileSink(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := sink.Query(context.Background(), &Query{})
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestStdoutSink(t *testing.T) {
+	out := &bytes.Buffer{}
+	sink := &StdoutSink{out: out}
+
+	err := sink.Write(context.Background(), &Record{Actor: "alice", Tenant: "tenant1", Action: "tenant.create"})
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), `"audit":{`)
+	assert.Contains(t, out.String(), `"actor":"alice"`)
+
+	_, err = sink.Query(context.Background(), &Query{})
+	assert.ErrorIs(t, err, ErrQueryNotSupported)
+}
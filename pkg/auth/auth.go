@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/squidflow/service/pkg/log"
+)
+
+// Errors
+var (
+	ErrInvalidToken    = errors.New("invalid token")
+	ErrMissingUsername = errors.New("token has no username claim")
+	ErrMissingTenant   = errors.New("token has no tenant claim")
+)
+
+type (
+	// Identity is the authenticated caller of the API
+	Identity struct {
+		// Username is the name of the caller
+		Username string
+		// Tenants are the tenants the caller is a member of
+		Tenants []string
+		// Groups are the groups of the caller in the identity provider
+		Groups []string
+	}
+
+	// Authenticator verifies the bearer token of a request, and returns the identity of the caller
+	Authenticator interface {
+		Authenticate(ctx context.Context, token string) (*Identity, error)
+	}
+
+	// Config is the configuration of the authenticator
+	// DevMode: if true, the bearer token is the plain `username@tenant`, never use it in production
+	// Issuer: the expected `iss` claim, the keys are discovered from the issuer if neither JWKSURL nor StaticKeys are set
+	// Audience: the expected `aud` claim
+	// JWKSURL: the url of the JSON Web Key Set that signs the tokens
+	// StaticKeys: paths to PEM encoded public keys or certificates that sign the tokens
+	// UsernameClaim: the claim that holds the username
+	// TenantClaim: the claim that holds the tenants of the user, a string or a list of strings
+	// GroupsClaim: the claim that holds the groups of the user, a string or a list of strings
+	// SigningAlgs: the accepted signing algorithms, default is RS256
+	Config struct {
+		DevMode       bool
+		Issuer        string
+		Audience      string
+		JWKSURL       string
+		StaticKeys    []string
+		UsernameClaim string
+		TenantClaim   string
+		GroupsClaim   string
+		SigningAlgs   []string
+	}
+)
+
+// NewAuthenticator returns the authenticator that matches the configuration
+func NewAuthenticator(ctx context.Context, cfg *Config) (Authenticator, error) {
+	if cfg.DevMode {
+		log.G().Warn("authentication is running in dev mode, bearer tokens are not verified")
+		return &devAuthenticator{}, nil
+	}
+
+	if cfg.Issuer == "" {
+		return nil, fmt.Errorf("auth issuer is required unless dev mode is enabled")
+	}
+
+	return NewJWTAuthenticator(ctx, cfg)
+}
+
+// HasTenant checks if the identity is a member of the tenant
+func (i *Identity) HasTenant(tenant string) bool {
+	for _, t := range i.Tenants {
+		if t == tenant {
+			return true
+		}
+	}
+
+	return false
+}
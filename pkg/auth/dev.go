@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// devAuthenticator accepts the legacy `username@tenant` bearer token without any verification
+type devAuthenticator struct{}
+
+func (d *devAuthenticator) Authenticate(_ context.Context, token string) (*Identity, error) {
+	parts := strings.Split(token, "@")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("%w: expected 'username@tenant'", ErrInvalidToken)
+	}
+
+	if parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("%w: username and tenant cannot be empty", ErrInvalidToken)
+	}
+
+	return &Identity{
+		Username: parts[0],
+		Tenants:  []string{parts[1]},
+	}, nil
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+
+	"github.com/squidflow/service/pkg/log"
+)
+
+const (
+	DefaultUsernameClaim = "preferred_username"
+	DefaultTenantClaim   = "tenants"
+	DefaultGroupsClaim   = "groups"
+)
+
+// jwtAuthenticator verifies the signature, issuer, audience and expiry of JWT bearer tokens
+type jwtAuthenticator struct {
+	verifier      *oidc.IDTokenVerifier
+	usernameClaim string
+	tenantClaim   string
+	groupsClaim   string
+}
+
+// NewJWTAuthenticator returns an authenticator that verifies tokens against the static keys,
+// the JWKS url, or the keys discovered from the issuer, in this order
+func NewJWTAuthenticator(ctx context.Context, cfg *Config) (Authenticator, error) {
+	verifierConfig := &oidc.Config{
+		ClientID:             cfg.Audience,
+		SkipClientIDCheck:    cfg.Audience == "",
+		SupportedSigningAlgs: cfg.SigningAlgs,
+	}
+
+	var verifier *oidc.IDTokenVerifier
+	switch {
+	case len(cfg.StaticKeys) > 0:
+		keys, err := loadPublicKeys(cfg.StaticKeys)
+		if err != nil {
+			return nil, err
+		}
+		verifier = oidc.NewVerifier(cfg.Issuer, &oidc.StaticKeySet{PublicKeys: keys}, verifierConfig)
+	case cfg.JWKSURL != "":
+		verifier = oidc.NewVerifier(cfg.Issuer, oidc.NewRemoteKeySet(ctx, cfg.JWKSURL), verifierConfig)
+	default:
+		provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover oidc provider '%s': %w", cfg.Issuer, err)
+		}
+		verifier = provider.Verifier(verifierConfig)
+	}
+
+	a := &jwtAuthenticator{
+		verifier:      verifier,
+		usernameClaim: cfg.UsernameClaim,
+		tenantClaim:   cfg.TenantClaim,
+		groupsClaim:   cfg.GroupsClaim,
+	}
+	if a.usernameClaim == "" {
+		a.usernameClaim = DefaultUsernameClaim
+	}
+	if a.tenantClaim == "" {
+		a.tenantClaim = DefaultTenantClaim
+	}
+	if a.groupsClaim == "" {
+		a.groupsClaim = DefaultGroupsClaim
+	}
+
+	log.G().WithFields(log.Fields{
+		"issuer":         cfg.Issuer,
+		"audience":       cfg.Audience,
+		"jwks_url":       cfg.JWKSURL,
+		"static_keys":    len(cfg.StaticKeys),
+		"username_claim": a.usernameClaim,
+		"tenant_claim":   a.tenantClaim,
+		"groups_claim":   a.groupsClaim,
+	}).Info("jwt authentication enabled")
+
+	return a, nil
+}
+
+func (a *jwtAuthenticator) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	idToken, err := a.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	claims := map[string]interface{}{}
+	if err = idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	username, _ := claims[a.usernameClaim].(string)
+	if username == "" {
+		return nil, ErrMissingUsername
+	}
+
+	tenants := stringsClaim(claims[a.tenantClaim])
+	if len(tenants) == 0 {
+		return nil, ErrMissingTenant
+	}
+
+	return &Identity{
+		Username: username,
+		Tenants:  tenants,
+		Groups:   stringsClaim(claims[a.groupsClaim]),
+	}, nil
+}
+
+// stringsClaim reads a claim that is either a string or a list of strings
+func stringsClaim(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []interface{}:
+		res := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				res = append(res, s)
+			}
+		}
+		return res
+	default:
+		return nil
+	}
+}
+
+// loadPublicKeys reads PEM encoded public keys or certificates from files
+func loadPublicKeys(paths []string) ([]crypto.PublicKey, error) {
+	keys := make([]crypto.PublicKey, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key '%s': %w", path, err)
+		}
+
+		key, err := parsePublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key '%s': %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+}
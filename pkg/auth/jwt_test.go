@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testIssuer   = "https://issuer.example.com"
+	testAudience = "squidflow"
+	testKeyID    = "test-key"
+)
+
+func signToken(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", testKeyID),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":                testIssuer,
+		"aud":                testAudience,
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"preferred_username": "alice",
+		"tenants":            []string{"tenant1", "tenant2"},
+	}
+}
+
+func writePublicKey(t *testing.T, key *rsa.PrivateKey) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestJWTAuthenticator_Authenticate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		cfg     *Config
+		token   func() string
+		want    *Identity
+		wantErr string
+	}{
+		"Should authenticate a valid token": {
+			token: func() string { return signToken(t, key, validClaims()) },
+			want:  &Identity{Username: "alice", Tenants: []string{"tenant1", "tenant2"}},
+		},
+		"Should accept a single tenant as a string": {
+			token: func() string {
+				claims := validClaims()
+				claims["tenants"] = "tenant1"
+				return signToken(t, key, claims)
+			},
+			want: &Identity{Username: "alice", Tenants: []string{"tenant1"}},
+		},
+		"Should read the configured claims": {
+			cfg: &Config{UsernameClaim: "email", TenantClaim: "projects", GroupsClaim: "roles"},
+			token: func() string {
+				claims := validClaims()
+				claims["email"] = "bob@example.com"
+				claims["projects"] = []string{"tenant3"}
+				claims["roles"] = "platform"
+				return signToken(t, key, claims)
+			},
+			want: &Identity{Username: "bob@example.com", Tenants: []string{"tenant3"}, Groups: []string{"platform"}},
+		},
+		"Should read the groups": {
+			token: func() string {
+				claims := validClaims()
+				claims["groups"] = []string{"admins", "devs"}
+				return signToken(t, key, claims)
+			},
+			want: &Identity{Username: "alice", Tenants: []string{"tenant1", "tenant2"}, Groups: []string{"admins", "devs"}},
+		},
+		"Should fail when the token is signed by an unknown key": {
+			token:   func() string { return signToken(t, otherKey, validClaims()) },
+			wantErr: "invalid token",
+		},
+		"Should fail when the token is expired": {
+			token: func() string {
+				claims := validClaims()
+				claims["exp"] = time.Now().Add(-time.Hour).Unix()
+				return signToken(t, key, claims)
+			},
+			wantErr: "token is expired",
+		},
+		"Should fail when the issuer does not match": {
+			token: func() string {
+				claims := validClaims()
+				claims["iss"] = "https://other.example.com"
+				return signToken(t, key, claims)
+			},
+			wantErr: "invalid token",
+		},
+		"Should fail when the audience does not match": {
+			token: func() string {
+				claims := validClaims()
+				claims["aud"] = "other"
+				return signToken(t, key, claims)
+			},
+			wantErr: "invalid token",
+		},
+		"Should fail when the username claim is missing": {
+			token: func() string {
+				claims := validClaims()
+				delete(claims, "preferred_username")
+				return signToken(t, key, claims)
+			},
+			wantErr: ErrMissingUsername.Error(),
+		},
+		"Should fail when the tenant claim is missing": {
+			token: func() string {
+				claims := validClaims()
+				delete(claims, "tenants")
+				return signToken(t, key, claims)
+			},
+			wantErr: ErrMissingTenant.Error(),
+		},
+		"Should fail on a legacy username@tenant token": {
+			token:   func() string { return "alice@tenant1" },
+			wantErr: "invalid token",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := tt.cfg
+			if cfg == nil {
+				cfg = &Config{}
+			}
+			cfg.Issuer = testIssuer
+			cfg.Audience = testAudience
+			cfg.StaticKeys = []string{writePublicKey(t, key)}
+
+			a, err := NewJWTAuthenticator(context.Background(), cfg)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			got, err := a.Authenticate(context.Background(), tt.token())
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestJWTAuthenticator_JWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: testKeyID, Algorithm: string(jose.RS256), Use: "sig"}},
+		})
+	}))
+	defer srv.Close()
+
+	a, err := NewJWTAuthenticator(context.Background(), &Config{
+		Issuer:   testIssuer,
+		Audience: testAudience,
+		JWKSURL:  srv.URL,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	got, err := a.Authenticate(context.Background(), signToken(t, key, validClaims()))
+	assert.NoError(t, err)
+	assert.Equal(t, &Identity{Username: "alice", Tenants: []string{"tenant1", "tenant2"}}, got)
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	tests := map[string]struct {
+		cfg     *Config
+		token   string
+		want    *Identity
+		wantErr string
+	}{
+		"Should accept username@tenant in dev mode": {
+			cfg:   &Config{DevMode: true},
+			token: "alice@tenant1",
+			want:  &Identity{Username: "alice", Tenants: []string{"tenant1"}},
+		},
+		"Should reject a malformed token in dev mode": {
+			cfg:     &Config{DevMode: true},
+			token:   "alice",
+			wantErr: "expected 'username@tenant'",
+		},
+		"Should reject an empty tenant in dev mode": {
+			cfg:     &Config{DevMode: true},
+			token:   "alice@",
+			wantErr: "username and tenant cannot be empty",
+		},
+		"Should require an issuer outside of dev mode": {
+			cfg:     &Config{},
+			wantErr: "auth issuer is required",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			a, err := NewAuthenticator(context.Background(), tt.cfg)
+			if err == nil {
+				var got *Identity
+				got, err = a.Authenticate(context.Background(), tt.token)
+				if tt.wantErr == "" {
+					assert.NoError(t, err)
+					assert.Equal(t, tt.want, got)
+					return
+				}
+			}
+
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
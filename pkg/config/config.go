@@ -3,9 +3,13 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/viper"
+
+	"github.com/squidflow/service/pkg/auth"
+	"github.com/squidflow/service/pkg/tracing"
 )
 
 // Config matches config.toml structure
@@ -30,6 +34,93 @@ type Config struct {
 		RemoteURL   string   `mapstructure:"remote_url" validate:"required,url"`
 		AccessToken string   `mapstructure:"access_token" validate:"required"`
 	} `mapstructure:"application_repo"`
+
+	Auth struct {
+		// DevMode accepts the unverified `username@tenant` bearer token, never enable it in production
+		DevMode bool `mapstructure:"dev_mode"`
+
+		OIDC struct {
+			Issuer        string   `mapstructure:"issuer" validate:"omitempty,url"`
+			Audience      string   `mapstructure:"audience"`
+			JWKSURL       string   `mapstructure:"jwks_url" validate:"omitempty,url"`
+			StaticKeys    []string `mapstructure:"static_keys"`
+			UsernameClaim string   `mapstructure:"username_claim"`
+			TenantClaim   string   `mapstructure:"tenant_claim"`
+			GroupsClaim   string   `mapstructure:"groups_claim"`
+			SigningAlgs   []string `mapstructure:"signing_algs"`
+		} `mapstructure:"oidc"`
+	} `mapstructure:"auth"`
+
+	RBAC struct {
+		// Enabled enforces the policy, every authenticated request is allowed otherwise
+		Enabled bool `mapstructure:"enabled"`
+		// PolicyFile is the path of the policy, it takes precedence over the ConfigMap
+		PolicyFile string `mapstructure:"policy_file"`
+
+		ConfigMap struct {
+			Namespace string `mapstructure:"namespace"`
+			Name      string `mapstructure:"name"`
+			Key       string `mapstructure:"key"`
+		} `mapstructure:"configmap"`
+	} `mapstructure:"rbac"`
+
+	Audit struct {
+		// Sink is where audit records go, one of file, stdout, none
+		Sink string `mapstructure:"sink" validate:"omitempty,oneof=file stdout none"`
+		// File is the JSON-lines file of the file sink
+		File string `mapstructure:"file" validate:"required_if=Sink file"`
+	} `mapstructure:"audit"`
+
+	Operations struct {
+		// Workers is the number of asynchronous operations that run concurrently
+		Workers int `mapstructure:"workers" validate:"min=0"`
+		// QueueSize is the number of asynchronous operations that can wait for a worker
+		QueueSize int `mapstructure:"queue_size" validate:"min=0"`
+		// Retention is how long finished operations can be queried
+		Retention time.Duration `mapstructure:"retention"`
+	} `mapstructure:"operations"`
+
+	RateLimit struct {
+		// Enabled limits the requests of each tenant, and the writer operations on each repository
+		Enabled bool `mapstructure:"enabled"`
+
+		ReadPerMinute   int `mapstructure:"read_per_minute" validate:"min=0"`
+		ReadBurst       int `mapstructure:"read_burst" validate:"min=0"`
+		MutatePerMinute int `mapstructure:"mutate_per_minute" validate:"min=0"`
+		MutateBurst     int `mapstructure:"mutate_burst" validate:"min=0"`
+		// RepoConcurrency is the number of writer operations in flight on each gitops repository
+		RepoConcurrency int `mapstructure:"repo_concurrency" validate:"min=0"`
+	} `mapstructure:"rate_limit"`
+
+	Idempotency struct {
+		// TTL is how long the response of a request with an Idempotency-Key header is replayed
+		TTL time.Duration `mapstructure:"ttl"`
+	} `mapstructure:"idempotency"`
+
+	FreezeWindows struct {
+		// SyncInterval is how often the freeze windows are written again as the sync windows of the projects,
+		// to drop the ranges that have ended. 0 disables it
+		SyncInterval time.Duration `mapstructure:"sync_interval"`
+	} `mapstructure:"freeze_windows"`
+
+	// Approvals are the approval policies by environment, like `[approvals.PRD]`. The changes of an application
+	// that target the clusters of an environment with required approvals wait in a change request until approved
+	Approvals map[string]struct {
+		Required int `mapstructure:"required" validate:"min=0"`
+		// Role is the role that approvers hold in the tenant of the change, tenant-admin by default
+		Role string `mapstructure:"role" validate:"omitempty,oneof=platform-admin tenant-admin developer viewer"`
+	} `mapstructure:"approvals" validate:"dive"`
+
+	Tracing struct {
+		// Enabled exports spans to an OTLP/HTTP collector
+		Enabled bool `mapstructure:"enabled"`
+		// Endpoint is the host:port of the collector
+		Endpoint string `mapstructure:"endpoint" validate:"required_if=Enabled true"`
+		// Insecure disables TLS to the collector
+		Insecure    bool    `mapstructure:"insecure"`
+		ServiceName string  `mapstructure:"service_name"`
+		SampleRatio float64 `mapstructure:"sample_ratio" validate:"min=0,max=1"`
+	} `mapstructure:"tracing"`
 }
 
 func init() {
@@ -41,6 +132,30 @@ func init() {
 	viper.SetDefault("server.address", "0.0.0.0")
 	viper.SetDefault("server.port", 8080)
 	viper.SetDefault("log.level", "info")
+	viper.SetDefault("auth.dev_mode", false)
+	viper.SetDefault("auth.oidc.username_claim", "preferred_username")
+	viper.SetDefault("auth.oidc.tenant_claim", "tenants")
+	viper.SetDefault("auth.oidc.groups_claim", "groups")
+	viper.SetDefault("auth.oidc.signing_algs", []string{"RS256"})
+	viper.SetDefault("rbac.enabled", false)
+	viper.SetDefault("rbac.configmap.key", "policy.yaml")
+	viper.SetDefault("audit.sink", "stdout")
+	viper.SetDefault("operations.workers", 4)
+	viper.SetDefault("operations.queue_size", 100)
+	viper.SetDefault("operations.retention", "1h")
+	viper.SetDefault("rate_limit.enabled", false)
+	viper.SetDefault("rate_limit.read_per_minute", 600)
+	viper.SetDefault("rate_limit.read_burst", 100)
+	viper.SetDefault("rate_limit.mutate_per_minute", 30)
+	viper.SetDefault("rate_limit.mutate_burst", 10)
+	viper.SetDefault("rate_limit.repo_concurrency", 2)
+	viper.SetDefault("idempotency.ttl", "24h")
+	viper.SetDefault("freeze_windows.sync_interval", "1h")
+	viper.SetDefault("tracing.enabled", false)
+	viper.SetDefault("tracing.endpoint", "localhost:4318")
+	viper.SetDefault("tracing.insecure", true)
+	viper.SetDefault("tracing.service_name", "squidflow-service")
+	viper.SetDefault("tracing.sample_ratio", 1.0)
 }
 
 func ParseConfig(configFilePath string) (*Config, error) {
@@ -67,3 +182,29 @@ func ParseConfig(configFilePath string) (*Config, error) {
 
 	return &config, nil
 }
+
+// AuthConfig returns the configuration of the request authenticator
+func (c *Config) AuthConfig() *auth.Config {
+	return &auth.Config{
+		DevMode:       c.Auth.DevMode,
+		Issuer:        c.Auth.OIDC.Issuer,
+		Audience:      c.Auth.OIDC.Audience,
+		JWKSURL:       c.Auth.OIDC.JWKSURL,
+		StaticKeys:    c.Auth.OIDC.StaticKeys,
+		UsernameClaim: c.Auth.OIDC.UsernameClaim,
+		TenantClaim:   c.Auth.OIDC.TenantClaim,
+		GroupsClaim:   c.Auth.OIDC.GroupsClaim,
+		SigningAlgs:   c.Auth.OIDC.SigningAlgs,
+	}
+}
+
+// TracingConfig returns the configuration of the span exporter
+func (c *Config) TracingConfig() tracing.Config {
+	return tracing.Config{
+		Enabled:     c.Tracing.Enabled,
+		Endpoint:    c.Tracing.Endpoint,
+		Insecure:    c.Tracing.Insecure,
+		ServiceName: c.Tracing.ServiceName,
+		SampleRatio: c.Tracing.SampleRatio,
+	}
+}
@@ -0,0 +1,269 @@
+package diff
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// StatusSynced the live resource has the desired state
+	StatusSynced Status = "synced"
+	// StatusModified the live resource differs from the desired state, the sync updates it
+	StatusModified Status = "modified"
+	// StatusMissing the resource does not exist in the cluster, the sync creates it
+	StatusMissing Status = "missing"
+	// StatusUnknown the live resource could not be read
+	StatusUnknown Status = "unknown"
+)
+
+type (
+	Status string
+
+	// Change is a field that differs between the desired and the live state of a resource,
+	// Path is the dotted path of the field, with the index of list items, e.g. spec.template.spec.containers[0].image
+	Change struct {
+		Path    string      `json:"path"`
+		Desired interface{} `json:"desired"`
+		Live    interface{} `json:"live"`
+	}
+
+	// ResourceDiff is the difference between the desired and the live state of a resource
+	ResourceDiff struct {
+		Group     string   `json:"group"`
+		Version   string   `json:"version"`
+		Kind      string   `json:"kind"`
+		Namespace string   `json:"namespace,omitempty"`
+		Name      string   `json:"name"`
+		Status    Status   `json:"status"`
+		Changes   []Change `json:"changes,omitempty"`
+		Error     string   `json:"error,omitempty"`
+	}
+)
+
+// serverManagedFields are set by the api server and the controllers, they are never part of the desired state
+var serverManagedFields = [][]string{
+	{"status"},
+	{"metadata", "managedFields"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "uid"},
+	{"metadata", "generation"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "deletionTimestamp"},
+	{"metadata", "deletionGracePeriodSeconds"},
+	{"metadata", "selfLink"},
+	{"metadata", "ownerReferences"},
+	{"metadata", "finalizers"},
+}
+
+// serverManagedAnnotations are added by kubectl, argocd and the controllers
+var serverManagedAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+	"argocd.argoproj.io/tracking-id",
+}
+
+// ParseManifests parses multi-document yaml manifests, empty documents are skipped
+func ParseManifests(manifests []byte) ([]*unstructured.Unstructured, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifests), 4096)
+	objs := []*unstructured.Unstructured{}
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objs, nil
+			}
+			return nil, fmt.Errorf("failed to parse manifests: %w", err)
+		}
+
+		if len(obj.Object) == 0 {
+			continue
+		}
+
+		// the decoder reads numbers as float64, round trip through json to read them as the api server does
+		b, err := obj.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse manifests: %w", err)
+		}
+		if err := obj.UnmarshalJSON(b); err != nil {
+			return nil, fmt.Errorf("failed to parse manifests: %w", err)
+		}
+
+		objs = append(objs, obj)
+	}
+}
+
+// Normalize returns a copy of the object without the fields managed by the server
+func Normalize(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	obj = obj.DeepCopy()
+	for _, fields := range serverManagedFields {
+		unstructured.RemoveNestedField(obj.Object, fields...)
+	}
+
+	if annotations := obj.GetAnnotations(); annotations != nil {
+		for _, a := range serverManagedAnnotations {
+			delete(annotations, a)
+		}
+		if len(annotations) == 0 {
+			unstructured.RemoveNestedField(obj.Object, "metadata", "annotations")
+		} else {
+			obj.SetAnnotations(annotations)
+		}
+	}
+
+	return obj
+}
+
+// Compare returns the changes of the fields set in the desired state. Fields only set in the live state
+// are defaulted by the server, or set by another manager, and are not reported
+func Compare(desired, live *unstructured.Unstructured) []Change {
+	changes := []Change{}
+	compareValue("", Normalize(desired).Object, Normalize(live).Object, &changes)
+
+	return changes
+}
+
+func compareValue(path string, desired, live interface{}, changes *[]Change) {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			if len(d) > 0 || live != nil {
+				*changes = append(*changes, Change{Path: path, Desired: desired, Live: live})
+			}
+			return
+		}
+
+		for k, v := range d {
+			compareValue(joinPath(path, k), v, l[k], changes)
+		}
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok || len(d) != len(l) {
+			if len(d) > 0 || live != nil {
+				*changes = append(*changes, Change{Path: path, Desired: desired, Live: live})
+			}
+			return
+		}
+
+		for i := range d {
+			compareValue(fmt.Sprintf("%s[%d]", path, i), d[i], l[i], changes)
+		}
+	case nil:
+		// a null field is unset
+	default:
+		if !equalScalar(desired, live) {
+			*changes = append(*changes, Change{Path: path, Desired: desired, Live: live})
+		}
+	}
+}
+
+// equalScalar compares scalars the way the api server stores them, numbers by value
+// and quantities in their canonical form, e.g. cpu 0.5 and 500m
+func equalScalar(desired, live interface{}) bool {
+	if reflect.DeepEqual(desired, live) {
+		return true
+	}
+
+	if live == nil {
+		return false
+	}
+
+	d, l := fmt.Sprint(desired), fmt.Sprint(live)
+	if df, err := strconv.ParseFloat(d, 64); err == nil {
+		if lf, err := strconv.ParseFloat(l, 64); err == nil {
+			return df == lf
+		}
+	}
+
+	dq, err := resource.ParseQuantity(d)
+	if err != nil {
+		return false
+	}
+	lq, err := resource.ParseQuantity(l)
+	if err != nil {
+		return false
+	}
+
+	return dq.Cmp(lq) == 0
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	if strings.ContainsAny(key, ".[]") {
+		return fmt.Sprintf("%s[%q]", path, key)
+	}
+
+	return path + "." + key
+}
+
+// Resources compares the desired resources with the live resources of the cluster. Namespaced resources
+// without a namespace are in namespace, the destination namespace of the application
+func Resources(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper, desired []*unstructured.Unstructured, namespace string) []ResourceDiff {
+	diffs := make([]ResourceDiff, 0, len(desired))
+	for _, obj := range desired {
+		gvk := obj.GroupVersionKind()
+		d := ResourceDiff{
+			Group:     gvk.Group,
+			Version:   gvk.Version,
+			Kind:      gvk.Kind,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+		}
+
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			d.Status = StatusUnknown
+			d.Error = fmt.Sprintf("unknown resource type: %v", err)
+			diffs = append(diffs, d)
+			continue
+		}
+
+		var ri dynamic.ResourceInterface = client.Resource(mapping.Resource)
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			if d.Namespace == "" {
+				d.Namespace = namespace
+				obj = obj.DeepCopy()
+				obj.SetNamespace(namespace)
+			}
+			ri = client.Resource(mapping.Resource).Namespace(d.Namespace)
+		} else {
+			d.Namespace = ""
+		}
+
+		live, err := ri.Get(ctx, d.Name, metav1.GetOptions{})
+		switch {
+		case k8serrors.IsNotFound(err):
+			d.Status = StatusMissing
+		case err != nil:
+			d.Status = StatusUnknown
+			d.Error = err.Error()
+		default:
+			d.Changes = Compare(obj, live)
+			d.Status = StatusSynced
+			if len(d.Changes) > 0 {
+				d.Status = StatusModified
+			}
+		}
+
+		diffs = append(diffs, d)
+	}
+
+	return diffs
+}
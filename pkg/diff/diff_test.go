@@ -0,0 +1,168 @@
+package diff
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+const deployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.27
+        resources:
+          limits:
+            cpu: 0.5
+            memory: 256Mi
+`
+
+func mustParse(t *testing.T, manifests string) []*unstructured.Unstructured {
+	objs, err := ParseManifests([]byte(manifests))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return objs
+}
+
+func TestParseManifests(t *testing.T) {
+	objs := mustParse(t, "---\n"+deployment+"\n---\n\n---\napiVersion: v1\nkind: Service\nmetadata:\n  name: web\n")
+
+	assert.Len(t, objs, 2)
+	assert.Equal(t, "Deployment", objs[0].GetKind())
+	assert.Equal(t, int64(2), objs[0].Object["spec"].(map[string]interface{})["replicas"])
+	assert.Equal(t, "Service", objs[1].GetKind())
+}
+
+func TestCompare(t *testing.T) {
+	tests := map[string]struct {
+		live string
+		want []Change
+	}{
+		"server managed and defaulted fields are ignored": {
+			live: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  uid: 9a3c
+  resourceVersion: "42"
+  generation: 3
+  creationTimestamp: "2024-01-01T00:00:00Z"
+  labels:
+    app: web
+    app.kubernetes.io/instance: tenant1-web
+  annotations:
+    deployment.kubernetes.io/revision: "3"
+spec:
+  replicas: 2
+  progressDeadlineSeconds: 600
+  template:
+    spec:
+      dnsPolicy: ClusterFirst
+      containers:
+      - name: web
+        image: nginx:1.27
+        imagePullPolicy: IfNotPresent
+        resources:
+          limits:
+            cpu: 500m
+            memory: 256Mi
+status:
+  replicas: 2
+`,
+			want: []Change{},
+		},
+		"changed fields": {
+			live: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.26
+        resources:
+          limits:
+            cpu: 500m
+            memory: 256Mi
+`,
+			want: []Change{
+				{Path: "metadata.labels", Desired: map[string]interface{}{"app": "web"}, Live: nil},
+				{Path: "spec.replicas", Desired: int64(2), Live: int64(1)},
+				{Path: "spec.template.spec.containers[0].image", Desired: "nginx:1.27", Live: "nginx:1.26"},
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			desired := mustParse(t, deployment)[0]
+			live := mustParse(t, tt.live)[0]
+
+			assert.ElementsMatch(t, tt.want, Compare(desired, live))
+		})
+	}
+}
+
+func TestResources(t *testing.T) {
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	services := schema.GroupVersionResource{Version: "v1", Resource: "services"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Service"}, meta.RESTScopeNamespace)
+
+	live := mustParse(t, deployment)[0]
+	live.SetNamespace("web")
+	_ = unstructured.SetNestedField(live.Object, int64(1), "spec", "replicas")
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		deployments: "DeploymentList",
+		services:    "ServiceList",
+	}, live)
+
+	desired := mustParse(t, deployment+`
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: web
+`)
+
+	got := Resources(context.Background(), client, mapper, desired, "web")
+
+	assert.Equal(t, []ResourceDiff{
+		{
+			Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "web", Name: "web",
+			Status:  StatusModified,
+			Changes: []Change{{Path: "spec.replicas", Desired: int64(2), Live: int64(1)}},
+		},
+		{Version: "v1", Kind: "Service", Namespace: "web", Name: "web", Status: StatusMissing},
+	}, got[:2])
+	assert.Equal(t, StatusUnknown, got[2].Status)
+	assert.NotEmpty(t, got[2].Error)
+}
@@ -0,0 +1,154 @@
+package freeze
+
+import (
+	"fmt"
+	"time"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/robfig/cron/v3"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/types"
+)
+
+// OverrideHeader carries the reason of an emergency change during a freeze window
+const OverrideHeader = "X-Freeze-Override"
+
+// the cron expressions of ArgoCD sync windows, without seconds
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+
+// Validate returns a validation error if the window is neither a schedule with a duration nor an absolute range
+func Validate(w *types.FreezeWindowSpec) error {
+	switch {
+	case w.Schedule != "" && (w.Start != nil || w.End != nil):
+		return apierr.Validation("a freeze window has either a schedule or a start and an end")
+	case w.Schedule != "":
+		if _, err := parser.Parse(w.Schedule); err != nil {
+			return apierr.Validation("invalid schedule '%s': %v", w.Schedule, err)
+		}
+
+		d, err := time.ParseDuration(w.Duration)
+		if err != nil || d <= 0 {
+			return apierr.Validation("invalid duration '%s', a schedule requires a positive duration like '48h'", w.Duration)
+		}
+	case w.Start != nil && w.End != nil:
+		if !w.End.After(*w.Start) {
+			return apierr.Validation("the end of a freeze window must be after its start")
+		}
+	default:
+		return apierr.Validation("a freeze window requires a schedule and a duration, or a start and an end")
+	}
+
+	if _, err := time.LoadLocation(w.TimeZone); err != nil {
+		return apierr.Validation("invalid time zone '%s'", w.TimeZone)
+	}
+
+	return nil
+}
+
+// Active returns true if the window is active at t
+func Active(w *types.FreezeWindow, t time.Time) bool {
+	if w.Schedule == "" {
+		return w.Start != nil && w.End != nil && !t.Before(*w.Start) && t.Before(*w.End)
+	}
+
+	schedule, err := parser.Parse(w.Schedule)
+	if err != nil {
+		return false
+	}
+	d, err := time.ParseDuration(w.Duration)
+	if err != nil {
+		return false
+	}
+	loc, err := time.LoadLocation(w.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	// the window is active if it started less than its duration ago
+	t = t.In(loc)
+	return !schedule.Next(t.Add(-d)).After(t)
+}
+
+// Blocking returns the first window active at t that applies to a change of the tenant on one of the clusters
+func Blocking(windows []types.FreezeWindow, tenant string, clusters []types.FreezeCluster, t time.Time) *types.FreezeWindow {
+	for i := range windows {
+		w := &windows[i]
+		if !Active(w, t) {
+			continue
+		}
+
+		for _, cluster := range clusters {
+			if w.AppliesTo(tenant, cluster.Name, cluster.Environment) {
+				return w
+			}
+		}
+	}
+
+	return nil
+}
+
+// SyncWindows returns the deny sync windows of the AppProject of the tenant, so that ArgoCD does not sync
+// automatically during the freeze windows. Manual syncs stay allowed for emergency changes.
+// ArgoCD windows recur, an absolute range is a yearly schedule that is dropped once it has ended
+func SyncWindows(windows []types.FreezeWindow, tenant string, clusters []types.FreezeCluster, now time.Time) argocdv1alpha1.SyncWindows {
+	var syncWindows argocdv1alpha1.SyncWindows
+	for i := range windows {
+		w := &windows[i]
+		if !w.ScopesTenant(tenant) {
+			continue
+		}
+
+		scope := []string{"*"}
+		if len(w.Environments) > 0 || len(w.Clusters) > 0 {
+			scope = scopedClusters(w, tenant, clusters)
+			if len(scope) == 0 {
+				continue
+			}
+		}
+
+		sw := &argocdv1alpha1.SyncWindow{
+			Kind:       "deny",
+			Schedule:   w.Schedule,
+			Duration:   w.Duration,
+			Clusters:   scope,
+			ManualSync: true,
+			TimeZone:   w.TimeZone,
+		}
+		if w.Schedule == "" {
+			if w.End == nil || w.Start == nil || !w.End.After(now) {
+				continue
+			}
+
+			start := w.Start.UTC()
+			sw.Schedule = fmt.Sprintf("%d %d %d %d *", start.Minute(), start.Hour(), start.Day(), int(start.Month()))
+			sw.Duration = w.End.Sub(*w.Start).Round(time.Minute).String()
+			sw.TimeZone = "UTC"
+		}
+		if sw.TimeZone == "" {
+			sw.TimeZone = "UTC"
+		}
+
+		syncWindows = append(syncWindows, sw)
+	}
+
+	return syncWindows
+}
+
+// scopedClusters returns the names and the servers of the clusters the window applies to,
+// ArgoCD matches either with the destination of an application
+func scopedClusters(w *types.FreezeWindow, tenant string, clusters []types.FreezeCluster) []string {
+	var scope []string
+	for _, cluster := range clusters {
+		if !w.AppliesTo(tenant, cluster.Name, cluster.Environment) {
+			continue
+		}
+
+		scope = append(scope, cluster.Name)
+		if cluster.Server != "" {
+			scope = append(scope, cluster.Server)
+		}
+	}
+
+	return scope
+}
@@ -0,0 +1,134 @@
+package freeze
+
+import (
+	"testing"
+	"time"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/types"
+)
+
+func date(month time.Month, day, hour int) time.Time {
+	return time.Date(2024, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		spec    types.FreezeWindowSpec
+		wantErr bool
+	}{
+		"a schedule with a duration": {
+			spec: types.FreezeWindowSpec{Schedule: "0 18 * * 5", Duration: "60h", TimeZone: "Europe/Paris"},
+		},
+		"an absolute range": {
+			spec: types.FreezeWindowSpec{Start: ptr(date(12, 20, 0)), End: ptr(date(12, 27, 0))},
+		},
+		"a schedule without a duration": {
+			spec:    types.FreezeWindowSpec{Schedule: "0 18 * * 5"},
+			wantErr: true,
+		},
+		"a schedule with seconds": {
+			spec:    types.FreezeWindowSpec{Schedule: "0 0 18 * * 5", Duration: "1h"},
+			wantErr: true,
+		},
+		"a schedule and a range": {
+			spec:    types.FreezeWindowSpec{Schedule: "0 18 * * 5", Duration: "1h", Start: ptr(date(12, 20, 0))},
+			wantErr: true,
+		},
+		"a range that ends before it starts": {
+			spec:    types.FreezeWindowSpec{Start: ptr(date(12, 27, 0)), End: ptr(date(12, 20, 0))},
+			wantErr: true,
+		},
+		"an unknown time zone": {
+			spec:    types.FreezeWindowSpec{Schedule: "0 18 * * 5", Duration: "1h", TimeZone: "Mars/Olympus"},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := Validate(&tt.spec)
+			if tt.wantErr {
+				assert.Equal(t, apierr.CodeValidation, apierr.CodeOf(err))
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestActive(t *testing.T) {
+	// from friday 18:00 to monday 06:00
+	weekend := &types.FreezeWindow{FreezeWindowSpec: types.FreezeWindowSpec{Schedule: "0 18 * * 5", Duration: "60h"}}
+	holidays := &types.FreezeWindow{FreezeWindowSpec: types.FreezeWindowSpec{Start: ptr(date(12, 20, 0)), End: ptr(date(12, 27, 0))}}
+	paris := &types.FreezeWindow{FreezeWindowSpec: types.FreezeWindowSpec{Schedule: "0 18 * * 5", Duration: "2h", TimeZone: "Europe/Paris"}}
+
+	// 2024-05-03 is a friday
+	assert.False(t, Active(weekend, date(5, 3, 17)))
+	assert.True(t, Active(weekend, date(5, 3, 18)))
+	assert.True(t, Active(weekend, date(5, 5, 12)))
+	assert.False(t, Active(weekend, date(5, 6, 6)))
+
+	assert.False(t, Active(holidays, date(12, 19, 23)))
+	assert.True(t, Active(holidays, date(12, 24, 12)))
+	assert.False(t, Active(holidays, date(12, 27, 0)))
+
+	// 18:00 in Paris is 16:00 UTC in summer time
+	assert.True(t, Active(paris, date(5, 3, 16)))
+	assert.False(t, Active(paris, date(5, 3, 18)))
+}
+
+func TestBlocking(t *testing.T) {
+	windows := []types.FreezeWindow{
+		{Name: "prd-weekend", FreezeWindowSpec: types.FreezeWindowSpec{Schedule: "0 18 * * 5", Duration: "60h", Environments: []string{"PRD"}}},
+		{Name: "tenant1-release", FreezeWindowSpec: types.FreezeWindowSpec{Start: ptr(date(5, 1, 0)), End: ptr(date(5, 2, 0)), Tenants: []string{"tenant1"}}},
+	}
+	sit := types.FreezeCluster{Name: "sit-1", Environment: "SIT"}
+	prd := types.FreezeCluster{Name: "prd-1", Environment: "PRD"}
+
+	assert.Nil(t, Blocking(windows, "tenant2", []types.FreezeCluster{sit}, date(5, 4, 12)))
+	assert.Equal(t, "prd-weekend", Blocking(windows, "tenant2", []types.FreezeCluster{sit, prd}, date(5, 4, 12)).Name)
+	assert.Nil(t, Blocking(windows, "tenant2", []types.FreezeCluster{prd}, date(5, 1, 12)))
+	assert.Equal(t, "tenant1-release", Blocking(windows, "tenant1", []types.FreezeCluster{sit}, date(5, 1, 12)).Name)
+}
+
+func TestSyncWindows(t *testing.T) {
+	windows := []types.FreezeWindow{
+		{Name: "prd-weekend", FreezeWindowSpec: types.FreezeWindowSpec{Schedule: "0 18 * * 5", Duration: "60h", TimeZone: "Europe/Paris", Environments: []string{"PRD"}}},
+		{Name: "holidays", FreezeWindowSpec: types.FreezeWindowSpec{Start: ptr(date(12, 20, 0)), End: ptr(date(12, 27, 0))}},
+		{Name: "tenant1-release", FreezeWindowSpec: types.FreezeWindowSpec{Schedule: "0 0 1 * *", Duration: "24h", Tenants: []string{"tenant1"}}},
+		{Name: "uat", FreezeWindowSpec: types.FreezeWindowSpec{Schedule: "0 0 * * *", Duration: "1h", Environments: []string{"UAT"}}},
+		{Name: "last-year", FreezeWindowSpec: types.FreezeWindowSpec{Start: ptr(date(1, 1, 0)), End: ptr(date(1, 2, 0))}},
+	}
+	clusters := []types.FreezeCluster{
+		{Name: "sit-1", Server: "https://sit-1:6443", Environment: "SIT"},
+		{Name: "prd-1", Server: "https://prd-1:6443", Environment: "PRD"},
+	}
+
+	got := SyncWindows(windows, "tenant2", clusters, date(5, 1, 0))
+	assert.Equal(t, argocdv1alpha1.SyncWindows{
+		{
+			Kind:       "deny",
+			Schedule:   "0 18 * * 5",
+			Duration:   "60h",
+			Clusters:   []string{"prd-1", "https://prd-1:6443"},
+			ManualSync: true,
+			TimeZone:   "Europe/Paris",
+		},
+		{
+			Kind:       "deny",
+			Schedule:   "0 0 20 12 *",
+			Duration:   "168h0m0s",
+			Clusters:   []string{"*"},
+			ManualSync: true,
+			TimeZone:   "UTC",
+		},
+	}, got)
+}
@@ -50,6 +50,36 @@ func (mr *MockRepositoryMockRecorder) CurrentBranch() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CurrentBranch", reflect.TypeOf((*MockRepository)(nil).CurrentBranch))
 }
 
+// Files mocks base method.
+func (m *MockRepository) Files(ctx context.Context, revision, path string) (map[string][]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Files", ctx, revision, path)
+	ret0, _ := ret[0].(map[string][]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Files indicates an expected call of Files.
+func (mr *MockRepositoryMockRecorder) Files(ctx, revision, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Files", reflect.TypeOf((*MockRepository)(nil).Files), ctx, revision, path)
+}
+
+// History mocks base method.
+func (m *MockRepository) History(ctx context.Context, path string, limit int) ([]git.Commit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "History", ctx, path, limit)
+	ret0, _ := ret[0].([]git.Commit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// History indicates an expected call of History.
+func (mr *MockRepositoryMockRecorder) History(ctx, path, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "History", reflect.TypeOf((*MockRepository)(nil).History), ctx, path, limit)
+}
+
 // Persist mocks base method.
 func (m *MockRepository) Persist(ctx context.Context, opts *git.PushOptions) (string, error) {
 	m.ctrl.T.Helper()
@@ -199,7 +199,7 @@ func (g *github) CreatePullRequest(ctx context.Context, opts *PullRequestOptions
 		Draft:               gh.Bool(false),
 	}
 
-	log.G().WithFields(log.Fields{
+	log.G(ctx).WithFields(log.Fields{
 		"owner": opts.Owner,
 		"repo":  opts.Repo,
 		"head":  head,
@@ -214,7 +214,7 @@ func (g *github) CreatePullRequest(ctx context.Context, opts *PullRequestOptions
 			opts.Owner, opts.Repo, head, opts.Base, err)
 	}
 
-	log.G().WithFields(log.Fields{
+	log.G(ctx).WithFields(log.Fields{
 		"pr": pr.GetHTMLURL(),
 	}).Debug("pull request created")
 
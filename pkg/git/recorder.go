@@ -0,0 +1,55 @@
+package git
+
+import (
+	"context"
+	"sync"
+)
+
+type (
+	persistRecorderKey struct{}
+
+	// PersistRecorder collects the commit SHAs and pull request URLs that are
+	// persisted with a context, so callers can report what a request changed.
+	// The revisions are also recorded by the recorder of the parent context
+	PersistRecorder struct {
+		mu        sync.Mutex
+		revisions []string
+		parent    *PersistRecorder
+	}
+)
+
+// WithPersistRecorder returns a context that records every successful Persist
+func WithPersistRecorder(ctx context.Context) (context.Context, *PersistRecorder) {
+	rec := &PersistRecorder{parent: PersistRecorderFrom(ctx)}
+	return context.WithValue(ctx, persistRecorderKey{}, rec), rec
+}
+
+// PersistRecorderFrom returns the recorder of ctx, or nil if ctx has none
+func PersistRecorderFrom(ctx context.Context) *PersistRecorder {
+	rec, _ := ctx.Value(persistRecorderKey{}).(*PersistRecorder)
+	return rec
+}
+
+// Revisions returns the commit SHAs or pull request URLs persisted so far
+func (r *PersistRecorder) Revisions() []string {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.revisions...)
+}
+
+func recordPersist(ctx context.Context, revision string) {
+	if revision == "" {
+		return
+	}
+
+	for rec := PersistRecorderFrom(ctx); rec != nil; rec = rec.parent {
+		rec.mu.Lock()
+		rec.revisions = append(rec.revisions, revision)
+		rec.mu.Unlock()
+	}
+}
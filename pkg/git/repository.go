@@ -8,12 +8,16 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/squidflow/service/pkg/apierr"
 	"github.com/squidflow/service/pkg/fs"
 	"github.com/squidflow/service/pkg/git/gogit"
 	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/metrics"
 	"github.com/squidflow/service/pkg/store"
+	"github.com/squidflow/service/pkg/tracing"
 	"github.com/squidflow/service/pkg/util"
 
 	billy "github.com/go-git/go-billy/v5"
@@ -22,12 +26,14 @@ import (
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/storage"
 	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 //go:generate mockgen -destination=./mocks/repository.go -package=mocks -source=./repository.go Repository
@@ -42,6 +48,21 @@ type (
 
 		// CurrentBranch returns the name of the current branch
 		CurrentBranch() (string, error)
+
+		// History returns the commits of the current branch that changed files under path, newest first
+		History(ctx context.Context, path string, limit int) ([]Commit, error)
+
+		// Files returns the content of the files under path at the revision, by their path in the repository
+		Files(ctx context.Context, revision, path string) (map[string][]byte, error)
+	}
+
+	// Commit is a commit of the repository history
+	Commit struct {
+		SHA       string
+		Author    string
+		Email     string
+		Message   string
+		Timestamp time.Time
 	}
 
 	AddFlagsOptions struct {
@@ -75,6 +96,9 @@ type (
 		AddGlobPattern string
 		CommitMsg      string
 		Progress       io.Writer
+		// Direct pushes the commit to the main branch whatever the gitops mode, for the state of the service
+		// that is read back right away, like change requests, which a pull request would leave unmerged
+		Direct bool
 	}
 
 	repo struct {
@@ -83,6 +107,8 @@ type (
 		progress     io.Writer
 		providerType string
 		repoURL      string
+		// deepened is set once the history of the shallow clone has been fetched
+		deepened atomic.Bool
 	}
 )
 
@@ -90,6 +116,9 @@ type (
 const (
 	pushRetries        = 3
 	failureBackoffTime = 3 * time.Second
+
+	// historyDepth is the number of commits fetched when the history of a shallow clone is needed
+	historyDepth = 1000
 )
 
 // Errors
@@ -230,7 +259,7 @@ func (o *CloneOptions) GetRepo(ctx context.Context) (Repository, fs.FS, error) {
 	}
 
 	_, orgRepo, _, _, _, _, _ := util.ParseGitUrl(o.Repo)
-	log.G().WithFields(log.Fields{
+	log.G(ctx).WithFields(log.Fields{
 		"key":        orgRepo,
 		"url":        o.url,
 		"repo":       o.Repo,
@@ -262,8 +291,12 @@ func (o *CloneOptions) GetRepo(ctx context.Context) (Repository, fs.FS, error) {
 	}
 
 	// Cache miss, perform clone
-	log.G().Infof("cloning git repository: %s", o.url)
-	newRepo, err := clone(ctx, o)
+	log.G(ctx).Infof("cloning git repository: %s", o.url)
+	cloneStart := time.Now()
+	cloneCtx, span := tracing.Start(ctx, "git.clone", attribute.String("repo", orgRepo))
+	newRepo, err := clone(cloneCtx, o)
+	tracing.End(span, err)
+	metrics.ObserveGit("clone", cloneStart, err)
 	if err != nil {
 		switch err {
 		case transport.ErrRepositoryNotFound:
@@ -271,7 +304,7 @@ func (o *CloneOptions) GetRepo(ctx context.Context) (Repository, fs.FS, error) {
 				return nil, nil, err
 			}
 
-			log.G().WithField("repo", o.Repo).Debug("repository was not found, trying to create it")
+			log.G(ctx).WithField("repo", o.Repo).Debug("repository was not found, trying to create it")
 			defaultBranch, err := createRepo(ctx, o)
 			if err != nil {
 				return nil, nil, fmt.Errorf("failed to create repository: %w", err)
@@ -289,7 +322,7 @@ func (o *CloneOptions) GetRepo(ctx context.Context) (Repository, fs.FS, error) {
 			}
 
 		default:
-			return nil, nil, err
+			return nil, nil, apierr.UpstreamGit(err, "failed to clone repository '%s'", orgRepo)
 		}
 	}
 
@@ -327,6 +360,77 @@ var validateRepoWritePermission = func(ctx context.Context, r *repo) error {
 	return nil
 }
 
+// remoteMain returns the reference of the main branch of the remote
+func (r *repo) remoteMain() (*plumbing.Reference, error) {
+	remote, err := r.Remote("origin")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get remote: %w", err)
+	}
+
+	refs, err := remote.List(&gg.ListOptions{
+		Auth: getAuth(r.auth),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote refs: %w", err)
+	}
+
+	for _, ref := range refs {
+		if ref.Name().String() == "refs/heads/main" {
+			return ref, nil
+		}
+	}
+
+	return nil, fmt.Errorf("could not find main branch in remote")
+}
+
+// pushToMain commits the changes of the worktree on top of the main branch of the remote and pushes it, in pull
+// request mode the worktree may be on the branch of the last pull request
+func (r *repo) pushToMain(ctx context.Context, opts *PushOptions) (string, error) {
+	mainRef, err := r.remoteMain()
+	if err != nil {
+		return "", err
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	// the commit is made on a new branch from the remote main, the local main may be behind it
+	branch := plumbing.NewBranchReferenceName(fmt.Sprintf("direct/%s", time.Now().Format("20060102-150405.000000000")))
+	err = w.Checkout(&gg.CheckoutOptions{
+		Hash:   mainRef.Hash(),
+		Branch: branch,
+		Keep:   true,
+		Create: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create new branch: %w", err)
+	}
+
+	h, err := r.commit(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+
+	cert, err := r.auth.GetCertificate()
+	if err != nil {
+		return "", fmt.Errorf("failed to get certificate: %w", err)
+	}
+
+	err = r.PushContext(ctx, &gg.PushOptions{
+		Auth:     getAuth(r.auth),
+		Progress: opts.Progress,
+		CABundle: cert,
+		RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:refs/heads/main", branch))},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to push the main branch: %w", err)
+	}
+
+	return h.String(), nil
+}
+
 // if opts.CreatePR is true, it will create a pull request to the main branch
 // if opts.CreatePR is false, it will append the commit to the main branch and push to remote
 func (r *repo) Persist(ctx context.Context, opts *PushOptions) (string, error) {
@@ -344,10 +448,31 @@ func (r *repo) Persist(ctx context.Context, opts *PushOptions) (string, error) {
 		return "", fmt.Errorf("failed reading git certificate file: %w", err)
 	}
 
-	switch viper.GetString("gitops.mode") {
-	case "pull_request":
+	mode := viper.GetString("gitops.mode")
+	switch {
+	case mode == "pull_request" && opts.Direct:
+		start := time.Now()
+		pushCtx, span := tracing.Start(ctx, "git.push", attribute.String("repo", r.repoURL))
+		h, err := r.pushToMain(pushCtx, opts)
+		tracing.End(span, err)
+		metrics.ObserveGit("push", start, err)
+		if err != nil {
+			return "", apierr.UpstreamGit(err, "failed to push to repository")
+		}
+		recordPersist(ctx, h)
+		return h, nil
+	case mode == "pull_request":
 		// create pull request to main branch
-		return r.createPullRequest(ctx, opts)
+		start := time.Now()
+		prCtx, span := tracing.Start(ctx, "git.pull_request", attribute.String("repo", r.repoURL))
+		pr, err := r.createPullRequest(prCtx, opts)
+		tracing.End(span, err)
+		metrics.ObserveGit("pull_request", start, err)
+		if err != nil {
+			return pr, apierr.UpstreamGit(err, "failed to create pull request")
+		}
+		recordPersist(ctx, pr)
+		return pr, nil
 	default:
 		// direct merge mode
 		h, err := r.commit(ctx, opts)
@@ -355,8 +480,10 @@ func (r *repo) Persist(ctx context.Context, opts *PushOptions) (string, error) {
 			return "", err
 		}
 
+		start := time.Now()
+		pushCtx, span := tracing.Start(ctx, "git.push", attribute.String("repo", r.repoURL))
 		for try := 0; try < pushRetries; try++ {
-			err = r.PushContext(ctx, &gg.PushOptions{
+			err = r.PushContext(pushCtx, &gg.PushOptions{
 				Auth:     getAuth(r.auth),
 				Progress: progress,
 				CABundle: cert,
@@ -365,14 +492,20 @@ func (r *repo) Persist(ctx context.Context, opts *PushOptions) (string, error) {
 				break
 			}
 
-			log.G().WithFields(log.Fields{
+			log.G(ctx).WithFields(log.Fields{
 				"retry": try,
 				"err":   err.Error(),
 			}).Warn("Failed to push to repository, trying again in 3 seconds...")
 
 			time.Sleep(failureBackoffTime)
 		}
-		return h.String(), err
+		tracing.End(span, err)
+		metrics.ObserveGit("push", start, err)
+		if err != nil {
+			return h.String(), apierr.UpstreamGit(err, "failed to push to repository")
+		}
+		recordPersist(ctx, h.String())
+		return h.String(), nil
 	}
 }
 
@@ -385,9 +518,148 @@ func (r *repo) CurrentBranch() (string, error) {
 	return ref.Name().Short(), nil
 }
 
-func (r *repo) commit(ctx context.Context, opts *PushOptions) (*plumbing.Hash, error) {
+func (r *repo) History(ctx context.Context, path string, limit int) ([]Commit, error) {
+	commits, truncated, err := r.history(path, limit)
+	if err != nil || !truncated || r.deepened.Load() {
+		return commits, err
+	}
+
+	// the history of the path goes past the shallow clone, fetch it once
+	if err := r.deepen(ctx); err != nil {
+		return nil, err
+	}
+
+	commits, _, err = r.history(path, limit)
+	return commits, err
+}
+
+// history returns the commits of the current branch that changed files under path, truncated is
+// true when the walk ended at the missing parent of the oldest commit of a shallow clone
+func (r *repo) history(path string, limit int) (commits []Commit, truncated bool, err error) {
+	iter, err := r.Repository.Log(&gg.LogOptions{
+		PathFilter: func(p string) bool {
+			return p == path || strings.HasPrefix(p, path+"/")
+		},
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read the history of '%s': %w", path, err)
+	}
+	defer iter.Close()
+
+	commits = []Commit{}
+	err = iter.ForEach(func(c *object.Commit) error {
+		if limit > 0 && len(commits) >= limit {
+			return storer.ErrStop
+		}
+
+		commits = append(commits, Commit{
+			SHA:       c.Hash.String(),
+			Author:    c.Author.Name,
+			Email:     c.Author.Email,
+			Message:   strings.TrimSpace(c.Message),
+			Timestamp: c.Author.When,
+		})
+
+		return nil
+	})
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return commits, true, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read the history of '%s': %w", path, err)
+	}
+
+	return commits, false, nil
+}
+
+func (r *repo) Files(ctx context.Context, revision, path string) (map[string][]byte, error) {
+	h, err := r.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		// the revision may be older than the shallow clone
+		if r.deepened.Load() {
+			return nil, apierr.NotFound("revision '%s' not found", revision)
+		}
+		if err := r.deepen(ctx); err != nil {
+			return nil, err
+		}
+
+		if h, err = r.ResolveRevision(plumbing.Revision(revision)); err != nil {
+			return nil, apierr.NotFound("revision '%s' not found", revision)
+		}
+	}
+
+	c, err := r.CommitObject(*h)
+	if err != nil {
+		return nil, apierr.NotFound("revision '%s' is not a commit", revision)
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the tree of revision '%s': %w", revision, err)
+	}
+
+	files := map[string][]byte{}
+	if f, err := tree.File(path); err == nil {
+		content, err := f.Contents()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%s' at revision '%s': %w", path, revision, err)
+		}
+
+		files[path] = []byte(content)
+		return files, nil
+	}
+
+	dir, err := tree.Tree(path)
+	if errors.Is(err, object.ErrDirectoryNotFound) {
+		return files, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s' at revision '%s': %w", path, revision, err)
+	}
+
+	err = dir.Files().ForEach(func(f *object.File) error {
+		content, err := f.Contents()
+		if err != nil {
+			return err
+		}
+
+		files[path+"/"+f.Name] = []byte(content)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s' at revision '%s': %w", path, revision, err)
+	}
+
+	return files, nil
+}
+
+// deepen fetches the history of a shallow clone, up to historyDepth commits. The clone is only
+// deepened once, later reads use the fetched history
+func (r *repo) deepen(ctx context.Context) error {
+	cert, err := r.auth.GetCertificate()
+	if err != nil {
+		return fmt.Errorf("failed reading git certificate file: %w", err)
+	}
+
+	err = r.FetchContext(ctx, &gg.FetchOptions{
+		Auth:     getAuth(r.auth),
+		Depth:    historyDepth,
+		CABundle: cert,
+	})
+	if err != nil && !errors.Is(err, gg.NoErrAlreadyUpToDate) && !errors.Is(err, gg.ErrRemoteNotFound) {
+		return apierr.UpstreamGit(err, "failed to fetch the repository history")
+	}
+
+	r.deepened.Store(true)
+	return nil
+}
+
+func (r *repo) commit(ctx context.Context, opts *PushOptions) (_ *plumbing.Hash, err error) {
 	var h plumbing.Hash
 
+	ctx, span := tracing.Start(ctx, "git.commit", attribute.String("repo", r.repoURL))
+	defer func() { tracing.End(span, err) }()
+
 	author, err := r.getAuthor(ctx)
 	if err != nil {
 		return nil, err
@@ -410,7 +682,7 @@ func (r *repo) commit(ctx context.Context, opts *PushOptions) (*plumbing.Hash, e
 		}
 	}
 
-	h, err = w.Commit(opts.CommitMsg, &gg.CommitOptions{
+	h, err = w.Commit(commitMessage(ctx, opts.CommitMsg), &gg.CommitOptions{
 		All:               true,
 		Author:            author,
 		AllowEmptyCommits: false,
@@ -760,7 +1032,7 @@ func (r *repo) initBranch(ctx context.Context, branchName string) error {
 		create = true
 	}
 
-	log.G().WithField("branch", b).Debug("checking out branch")
+	log.G(ctx).WithField("branch", b).Debug("checking out branch")
 
 	w, err := worktree(r)
 	if err != nil {
@@ -815,17 +1087,17 @@ func (r *repo) cloneSubmodules(ctx context.Context) error {
 		return fmt.Errorf("failed to get worktree: %w", err)
 	}
 
-	log.G().WithField("repo", r.repoURL).Debug("Cloning submodules of repository")
+	log.G(ctx).WithField("repo", r.repoURL).Debug("Cloning submodules of repository")
 
 	subs, err := w.Submodules()
 	if err != nil {
 		return fmt.Errorf("failed to get submodules: %w", err)
 	}
 
-	log.G().Infof("Found %d submodules", len(subs))
+	log.G(ctx).Infof("Found %d submodules", len(subs))
 
 	for _, sub := range subs {
-		log.G().Debugf("Cloning submodule: %s", sub.Config().Name)
+		log.G(ctx).Debugf("Cloning submodule: %s", sub.Config().Name)
 		if err := sub.Update(&gg.SubmoduleUpdateOptions{
 			Init:              true,
 			RecurseSubmodules: gg.DefaultSubmoduleRecursionDepth,
@@ -834,7 +1106,7 @@ func (r *repo) cloneSubmodules(ctx context.Context) error {
 			return fmt.Errorf("failed to update submodule %s: %w", sub.Config().Name, err)
 		}
 
-		log.G().Infof("Successfully cloned submodule: %s", sub.Config().Name)
+		log.G(ctx).Infof("Successfully cloned submodule: %s", sub.Config().Name)
 	}
 
 	return nil
@@ -842,28 +1114,9 @@ func (r *repo) cloneSubmodules(ctx context.Context) error {
 
 func (r *repo) createPullRequest(ctx context.Context, opts *PushOptions) (string, error) {
 	// 1. get reference of remote main branch
-	remote, err := r.Remote("origin")
-	if err != nil {
-		return "", fmt.Errorf("failed to get remote: %w", err)
-	}
-
-	refs, err := remote.List(&gg.ListOptions{
-		Auth: getAuth(r.auth),
-	})
+	mainRef, err := r.remoteMain()
 	if err != nil {
-		return "", fmt.Errorf("failed to list remote refs: %w", err)
-	}
-
-	var mainRef *plumbing.Reference
-	for _, ref := range refs {
-		if ref.Name().String() == "refs/heads/main" {
-			mainRef = ref
-			break
-		}
-	}
-
-	if mainRef == nil {
-		return "", fmt.Errorf("could not find main branch in remote")
+		return "", err
 	}
 
 	w, err := r.Worktree()
@@ -878,7 +1131,7 @@ func (r *repo) createPullRequest(ctx context.Context, opts *PushOptions) (string
 	}
 	newBranch := fmt.Sprintf("%s/%s", branchPrefix, time.Now().Format("20060102-150405"))
 
-	log.G().WithFields(log.Fields{
+	log.G(ctx).WithFields(log.Fields{
 		"branch": newBranch,
 		"base":   mainRef.Hash().String(),
 	}).Debug("creating new branch from remote main")
@@ -898,7 +1151,7 @@ func (r *repo) createPullRequest(ctx context.Context, opts *PushOptions) (string
 	if err != nil {
 		return "", err
 	}
-	log.G().WithField("hash", h).Debug("committed changes")
+	log.G(ctx).WithField("hash", h).Debug("committed changes")
 
 	// 4. push new branch to remote
 	cert, err := r.auth.GetCertificate()
@@ -926,7 +1179,7 @@ func (r *repo) createPullRequest(ctx context.Context, opts *PushOptions) (string
 	}
 
 	owner, repo := split[0], split[1]
-	log.G().WithFields(log.Fields{
+	log.G(ctx).WithFields(log.Fields{
 		"owner": owner,
 		"repo":  repo,
 		"head":  newBranch,
@@ -940,11 +1193,12 @@ func (r *repo) createPullRequest(ctx context.Context, opts *PushOptions) (string
 	}
 
 	pr, err := provider.CreatePullRequest(ctx, &PullRequestOptions{
-		Owner: owner,
-		Repo:  repo,
-		Head:  newBranch,
-		Base:  "main",
-		Title: opts.CommitMsg,
+		Owner:       owner,
+		Repo:        repo,
+		Head:        newBranch,
+		Base:        "main",
+		Title:       opts.CommitMsg,
+		Description: strings.Join(trailersOf(ctx), "\n"),
 	})
 
 	return pr, err
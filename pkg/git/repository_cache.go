@@ -11,6 +11,7 @@ import (
 	"github.com/squidflow/service/pkg/fs"
 	"github.com/squidflow/service/pkg/git/gogit"
 	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/metrics"
 )
 
 type repositoryCache struct {
@@ -50,6 +51,15 @@ func (c *repositoryCache) Get(key string, pull bool) (gogit.Repository, fs.FS, b
 	entry, exists := c.cache[key]
 	c.mu.RUnlock()
 
+	hit := false
+	defer func() {
+		if hit {
+			metrics.RepoCacheRequests.WithLabelValues("hit").Inc()
+		} else {
+			metrics.RepoCacheRequests.WithLabelValues("miss").Inc()
+		}
+	}()
+
 	if !exists {
 		log.G().WithField("key", key).Debug("cache miss - entry not found")
 		return nil, nil, false
@@ -59,6 +69,7 @@ func (c *repositoryCache) Get(key string, pull bool) (gogit.Repository, fs.FS, b
 		c.mu.Lock()
 		delete(c.cache, key)
 		c.mu.Unlock()
+		metrics.RepoCacheEvictions.WithLabelValues("expired").Inc()
 		log.G().WithFields(log.Fields{
 			"key": key,
 			"age": time.Since(entry.lastUsed),
@@ -81,10 +92,16 @@ func (c *repositoryCache) Get(key string, pull bool) (gogit.Repository, fs.FS, b
 		}
 
 		// Try normal pull first
+		pullStart := time.Now()
 		err = w.Pull(&gg.PullOptions{
 			RemoteName: "origin",
 			Force:      true,
 		})
+		if err == gg.NoErrAlreadyUpToDate {
+			metrics.ObserveGit("pull", pullStart, nil)
+		} else {
+			metrics.ObserveGit("pull", pullStart, err)
+		}
 
 		if err != nil {
 			if err == gg.NoErrAlreadyUpToDate {
@@ -158,6 +175,7 @@ func (c *repositoryCache) Get(key string, pull bool) (gogit.Repository, fs.FS, b
 		"key":       key,
 		"cache_hit": true,
 	}).Debug("cache hit")
+	hit = true
 
 	return entry.repo, filesystem, true
 }
@@ -195,6 +213,7 @@ func (c *repositoryCache) Set(key string, repo gogit.Repository, filesystem bill
 			"age":         time.Since(oldestTime),
 		}).Debug("Evicting cache entry")
 		delete(c.cache, oldestKey)
+		metrics.RepoCacheEvictions.WithLabelValues("capacity").Inc()
 	}
 
 	c.cache[key] = &repositoryCacheEntry{
@@ -223,6 +242,7 @@ func (c *repositoryCache) cleanup() {
 					"age": now.Sub(entry.lastUsed),
 				}).Debug("Removing expired cache entry")
 				delete(c.cache, key)
+				metrics.RepoCacheEvictions.WithLabelValues("expired").Inc()
 			}
 		}
 		afterCount := len(c.cache)
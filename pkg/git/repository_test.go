@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/squidflow/service/pkg/apierr"
 	"github.com/squidflow/service/pkg/fs"
 	"github.com/squidflow/service/pkg/git/gogit"
 	"github.com/squidflow/service/pkg/git/gogit/mocks"
@@ -17,9 +18,11 @@ import (
 	gg "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/golang/mock/gomock"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -664,7 +667,8 @@ func TestGetRepo(t *testing.T) {
 			assertFn: func(t *testing.T, r Repository, f fs.FS, e error) {
 				assert.Nil(t, r)
 				assert.Nil(t, f)
-				assert.EqualError(t, e, "some error")
+				assert.EqualError(t, e, "failed to clone repository 'owner/name2': some error")
+				assert.Equal(t, apierr.CodeUpstreamGit, apierr.CodeOf(e))
 			},
 		},
 		"Should fail when repo not found": {
@@ -903,15 +907,23 @@ func Test_repo_Persist(t *testing.T) {
 
 			tt.beforeFn(mockRepo, mockWt)
 
-			revision, err := r.Persist(context.Background(), tt.opts)
+			// a nested recorder also records to the recorder of its parent context
+			ctx, outer := WithPersistRecorder(context.Background())
+			ctx, rec := WithPersistRecorder(ctx)
+			revision, err := r.Persist(ctx, tt.opts)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
-			if !tt.wantErr {
-				assert.Equal(t, tt.retRevision, revision)
+			if tt.wantErr {
+				assert.Empty(t, rec.Revisions())
+				return
 			}
+
+			assert.Equal(t, tt.retRevision, revision)
+			assert.Equal(t, []string{tt.retRevision}, rec.Revisions())
+			assert.Equal(t, []string{tt.retRevision}, outer.Revisions())
 		})
 	}
 }
@@ -1398,6 +1410,7 @@ func Test_createRepo(t *testing.T) {
 func Test_repo_commit(t *testing.T) {
 	tests := map[string]struct {
 		branchName string
+		trailers   []string
 		wantErr    string
 		retErr     error
 		beforeFn   func(r *mocks.MockRepository, wt *mocks.MockWorktree, p *mockProvider)
@@ -1427,6 +1440,31 @@ func Test_repo_commit(t *testing.T) {
 					Return(nil)
 			},
 		},
+		"Success - with trailers": {
+			trailers: []string{"Change-Request: 3f2a", "Approved-by: alice"},
+			beforeFn: func(r *mocks.MockRepository, wt *mocks.MockWorktree, _ *mockProvider) {
+				hash := plumbing.NewHash("3992c4")
+				config := &config.Config{
+					User: struct {
+						Name  string
+						Email string
+					}{
+						Name:  "user",
+						Email: "email",
+					},
+				}
+
+				r.EXPECT().ConfigScoped(gomock.Any()).
+					Times(1).
+					Return(config, nil)
+				wt.EXPECT().Commit("test\n\nChange-Request: 3f2a\nApproved-by: alice", gomock.Any()).
+					Times(1).
+					Return(hash, nil)
+				wt.EXPECT().AddGlob(gomock.Any()).
+					Times(1).
+					Return(nil)
+			},
+		},
 		"Success - author info from provider": {
 			branchName: "",
 			beforeFn: func(r *mocks.MockRepository, wt *mocks.MockWorktree, _ *mockProvider) {
@@ -1581,7 +1619,7 @@ func Test_repo_commit(t *testing.T) {
 
 			tt.beforeFn(mockRepo, mockWt, mockProvider)
 
-			got, err := r.commit(context.Background(), &PushOptions{CommitMsg: "test"})
+			got, err := r.commit(WithTrailers(context.Background(), tt.trailers...), &PushOptions{CommitMsg: "test"})
 
 			if err != nil || tt.wantErr != "" {
 				assert.EqualError(t, err, tt.wantErr)
@@ -1686,3 +1724,123 @@ func Test_validateRepoWritePermission(t *testing.T) {
 		})
 	}
 }
+
+// newHistoryRepo returns an in-memory repository with a commit for each of the file writes
+func newHistoryRepo(t *testing.T, writes ...map[string]string) (*repo, []string) {
+	wtfs := memfs.New()
+	r, err := gg.Init(memory.NewStorage(), wtfs)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	wt, _ := r.Worktree()
+	shas := []string{}
+	for i, files := range writes {
+		for name, content := range files {
+			f, _ := wtfs.Create(name)
+			_, _ = f.Write([]byte(content))
+			_ = f.Close()
+		}
+		_ = wt.AddGlob(".")
+		h, err := wt.Commit(fmt.Sprintf("commit %d", i), &gg.CommitOptions{
+			Author: &object.Signature{Name: "user", Email: "user@email.com"},
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		shas = append(shas, h.String())
+	}
+
+	return &repo{Repository: r}, shas
+}
+
+func Test_repo_History(t *testing.T) {
+	r, shas := newHistoryRepo(t,
+		map[string]string{"apps/app1/config.json": "v1"},
+		map[string]string{"apps/app10/config.json": "v1"},
+		map[string]string{"apps/app1/config.json": "v2"},
+	)
+
+	tests := map[string]struct {
+		path  string
+		limit int
+		want  []string
+	}{
+		"commits of the path, newest first": {
+			path: "apps/app1",
+			want: []string{shas[2], shas[0]},
+		},
+		"limit": {
+			path:  "apps/app1",
+			limit: 1,
+			want:  []string{shas[2]},
+		},
+		"no commits": {
+			path: "apps/app2",
+			want: []string{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			commits, err := r.History(context.Background(), tt.path, tt.limit)
+			assert.NoError(t, err)
+
+			got := []string{}
+			for _, c := range commits {
+				got = append(got, c.SHA)
+				assert.Equal(t, "user", c.Author)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_repo_Files(t *testing.T) {
+	r, shas := newHistoryRepo(t,
+		map[string]string{"apps/app1/config.json": "v1", "apps/app1/base/kustomization.yaml": "base"},
+		map[string]string{"apps/app1/config.json": "v2"},
+	)
+
+	tests := map[string]struct {
+		revision string
+		path     string
+		want     map[string][]byte
+		wantCode apierr.Code
+	}{
+		"directory": {
+			revision: shas[0],
+			path:     "apps/app1",
+			want: map[string][]byte{
+				"apps/app1/config.json":             []byte("v1"),
+				"apps/app1/base/kustomization.yaml": []byte("base"),
+			},
+		},
+		"file": {
+			revision: shas[1],
+			path:     "apps/app1/config.json",
+			want:     map[string][]byte{"apps/app1/config.json": []byte("v2")},
+		},
+		"missing path": {
+			revision: shas[1],
+			path:     "apps/app2",
+			want:     map[string][]byte{},
+		},
+		"unknown revision": {
+			revision: "0000000000000000000000000000000000000001",
+			path:     "apps/app1",
+			wantCode: apierr.CodeNotFound,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := r.Files(context.Background(), tt.revision, tt.path)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apierr.CodeOf(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package git
+
+import (
+	"context"
+	"strings"
+)
+
+type trailersKey struct{}
+
+// WithTrailers returns a context whose persisted commits carry the trailers, like `Approved-by: alice`,
+// at the end of their message. In pull request mode they are the description of the pull request
+func WithTrailers(ctx context.Context, trailers ...string) context.Context {
+	trailers = append(append([]string(nil), trailersOf(ctx)...), trailers...)
+	return context.WithValue(ctx, trailersKey{}, trailers)
+}
+
+func trailersOf(ctx context.Context) []string {
+	trailers, _ := ctx.Value(trailersKey{}).([]string)
+	return trailers
+}
+
+// commitMessage returns the commit message with the trailers of ctx
+func commitMessage(ctx context.Context, msg string) string {
+	trailers := trailersOf(ctx)
+	if len(trailers) == 0 {
+		return msg
+	}
+
+	return msg + "\n\n" + strings.Join(trailers, "\n")
+}
@@ -1,24 +1,212 @@
 package handler
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/middleware"
+	"github.com/squidflow/service/pkg/operation"
+	"github.com/squidflow/service/pkg/rbac"
+	repowriter "github.com/squidflow/service/pkg/repo/writer"
+	"github.com/squidflow/service/pkg/types"
 )
 
-type AppCodeResponse struct {
-	Success  bool     `json:"success"`
-	Message  string   `json:"message"`
-	AppCodes []string `json:"appCodes"`
+// AppCodeCreate registers an appcode in the meta repo
+func AppCodeCreate(c *gin.Context) {
+	var req types.AppCodeCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Write(c, apierr.Validation("Invalid request: %v", err))
+		return
+	}
+
+	middleware.SetAuditTarget(c, req.Name)
+
+	if errs := validation.IsDNS1123Label(req.Name); len(errs) > 0 {
+		apierr.Write(c, apierr.Validation("invalid appcode '%s': %s", req.Name, strings.Join(errs, ", ")))
+		return
+	}
+
+	code := &types.AppCode{
+		Name:        req.Name,
+		Description: req.Description,
+		OwnerTeam:   req.OwnerTeam,
+		CostCenter:  req.CostCenter,
+		Tenants:     req.Tenants,
+		Active:      true,
+		CreatedBy:   c.GetString(middleware.UserNameKey),
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	log.G(c.Request.Context()).WithFields(log.Fields{
+		"appcode":     code.Name,
+		"owner_team":  code.OwnerTeam,
+		"cost_center": code.CostCenter,
+		"tenants":     code.Tenants,
+	}).Info("create appcode")
+
+	executeMetaOperation(c, func(ctx context.Context) (interface{}, error) {
+		operation.Report(ctx, "writing appcode '%s' to the meta repo", code.Name)
+		if err := repowriter.MetaRepo().AppCodeCreate(ctx, code); err != nil {
+			return nil, fmt.Errorf("failed to create appcode: %w", err)
+		}
+
+		return types.AppCodeResponse{
+			Success: true,
+			Message: fmt.Sprintf("appcode '%s' created successfully", code.Name),
+			Item:    *code,
+		}, nil
+	}, http.StatusCreated, apierr.CodeInternal)
 }
 
-// AppCodeList handles the request to list app codes
+// AppCodeList returns the appcodes visible to the tenant of the request,
+// callers allowed to update appcodes see every appcode
+// query: limit, continue, sort (name, created_at), prefix, active, owner_team, cost_center
 func AppCodeList(c *gin.Context) {
-	appCodes := []string{"0001", "0002", "0003"}
-	c.JSON(http.StatusOK, AppCodeResponse{
+	q, err := parseListQuery(c, "name", appCodeSortFields)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	tenant := c.GetString(middleware.TenantKey)
+	all := middleware.Allowed(c, tenant, rbac.ResourceAppCodes, rbac.VerbUpdate)
+
+	codes, err := repowriter.MetaRepo().AppCodeList(c.Request.Context())
+	if err != nil {
+		apierr.Write(c, fmt.Errorf("failed to list appcodes: %w", err))
+		return
+	}
+
+	matched := make([]types.AppCode, 0, len(codes))
+	for _, code := range codes {
+		if (!all && !code.VisibleTo(tenant)) ||
+			!strings.HasPrefix(code.Name, q.Prefix) ||
+			!matchQuery(c, "active", strconv.FormatBool(code.Active)) ||
+			!matchQuery(c, "owner_team", code.OwnerTeam) ||
+			!matchQuery(c, "cost_center", code.CostCenter) {
+			continue
+		}
+
+		matched = append(matched, code)
+	}
+	sortItems(matched, q, appCodeSortFields)
+	page, next := paginate(matched, q)
+
+	names := make([]string, 0, len(page))
+	for _, code := range page {
+		names = append(names, code.Name)
+	}
+
+	c.JSON(http.StatusOK, types.AppCodeListResponse{
 		Success:  true,
 		Message:  "App codes listed successfully",
-		AppCodes: appCodes,
+		Total:    len(matched),
+		Items:    page,
+		AppCodes: names,
+		Continue: next,
+	})
+}
+
+var appCodeSortFields = map[string]compareFunc[types.AppCode]{
+	"name": func(a, b types.AppCode) int {
+		return strings.Compare(a.Name, b.Name)
+	},
+	"created_at": func(a, b types.AppCode) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	},
+}
+
+// AppCodeGet returns an appcode, appcodes not visible to the tenant of the request are not found
+func AppCodeGet(c *gin.Context) {
+	name := c.Param("name")
+	tenant := c.GetString(middleware.TenantKey)
+
+	code, err := repowriter.MetaRepo().AppCodeGet(c.Request.Context(), name)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	if !code.VisibleTo(tenant) && !middleware.Allowed(c, tenant, rbac.ResourceAppCodes, rbac.VerbUpdate) {
+		apierr.Write(c, apierr.NotFound("appcode '%s' not found", name))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.AppCodeResponse{
+		Success: true,
+		Message: "App code retrieved successfully",
+		Item:    *code,
 	})
-	return
+}
+
+// AppCodeUpdate changes the owner team, cost center, description, visibility or the state of an appcode
+func AppCodeUpdate(c *gin.Context) {
+	var req types.AppCodeUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Write(c, apierr.Validation("Invalid request: %v", err))
+		return
+	}
+
+	updateAppCode(c, &req)
+}
+
+// AppCodeDeactivate deactivates an appcode, existing applications are kept but no application can be created with it
+func AppCodeDeactivate(c *gin.Context) {
+	active := false
+	updateAppCode(c, &types.AppCodeUpdateRequest{Active: &active})
+}
+
+func updateAppCode(c *gin.Context, req *types.AppCodeUpdateRequest) {
+	name := c.Param("name")
+	middleware.SetAuditTarget(c, name)
+
+	if (req.OwnerTeam != nil && *req.OwnerTeam == "") || (req.CostCenter != nil && *req.CostCenter == "") {
+		apierr.Write(c, apierr.Validation("owner_team and cost_center can not be empty"))
+		return
+	}
+	req.UpdatedBy = c.GetString(middleware.UserNameKey)
+
+	executeMetaOperation(c, func(ctx context.Context) (interface{}, error) {
+		operation.Report(ctx, "writing appcode '%s' to the meta repo", name)
+		code, err := repowriter.MetaRepo().AppCodeUpdate(ctx, name, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update appcode: %w", err)
+		}
+
+		return types.AppCodeResponse{
+			Success: true,
+			Message: fmt.Sprintf("appcode '%s' updated successfully", name),
+			Item:    *code,
+		}, nil
+	}, http.StatusOK, apierr.CodeInternal)
+}
+
+// validateAppCode returns a validation error if the appcode is not registered, inactive or not visible to the tenant
+func validateAppCode(ctx context.Context, tenant, name string) error {
+	code, err := repowriter.MetaRepo().AppCodeGet(ctx, name)
+	if apierr.CodeOf(err) == apierr.CodeNotFound {
+		return apierr.Validation("appcode '%s' is not registered", name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get appcode '%s': %w", name, err)
+	}
+
+	if !code.VisibleTo(tenant) {
+		return apierr.Validation("appcode '%s' is not registered", name)
+	}
+
+	if !code.Active {
+		return apierr.Validation("appcode '%s' is inactive", name)
+	}
+
+	return nil
 }
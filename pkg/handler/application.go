@@ -3,22 +3,33 @@ package handler
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	argocdv1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	argocdv1alpha1client "github.com/argoproj/argo-cd/v2/pkg/client/clientset/versioned/typed/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	synccommon "github.com/argoproj/gitops-engine/pkg/sync/common"
 	"github.com/gin-gonic/gin"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/spf13/viper"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
 
+	"github.com/squidflow/service/pkg/apierr"
 	"github.com/squidflow/service/pkg/application"
+	"github.com/squidflow/service/pkg/appstatus"
 	"github.com/squidflow/service/pkg/argocd"
 	"github.com/squidflow/service/pkg/fs"
 	"github.com/squidflow/service/pkg/git"
 	"github.com/squidflow/service/pkg/kube"
 	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/metrics"
 	"github.com/squidflow/service/pkg/middleware"
+	"github.com/squidflow/service/pkg/operation"
 	repowriter "github.com/squidflow/service/pkg/repo/writer"
 	"github.com/squidflow/service/pkg/source"
 	"github.com/squidflow/service/pkg/store"
@@ -28,27 +39,68 @@ import (
 func ApplicationCreate(c *gin.Context) {
 	username := c.GetString(middleware.UserNameKey)
 	tenant := c.GetString(middleware.TenantKey)
-	log.G().WithFields(log.Fields{
+	log.G(c.Request.Context()).WithFields(log.Fields{
 		"username": username,
 		"tenant":   tenant,
 	}).Debug("create argo application")
 
 	var createReq types.ApplicationCreateRequest
 	if err := c.BindJSON(&createReq); err != nil {
-		c.JSON(400, gin.H{
-			"error": "Invalid request body: " + err.Error(),
-		})
+		apierr.Write(c, apierr.Validation("Invalid request body: %v", err))
 		return
 	}
 
+	middleware.SetAuditTarget(c, createReq.ApplicationInstantiation.ApplicationName)
+
 	if tenant != createReq.ApplicationInstantiation.TenantName {
-		c.JSON(400, gin.H{
-			"error": "ApplicationInstantiation field tenant in request body does not match tenant in authorization header",
-		})
+		apierr.Write(c, apierr.Validation("ApplicationInstantiation field tenant in request body does not match tenant in authorization header"))
 		return
 	}
 
+	if err := validateAppCode(c.Request.Context(), tenant, createReq.ApplicationInstantiation.AppCode); err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	targets, err := resolveAppTargets(c.Request.Context(), createReq.ApplicationTarget)
+	if err != nil {
+		apierr.Write(c, apierr.Validation("invalid application target: %v", err))
+		return
+	}
+
+	if err := validateIngresses(createReq.ApplicationInstantiation.Ingress); err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	if err := validateExternalSecret(&createReq.ApplicationInstantiation.Security.ExternalSecret); err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	if !createReq.IsDryRun {
+		clusters := func(context.Context) ([]string, error) {
+			return targetClusters(targets), nil
+		}
+		if err := checkFreeze(c, clusters); err != nil {
+			apierr.Write(c, err)
+			return
+		}
+
+		if requestApproval(c, types.ActionTypeCreate, createReq.ApplicationInstantiation.ApplicationName, clusters, &createReq) {
+			return
+		}
+	}
+
+	executeOperation(c, func(ctx context.Context) (interface{}, error) {
+		return createApplication(ctx, tenant, username, &createReq, targets)
+	}, 201, apierr.CodeValidation)
+}
+
+// createApplication clones the application source, and writes the application to the gitops repo of the tenant
+func createApplication(ctx context.Context, tenant, username string, createReq *types.ApplicationCreateRequest, targets []types.ApplicationTarget) (*types.ApplicationCreatedResp, error) {
 	// check the application source is valid add it to cache
+	operation.Report(ctx, "cloning application source %s", createReq.ApplicationSource.Repo)
 	appCloneOpts := &git.CloneOptions{
 		Repo: application.BuildKustomizeResourceRef(application.ApplicationSourceOption{
 			Repo:           createReq.ApplicationSource.Repo,
@@ -60,10 +112,9 @@ func ApplicationCreate(c *gin.Context) {
 		Submodules:    true,
 	}
 	appCloneOpts.Parse()
-	_, appfs, err := appCloneOpts.GetRepo(context.Background())
+	_, appfs, err := appCloneOpts.GetRepo(ctx)
 	if err != nil {
-		c.JSON(400, gin.H{"error": fmt.Sprintf("failed to clone application source repository: %v", err)})
-		return
+		return nil, fmt.Errorf("failed to clone application source repository: %w", err)
 	}
 
 	appSource, err := source.NewAppSource(
@@ -72,9 +123,8 @@ func ApplicationCreate(c *gin.Context) {
 		createReq.ApplicationSource.ApplicationSpecifier.HelmManifestPath,
 	)
 	if err != nil {
-		log.G().WithError(err).Error("failed to create app source")
-		c.JSON(400, gin.H{"error": fmt.Sprintf("failed to create app source: %v", err)})
-		return
+		log.G(ctx).WithError(err).Error("failed to create app source")
+		return nil, fmt.Errorf("failed to create app source: %w", err)
 	}
 
 	// Normal application creation flow
@@ -88,7 +138,8 @@ func ApplicationCreate(c *gin.Context) {
 				TargetRevision: createReq.ApplicationSource.TargetRevision,
 			}),
 			InstallationMode: application.InstallModeType(createReq.ApplicationInstantiation.InstallationMode),
-			DestServer:       "https://kubernetes.default.svc",
+			DestServer:       targets[0].Server,
+			DestNamespace:    targets[0].Namespace,
 			Annotations: map[string]string{
 				argocd.AnnotationKeyEnvironment: username,
 				argocd.AnnotationKeyTenant:      tenant,
@@ -100,20 +151,40 @@ func ApplicationCreate(c *gin.Context) {
 		ProjectName: createReq.ApplicationInstantiation.TenantName,
 		KubeFactory: kube.NewFactory(),
 		DryRun:      createReq.IsDryRun,
+		Targets:     targets,
+		Security:    createReq.ApplicationInstantiation.Security,
+		Source:      createReq.ApplicationSource,
+	}
+
+	// flattened manifests are rendered with the env of the cluster of each target
+	if !createReq.IsDryRun {
+		envs, err := clusterEnvs(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the environments of the clusters: %w", err)
+		}
+		opt.Envs = envs
+	}
+
+	if ingresses := createReq.ApplicationInstantiation.Ingress; len(ingresses) > 0 && !createReq.IsDryRun {
+		operation.Report(ctx, "detecting the ingress classes of clusters %v", targetClusters(targets))
+		classes, err := resolveIngressClasses(ctx, tenant, opt.AppOpts.AppName, targetClusters(targets), ingresses)
+		if err != nil {
+			return nil, err
+		}
+		opt.Ingress, opt.IngressClasses = ingresses, classes
 	}
 
-	log.G().WithFields(log.Fields{
+	log.G(ctx).WithFields(log.Fields{
 		"appOpts": opt.AppOpts,
 	}).Debug("create application options: ")
 
-	// TODO: support multiple clusters
-	createResp, err := repowriter.TenantRepo(tenant).RunAppCreate(context.Background(), &opt)
+	operation.Report(ctx, "writing application '%s' to the gitops repo", opt.AppOpts.AppName)
+	createResp, err := repowriter.TenantRepo(tenant).RunAppCreate(ctx, &opt)
 	if err != nil {
-		c.JSON(400, gin.H{"error": fmt.Sprintf("failed to create application in cluster %s: %v", opt.AppOpts.DestServer, err)})
-		return
+		return nil, fmt.Errorf("failed to create application: %w", err)
 	}
 
-	c.JSON(201, createResp)
+	return createResp, nil
 }
 
 func ApplicationDelete(c *gin.Context) {
@@ -121,49 +192,78 @@ func ApplicationDelete(c *gin.Context) {
 	tenant := c.GetString(middleware.TenantKey)
 	appName := c.Param("name")
 
-	log.G().WithFields(log.Fields{
+	log.G(c.Request.Context()).WithFields(log.Fields{
 		"username": username,
 		"tenant":   tenant,
 		"appName":  appName,
 	}).Debug("delete argo application")
 
-	// 1. delete from gitops repo first
-	if err := repowriter.TenantRepo(tenant).RunAppDelete(context.Background(), appName); err != nil {
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to delete application: %v", err)})
+	if err := checkFreeze(c, appClusters(tenant, appName)); err != nil {
+		apierr.Write(c, err)
 		return
 	}
 
+	if requestApproval(c, types.ActionTypeDelete, appName, appClusters(tenant, appName), nil) {
+		return
+	}
+
+	executeOperation(c, func(ctx context.Context) (interface{}, error) {
+		return nil, deleteApplication(ctx, tenant, appName)
+	}, 204, apierr.CodeInternal)
+}
+
+// deleteApplication deletes the application from the gitops repo of the tenant, and the argocd applications of all its targets
+func deleteApplication(ctx context.Context, tenant, appName string) error {
+	// the argocd applications of all the targets
+	applicationNames := []string{fmt.Sprintf("%s-%s", tenant, appName)}
+	if app, err := repowriter.TenantRepo(tenant).RunAppGet(ctx, appName); err == nil {
+		applicationNames = applicationNames[:0]
+		for _, target := range app.ApplicationTarget {
+			applicationNames = append(applicationNames, target.ArgoApplication)
+		}
+	}
+
+	// 1. delete from gitops repo first
+	operation.Report(ctx, "deleting application '%s' from the gitops repo", appName)
+	if err := repowriter.TenantRepo(tenant).RunAppDelete(ctx, appName); err != nil {
+		return fmt.Errorf("Failed to delete application: %w", err)
+	}
+
 	// 2. delete from kubernetes
 	// pull request mode, do not delete from kubernetes
 	if viper.GetString("gitops.mode") != "pull_request" {
-		go func(projectName string, appName string) {
-			argoClient, err := kube.NewArgoCdClient()
-			if err != nil {
-				log.G().WithFields(log.Fields{
+		operation.Report(ctx, "deleting argocd applications %v", applicationNames)
+		go deleteArgoApplications(context.WithoutCancel(ctx), tenant, appName, applicationNames)
+	}
+
+	return nil
+}
+
+func deleteArgoApplications(ctx context.Context, projectName string, appName string, applicationNames []string) {
+	argoClient, err := kube.NewArgoCdClient()
+	if err != nil {
+		log.G(ctx).WithFields(log.Fields{
+			"projectName": projectName,
+			"appName":     appName,
+		}).Warn("delete application failed to create ArgoCD client")
+		return
+	}
+	for _, applicationName := range applicationNames {
+		err = argoClient.Applications(store.Default.ArgoCDNamespace).Delete(ctx, applicationName, metav1.DeleteOptions{})
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				log.G(ctx).WithFields(log.Fields{
 					"projectName": projectName,
-					"appName":     appName,
-				}).Warn("delete application failed to create ArgoCD client")
-				return
-			}
-			applicationName := fmt.Sprintf("%s-%s", projectName, appName)
-			err = argoClient.Applications(store.Default.ArgoCDNamespace).Delete(context.Background(), applicationName, metav1.DeleteOptions{})
-			if err != nil {
-				if k8serrors.IsNotFound(err) {
-					log.G().WithFields(log.Fields{
-						"projectName": projectName,
-						"appName":     appName,
-					}).Warn("delete application handler: application not found")
-				} else {
-					log.G().WithFields(log.Fields{
-						"projectName": projectName,
-						"appName":     appName,
-					}).Warn("delete application handler: failed to delete application")
-				}
+					"appName":     applicationName,
+				}).Warn("delete application handler: application not found")
+			} else {
+				log.G(ctx).WithFields(log.Fields{
+					"projectName": projectName,
+					"appName":     applicationName,
+				}).Warn("delete application handler: failed to delete application")
 			}
-		}(tenant, appName)
+		}
 	}
-
-	c.JSON(204, nil)
 }
 
 func ApplicationGet(c *gin.Context) {
@@ -171,118 +271,218 @@ func ApplicationGet(c *gin.Context) {
 	username := c.GetString(middleware.UserNameKey)
 	appName := c.Param("name")
 
-	log.G().Infof("tenant: %s, username: %s, appName: %s", tenant, username, appName)
+	log.G(c.Request.Context()).Infof("tenant: %s, username: %s, appName: %s", tenant, username, appName)
 
 	argoClient, err := kube.NewArgoCdClient()
 	if err != nil {
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to create ArgoCD client: %v", err)})
+		apierr.Write(c, fmt.Errorf("Failed to create ArgoCD client: %w", err))
 		return
 	}
 
-	app, err := repowriter.TenantRepo(tenant).RunAppGet(context.Background(), appName)
+	app, err := repowriter.TenantRepo(tenant).RunAppGet(c.Request.Context(), appName)
+	if err != nil {
+		apierr.Write(c, fmt.Errorf("failed to get application detail: %w", err))
+		return
+	}
 
-	var argocdappname = fmt.Sprintf("%s-%s", app.ApplicationInstantiation.TenantName, app.ApplicationInstantiation.ApplicationName)
-	log.G().WithFields(log.Fields{
+	var argocdappname = argoApplicationName(app)
+	log.G(c.Request.Context()).WithFields(log.Fields{
 		"application namespace": app.ApplicationInstantiation.TenantName,
 		"application name":      store.Default.ArgoCDNamespace,
 	}).Debug("get application status")
 
 	//TODO: opt with list method
 	applicationRuntime, err := argoClient.Applications(store.Default.ArgoCDNamespace).
-		Get(context.Background(), argocdappname, metav1.GetOptions{})
+		Get(c.Request.Context(), argocdappname, metav1.GetOptions{})
 	if err != nil {
-		if k8serrors.IsNotFound(err) {
-			log.G().WithFields(log.Fields{
-				"application": appName,
-				"namespace":   store.Default.ArgoCDNamespace,
-			}).Info("application not install in argocd")
-		} else {
-			log.G().WithError(err).Error("failed to get application")
+		if !k8serrors.IsNotFound(err) {
+			log.G(c.Request.Context()).WithError(err).Error("failed to get application")
+			apierr.Write(c, apierr.UpstreamArgoCD(err, "failed to get application detail"))
+			return
 		}
+		log.G(c.Request.Context()).WithFields(log.Fields{
+			"application": appName,
+			"namespace":   store.Default.ArgoCDNamespace,
+		}).Info("application not install in argocd")
 	} else {
-		app.ApplicationRuntime.Status = getAppStatus(applicationRuntime)
-		app.ApplicationRuntime.Health = getAppHealth(applicationRuntime)
-		app.ApplicationRuntime.SyncStatus = getAppSyncStatus(applicationRuntime)
-		app.ApplicationRuntime.ArgoCDUrl = fmt.Sprintf("https://argocd.squidflow.io/applications/%s", argocdappname)
-		app.ApplicationRuntime.CreatedAt = applicationRuntime.CreationTimestamp.Time
-		app.ApplicationRuntime.CreatedBy = applicationRuntime.Annotations["squidflow.github.io/created-by"]
-		app.ApplicationRuntime.LastUpdatedAt = time.Now() // TODO: fix this
-		app.ApplicationRuntime.LastUpdatedBy = applicationRuntime.Annotations["squidflow.github.io/last-modified-by"]
-	}
-
-	if err != nil {
-		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to get application detail: %v", err)})
-		return
+		setAppRuntime(&app.ApplicationRuntime, applicationRuntime, argocdappname)
+		setResourceMetrics(c.Request.Context(), app)
 	}
 
 	c.JSON(200, app)
 }
 
+// ApplicationsList lists the applications of the tenant, with the runtime status of their ArgoCD application
+// query: limit, continue, sort (name, created_at, health, sync_status), prefix, health, sync_status, appcode, cluster, namespace,
+// metrics, with `true` the resource metrics of the applications of the page are read from their destination clusters
 func ApplicationsList(c *gin.Context) {
 	tenant := c.GetString(middleware.TenantKey)
 	username := c.GetString(middleware.UserNameKey)
 
-	log.G().Infof("tenant: %s, username: %s", tenant, username)
+	log.G(c.Request.Context()).Infof("tenant: %s, username: %s", tenant, username)
 
-	argoClient, err := kube.NewArgoCdClient()
+	q, err := parseListQuery(c, "name", applicationSortFields)
 	if err != nil {
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to create ArgoCD client: %v", err)})
+		apierr.Write(c, err)
 		return
 	}
 
-	apps, err := repowriter.TenantRepo(tenant).RunAppList(context.Background())
+	apps, err := repowriter.TenantRepo(tenant).RunAppList(c.Request.Context())
 	if err != nil {
-		c.JSON(400, gin.H{"error": fmt.Sprintf("failed to list applications: %v", err)})
+		apierr.Write(c, fmt.Errorf("failed to list applications: %w", err))
 		return
 	}
 
-	// update application runtime status
-	for i, app := range apps {
-		var argocdappname = fmt.Sprintf("%s-%s", app.ApplicationInstantiation.TenantName, app.ApplicationInstantiation.ApplicationName)
-		log.G().WithFields(log.Fields{
-			"application namespace": app.ApplicationInstantiation.TenantName,
-			"application name":      store.Default.ArgoCDNamespace,
-		}).Debug("get application status")
+	argoApps, err := listTenantArgoApplications(c, tenant)
+	if err != nil {
+		log.G(c.Request.Context()).WithError(err).Error("failed to list argocd applications, listing applications without runtime status")
+	}
 
-		//TODO: opt with client-go list method
-		argoApp, err := argoClient.Applications(store.Default.ArgoCDNamespace).
-			Get(context.Background(), argocdappname, metav1.GetOptions{})
-		if err != nil {
-			log.G().WithError(err).Error("Failed to get application")
-			continue
-		} else {
-			apps[i].ApplicationRuntime.Status = getAppStatus(argoApp)
-			apps[i].ApplicationRuntime.Health = getAppHealth(argoApp)
-			apps[i].ApplicationRuntime.SyncStatus = getAppSyncStatus(argoApp)
-			apps[i].ApplicationRuntime.ArgoCDUrl = fmt.Sprintf("https://argocd.squidflow.io/applications/%s", argocdappname)
-			apps[i].ApplicationRuntime.CreatedAt = argoApp.CreationTimestamp.Time
-			apps[i].ApplicationRuntime.CreatedBy = argoApp.Annotations["squidflow.github.io/created-by"]
-			apps[i].ApplicationRuntime.LastUpdatedAt = time.Now() // TODO: fix this
-			apps[i].ApplicationRuntime.LastUpdatedBy = argoApp.Annotations["squidflow.github.io/last-modified-by"]
+	matched := make([]types.Application, 0, len(apps))
+	for _, app := range apps {
+		argocdappname := argoApplicationName(&app)
+		if argoApp, ok := argoApps[argocdappname]; ok {
+			setAppRuntime(&app.ApplicationRuntime, argoApp, argocdappname)
+		}
+
+		if matchApplication(c, q, &app) {
+			matched = append(matched, app)
 		}
 	}
 
+	sortItems(matched, q, applicationSortFields)
+	page, next := paginate(matched, q)
+
+	// the metrics are read from the destination clusters, only on request and for the applications of the page
+	if c.Query("metrics") == "true" {
+		installed := make([]*types.Application, 0, len(page))
+		for i := range page {
+			if _, ok := argoApps[argoApplicationName(&page[i])]; ok {
+				installed = append(installed, &page[i])
+			}
+		}
+		setResourceMetrics(c.Request.Context(), installed...)
+	}
+
 	c.JSON(200, types.ApplicationListResponse{
-		Total:   int64(len(apps)),
-		Success: true,
-		Message: "applications listed successfully",
-		Items:   apps,
-		Error:   "",
+		Total:    int64(len(matched)),
+		Success:  true,
+		Message:  "applications listed successfully",
+		Items:    page,
+		Error:    "",
+		Continue: next,
 	})
 }
 
-// ApplicationSync handles the synchronization of one or more Argo CD applications
+var applicationSortFields = map[string]compareFunc[types.Application]{
+	"name": func(a, b types.Application) int {
+		return strings.Compare(a.ApplicationInstantiation.ApplicationName, b.ApplicationInstantiation.ApplicationName)
+	},
+	"created_at": func(a, b types.Application) int {
+		return a.ApplicationRuntime.CreatedAt.Compare(b.ApplicationRuntime.CreatedAt)
+	},
+	"health": func(a, b types.Application) int {
+		return strings.Compare(a.ApplicationRuntime.Health, b.ApplicationRuntime.Health)
+	},
+	"sync_status": func(a, b types.Application) int {
+		return strings.Compare(a.ApplicationRuntime.SyncStatus, b.ApplicationRuntime.SyncStatus)
+	},
+}
+
+// matchApplication matches the application against the filters of the request
+func matchApplication(c *gin.Context, q *listQuery, app *types.Application) bool {
+	if !strings.HasPrefix(app.ApplicationInstantiation.ApplicationName, q.Prefix) ||
+		!matchQuery(c, "health", app.ApplicationRuntime.Health) ||
+		!matchQuery(c, "sync_status", app.ApplicationRuntime.SyncStatus) ||
+		!matchQuery(c, "appcode", app.ApplicationInstantiation.AppCode) {
+		return false
+	}
+
+	cluster, namespace := c.Query("cluster"), c.Query("namespace")
+	if cluster == "" && namespace == "" {
+		return true
+	}
+
+	for _, target := range app.ApplicationTarget {
+		if (cluster == "" || target.Cluster == cluster) && (namespace == "" || target.Namespace == namespace) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// listTenantArgoApplications returns the ArgoCD applications of the tenant by name, from the cache of the
+// application watcher when it is enabled, otherwise with a single list call filtered by the tenant label
+func listTenantArgoApplications(c *gin.Context, tenant string) (map[string]*argocdv1alpha1.Application, error) {
+	var apps []*argocdv1alpha1.Application
+	if watcher, ok := c.Value(middleware.AppWatcherKey).(*appstatus.Watcher); ok {
+		var err error
+		if apps, err = watcher.List(appstatus.TenantFilter(tenant)); err != nil {
+			return nil, err
+		}
+	} else {
+		argoClient, err := kube.NewArgoCdClient()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ArgoCD client: %w", err)
+		}
+
+		list, err := argoClient.Applications(store.Default.ArgoCDNamespace).List(c.Request.Context(), metav1.ListOptions{
+			LabelSelector: labels.Set{store.Default.LabelKeyTenant: tenant}.String(),
+		})
+		if err != nil {
+			return nil, apierr.UpstreamArgoCD(err, "failed to list argocd applications")
+		}
+		for i := range list.Items {
+			apps = append(apps, &list.Items[i])
+		}
+	}
+
+	res := make(map[string]*argocdv1alpha1.Application, len(apps))
+	for _, app := range apps {
+		res[app.Name] = app
+	}
+
+	return res, nil
+}
+
+// argoApplicationName returns the name of the ArgoCD application of the first target of the application
+func argoApplicationName(app *types.Application) string {
+	if len(app.ApplicationTarget) > 0 && app.ApplicationTarget[0].ArgoApplication != "" {
+		return app.ApplicationTarget[0].ArgoApplication
+	}
+
+	return fmt.Sprintf("%s-%s", app.ApplicationInstantiation.TenantName, app.ApplicationInstantiation.ApplicationName)
+}
+
+// setAppRuntime sets the runtime status of the application from its ArgoCD application
+func setAppRuntime(runtime *types.ApplicationRuntime, argoApp *argocdv1alpha1.Application, argocdappname string) {
+	runtime.Status = getAppStatus(argoApp)
+	runtime.Health = getAppHealth(argoApp)
+	runtime.SyncStatus = getAppSyncStatus(argoApp)
+	runtime.ArgoCDUrl = fmt.Sprintf("https://argocd.squidflow.io/applications/%s", argocdappname)
+	runtime.CreatedAt = argoApp.CreationTimestamp.Time
+	runtime.CreatedBy = argoApp.Annotations["squidflow.github.io/created-by"]
+	runtime.LastUpdatedAt = time.Now() // TODO: fix this
+	runtime.LastUpdatedBy = argoApp.Annotations["squidflow.github.io/last-modified-by"]
+}
+
+// ApplicationSync handles the synchronization of one or more Argo CD applications of the tenant
 func ApplicationSync(c *gin.Context) {
+	username := c.GetString(middleware.UserNameKey)
+	tenant := c.GetString(middleware.TenantKey)
+
 	var req types.SyncApplicationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": fmt.Sprintf("Invalid request format: %v", err)})
+		apierr.Write(c, apierr.Validation("Invalid request format: %v", err))
 		return
 	}
+	middleware.SetAuditTarget(c, strings.Join(req.Applications, ","))
 
 	// Create ArgoCD client
 	argoClient, err := kube.NewArgoCdClient()
 	if err != nil {
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to create ArgoCD client: %v", err)})
+		apierr.Write(c, fmt.Errorf("Failed to create ArgoCD client: %w", err))
 		return
 	}
 
@@ -291,57 +491,230 @@ func ApplicationSync(c *gin.Context) {
 	}
 
 	// Process each application
+	var submitted []*types.SyncApplicationResult
 	for _, appName := range req.Applications {
-		result := types.SyncApplicationResult{
-			Name: appName,
+		// the application is looked up in the tenant's gitops repo, so a tenant can only sync its own applications
+		app, err := repowriter.TenantRepo(tenant).RunAppGet(c.Request.Context(), appName)
+		if err != nil {
+			response.Results = append(response.Results, types.SyncApplicationResult{
+				Name:    appName,
+				Status:  "Failed",
+				Message: fmt.Sprintf("Failed to get application: %v", err),
+			})
+			continue
 		}
 
-		// Get the application
-		app, err := argoClient.Applications(appName).Get(context.Background(), appName, metav1.GetOptions{})
-		if err != nil {
-			result.Status = "Failed"
-			result.Message = fmt.Sprintf("Failed to get application: %v", err)
-			response.Results = append(response.Results, result)
+		if err := checkFreeze(c, func(context.Context) ([]string, error) {
+			return targetClusters(app.ApplicationTarget), nil
+		}); err != nil {
+			response.Results = append(response.Results, types.SyncApplicationResult{
+				Name:    appName,
+				Status:  "Failed",
+				Message: err.Error(),
+			})
 			continue
 		}
 
-		// Prepare sync operation
-		syncOp := argocdv1alpha1.SyncOperation{
-			Prune: false,
+		for _, target := range app.ApplicationTarget {
+			result := types.SyncApplicationResult{
+				Name:            appName,
+				Cluster:         target.Cluster,
+				ArgoApplication: target.ArgoApplication,
+			}
+
+			operationState, err := submitAppSync(c.Request.Context(), argoClient, tenant, username, target.ArgoApplication, &req)
+			if err != nil {
+				result.Status = "Failed"
+				result.Message = fmt.Sprintf("Failed to sync application: %v", err)
+			} else {
+				result.Status = "Submitted"
+				result.Message = "Application sync submitted successfully"
+				result.Operation = operationState
+			}
+
+			response.Results = append(response.Results, result)
+			log.G(c.Request.Context()).WithFields(log.Fields{
+				"application": target.ArgoApplication,
+				"status":      result.Status,
+				"message":     result.Message,
+			}).Info("Application sync result")
+		}
+	}
+
+	for i := range response.Results {
+		if response.Results[i].Status == "Submitted" {
+			submitted = append(submitted, &response.Results[i])
+		}
+	}
+
+	if req.Wait && !req.DryRun && len(submitted) > 0 {
+		timeout := time.Duration(req.TimeoutSeconds) * time.Second
+		if timeout == 0 {
+			timeout = defaultSyncWaitTimeout
+		}
+
+		resources := make([]kube.Resource, 0, len(submitted))
+		for _, result := range submitted {
+			resources = append(resources, kube.Resource{
+				Name:      result.ArgoApplication,
+				Namespace: store.Default.ArgoCDNamespace,
+				WaitFunc:  getAppSyncOperationWaitFunc(argoClient, result.Operation.StartedAt),
+			})
+		}
+
+		waitErr := kube.NewFactory().Wait(c.Request.Context(), &kube.WaitOptions{
+			Interval:  store.Default.WaitInterval,
+			Timeout:   timeout,
+			Resources: resources,
+		})
+
+		for _, result := range submitted {
+			argoApp, err := argoClient.Applications(store.Default.ArgoCDNamespace).Get(c.Request.Context(), result.ArgoApplication, metav1.GetOptions{})
+			if err != nil {
+				result.Message = fmt.Sprintf("Failed to get application after sync: %v", err)
+				continue
+			}
+
+			result.Operation = getSyncOperationResult(argoApp)
+			switch {
+			case result.Operation.Phase == string(synccommon.OperationFailed) || result.Operation.Phase == string(synccommon.OperationError):
+				result.Status = "Failed"
+				result.Message = result.Operation.Message
+			case waitErr != nil && (result.Operation.Phase != string(synccommon.OperationSucceeded) || result.Operation.Health != "Healthy"):
+				result.Status = "Timeout"
+				result.Message = fmt.Sprintf("Application is not synced and healthy yet: %v", waitErr)
+			default:
+				result.Status = "Synced"
+				result.Message = "Application synced successfully"
+			}
+		}
+	}
+
+	c.JSON(200, response)
+}
+
+const defaultSyncWaitTimeout = 5 * time.Minute
+
+// submitAppSync sets the sync operation on the ArgoCD application of the tenant,
+// the application controller picks it up the same way as a sync from the ArgoCD UI
+func submitAppSync(ctx context.Context, argoClient *argocdv1alpha1client.ArgoprojV1alpha1Client, tenant, username, name string, req *types.SyncApplicationRequest) (*types.SyncOperationResult, error) {
+	var result *types.SyncOperationResult
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		app, err := argoClient.Applications(store.Default.ArgoCDNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if app.Spec.Project != tenant {
+			return fmt.Errorf("application '%s' does not belong to tenant '%s'", name, tenant)
+		}
+
+		if app.Operation != nil {
+			return fmt.Errorf("another operation is already in progress")
+		}
+
+		syncOp := &argocdv1alpha1.SyncOperation{
+			Revision: req.Revision,
+			Prune:    req.Prune,
+			DryRun:   req.DryRun,
+			SyncStrategy: &argocdv1alpha1.SyncStrategy{
+				Hook: &argocdv1alpha1.SyncStrategyHook{
+					SyncStrategyApply: argocdv1alpha1.SyncStrategyApply{
+						Force: req.Force,
+					},
+				},
+			},
+		}
+		for _, res := range req.Resources {
+			syncOp.Resources = append(syncOp.Resources, argocdv1alpha1.SyncOperationResource{
+				Group:     res.Group,
+				Kind:      res.Kind,
+				Name:      res.Name,
+				Namespace: res.Namespace,
+			})
 		}
 
-		// Update application with sync operation
 		app.Operation = &argocdv1alpha1.Operation{
-			Sync: &syncOp,
+			Sync: syncOp,
+			InitiatedBy: argocdv1alpha1.OperationInitiator{
+				Username: username,
+			},
 		}
 
-		// Apply the sync operation
+		startedAt := time.Now()
+		if _, err = argoClient.Applications(store.Default.ArgoCDNamespace).Update(ctx, app, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+
+		result = &types.SyncOperationResult{
+			Phase:      string(synccommon.OperationRunning),
+			Revision:   req.Revision,
+			SyncStatus: getAppSyncStatus(app),
+			Health:     getAppHealth(app),
+			StartedAt:  startedAt,
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// getAppSyncOperationWaitFunc waits for the sync operation started after startedAt to complete, and for the
+// application to be healthy after a successful sync. It finishes on the phase of the operation rather than on the
+// sync status, an application synced to a revision other than its target revision stays OutOfSync
+func getAppSyncOperationWaitFunc(argoClient argocdv1alpha1client.ArgoprojV1alpha1Interface, startedAt time.Time) kube.WaitFunc {
+	return func(ctx context.Context, _ kube.Factory, ns, name string) (bool, error) {
+		app, err := argoClient.Applications(ns).Get(ctx, name, metav1.GetOptions{})
 		if err != nil {
-			result.Status = "Failed"
-			result.Message = fmt.Sprintf("Failed to sync application: %v", err)
-		} else {
-			result.Status = "Syncing"
-			result.Message = "Application sync initiated successfully"
+			return false, err
 		}
 
-		response.Results = append(response.Results, result)
-		log.G().WithFields(log.Fields{
-			"application": appName,
-			"status":      result.Status,
-			"message":     result.Message,
-		}).Info("Application sync result")
+		state := app.Status.OperationState
+		if app.Operation != nil || state == nil || state.StartedAt.Time.Before(startedAt.Truncate(time.Second)) || !state.Phase.Completed() {
+			return false, nil
+		}
+
+		if !state.Phase.Successful() {
+			// the operation failed, there is nothing to wait for
+			return true, nil
+		}
+
+		return app.Status.Health.Status == health.HealthStatusHealthy, nil
 	}
+}
 
-	c.JSON(200, response)
+// getSyncOperationResult returns the state of the last operation of the ArgoCD application
+func getSyncOperationResult(app *argocdv1alpha1.Application) *types.SyncOperationResult {
+	result := &types.SyncOperationResult{
+		Phase:      "Unknown",
+		Revision:   app.Status.Sync.Revision,
+		SyncStatus: getAppSyncStatus(app),
+		Health:     getAppHealth(app),
+	}
+
+	if state := app.Status.OperationState; state != nil {
+		result.Phase = string(state.Phase)
+		result.Message = state.Message
+		result.StartedAt = state.StartedAt.Time
+		if state.FinishedAt != nil {
+			finishedAt := state.FinishedAt.Time
+			result.FinishedAt = &finishedAt
+		}
+		if state.SyncResult != nil {
+			result.Revision = state.SyncResult.Revision
+		}
+	}
+
+	return result
 }
 
-// TODO: not implemented
+// ApplicationUpdate updates the application source, destination, ingress, external secrets and annotations in the gitops repo
 func ApplicationUpdate(c *gin.Context) {
 	username := c.GetString(middleware.UserNameKey)
 	tenant := c.GetString(middleware.TenantKey)
 	appName := c.Param("name")
 
-	log.G().WithFields(log.Fields{
+	log.G(c.Request.Context()).WithFields(log.Fields{
 		"username": username,
 		"tenant":   tenant,
 		"appName":  appName,
@@ -349,73 +722,207 @@ func ApplicationUpdate(c *gin.Context) {
 
 	var updateReq types.ApplicationUpdateRequest
 	if err := c.BindJSON(&updateReq); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body: " + err.Error()})
+		apierr.Write(c, apierr.Validation("Invalid request body: %v", err))
 		return
 	}
 
-	cloneOpts := &git.CloneOptions{
-		Repo:     viper.GetString("application_repo.remote_url"),
-		FS:       fs.Create(memfs.New()),
-		Provider: "github",
-		Auth: git.Auth{
-			Password: viper.GetString("application_repo.access_token"),
-		},
-		CloneForWrite: true,
+	if len(updateReq.ApplicationTarget) > 0 {
+		targets, err := resolveAppTargets(c.Request.Context(), updateReq.ApplicationTarget)
+		if err != nil {
+			apierr.Write(c, apierr.Validation("invalid application target: %v", err))
+			return
+		}
+		updateReq.ApplicationTarget = targets
+	}
+
+	if err := validateExternalSecret(&updateReq.ApplicationInstantiation.Security.ExternalSecret); err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	if err := validateIngresses(updateReq.ApplicationInstantiation.Ingress); err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	clusters := appClusters(tenant, appName, updateReq.ApplicationTarget...)
+	if err := checkFreeze(c, clusters); err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	if requestApproval(c, types.ActionTypeUpdate, appName, clusters, &updateReq) {
+		return
 	}
-	cloneOpts.Parse()
 
+	updateOpts := newUpdateOptions(tenant, appName, username, &updateReq)
+	executeOperation(c, func(ctx context.Context) (interface{}, error) {
+		return updateApplication(ctx, updateOpts)
+	}, 200, apierr.CodeInternal)
+}
+
+// newUpdateOptions returns the options of the update of the application by the user
+func newUpdateOptions(tenant, appName, username string, updateReq *types.ApplicationUpdateRequest) *types.UpdateOptions {
 	annotations := make(map[string]string)
 	if updateReq.ApplicationInstantiation.Description != "" {
 		annotations["squidflow.github.io/description"] = updateReq.ApplicationInstantiation.Description
 	}
 
-	// TODO: support security
-	log.G().WithFields(log.Fields{
-		"security": updateReq.ApplicationInstantiation.Security,
-	}).Debug("TODO support security")
-
-	// TODO: support ingress
-	log.G().WithFields(log.Fields{
-		"ingress": updateReq.ApplicationInstantiation.Ingress,
-	}).Debug("TODO support ingress")
-
 	annotations[argocd.AnnotationKeyLastModifiedBy] = username
 	annotations[argocd.AnnotationKeyLastModifiedAt] = time.Now().Format(time.RFC3339)
 
-	updateOpts := &types.UpdateOptions{
+	return &types.UpdateOptions{
 		ProjectName: tenant,
 		AppName:     appName,
 		Username:    username,
-		UpdateReq:   &updateReq,
+		UpdateReq:   updateReq,
 		KubeFactory: kube.NewFactory(),
 		Annotations: annotations,
 	}
+}
 
-	if err := repowriter.TenantRepo(tenant).RunAppUpdate(context.Background(), updateOpts); err != nil {
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to update application: %v", err)})
-		return
+// updateApplication writes the update to the gitops repo of the tenant, and returns the updated application
+func updateApplication(ctx context.Context, updateOpts *types.UpdateOptions) (gin.H, error) {
+	if err := resolveUpdateIngressClasses(ctx, updateOpts); err != nil {
+		return nil, err
 	}
 
-	// TODO: update application in ArgoCD
-	//argoClient, err := kube.NewArgoCdClient()
-	//if err != nil {
-	//	c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to create ArgoCD client: %v", err)})
-	//	return
-	//}
+	// flattened manifests of a new source or of new targets are rendered with the env of the cluster of each target
+	if req := updateOpts.UpdateReq; req != nil && (req.ApplicationSource.Repo != "" || len(req.ApplicationTarget) > 0) {
+		envs, err := clusterEnvs(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the environments of the clusters: %w", err)
+		}
+		updateOpts.Envs = envs
+	}
 
-	app, err := repowriter.TenantRepo(tenant).RunAppGet(context.Background(), appName)
+	operation.Report(ctx, "writing application '%s' to the gitops repo", updateOpts.AppName)
+	if err := repowriter.TenantRepo(updateOpts.ProjectName).RunAppUpdate(ctx, updateOpts); err != nil {
+		return nil, fmt.Errorf("Failed to update application: %w", err)
+	}
 
+	app, err := repowriter.TenantRepo(updateOpts.ProjectName).RunAppGet(ctx, updateOpts.AppName)
 	if err != nil {
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to get updated application details: %v", err)})
-		return
+		return nil, fmt.Errorf("Failed to get updated application details: %w", err)
 	}
 
-	c.JSON(200, gin.H{
+	return gin.H{
 		"message":     "application updated successfully",
 		"application": app,
+	}, nil
+}
+
+// resolveUpdateIngressClasses detects the ingress classes of the clusters of the updated application, when the
+// update writes its ingress. The ingress is written again when the targets change, to the overlays of the new targets
+func resolveUpdateIngressClasses(ctx context.Context, updateOpts *types.UpdateOptions) error {
+	req := updateOpts.UpdateReq
+	if req == nil || (req.ApplicationInstantiation.Ingress == nil && len(req.ApplicationTarget) == 0) {
+		return nil
+	}
+
+	app, err := repowriter.TenantRepo(updateOpts.ProjectName).RunAppGet(ctx, updateOpts.AppName)
+	if err != nil {
+		return fmt.Errorf("failed to get application detail: %w", err)
+	}
+
+	ingresses := req.ApplicationInstantiation.Ingress
+	if ingresses == nil {
+		ingresses = app.ApplicationInstantiation.Ingress
+	}
+	if len(ingresses) == 0 {
+		return nil
+	}
+
+	clusters := targetClusters(req.ApplicationTarget)
+	if len(clusters) == 0 {
+		clusters = targetClusters(app.ApplicationTarget)
+	}
+
+	operation.Report(ctx, "detecting the ingress classes of clusters %v", clusters)
+	updateOpts.IngressClasses, err = resolveIngressClasses(ctx, updateOpts.ProjectName, updateOpts.AppName, clusters, ingresses)
+	return err
+}
+
+// ApplicationHistory lists the commits of the gitops repo that changed the application, newest first
+// query: limit
+func ApplicationHistory(c *gin.Context) {
+	tenant := c.GetString(middleware.TenantKey)
+	appName := c.Param("name")
+
+	limit := 0
+	if l := c.Query("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit <= 0 || limit > maxListLimit {
+			apierr.Write(c, apierr.Validation("invalid limit, expected 1-%d", maxListLimit))
+			return
+		}
+	}
+
+	revisions, err := repowriter.TenantRepo(tenant).RunAppHistory(c.Request.Context(), appName, limit)
+	if err != nil {
+		apierr.Write(c, fmt.Errorf("failed to get the history of application '%s': %w", appName, err))
+		return
+	}
+
+	c.JSON(200, types.ApplicationHistoryResponse{
+		Success: true,
+		Message: "application history retrieved successfully",
+		Total:   len(revisions),
+		Items:   revisions,
 	})
 }
 
+// ApplicationRollback restores the application to a commit of its history, with a new commit
+// or a pull request in pull request mode
+func ApplicationRollback(c *gin.Context) {
+	username := c.GetString(middleware.UserNameKey)
+	tenant := c.GetString(middleware.TenantKey)
+	appName := c.Param("name")
+	middleware.SetAuditTarget(c, appName)
+
+	var req types.ApplicationRollbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Write(c, apierr.Validation("Invalid request body: %v", err))
+		return
+	}
+
+	log.G(c.Request.Context()).WithFields(log.Fields{
+		"username": username,
+		"tenant":   tenant,
+		"appName":  appName,
+		"revision": req.Revision,
+	}).Info("rollback application")
+
+	if err := checkFreeze(c, appClusters(tenant, appName)); err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	if requestApproval(c, types.ActionTypeRollback, appName, appClusters(tenant, appName), &req) {
+		return
+	}
+
+	executeOperation(c, func(ctx context.Context) (interface{}, error) {
+		return rollbackApplication(ctx, tenant, appName, req.Revision)
+	}, 200, apierr.CodeInternal)
+}
+
+// rollbackApplication restores the application to the revision in the gitops repo of the tenant
+func rollbackApplication(ctx context.Context, tenant, appName, revision string) (*types.ApplicationRollbackResponse, error) {
+	operation.Report(ctx, "restoring application '%s' to revision '%s'", appName, revision)
+	commit, err := repowriter.TenantRepo(tenant).RunAppRollback(ctx, appName, revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rollback application: %w", err)
+	}
+
+	return &types.ApplicationRollbackResponse{
+		Success:  true,
+		Message:  fmt.Sprintf("application '%s' rolled back to '%s'", appName, revision),
+		Revision: revision,
+		Commit:   commit,
+	}, nil
+}
+
 // ApplicationSourceValidate handles the request for validating application source
 func ApplicationSourceValidate(c *gin.Context) {
 	var req types.ApplicationSourceRequest
@@ -437,7 +944,7 @@ func ApplicationSourceValidate(c *gin.Context) {
 		req.TargetRevision = "main"
 	}
 
-	log.G().WithFields(log.Fields{
+	log.G(c.Request.Context()).WithFields(log.Fields{
 		"repo":     req.Repo,
 		"path":     req.Path,
 		"revision": req.TargetRevision,
@@ -453,9 +960,9 @@ func ApplicationSourceValidate(c *gin.Context) {
 	cloneOpts.Parse()
 	cloneOpts.SetRevision(req.TargetRevision)
 
-	_, repofs, err := cloneOpts.GetRepo(context.Background())
+	_, repofs, err := cloneOpts.GetRepo(c.Request.Context())
 	if err != nil {
-		log.G().WithError(err).Error("failed to clone repository")
+		log.G(c.Request.Context()).WithError(err).Error("failed to clone repository")
 		c.JSON(400, gin.H{
 			"success": false,
 			"message": fmt.Sprintf("failed to clone repository: %v", err),
@@ -466,7 +973,7 @@ func ApplicationSourceValidate(c *gin.Context) {
 	// Create appropriate AppSource based on the repository content
 	appSource, err := source.NewAppSource(repofs, req.Path, req.ApplicationSpecifier.HelmManifestPath)
 	if err != nil {
-		log.G().WithError(err).Error("failed to create app source")
+		log.G(c.Request.Context()).WithError(err).Error("failed to create app source")
 		c.JSON(400, gin.H{
 			"success": false,
 			"message": err.Error(),
@@ -487,7 +994,8 @@ func ApplicationSourceValidate(c *gin.Context) {
 		// Generate manifest
 		manifest, err := appSource.Manifest(env)
 		if err != nil {
-			log.G().WithError(err).WithFields(log.Fields{
+			metrics.ObserveRenderFailure(c.GetString(middleware.TenantKey), err)
+			log.G(c.Request.Context()).WithError(err).WithFields(log.Fields{
 				"env": env,
 			}).Error("failed to generate manifest")
 			envResult.Valid = false
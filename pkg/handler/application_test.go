@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/argo-cd/v2/pkg/client/clientset/versioned/fake"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	synccommon "github.com/argoproj/gitops-engine/pkg/sync/common"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/middleware"
+)
+
+func TestApplicationCreate_emptyTargets(t *testing.T) {
+	body := `{
+		"application_source": {"repo": "https://github.com/squidflow/demo.git", "path": "app"},
+		"application_instantiation": {"application_name": "app", "tenant_name": "tenant"},
+		"application_target": []
+	}`
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/deploy/argocdapplications", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set(middleware.TenantKey, "tenant")
+
+	ApplicationCreate(c)
+
+	assert.Equal(t, 400, w.Code)
+	resp := apierr.Response{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, apierr.CodeValidation, resp.Code)
+	assert.Contains(t, resp.Error, "ApplicationTarget")
+}
+
+func Test_resolveAppTargets_empty(t *testing.T) {
+	targets, err := resolveAppTargets(context.Background(), nil)
+	assert.Nil(t, targets)
+	assert.EqualError(t, err, "at least one application target is required")
+	assert.Equal(t, apierr.CodeValidation, apierr.CodeOf(err))
+}
+
+func Test_getAppSyncOperationWaitFunc(t *testing.T) {
+	startedAt := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	app := func(phase synccommon.OperationPhase, started time.Time, healthStatus health.HealthStatusCode) *argocdv1alpha1.Application {
+		return &argocdv1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant1-app1", Namespace: "argocd"},
+			Status: argocdv1alpha1.ApplicationStatus{
+				// synced to a revision other than the target revision
+				Sync:   argocdv1alpha1.SyncStatus{Status: argocdv1alpha1.SyncStatusCodeOutOfSync},
+				Health: argocdv1alpha1.HealthStatus{Status: healthStatus},
+				OperationState: &argocdv1alpha1.OperationState{
+					Phase:     phase,
+					StartedAt: metav1.NewTime(started),
+				},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		app  *argocdv1alpha1.Application
+		want bool
+	}{
+		"Should wait for a running operation": {
+			app: app(synccommon.OperationRunning, startedAt, health.HealthStatusHealthy),
+		},
+		"Should wait for the operation that was submitted": {
+			app: app(synccommon.OperationSucceeded, startedAt.Add(-time.Minute), health.HealthStatusHealthy),
+		},
+		"Should wait for the application to be healthy": {
+			app: app(synccommon.OperationSucceeded, startedAt, health.HealthStatusProgressing),
+		},
+		"Should finish on a successful operation of an application that is out of sync": {
+			app:  app(synccommon.OperationSucceeded, startedAt, health.HealthStatusHealthy),
+			want: true,
+		},
+		"Should finish on a failed operation": {
+			app:  app(synccommon.OperationFailed, startedAt, health.HealthStatusDegraded),
+			want: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cs := fake.NewSimpleClientset(tt.app)
+			waitFunc := getAppSyncOperationWaitFunc(cs.ArgoprojV1alpha1(), startedAt)
+
+			got, err := waitFunc(context.Background(), nil, "argocd", "tenant1-app1")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/audit"
+	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/middleware"
+	"github.com/squidflow/service/pkg/rbac"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AuditList returns the audit records of a tenant, newest first
+// query: tenant (default: tenant of the request), since, until (RFC3339), limit
+func AuditList(c *gin.Context) {
+	tenant := c.DefaultQuery("tenant", c.GetString(middleware.TenantKey))
+	if tenant != c.GetString(middleware.TenantKey) && !middleware.RBACEnabled(c) {
+		apierr.Write(c, apierr.Forbidden("audit records of other tenants require rbac to be enabled"))
+		return
+	}
+
+	if !middleware.Enforce(c, tenant, rbac.ResourceAudit, rbac.VerbRead) {
+		return
+	}
+
+	q := &audit.Query{
+		Tenant: tenant,
+		Limit:  defaultAuditLimit,
+	}
+
+	var err error
+	if since := c.Query("since"); since != "" {
+		if q.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			apierr.Write(c, apierr.Validation("invalid since, expected RFC3339: %v", err))
+			return
+		}
+	}
+
+	if until := c.Query("until"); until != "" {
+		if q.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			apierr.Write(c, apierr.Validation("invalid until, expected RFC3339: %v", err))
+			return
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit <= 0 || q.Limit > maxAuditLimit {
+			apierr.Write(c, apierr.Validation("invalid limit, expected 1-%d", maxAuditLimit))
+			return
+		}
+	}
+
+	sink, ok := c.Value(middleware.AuditSinkKey).(audit.Sink)
+	if !ok {
+		apierr.Write(c, apierr.NotImplemented("audit is not configured"))
+		return
+	}
+
+	records, err := sink.Query(c.Request.Context(), q)
+	if err != nil {
+		if errors.Is(err, audit.ErrQueryNotSupported) {
+			apierr.Write(c, apierr.NotImplemented("%v", err))
+			return
+		}
+		log.G(c.Request.Context()).Errorf("failed to query audit records: %v", err)
+		apierr.Write(c, fmt.Errorf("failed to query audit records: %w", err))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"total":   len(records),
+		"items":   records,
+	})
+}
@@ -0,0 +1,360 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/approval"
+	"github.com/squidflow/service/pkg/git"
+	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/middleware"
+	"github.com/squidflow/service/pkg/operation"
+	"github.com/squidflow/service/pkg/rbac"
+	repowriter "github.com/squidflow/service/pkg/repo/writer"
+	"github.com/squidflow/service/pkg/types"
+)
+
+// changeRequestExecutors execute the operation of an approved change request from its payload,
+// like the handler of the operation would, on behalf of the requester
+var changeRequestExecutors = map[types.ActionType]func(ctx context.Context, cr *types.ChangeRequest) (interface{}, error){
+	types.ActionTypeCreate: func(ctx context.Context, cr *types.ChangeRequest) (interface{}, error) {
+		var req types.ApplicationCreateRequest
+		if err := json.Unmarshal(cr.Payload, &req); err != nil {
+			return nil, err
+		}
+
+		targets, err := resolveAppTargets(ctx, req.ApplicationTarget)
+		if err != nil {
+			return nil, apierr.Validation("invalid application target: %v", err)
+		}
+
+		return createApplication(ctx, cr.Tenant, cr.RequestedBy, &req, targets)
+	},
+	types.ActionTypeUpdate: func(ctx context.Context, cr *types.ChangeRequest) (interface{}, error) {
+		var req types.ApplicationUpdateRequest
+		if err := json.Unmarshal(cr.Payload, &req); err != nil {
+			return nil, err
+		}
+
+		return updateApplication(ctx, newUpdateOptions(cr.Tenant, cr.Application, cr.RequestedBy, &req))
+	},
+	types.ActionTypeDelete: func(ctx context.Context, cr *types.ChangeRequest) (interface{}, error) {
+		return nil, deleteApplication(ctx, cr.Tenant, cr.Application)
+	},
+	types.ActionTypeRollback: func(ctx context.Context, cr *types.ChangeRequest) (interface{}, error) {
+		var req types.ApplicationRollbackRequest
+		if err := json.Unmarshal(cr.Payload, &req); err != nil {
+			return nil, err
+		}
+
+		return rollbackApplication(ctx, cr.Tenant, cr.Application, req.Revision)
+	},
+	types.ActionTypePromote: func(ctx context.Context, cr *types.ChangeRequest) (interface{}, error) {
+		var opts types.AppPromoteOptions
+		if err := json.Unmarshal(cr.Payload, &opts); err != nil {
+			return nil, err
+		}
+
+		return promoteApplication(ctx, cr.Tenant, cr.Environment, &opts)
+	},
+	types.ActionTypeUnpin: func(ctx context.Context, cr *types.ChangeRequest) (interface{}, error) {
+		var opts types.AppUnpinOptions
+		if err := json.Unmarshal(cr.Payload, &opts); err != nil {
+			return nil, err
+		}
+
+		return unpinApplication(ctx, cr.Tenant, cr.Environment, &opts)
+	},
+}
+
+// requestApproval stores the change of the application as a change request, and responds with it, when the
+// clusters it targets belong to an environment that requires approvals. It returns false when the change
+// can be persisted right away
+func requestApproval(c *gin.Context, op types.ActionType, appName string, clusters func(ctx context.Context) ([]string, error), payload interface{}) bool {
+	if len(viper.GetStringMap("approvals")) == 0 {
+		return false
+	}
+
+	ctx := c.Request.Context()
+	targeted, err := clusters(ctx)
+	if err != nil {
+		apierr.Write(c, err)
+		return true
+	}
+
+	envs, err := clusterEnvironments(ctx)
+	if err != nil {
+		apierr.Write(c, err)
+		return true
+	}
+
+	targetedEnvs := make([]string, 0, len(targeted))
+	for _, cluster := range targeted {
+		targetedEnvs = append(targetedEnvs, envs[cluster])
+	}
+
+	env, policy := approval.Strictest(targetedEnvs...)
+	if policy.Required == 0 {
+		return false
+	}
+
+	cr := &types.ChangeRequest{
+		ID:           uuid.New().String(),
+		Tenant:       c.GetString(middleware.TenantKey),
+		Application:  appName,
+		Operation:    op,
+		Environment:  env,
+		Clusters:     targeted,
+		RequestedBy:  c.GetString(middleware.UserNameKey),
+		RequestedAt:  time.Now().UTC(),
+		Status:       types.ChangeRequestPending,
+		Required:     policy.Required,
+		ApproverRole: string(policy.Role),
+	}
+	if payload != nil {
+		if cr.Payload, err = json.Marshal(payload); err != nil {
+			apierr.Write(c, apierr.Internal(err, "failed to encode the change request"))
+			return true
+		}
+	}
+
+	log.G(ctx).WithFields(log.Fields{
+		"changeRequest": cr.ID,
+		"tenant":        cr.Tenant,
+		"operation":     op,
+		"application":   appName,
+		"environment":   env,
+		"required":      policy.Required,
+	}).Info("change requires approvals")
+
+	executeMetaOperation(c, func(ctx context.Context) (interface{}, error) {
+		operation.Report(ctx, "'%s' requires %d approvals, writing change request '%s' to the meta repo", env, policy.Required, cr.ID)
+		if err := repowriter.MetaRepo().ChangeRequestCreate(ctx, cr); err != nil {
+			return nil, fmt.Errorf("failed to create change request: %w", err)
+		}
+
+		return types.ChangeRequestResponse{
+			Success: true,
+			Message: fmt.Sprintf("changes to '%s' require %d approvals, change request '%s' is pending", env, policy.Required, cr.ID),
+			Item:    *cr,
+		}, nil
+	}, http.StatusAccepted, apierr.CodeInternal)
+
+	return true
+}
+
+// appClusters returns the clusters of the targets, and of the targets of the application when it exists
+func appClusters(tenant, appName string, targets ...types.ApplicationTarget) func(ctx context.Context) ([]string, error) {
+	return func(ctx context.Context) ([]string, error) {
+		clusters := targetClusters(targets)
+		app, err := repowriter.TenantRepo(tenant).RunAppGet(ctx, appName)
+		if apierr.CodeOf(err) == apierr.CodeNotFound {
+			return clusters, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get application detail: %w", err)
+		}
+
+		return append(clusters, targetClusters(app.ApplicationTarget)...), nil
+	}
+}
+
+func targetClusters(targets []types.ApplicationTarget) []string {
+	clusters := make([]string, 0, len(targets))
+	for _, target := range targets {
+		clusters = append(clusters, target.Cluster)
+	}
+
+	return clusters
+}
+
+// ChangeRequestList lists the change requests of the tenant
+// query: limit, continue, sort (requested_at, application, status), status, operation, application, environment
+func ChangeRequestList(c *gin.Context) {
+	tenant := c.GetString(middleware.TenantKey)
+
+	q, err := parseListQuery(c, "-requested_at", changeRequestSortFields)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+	if c.Query("sort") == "" {
+		q.Sort, q.Desc = "requested_at", true
+	}
+
+	crs, err := repowriter.MetaRepo().ChangeRequestList(c.Request.Context(), tenant)
+	if err != nil {
+		apierr.Write(c, fmt.Errorf("failed to list change requests: %w", err))
+		return
+	}
+
+	matched := make([]types.ChangeRequest, 0, len(crs))
+	for _, cr := range crs {
+		if !matchQuery(c, "status", cr.Status) ||
+			!matchQuery(c, "operation", string(cr.Operation)) ||
+			!matchQuery(c, "application", cr.Application) ||
+			!matchQuery(c, "environment", cr.Environment) {
+			continue
+		}
+
+		matched = append(matched, cr)
+	}
+	sortItems(matched, q, changeRequestSortFields)
+	page, next := paginate(matched, q)
+
+	c.JSON(http.StatusOK, types.ChangeRequestListResponse{
+		Success:  true,
+		Message:  "change requests listed successfully",
+		Total:    len(matched),
+		Items:    page,
+		Continue: next,
+	})
+}
+
+var changeRequestSortFields = map[string]compareFunc[types.ChangeRequest]{
+	"requested_at": func(a, b types.ChangeRequest) int {
+		return a.RequestedAt.Compare(b.RequestedAt)
+	},
+	"application": func(a, b types.ChangeRequest) int {
+		return strings.Compare(a.Application, b.Application)
+	},
+	"status": func(a, b types.ChangeRequest) int {
+		return strings.Compare(a.Status, b.Status)
+	},
+}
+
+// ChangeRequestGet returns a change request of the tenant
+func ChangeRequestGet(c *gin.Context) {
+	cr, err := repowriter.MetaRepo().ChangeRequestGet(c.Request.Context(), c.GetString(middleware.TenantKey), c.Param("id"))
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, types.ChangeRequestResponse{
+		Success: true,
+		Message: "change request retrieved successfully",
+		Item:    *cr,
+	})
+}
+
+// ChangeRequestApprove approves a change request, the change is executed with the approval that completes the
+// approvals required. Its commit, or its pull request, records the change request and its approvals
+func ChangeRequestApprove(c *gin.Context) {
+	decideChangeRequest(c, types.DecisionApprove)
+}
+
+// ChangeRequestReject rejects a change request, a rejected change is never executed
+func ChangeRequestReject(c *gin.Context) {
+	decideChangeRequest(c, types.DecisionReject)
+}
+
+func decideChangeRequest(c *gin.Context, decision string) {
+	username := c.GetString(middleware.UserNameKey)
+	tenant := c.GetString(middleware.TenantKey)
+	id := c.Param("id")
+	middleware.SetAuditTarget(c, id)
+
+	var req types.ChangeRequestDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierr.Write(c, apierr.Validation("Invalid request body: %v", err))
+		return
+	}
+
+	cr, err := repowriter.MetaRepo().ChangeRequestGet(c.Request.Context(), tenant, id)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	if !middleware.HasRole(c, tenant, rbac.Role(cr.ApproverRole), rbac.RolePlatformAdmin) {
+		apierr.Write(c, apierr.Forbidden("the changes of '%s' are approved by the %s of tenant '%s'", cr.Environment, cr.ApproverRole, tenant))
+		return
+	}
+
+	// the approval that completes the required approvals executes the change
+	if decision == types.DecisionApprove && cr.Approvals()+1 >= cr.Required {
+		if err := checkFreeze(c, func(context.Context) ([]string, error) {
+			return cr.Clusters, nil
+		}); err != nil {
+			apierr.Write(c, err)
+			return
+		}
+	}
+
+	log.G(c.Request.Context()).WithFields(log.Fields{
+		"changeRequest": id,
+		"tenant":        tenant,
+		"approver":      username,
+		"decision":      decision,
+	}).Info("decide on change request")
+
+	executeMetaOperation(c, func(ctx context.Context) (interface{}, error) {
+		// read the change request again, another approver may have decided on it since
+		cr, err := repowriter.MetaRepo().ChangeRequestGet(ctx, tenant, id)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := approval.Decide(cr, username, decision, req.Comment, time.Now()); err != nil {
+			return nil, err
+		}
+
+		operation.Report(ctx, "recording the decision of '%s' on change request '%s'", username, id)
+		if err := repowriter.MetaRepo().ChangeRequestUpdate(ctx, cr); err != nil {
+			return nil, fmt.Errorf("failed to update change request: %w", err)
+		}
+
+		if cr.Status == types.ChangeRequestApproved {
+			if err := executeChangeRequest(ctx, cr); err != nil {
+				return nil, err
+			}
+		}
+
+		return types.ChangeRequestResponse{
+			Success: true,
+			Message: fmt.Sprintf("change request '%s' is %s", id, cr.Status),
+			Item:    *cr,
+		}, nil
+	}, http.StatusOK, apierr.CodeInternal)
+}
+
+// executeChangeRequest executes the operation of an approved change request, and records its result
+func executeChangeRequest(ctx context.Context, cr *types.ChangeRequest) error {
+	execute, ok := changeRequestExecutors[cr.Operation]
+	if !ok {
+		return apierr.Unprocessable("operation '%s' of change request '%s' is not supported", cr.Operation, cr.ID)
+	}
+
+	operation.Report(ctx, "executing change request '%s' to %s application '%s'", cr.ID, cr.Operation, cr.Application)
+	execCtx, rec := git.WithPersistRecorder(git.WithTrailers(ctx, approval.Trailers(cr)...))
+	_, execErr := execute(execCtx, cr)
+
+	now := time.Now().UTC()
+	cr.ExecutedAt = &now
+	cr.Status, cr.Result = types.ChangeRequestExecuted, strings.Join(rec.Revisions(), ", ")
+	if execErr != nil {
+		cr.Status, cr.Result = types.ChangeRequestFailed, execErr.Error()
+	}
+
+	if err := repowriter.MetaRepo().ChangeRequestUpdate(ctx, cr); err != nil {
+		log.G(ctx).WithError(err).WithField("changeRequest", cr.ID).Error("failed to record the result of change request")
+	}
+
+	if execErr != nil {
+		return fmt.Errorf("change request '%s' is approved but failed: %w", cr.ID, execErr)
+	}
+
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/kubernetes"
 
@@ -20,9 +21,12 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/squidflow/service/pkg/apierr"
 	"github.com/squidflow/service/pkg/argocd"
 	"github.com/squidflow/service/pkg/kube"
 	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/middleware"
+	"github.com/squidflow/service/pkg/rbac"
 	"github.com/squidflow/service/pkg/types"
 )
 
@@ -31,30 +35,34 @@ import (
 // you should confirm the CA has been mount to argocd-server pod
 // otherwise, the API will reject your request with CA related error
 func ClusterRegister(c *gin.Context) {
+	if !middleware.Enforce(c, c.GetString(middleware.TenantKey), rbac.ResourceClusters, rbac.VerbCreate) {
+		return
+	}
+
 	var req types.CreateClusterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		apierr.Write(c, apierr.Validation("Invalid request: %v", err))
 		return
 	}
 
-	log.G().WithFields(log.Fields{
+	middleware.SetAuditTarget(c, req.Name)
+
+	log.G(c.Request.Context()).WithFields(log.Fields{
 		"name": req.Name,
 		"env":  req.Env,
 	}).Debug("user input create destination cluster")
 
 	// Note: this api will create the cluster in argo-cd db without using argocd api so that
 	// the argocd-server cache will not be tracked
-	cls, err := argocd.RegisterCluster2ArgoCd(req.Name, req.Env, req.KubeConfig, req.Labels)
+	cls, err := argocd.RegisterCluster2ArgoCd(c.Request.Context(), req.Name, req.Env, req.KubeConfig, req.Labels)
 	if err != nil {
-		if strings.Contains(err.Error(), "existing cluster") {
-			c.JSON(400, gin.H{"error": fmt.Sprintf("Cluster %s already exists", req.Name)})
-			return
-		}
-		log.G().Errorf("Failed to create cluster: %v", err)
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to create cluster: %v", err)})
+		log.G(c.Request.Context()).Errorf("Failed to create cluster: %v", err)
+		apierr.Write(c, fmt.Errorf("Failed to create cluster: %w", err))
 		return
 	}
 
+	resyncFreezeWindows(c)
+
 	// parse the kubeConfig
 	c.JSON(201, gin.H{
 		"message": fmt.Sprintf("destination cluster: %s created successfully", req.Name),
@@ -66,21 +74,23 @@ func ClusterRegister(c *gin.Context) {
 func ClusterDeregister(c *gin.Context) {
 	name := c.Param("name")
 	if name == "" {
-		c.JSON(400, gin.H{"error": "cluster name is required"})
+		apierr.Write(c, apierr.Validation("cluster name is required"))
 		return
 	}
 
-	log.G().WithFields(log.Fields{
+	log.G(c.Request.Context()).WithFields(log.Fields{
 		"name": name,
 	}).Debug("deleting destination cluster")
 
-	err := argocd.DeregisterCluster2ArgoCd(name)
+	err := argocd.DeregisterCluster2ArgoCd(c.Request.Context(), name)
 	if err != nil {
-		log.G().Errorf("Failed to delete cluster %s: %v", name, err)
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to delete cluster: %v", err)})
+		log.G(c.Request.Context()).Errorf("Failed to delete cluster %s: %v", name, err)
+		apierr.Write(c, fmt.Errorf("Failed to delete cluster: %w", err))
 		return
 	}
 
+	resyncFreezeWindows(c)
+
 	c.JSON(200, gin.H{
 		"message": fmt.Sprintf("Destination cluster %s deleted successfully", name),
 	})
@@ -90,44 +100,44 @@ func ClusterDeregister(c *gin.Context) {
 func ClusterGet(c *gin.Context) {
 	name := c.Param("name")
 	if name == "" {
-		c.JSON(400, gin.H{"error": "cluster name is required"})
+		apierr.Write(c, apierr.Validation("cluster name is required"))
 		return
 	}
 
-	log.G().WithFields(log.Fields{
+	log.G(c.Request.Context()).WithFields(log.Fields{
 		"name": name,
 	}).Debug("getting destination cluster")
 
-	cluster, err := argocd.GetCluster(name)
+	cluster, err := argocd.GetCluster(c.Request.Context(), name)
 	if err != nil {
-		log.G().Errorf("failed to get cluster %s: %v", name, err)
-		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to get cluster: %v", err)})
+		log.G(c.Request.Context()).Errorf("failed to get cluster %s: %v", name, err)
+		apierr.Write(c, fmt.Errorf("failed to get cluster: %w", err))
 		return
 	}
 
 	// Get kubernetes client for the cluster
 	destK8sClient, err := GetDestKubernetesClient(cluster)
 	if err != nil {
-		log.G().Errorf("failed to get Kubernetes client for cluster %s: %v", name, err)
-		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to connect to cluster: %v", err)})
+		log.G(c.Request.Context()).Errorf("failed to get Kubernetes client for cluster %s: %v", name, err)
+		apierr.Write(c, fmt.Errorf("failed to connect to cluster: %w", err))
 		return
 	}
 
 	// Get cluster version
 	version, err := destK8sClient.Discovery().ServerVersion()
 	if err != nil {
-		log.G().Errorf("failed to get server version: %v", err)
-		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to get cluster version: %v", err)})
+		log.G(c.Request.Context()).Errorf("failed to get server version: %v", err)
+		apierr.Write(c, fmt.Errorf("failed to get cluster version: %w", err))
 		return
 	}
 
-	total, readyNodes := countReadyNodes(destK8sClient)
+	total, readyNodes := countReadyNodes(c.Request.Context(), destK8sClient)
 
 	// Build response
 	response := &types.ClusterResponse{
 		Name:        cluster.Name,
 		Environment: cluster.Labels["squidflow.github.io/cluster-env"],
-		Status:      getClusterStatus(destK8sClient),
+		Status:      getClusterStatus(c.Request.Context(), destK8sClient),
 		Provider:    cluster.Labels["squidflow.github.io/cluster-vendor"],
 		Version: types.VersionInfo{
 			Kubernetes: version.GitVersion,
@@ -145,7 +155,7 @@ func ClusterGet(c *gin.Context) {
 			Total: total,
 		},
 		NetworkPolicy:     true,
-		IngressController: getIngressController(cluster.Labels["vendor"]),
+		IngressController: detectIngressController(c.Request.Context(), destK8sClient, cluster.Annotations[argocd.AnnotationKeyVendor]),
 		LastUpdated:       cluster.Info.ConnectionState.ModifiedAt.String(),
 		ConsoleUrl:        getConsoleURL(*cluster),
 		Monitoring:        getMonitoringInfo(*cluster),
@@ -157,40 +167,66 @@ func ClusterGet(c *gin.Context) {
 }
 
 // ClusterList handles the GET request for listing clusters
+// query: limit, continue, sort (name, env), prefix, env, selector (label selector of the clusters)
 func ClusterList(c *gin.Context) {
-	clusterList, err := argocd.ListClusters()
+	q, err := parseListQuery(c, "name", clusterSortFields)
 	if err != nil {
-		log.G().Errorf("failed to list clusters: %v", err)
-		c.JSON(500, gin.H{"error": "failed to list clusters"})
+		apierr.Write(c, err)
 		return
 	}
 
-	response := &types.ClusterListResponse{
-		Success: true,
-		Total:   len(clusterList.Items),
-		Message: "success",
-		Items:   []types.ClusterResponse{},
-		Error:   "",
+	selector, err := labels.Parse(c.Query("selector"))
+	if err != nil {
+		apierr.Write(c, apierr.Validation("invalid selector: %v", err))
+		return
 	}
 
+	clusterList, err := argocd.ListClusters(c.Request.Context())
+	if err != nil {
+		log.G(c.Request.Context()).Errorf("failed to list clusters: %v", err)
+		apierr.Write(c, err)
+		return
+	}
+
+	// the clusters are filtered and paginated before connecting to them
+	matched := make([]argoappv1.Cluster, 0, len(clusterList.Items))
 	for _, cluster := range clusterList.Items {
+		if strings.HasPrefix(cluster.Name, q.Prefix) &&
+			matchQuery(c, "env", cluster.Annotations[argocd.AnnotationKeyEnvironment]) &&
+			selector.Matches(labels.Set(cluster.Labels)) {
+			matched = append(matched, cluster)
+		}
+	}
+	sortItems(matched, q, clusterSortFields)
+	page, next := paginate(matched, q)
+
+	response := &types.ClusterListResponse{
+		Success:  true,
+		Total:    len(matched),
+		Message:  "success",
+		Items:    []types.ClusterResponse{},
+		Error:    "",
+		Continue: next,
+	}
+
+	for _, cluster := range page {
 		destK8sClient, err := GetDestKubernetesClient(&cluster)
 		if err != nil {
-			log.G().Warnf("Failed to get Kubernetes client with TLS for cluster %s: %v", cluster.Name, err)
+			log.G(c.Request.Context()).Warnf("Failed to get Kubernetes client with TLS for cluster %s: %v", cluster.Name, err)
 			continue
 		}
 
 		version, err := destK8sClient.Discovery().ServerVersion()
 		if err != nil {
-			log.G().Errorf("Failed to get server version: %v", err)
+			log.G(c.Request.Context()).Errorf("Failed to get server version: %v", err)
 			continue
 		}
 
-		total, readyNodes := countReadyNodes(destK8sClient)
+		total, readyNodes := countReadyNodes(c.Request.Context(), destK8sClient)
 		clusterInfo := types.ClusterResponse{
 			Name:        cluster.Name,
 			Environment: cluster.Annotations["squidflow.github.io/cluster-env"],
-			Status:      getClusterStatus(destK8sClient),
+			Status:      getClusterStatus(c.Request.Context(), destK8sClient),
 			Provider:    cluster.Annotations["squidflow.github.io/cluster-vendor"],
 			Version: types.VersionInfo{
 				Kubernetes: version.GitVersion,
@@ -208,7 +244,7 @@ func ClusterList(c *gin.Context) {
 				Total: total,
 			},
 			NetworkPolicy:     true, // This should be determined based on cluster configuration
-			IngressController: getIngressController(cluster.Labels["vendor"]),
+			IngressController: detectIngressController(c.Request.Context(), destK8sClient, cluster.Annotations[argocd.AnnotationKeyVendor]),
 			LastUpdated:       time.Now().String(),
 			ConsoleUrl:        getConsoleURL(cluster),
 			Monitoring:        getMonitoringInfo(cluster),
@@ -221,20 +257,29 @@ func ClusterList(c *gin.Context) {
 	c.JSON(200, response)
 }
 
+var clusterSortFields = map[string]compareFunc[argoappv1.Cluster]{
+	"name": func(a, b argoappv1.Cluster) int {
+		return strings.Compare(a.Name, b.Name)
+	},
+	"env": func(a, b argoappv1.Cluster) int {
+		return strings.Compare(a.Annotations[argocd.AnnotationKeyEnvironment], b.Annotations[argocd.AnnotationKeyEnvironment])
+	},
+}
+
 func ClusterUpdate(c *gin.Context) {
 	name := c.Param("name")
 	if name == "" {
-		c.JSON(400, gin.H{"error": "cluster name is required"})
+		apierr.Write(c, apierr.Validation("cluster name is required"))
 		return
 	}
 
 	var req types.UpdateClusterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		apierr.Write(c, apierr.Validation("Invalid request: %v", err))
 		return
 	}
 
-	log.G().WithFields(log.Fields{
+	log.G(c.Request.Context()).WithFields(log.Fields{
 		"name": name,
 		"env":  req.Env,
 	}).Debug("updating destination cluster")
@@ -245,12 +290,12 @@ func ClusterUpdate(c *gin.Context) {
 	defer closer.Close()
 
 	// First get existing cluster
-	existingCluster, err := clusterClient.Get(context.Background(), &clusterpkg.ClusterQuery{
+	existingCluster, err := clusterClient.Get(c.Request.Context(), &clusterpkg.ClusterQuery{
 		Name: name,
 	})
 	if err != nil {
-		log.G().Errorf("Failed to get cluster %s: %v", name, err)
-		c.JSON(404, gin.H{"error": fmt.Sprintf("Cluster %s not found", name)})
+		log.G(c.Request.Context()).Errorf("Failed to get cluster %s: %v", name, err)
+		apierr.Write(c, apierr.NotFound("Cluster %s not found", name))
 		return
 	}
 
@@ -259,16 +304,16 @@ func ClusterUpdate(c *gin.Context) {
 		// Only process kubeconfig if it's provided
 		kubeconfigBytes, err := base64.StdEncoding.DecodeString(req.KubeConfig)
 		if err != nil {
-			log.G().Errorf("Failed to decode kubeConfig: %v", err)
-			c.JSON(400, gin.H{"error": fmt.Sprintf("Failed to decode kubeconfig: %v", err)})
+			log.G(c.Request.Context()).Errorf("Failed to decode kubeConfig: %v", err)
+			apierr.Write(c, apierr.Validation("Failed to decode kubeconfig: %v", err))
 			return
 		}
 
 		// Parse kubeconfig
 		restConfig, err = clientcmd.RESTConfigFromKubeConfig(kubeconfigBytes)
 		if err != nil {
-			log.G().Errorf("Failed to parse kubeConfig: %v", err)
-			c.JSON(400, gin.H{"error": fmt.Sprintf("Failed to parse kubeconfig: %v", err)})
+			log.G(c.Request.Context()).Errorf("Failed to parse kubeConfig: %v", err)
+			apierr.Write(c, apierr.Validation("Failed to parse kubeconfig: %v", err))
 			return
 		}
 	}
@@ -303,15 +348,17 @@ func ClusterUpdate(c *gin.Context) {
 	updatedCluster.Config.TLSClientConfig.Insecure = true
 	updatedCluster.Config.TLSClientConfig.CAData = nil
 
-	result, err := clusterClient.Update(context.Background(), &clusterpkg.ClusterUpdateRequest{
+	result, err := clusterClient.Update(c.Request.Context(), &clusterpkg.ClusterUpdateRequest{
 		Cluster: updatedCluster,
 	})
 	if err != nil {
-		log.G().Errorf("Failed to update cluster %s: %v", name, err)
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to update cluster: %v", err)})
+		log.G(c.Request.Context()).Errorf("Failed to update cluster %s: %v", name, err)
+		apierr.Write(c, apierr.UpstreamArgoCD(err, "Failed to update cluster"))
 		return
 	}
 
+	resyncFreezeWindows(c)
+
 	c.JSON(200, gin.H{
 		"message": fmt.Sprintf("Destination cluster %s updated successfully", name),
 		"cluster": result,
@@ -321,17 +368,28 @@ func ClusterUpdate(c *gin.Context) {
 // GetDestKubernetesClient returns a Kubernetes clientset with TLS configuration
 // improve: URIToSecretName
 func GetDestKubernetesClient(argocdCluster *argoappv1.Cluster) (kubernetes.Interface, error) {
+	restConfig, err := GetDestRestConfig(argocdCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create and return kubernetes client
+	return kubernetes.NewForConfig(restConfig)
+}
+
+// GetDestRestConfig returns the rest config of the destination cluster, from the credentials of its ArgoCD cluster secret
+func GetDestRestConfig(argocdCluster *argoappv1.Cluster) (*rest.Config, error) {
 	// Create kubernetes client to get secrets
 	factory := kube.NewFactory()
+	if argocdCluster.Name == "in-cluster" {
+		return factory.ToRESTConfig()
+	}
+
 	k8sClient, err := factory.KubernetesClientSet()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
 	}
 
-	if argocdCluster.Name == "in-cluster" {
-		return k8sClient, nil
-	}
-
 	// List secrets with the ArgoCD cluster label
 	secrets, err := k8sClient.CoreV1().Secrets("argocd").List(context.Background(), metav1.ListOptions{
 		LabelSelector: "argocd.argoproj.io/secret-type=cluster",
@@ -390,14 +448,13 @@ func GetDestKubernetesClient(argocdCluster *argoappv1.Cluster) (kubernetes.Inter
 		restConfig.TLSClientConfig.KeyData = keyData
 	}
 
-	// Create and return kubernetes client
-	return kubernetes.NewForConfig(restConfig)
+	return restConfig, nil
 }
 
-func countReadyNodes(destCluster kubernetes.Interface) (total, ready int) {
-	nodes, err := destCluster.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+func countReadyNodes(ctx context.Context, destCluster kubernetes.Interface) (total, ready int) {
+	nodes, err := destCluster.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
 	if err != nil {
-		log.G().Errorf("Failed to list nodes: %v", err)
+		log.G(ctx).Errorf("Failed to list nodes: %v", err)
 		return 0, 0
 	}
 	readyNodes := 0
@@ -412,7 +469,7 @@ func countReadyNodes(destCluster kubernetes.Interface) (total, ready int) {
 	return len(nodes.Items), readyNodes
 }
 
-// TODO: need to implement
+// getIngressController returns the ingress controller of the clusters of the vendor
 func getIngressController(vendor string) string {
 	log.G().WithFields(log.Fields{
 		"vendor": vendor,
@@ -422,8 +479,8 @@ func getIngressController(vendor string) string {
 }
 
 // TODO: need implement
-func getClusterStatus(destCluster kubernetes.Interface) []types.ComponentStatus {
-	cs, err := destCluster.CoreV1().ComponentStatuses().List(context.Background(), metav1.ListOptions{})
+func getClusterStatus(ctx context.Context, destCluster kubernetes.Interface) []types.ComponentStatus {
+	cs, err := destCluster.CoreV1().ComponentStatuses().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return []types.ComponentStatus{{
 			Name:    "cluster",
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/diff"
+	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/middleware"
+	repowriter "github.com/squidflow/service/pkg/repo/writer"
+	"github.com/squidflow/service/pkg/types"
+)
+
+// ApplicationDiff compares the desired manifests of each target of the application, built from the overlay of the
+// target with the env of its cluster, with the live resources of the target, so the changes of a sync are known
+// before it runs
+// query: cluster, only compare the target on that cluster
+func ApplicationDiff(c *gin.Context) {
+	tenant := c.GetString(middleware.TenantKey)
+	appName := c.Param("name")
+	ctx := c.Request.Context()
+
+	app, err := repowriter.TenantRepo(tenant).RunAppGet(ctx, appName)
+	if err != nil {
+		apierr.Write(c, fmt.Errorf("failed to get application detail: %w", err))
+		return
+	}
+
+	envs, err := clusterEnvs(ctx)
+	if err != nil {
+		apierr.Write(c, fmt.Errorf("failed to list the environments of the clusters: %w", err))
+		return
+	}
+
+	manifests, err := repowriter.TenantRepo(tenant).RunAppTargetManifests(ctx, appName, envs)
+	if err != nil {
+		apierr.Write(c, fmt.Errorf("failed to render the desired manifests: %w", err))
+		return
+	}
+
+	cluster := c.Query("cluster")
+	targets := []types.ApplicationTargetDiff{}
+	for _, target := range app.ApplicationTarget {
+		if cluster != "" && target.Cluster != cluster {
+			continue
+		}
+
+		desired, err := diff.ParseManifests(manifests[target.Cluster])
+		if err != nil {
+			apierr.Write(c, err)
+			return
+		}
+
+		targets = append(targets, diffTarget(ctx, target, desired))
+	}
+
+	if cluster != "" && len(targets) == 0 {
+		apierr.Write(c, apierr.NotFound("application '%s' has no target on cluster '%s'", appName, cluster))
+		return
+	}
+
+	c.JSON(200, types.ApplicationDiffResponse{
+		Success: true,
+		Message: "application diff computed successfully",
+		Targets: targets,
+	})
+}
+
+// diffTarget compares the desired resources with the live resources of the target cluster,
+// a cluster that can't be reached is reported in the target
+func diffTarget(ctx context.Context, target types.ApplicationTarget, desired []*unstructured.Unstructured) types.ApplicationTargetDiff {
+	namespace := target.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	result := types.ApplicationTargetDiff{
+		Cluster:   target.Cluster,
+		Namespace: namespace,
+		Resources: []diff.ResourceDiff{},
+	}
+
+	client, mapper, err := destDynamicClient(&argoappv1.Cluster{Name: target.Cluster, Server: target.Server})
+	if err != nil {
+		log.G(ctx).WithError(err).WithField("cluster", target.Cluster).Warn("failed to connect to the destination cluster")
+		result.Error = fmt.Sprintf("failed to connect to cluster '%s': %v", target.Cluster, err)
+		return result
+	}
+
+	result.Resources = diff.Resources(ctx, client, mapper, desired, namespace)
+
+	return result
+}
+
+// destDynamicClient returns a dynamic client of the destination cluster, and a rest mapper from its discovery api
+func destDynamicClient(cluster *argoappv1.Cluster) (dynamic.Interface, meta.RESTMapper, error) {
+	restConfig, err := GetDestRestConfig(cluster)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)), nil
+}
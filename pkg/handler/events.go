@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/appstatus"
+	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/middleware"
+	repowriter "github.com/squidflow/service/pkg/repo/writer"
+)
+
+// eventsHeartbeat keeps idle streams open through proxies
+const eventsHeartbeat = 30 * time.Second
+
+// ApplicationsEvents streams the status changes of all the applications of the tenant as Server-Sent Events
+func ApplicationsEvents(c *gin.Context) {
+	tenant := c.GetString(middleware.TenantKey)
+
+	streamAppEvents(c, appstatus.TenantFilter(tenant))
+}
+
+// ApplicationEvents streams the status changes of the argocd applications of all the targets of an application
+func ApplicationEvents(c *gin.Context) {
+	tenant := c.GetString(middleware.TenantKey)
+	appName := c.Param("name")
+
+	app, err := repowriter.TenantRepo(tenant).RunAppGet(c.Request.Context(), appName)
+	if err != nil {
+		apierr.Write(c, apierr.Ensure(fmt.Errorf("failed to get application '%s': %w", appName, err), apierr.CodeNotFound))
+		return
+	}
+
+	names := make([]string, 0, len(app.ApplicationTarget))
+	for _, target := range app.ApplicationTarget {
+		names = append(names, target.ArgoApplication)
+	}
+
+	streamAppEvents(c, appstatus.NamesFilter(tenant, names...))
+}
+
+// streamAppEvents sends the current status of the matching applications, then every change until the client goes away
+func streamAppEvents(c *gin.Context, filter appstatus.Filter) {
+	watcher, ok := c.Value(middleware.AppWatcherKey).(*appstatus.Watcher)
+	if !ok {
+		apierr.Write(c, apierr.NotImplemented("application events are not enabled"))
+		return
+	}
+
+	// subscribe before the snapshot so that no change is lost in between
+	events, unsubscribe := watcher.Subscribe(filter)
+	defer unsubscribe()
+
+	snapshot, err := watcher.Snapshot(filter)
+	if err != nil {
+		apierr.Write(c, fmt.Errorf("failed to list applications: %w", err))
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	for _, event := range snapshot {
+		c.SSEvent("application", event)
+	}
+	c.Writer.Flush()
+
+	log.G(c.Request.Context()).WithFields(log.Fields{
+		"tenant":     c.GetString(middleware.TenantKey),
+		"request_id": c.GetString("RequestID"),
+	}).Debug("application events stream opened")
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent("application", event)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("heartbeat", time.Now().UTC())
+			return true
+		}
+	})
+}
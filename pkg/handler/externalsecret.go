@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/types"
+)
+
+// validateExternalSecret checks the key mapping of the external secrets of an application, an empty list of
+// secrets removes the external secrets of the application
+func validateExternalSecret(conf *types.ExternalSecretConfig) error {
+	if len(conf.Secrets) == 0 {
+		if conf.Secrets == nil && conf.SecretStoreRef.ID != "" {
+			return apierr.Validation("external secret of secret store '%s' has no secrets", conf.SecretStoreRef.ID)
+		}
+		return nil
+	}
+
+	if conf.SecretStoreRef.ID == "" {
+		return apierr.Validation("secret store id of the external secrets is required")
+	}
+
+	if conf.RefreshInterval != "" {
+		if d, err := time.ParseDuration(conf.RefreshInterval); err != nil || d <= 0 {
+			return apierr.Validation("invalid refresh interval '%s' of the external secrets", conf.RefreshInterval)
+		}
+	}
+
+	names := make(map[string]bool, len(conf.Secrets))
+	for _, secret := range conf.Secrets {
+		if errs := validation.IsDNS1123Subdomain(secret.Name); len(errs) > 0 {
+			return apierr.Validation("invalid secret '%s': %s", secret.Name, strings.Join(errs, ", "))
+		}
+
+		if names[secret.Name] {
+			return apierr.Validation("secret '%s' is set more than once", secret.Name)
+		}
+		names[secret.Name] = true
+
+		if len(secret.Data) == 0 {
+			return apierr.Validation("secret '%s' has no data", secret.Name)
+		}
+
+		keys := make(map[string]bool, len(secret.Data))
+		for _, data := range secret.Data {
+			if errs := validation.IsConfigMapKey(data.SecretKey); len(errs) > 0 {
+				return apierr.Validation("invalid key '%s' of secret '%s': %s", data.SecretKey, secret.Name, strings.Join(errs, ", "))
+			}
+
+			if keys[data.SecretKey] {
+				return apierr.Validation("key '%s' of secret '%s' is set more than once", data.SecretKey, secret.Name)
+			}
+			keys[data.SecretKey] = true
+
+			if data.RemoteKey == "" {
+				return apierr.Validation("remote key of key '%s' of secret '%s' is required", data.SecretKey, secret.Name)
+			}
+		}
+	}
+
+	return nil
+}
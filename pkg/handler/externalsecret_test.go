@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/types"
+)
+
+func Test_validateExternalSecret(t *testing.T) {
+	secret := func(name string, data ...types.ExternalSecretKeyData) types.ExternalSecretTemplate {
+		return types.ExternalSecretTemplate{Name: name, Data: data}
+	}
+	password := types.ExternalSecretKeyData{SecretKey: "password", RemoteKey: "app/db", Property: "password"}
+	ref := types.SecretStoreRefConfig{ID: "ss1"}
+
+	tests := map[string]struct {
+		conf    types.ExternalSecretConfig
+		wantErr string
+	}{
+		"Should accept no external secrets": {},
+		"Should accept removing the external secrets": {
+			conf: types.ExternalSecretConfig{Secrets: []types.ExternalSecretTemplate{}},
+		},
+		"Should accept a key mapping": {
+			conf: types.ExternalSecretConfig{SecretStoreRef: ref, RefreshInterval: "30m", Secrets: []types.ExternalSecretTemplate{secret("db", password)}},
+		},
+		"Should fail with a secret store without secrets": {
+			conf:    types.ExternalSecretConfig{SecretStoreRef: ref},
+			wantErr: "external secret of secret store 'ss1' has no secrets",
+		},
+		"Should fail without secret store": {
+			conf:    types.ExternalSecretConfig{Secrets: []types.ExternalSecretTemplate{secret("db", password)}},
+			wantErr: "secret store id of the external secrets is required",
+		},
+		"Should fail with an invalid refresh interval": {
+			conf:    types.ExternalSecretConfig{SecretStoreRef: ref, RefreshInterval: "-1h", Secrets: []types.ExternalSecretTemplate{secret("db", password)}},
+			wantErr: "invalid refresh interval '-1h' of the external secrets",
+		},
+		"Should fail with a duplicated secret": {
+			conf:    types.ExternalSecretConfig{SecretStoreRef: ref, Secrets: []types.ExternalSecretTemplate{secret("db", password), secret("db", password)}},
+			wantErr: "secret 'db' is set more than once",
+		},
+		"Should fail with a secret without data": {
+			conf:    types.ExternalSecretConfig{SecretStoreRef: ref, Secrets: []types.ExternalSecretTemplate{secret("db")}},
+			wantErr: "secret 'db' has no data",
+		},
+		"Should fail with a duplicated key": {
+			conf:    types.ExternalSecretConfig{SecretStoreRef: ref, Secrets: []types.ExternalSecretTemplate{secret("db", password, password)}},
+			wantErr: "key 'password' of secret 'db' is set more than once",
+		},
+		"Should fail without remote key": {
+			conf:    types.ExternalSecretConfig{SecretStoreRef: ref, Secrets: []types.ExternalSecretTemplate{secret("db", types.ExternalSecretKeyData{SecretKey: "password"})}},
+			wantErr: "remote key of key 'password' of secret 'db' is required",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateExternalSecret(&tt.conf)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.EqualError(t, err, tt.wantErr)
+			assert.Equal(t, apierr.CodeValidation, apierr.CodeOf(err))
+		})
+	}
+}
@@ -0,0 +1,355 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/argocd"
+	"github.com/squidflow/service/pkg/freeze"
+	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/middleware"
+	"github.com/squidflow/service/pkg/operation"
+	"github.com/squidflow/service/pkg/ratelimit"
+	"github.com/squidflow/service/pkg/rbac"
+	repowriter "github.com/squidflow/service/pkg/repo/writer"
+	"github.com/squidflow/service/pkg/types"
+)
+
+// FreezeWindowCreate stores a freeze window in the meta repo, and writes it to the sync windows of the tenants
+func FreezeWindowCreate(c *gin.Context) {
+	var req types.FreezeWindowCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Write(c, apierr.Validation("Invalid request: %v", err))
+		return
+	}
+
+	middleware.SetAuditTarget(c, req.Name)
+
+	if errs := validation.IsDNS1123Label(req.Name); len(errs) > 0 {
+		apierr.Write(c, apierr.Validation("invalid freeze window '%s': %s", req.Name, strings.Join(errs, ", ")))
+		return
+	}
+
+	if err := freeze.Validate(&req.FreezeWindowSpec); err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	w := &types.FreezeWindow{
+		Name:             req.Name,
+		FreezeWindowSpec: req.FreezeWindowSpec,
+		CreatedBy:        c.GetString(middleware.UserNameKey),
+		CreatedAt:        time.Now().UTC(),
+	}
+
+	log.G(c.Request.Context()).WithFields(log.Fields{
+		"freezeWindow": w.Name,
+		"schedule":     w.Schedule,
+		"duration":     w.Duration,
+		"tenants":      w.Tenants,
+		"environments": w.Environments,
+		"clusters":     w.Clusters,
+	}).Info("create freeze window")
+
+	executeMetaOperation(c, func(ctx context.Context) (interface{}, error) {
+		clusters, err := freezeClusters(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		operation.Report(ctx, "writing freeze window '%s' to the meta repo", w.Name)
+		if err := repowriter.MetaRepo().FreezeWindowCreate(ctx, w, clusters); err != nil {
+			return nil, fmt.Errorf("failed to create freeze window: %w", err)
+		}
+
+		return types.FreezeWindowResponse{
+			Success: true,
+			Message: fmt.Sprintf("freeze window '%s' created successfully", w.Name),
+			Item:    *w,
+		}, nil
+	}, http.StatusCreated, apierr.CodeInternal)
+}
+
+// FreezeWindowList returns the freeze windows of the tenant of the request,
+// callers allowed to update freeze windows see every window
+// query: limit, continue, sort (name, created_at), prefix, active
+func FreezeWindowList(c *gin.Context) {
+	q, err := parseListQuery(c, "name", freezeWindowSortFields)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	tenant := c.GetString(middleware.TenantKey)
+	all := middleware.Allowed(c, tenant, rbac.ResourceFreezeWindows, rbac.VerbUpdate)
+
+	windows, err := repowriter.MetaRepo().FreezeWindowList(c.Request.Context())
+	if err != nil {
+		apierr.Write(c, fmt.Errorf("failed to list freeze windows: %w", err))
+		return
+	}
+
+	now := time.Now()
+	matched := make([]types.FreezeWindow, 0, len(windows))
+	for _, w := range windows {
+		if (!all && !w.ScopesTenant(tenant)) ||
+			!strings.HasPrefix(w.Name, q.Prefix) ||
+			!matchQuery(c, "active", fmt.Sprint(freeze.Active(&w, now))) {
+			continue
+		}
+
+		matched = append(matched, w)
+	}
+	sortItems(matched, q, freezeWindowSortFields)
+	page, next := paginate(matched, q)
+
+	c.JSON(http.StatusOK, types.FreezeWindowListResponse{
+		Success:  true,
+		Message:  "freeze windows listed successfully",
+		Total:    len(matched),
+		Items:    page,
+		Continue: next,
+	})
+}
+
+var freezeWindowSortFields = map[string]compareFunc[types.FreezeWindow]{
+	"name": func(a, b types.FreezeWindow) int {
+		return strings.Compare(a.Name, b.Name)
+	},
+	"created_at": func(a, b types.FreezeWindow) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	},
+}
+
+// FreezeWindowGet returns a freeze window, windows of other tenants are not found
+func FreezeWindowGet(c *gin.Context) {
+	name := c.Param("name")
+	tenant := c.GetString(middleware.TenantKey)
+
+	w, err := repowriter.MetaRepo().FreezeWindowGet(c.Request.Context(), name)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	if !w.ScopesTenant(tenant) && !middleware.Allowed(c, tenant, rbac.ResourceFreezeWindows, rbac.VerbUpdate) {
+		apierr.Write(c, apierr.NotFound("freeze window '%s' not found", name))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.FreezeWindowResponse{
+		Success: true,
+		Message: "freeze window retrieved successfully",
+		Item:    *w,
+	})
+}
+
+// FreezeWindowUpdate replaces the schedule or the range, and the scope of a freeze window
+func FreezeWindowUpdate(c *gin.Context) {
+	name := c.Param("name")
+	middleware.SetAuditTarget(c, name)
+
+	var req types.FreezeWindowUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Write(c, apierr.Validation("Invalid request: %v", err))
+		return
+	}
+
+	if err := freeze.Validate(&req.FreezeWindowSpec); err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	w := &types.FreezeWindow{
+		Name:             name,
+		FreezeWindowSpec: req.FreezeWindowSpec,
+		UpdatedBy:        c.GetString(middleware.UserNameKey),
+	}
+
+	executeMetaOperation(c, func(ctx context.Context) (interface{}, error) {
+		clusters, err := freezeClusters(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		operation.Report(ctx, "writing freeze window '%s' to the meta repo", name)
+		updated, err := repowriter.MetaRepo().FreezeWindowUpdate(ctx, w, clusters)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update freeze window: %w", err)
+		}
+
+		return types.FreezeWindowResponse{
+			Success: true,
+			Message: fmt.Sprintf("freeze window '%s' updated successfully", name),
+			Item:    *updated,
+		}, nil
+	}, http.StatusOK, apierr.CodeInternal)
+}
+
+// FreezeWindowDelete removes a freeze window and its sync windows
+func FreezeWindowDelete(c *gin.Context) {
+	name := c.Param("name")
+	middleware.SetAuditTarget(c, name)
+
+	executeMetaOperation(c, func(ctx context.Context) (interface{}, error) {
+		clusters, err := freezeClusters(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		operation.Report(ctx, "deleting freeze window '%s' from the meta repo", name)
+		if err := repowriter.MetaRepo().FreezeWindowDelete(ctx, name, clusters); err != nil {
+			return nil, fmt.Errorf("failed to delete freeze window: %w", err)
+		}
+
+		return gin.H{"message": fmt.Sprintf("freeze window '%s' deleted successfully", name)}, nil
+	}, http.StatusOK, apierr.CodeInternal)
+}
+
+// syncFreezeWindows writes the freeze windows again as the sync windows of the projects, scoped to the clusters
+// of ArgoCD. It waits for a writer slot of the meta repo
+func syncFreezeWindows(ctx context.Context, repos *ratelimit.RepoLimiter) error {
+	release, err := repos.Acquire(ctx, repowriter.MetaRepoURL())
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	clusters, err := freezeClusters(ctx)
+	if err != nil {
+		return err
+	}
+
+	return repowriter.MetaRepo().FreezeWindowSync(ctx, clusters)
+}
+
+// resyncFreezeWindows scopes the sync windows to the clusters after a cluster is registered, updated or deregistered.
+// The sync runs in the background, it does not hold the response of the change of the cluster while it waits for the
+// meta repo. A failure does not fail the change either, the next scheduled sync retries it
+func resyncFreezeWindows(c *gin.Context) {
+	repos, _ := c.Value(middleware.RepoLimiterKey).(*ratelimit.RepoLimiter)
+	go func(ctx context.Context) {
+		if err := syncFreezeWindows(ctx, repos); err != nil {
+			log.G(ctx).WithError(err).Warn("failed to sync the freeze windows with the clusters")
+		}
+	}(context.WithoutCancel(c.Request.Context()))
+}
+
+// StartFreezeWindowSync syncs the freeze windows every interval until ctx is done. ArgoCD sync windows recur, an
+// absolute range is written as a yearly schedule that the sync drops once the range has ended
+func StartFreezeWindowSync(ctx context.Context, repos *ratelimit.RepoLimiter, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := syncFreezeWindows(ctx, repos); err != nil {
+					log.G(ctx).WithError(err).Warn("failed to sync the freeze windows")
+				}
+			}
+		}
+	}()
+}
+
+// checkFreeze returns a frozen error if a freeze window of the tenant is active on one of the clusters that the
+// change targets. Tenant admins, or every caller when rbac is not enforced, override it with a reason in the
+// X-Freeze-Override header, the reason is audited
+func checkFreeze(c *gin.Context, clusters func(ctx context.Context) ([]string, error)) error {
+	ctx := c.Request.Context()
+	tenant := c.GetString(middleware.TenantKey)
+
+	windows, err := repowriter.MetaRepo().FreezeWindowList(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list freeze windows: %w", err)
+	}
+	if len(windows) == 0 {
+		return nil
+	}
+
+	targeted, err := clusters(ctx)
+	if err != nil {
+		return err
+	}
+
+	known, err := freezeClusters(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := freeze.Blocking(windows, tenant, selectFreezeClusters(known, targeted), time.Now())
+	if w == nil {
+		return nil
+	}
+
+	reason := strings.TrimSpace(c.GetHeader(freeze.OverrideHeader))
+	if reason == "" {
+		return apierr.Frozen("changes are frozen by freeze window '%s', set the %s header to a reason to override it in an emergency", w.Name, freeze.OverrideHeader).
+			WithDetails(map[string]interface{}{"freeze_window": w.Name})
+	}
+
+	if middleware.RBACEnabled(c) && !middleware.HasRole(c, tenant, rbac.RoleTenantAdmin, rbac.RolePlatformAdmin) {
+		return apierr.Forbidden("freeze window '%s' can only be overridden by the tenant-admin of tenant '%s'", w.Name, tenant)
+	}
+
+	c.Set(middleware.AuditOverrideKey, fmt.Sprintf("%s: %s", w.Name, reason))
+	log.G(ctx).WithFields(log.Fields{
+		"freezeWindow": w.Name,
+		"tenant":       tenant,
+		"user":         c.GetString(middleware.UserNameKey),
+		"reason":       reason,
+	}).Warn("freeze window overridden")
+
+	return nil
+}
+
+// freezeClusters returns the name, the server and the environment of the clusters of ArgoCD
+func freezeClusters(ctx context.Context) ([]types.FreezeCluster, error) {
+	list, err := argocd.ListClusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := make([]types.FreezeCluster, 0, len(list.Items))
+	for _, cluster := range list.Items {
+		clusters = append(clusters, types.FreezeCluster{
+			Name:        cluster.Name,
+			Server:      cluster.Server,
+			Environment: strings.ToUpper(cluster.Annotations[argocd.AnnotationKeyEnvironment]),
+		})
+	}
+
+	return clusters, nil
+}
+
+// selectFreezeClusters returns the known clusters by name, unknown clusters have no environment
+func selectFreezeClusters(known []types.FreezeCluster, names []string) []types.FreezeCluster {
+	selected := make([]types.FreezeCluster, 0, len(names))
+	for _, name := range names {
+		cluster := types.FreezeCluster{Name: name}
+		for _, k := range known {
+			if k.Name == name {
+				cluster = k
+				break
+			}
+		}
+
+		selected = append(selected, cluster)
+	}
+
+	return selected
+}
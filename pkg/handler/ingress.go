@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/argocd"
+	"github.com/squidflow/service/pkg/log"
+	repowriter "github.com/squidflow/service/pkg/repo/writer"
+	"github.com/squidflow/service/pkg/store"
+	"github.com/squidflow/service/pkg/types"
+)
+
+// validateIngresses checks that the hosts of the ingress of an application are valid and unique
+func validateIngresses(ingresses []types.IngressConfig) error {
+	hosts := make(map[string]bool, len(ingresses))
+	for _, ingress := range ingresses {
+		errs := validation.IsDNS1123Subdomain(ingress.Host)
+		if strings.HasPrefix(ingress.Host, "*.") {
+			errs = validation.IsWildcardDNS1123Subdomain(ingress.Host)
+		}
+		if len(errs) > 0 {
+			return apierr.Validation("invalid ingress host '%s': %s", ingress.Host, strings.Join(errs, ", "))
+		}
+
+		if hosts[ingress.Host] {
+			return apierr.Validation("ingress host '%s' is set more than once", ingress.Host)
+		}
+		hosts[ingress.Host] = true
+
+		if ingress.TLS.SecretName != "" {
+			if errs := validation.IsDNS1123Subdomain(ingress.TLS.SecretName); len(errs) > 0 {
+				return apierr.Validation("invalid tls secret '%s' of ingress host '%s': %s", ingress.TLS.SecretName, ingress.Host, strings.Join(errs, ", "))
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveIngressClasses returns the ingress class of each cluster, detected on the cluster. It fails
+// with a conflict when a host of the ingress is routed by an ingress of another app on one of the clusters, or
+// committed to the ingress of another app that targets one of the clusters
+func resolveIngressClasses(ctx context.Context, tenant, appName string, clusters []string, ingresses []types.IngressConfig) (map[string]string, error) {
+	list, err := argocd.ListClusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make(map[string]bool, len(ingresses))
+	for _, ingress := range ingresses {
+		hosts[ingress.Host] = true
+	}
+
+	committed, err := committedIngressHosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	classes := make(map[string]string, len(clusters))
+	for _, name := range clusters {
+		found := false
+		for i := range list.Items {
+			cluster := &list.Items[i]
+			if cluster.Name != name && cluster.Server != name {
+				continue
+			}
+			found = true
+
+			if err = checkCommittedIngressHosts(committed, cluster, tenant, appName, hosts); err != nil {
+				return nil, err
+			}
+
+			client, err := GetDestKubernetesClient(cluster)
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect to cluster '%s': %w", name, err)
+			}
+
+			if err = checkIngressHosts(ctx, client, name, tenant, appName, hosts); err != nil {
+				return nil, err
+			}
+
+			classes[name] = detectIngressController(ctx, client, cluster.Annotations[argocd.AnnotationKeyVendor])
+			break
+		}
+
+		if !found {
+			return nil, apierr.Validation("cluster '%s' is not registered", name)
+		}
+	}
+
+	return classes, nil
+}
+
+// checkIngressHosts returns a conflict when one of the hosts is routed by an ingress of the cluster,
+// that is not the ingress of the app
+func checkIngressHosts(ctx context.Context, client kubernetes.Interface, cluster, tenant, appName string, hosts map[string]bool) error {
+	ingresses, err := client.NetworkingV1().Ingresses(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list the ingresses of cluster '%s': %w", cluster, err)
+	}
+
+	for _, ingress := range ingresses.Items {
+		if ingress.Labels[store.Default.LabelKeyAppName] == appName && ingress.Labels[store.Default.LabelKeyTenant] == tenant {
+			continue
+		}
+
+		for _, rule := range ingress.Spec.Rules {
+			if hosts[rule.Host] {
+				return apierr.Conflict("ingress host '%s' is already routed by ingress '%s/%s' on cluster '%s'", rule.Host, ingress.Namespace, ingress.Name, cluster).
+					WithDetails(map[string]interface{}{"host": rule.Host, "cluster": cluster})
+			}
+		}
+	}
+
+	return nil
+}
+
+// committedIngressHosts returns the ingress hosts of the apps of every gitops repo, the ingresses that are
+// committed but not synced yet are not on the clusters
+func committedIngressHosts(ctx context.Context) ([]types.IngressHost, error) {
+	var hosts []types.IngressHost
+	for _, w := range repowriter.ApplicationWriters() {
+		list, err := w.RunIngressHostList(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the ingress hosts of the gitops repo: %w", err)
+		}
+		hosts = append(hosts, list...)
+	}
+
+	return hosts, nil
+}
+
+// checkCommittedIngressHosts returns a conflict when one of the hosts is committed to the ingress of a target
+// of another app on the cluster
+func checkCommittedIngressHosts(committed []types.IngressHost, cluster *argoappv1.Cluster, tenant, appName string, hosts map[string]bool) error {
+	for _, host := range committed {
+		if !hosts[host.Host] || (host.AppName == appName && host.Tenant == tenant) {
+			continue
+		}
+
+		if (host.Cluster != "" && host.Cluster == cluster.Name) || (host.Server != "" && host.Server == cluster.Server) {
+			return apierr.Conflict("ingress host '%s' is already claimed by application '%s' of tenant '%s' on cluster '%s'", host.Host, host.AppName, host.Tenant, cluster.Name).
+				WithDetails(map[string]interface{}{"host": host.Host, "cluster": cluster.Name})
+		}
+	}
+
+	return nil
+}
+
+// detectIngressController returns the default IngressClass of the cluster, or its only one. It falls back to
+// the ingress controller of the vendor of the cluster when the cluster has no class to choose
+func detectIngressController(ctx context.Context, client kubernetes.Interface, vendor string) string {
+	classes, err := client.NetworkingV1().IngressClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.G(ctx).WithError(err).Debug("failed to list ingress classes")
+		return getIngressController(vendor)
+	}
+
+	for _, class := range classes.Items {
+		if class.Annotations[networkingv1.AnnotationIsDefaultIngressClass] == "true" {
+			return class.Name
+		}
+	}
+
+	if len(classes.Items) == 1 {
+		return classes.Items[0].Name
+	}
+
+	return getIngressController(vendor)
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/store"
+	"github.com/squidflow/service/pkg/types"
+)
+
+func Test_validateIngresses(t *testing.T) {
+	tests := map[string]struct {
+		ingresses []types.IngressConfig
+		wantErr   string
+	}{
+		"Should accept hosts and wildcard hosts": {
+			ingresses: []types.IngressConfig{
+				{Host: "app.example.com", TLS: types.TLSConfig{Enabled: true, SecretName: "app-tls"}},
+				{Host: "*.app.example.com"},
+			},
+		},
+		"Should fail with an invalid host": {
+			ingresses: []types.IngressConfig{{Host: "App_Example"}},
+			wantErr:   "invalid ingress host 'App_Example'",
+		},
+		"Should fail with a duplicated host": {
+			ingresses: []types.IngressConfig{{Host: "app.example.com"}, {Host: "app.example.com"}},
+			wantErr:   "ingress host 'app.example.com' is set more than once",
+		},
+		"Should fail with an invalid tls secret": {
+			ingresses: []types.IngressConfig{{Host: "app.example.com", TLS: types.TLSConfig{Enabled: true, SecretName: "App_TLS"}}},
+			wantErr:   "invalid tls secret 'App_TLS' of ingress host 'app.example.com'",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateIngresses(tt.ingresses)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorContains(t, err, tt.wantErr)
+			assert.Equal(t, apierr.CodeValidation, apierr.CodeOf(err))
+		})
+	}
+}
+
+func Test_checkIngressHosts(t *testing.T) {
+	ingress := func(name string, labels map[string]string, host string) *networkingv1.Ingress {
+		return &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "web", Labels: labels},
+			Spec:       networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{{Host: host}}},
+		}
+	}
+	appLabels := map[string]string{store.Default.LabelKeyAppName: "app", store.Default.LabelKeyTenant: "tenant"}
+
+	tests := map[string]struct {
+		ingress  *networkingv1.Ingress
+		wantCode apierr.Code
+	}{
+		"Should accept a host of the ingress of the app": {
+			ingress: ingress("app", appLabels, "app.example.com"),
+		},
+		"Should accept a host that is not routed": {
+			ingress: ingress("other", nil, "other.example.com"),
+		},
+		"Should fail with a host of another app": {
+			ingress:  ingress("other", map[string]string{store.Default.LabelKeyAppName: "app", store.Default.LabelKeyTenant: "other"}, "app.example.com"),
+			wantCode: apierr.CodeConflict,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := kubefake.NewSimpleClientset(tt.ingress)
+			err := checkIngressHosts(context.Background(), client, "prod", "tenant", "app", map[string]bool{"app.example.com": true})
+			if tt.wantCode == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.Equal(t, tt.wantCode, apierr.CodeOf(err))
+		})
+	}
+}
+
+func Test_checkCommittedIngressHosts(t *testing.T) {
+	cluster := &argoappv1.Cluster{Name: "prod", Server: "https://prod:6443"}
+	hosts := map[string]bool{"app.example.com": true}
+
+	tests := map[string]struct {
+		committed types.IngressHost
+		wantCode  apierr.Code
+	}{
+		"Should accept a host of the app": {
+			committed: types.IngressHost{Host: "app.example.com", Tenant: "tenant", AppName: "app", Server: "https://prod:6443"},
+		},
+		"Should accept a host of another app on another cluster": {
+			committed: types.IngressHost{Host: "app.example.com", Tenant: "other", AppName: "app", Cluster: "sit", Server: "https://sit:6443"},
+		},
+		"Should accept another host of another app on the cluster": {
+			committed: types.IngressHost{Host: "other.example.com", Tenant: "other", AppName: "other", Server: "https://prod:6443"},
+		},
+		"Should fail with a host of another app on the server of the cluster": {
+			committed: types.IngressHost{Host: "app.example.com", Tenant: "other", AppName: "app", Server: "https://prod:6443"},
+			wantCode:  apierr.CodeConflict,
+		},
+		"Should fail with a host of another app on a target of the cluster": {
+			committed: types.IngressHost{Host: "app.example.com", Tenant: "tenant", AppName: "other", Cluster: "prod"},
+			wantCode:  apierr.CodeConflict,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkCommittedIngressHosts([]types.IngressHost{tt.committed}, cluster, "tenant", "app", hosts)
+			if tt.wantCode == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.Equal(t, tt.wantCode, apierr.CodeOf(err))
+		})
+	}
+}
+
+func Test_detectIngressController(t *testing.T) {
+	class := func(name string, isDefault bool) *networkingv1.IngressClass {
+		c := &networkingv1.IngressClass{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if isDefault {
+			c.Annotations = map[string]string{networkingv1.AnnotationIsDefaultIngressClass: "true"}
+		}
+		return c
+	}
+
+	tests := map[string]struct {
+		classes []*networkingv1.IngressClass
+		want    string
+	}{
+		"Should return the default class": {
+			classes: []*networkingv1.IngressClass{class("alb", false), class("traefik", true)},
+			want:    "traefik",
+		},
+		"Should return the only class": {
+			classes: []*networkingv1.IngressClass{class("alb", false)},
+			want:    "alb",
+		},
+		"Should fall back to the controller of the vendor": {
+			want: "nginx",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := kubefake.NewSimpleClientset()
+			for _, c := range tt.classes {
+				_, _ = client.NetworkingV1().IngressClasses().Create(context.Background(), c, metav1.CreateOptions{})
+			}
+
+			assert.Equal(t, tt.want, detectIngressController(context.Background(), client, "aliyun"))
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"encoding/base64"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/squidflow/service/pkg/apierr"
+)
+
+const maxListLimit = 500
+
+type (
+	// listQuery is the pagination and the order of a list request, from the query parameters
+	// limit, continue (the cursor returned with the previous page), sort (`field`, or `-field`
+	// for the descending order) and prefix (of the name)
+	listQuery struct {
+		Limit  int
+		Offset int
+		Sort   string
+		Desc   bool
+		Prefix string
+	}
+
+	// compareFunc orders two items of a list by one field
+	compareFunc[T any] func(a, b T) int
+)
+
+// parseListQuery returns the list query of the request, fields are the comparators of the
+// sortable fields, the items are sorted by defaultSort when the request has no sort
+func parseListQuery[T any](c *gin.Context, defaultSort string, fields map[string]compareFunc[T]) (*listQuery, error) {
+	q := &listQuery{
+		Sort:   defaultSort,
+		Prefix: c.Query("prefix"),
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		var err error
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit <= 0 || q.Limit > maxListLimit {
+			return nil, apierr.Validation("invalid limit, expected 1-%d", maxListLimit)
+		}
+	}
+
+	if cont := c.Query("continue"); cont != "" {
+		offset, err := decodeContinue(cont)
+		if err != nil {
+			return nil, apierr.Validation("invalid continue token")
+		}
+		q.Offset = offset
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		q.Desc = strings.HasPrefix(sort, "-")
+		q.Sort = strings.TrimPrefix(sort, "-")
+		if _, ok := fields[q.Sort]; !ok {
+			return nil, apierr.Validation("invalid sort '%s', expected one of %s", sort, strings.Join(sortedKeys(fields), ", "))
+		}
+	}
+
+	return q, nil
+}
+
+// sortItems sorts the items by the field of the query, items of the same field value keep their order
+func sortItems[T any](items []T, q *listQuery, fields map[string]compareFunc[T]) {
+	compare, ok := fields[q.Sort]
+	if !ok {
+		return
+	}
+
+	slices.SortStableFunc(items, func(a, b T) int {
+		if q.Desc {
+			return compare(b, a)
+		}
+		return compare(a, b)
+	})
+}
+
+// paginate returns the page of the query, and the continue token of the next page,
+// which is empty on the last page
+func paginate[T any](items []T, q *listQuery) ([]T, string) {
+	if q.Offset >= len(items) {
+		return []T{}, ""
+	}
+
+	items = items[q.Offset:]
+	if q.Limit == 0 || len(items) <= q.Limit {
+		return items, ""
+	}
+
+	return items[:q.Limit], encodeContinue(q.Offset + q.Limit)
+}
+
+func encodeContinue(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeContinue(token string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+
+	offset, err := strconv.Atoi(string(b))
+	if err != nil || offset < 0 {
+		return 0, apierr.Validation("invalid offset")
+	}
+
+	return offset, nil
+}
+
+func sortedKeys[T any](fields map[string]compareFunc[T]) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// matchQuery matches the value of a filter query parameter case-insensitively, an empty parameter matches everything
+func matchQuery(c *gin.Context, key, value string) bool {
+	want := c.Query(key)
+	return want == "" || strings.EqualFold(want, value)
+}
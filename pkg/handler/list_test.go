@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/squidflow/service/pkg/apierr"
+)
+
+var testSortFields = map[string]compareFunc[string]{
+	"name": strings.Compare,
+}
+
+func newListContext(query string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/?"+query, nil)
+	return c
+}
+
+func Test_parseListQuery(t *testing.T) {
+	tests := map[string]struct {
+		query    string
+		want     *listQuery
+		wantCode apierr.Code
+	}{
+		"defaults": {
+			query: "",
+			want:  &listQuery{Sort: "name"},
+		},
+		"descending sort with prefix and limit": {
+			query: "sort=-name&prefix=app&limit=10&continue=" + encodeContinue(20),
+			want:  &listQuery{Limit: 10, Offset: 20, Sort: "name", Desc: true, Prefix: "app"},
+		},
+		"unknown sort field": {
+			query:    "sort=size",
+			wantCode: apierr.CodeValidation,
+		},
+		"limit out of range": {
+			query:    "limit=1000",
+			wantCode: apierr.CodeValidation,
+		},
+		"malformed continue token": {
+			query:    "continue=not-a-token",
+			wantCode: apierr.CodeValidation,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseListQuery(newListContext(tt.query), "name", testSortFields)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apierr.CodeOf(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_paginate(t *testing.T) {
+	items := []string{"e", "a", "d", "b", "c"}
+	q := &listQuery{Limit: 2, Sort: "name", Desc: true}
+	sortItems(items, q, testSortFields)
+
+	var pages [][]string
+	for {
+		page, next := paginate(items, q)
+		pages = append(pages, page)
+		if next == "" {
+			break
+		}
+
+		offset, err := decodeContinue(next)
+		if !assert.NoError(t, err) {
+			return
+		}
+		q.Offset = offset
+	}
+
+	assert.Equal(t, [][]string{{"e", "d"}, {"c", "b"}, {"a"}}, pages)
+
+	page, next := paginate(items, &listQuery{Offset: 10})
+	assert.Empty(t, page)
+	assert.Empty(t, next)
+}
@@ -0,0 +1,380 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/argocd"
+	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/middleware"
+	repowriter "github.com/squidflow/service/pkg/repo/writer"
+	"github.com/squidflow/service/pkg/types"
+)
+
+const (
+	// defaultLogTail is the number of lines returned for each container when tail is not set
+	defaultLogTail = 100
+	// maxLogStreams bounds the containers whose logs are read by a request
+	maxLogStreams = 20
+	// maxLogLine bounds the length of a log line, longer lines end the stream of the container
+	maxLogLine = 1 << 20
+)
+
+// logStream is a container whose logs are read
+type logStream struct {
+	namespace string
+	pod       string
+	container string
+}
+
+// ApplicationLogs returns the logs of the pods of the application, each line is prefixed by its pod and container
+// query: cluster, required when the application has several targets
+// query: pod, container, selector (label selector of the pods), since (e.g. 10m), tail (lines per container), follow
+func ApplicationLogs(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	opts := &corev1.PodLogOptions{Follow: c.Query("follow") == "true"}
+	if since := c.Query("since"); since != "" {
+		d, err := time.ParseDuration(since)
+		if err != nil || d <= 0 {
+			apierr.Write(c, apierr.Validation("invalid since '%s', expected a duration like 10m", since))
+			return
+		}
+		seconds := int64(d.Seconds())
+		opts.SinceSeconds = &seconds
+	}
+
+	tail := int64(defaultLogTail)
+	if v := c.Query("tail"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			apierr.Write(c, apierr.Validation("invalid tail '%s', expected a positive number", v))
+			return
+		}
+		tail = n
+	}
+	if tail > 0 {
+		opts.TailLines = &tail
+	}
+
+	selector, err := labels.Parse(c.Query("selector"))
+	if err != nil {
+		apierr.Write(c, apierr.Validation("invalid selector: %v", err))
+		return
+	}
+
+	target, resources, err := appTargetResources(c)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	client, err := GetDestKubernetesClient(&argocdv1alpha1.Cluster{Name: target.Cluster, Server: target.Server})
+	if err != nil {
+		apierr.Write(c, apierr.Unavailable("failed to connect to cluster '%s'", target.Cluster).Wrap(err))
+		return
+	}
+
+	pods, err := appPods(ctx, client, resources, c.Query("pod"), selector)
+	if err != nil {
+		apierr.Write(c, apierr.Unavailable("failed to list the pods of the application").Wrap(err))
+		return
+	}
+
+	streams := podLogStreams(pods, c.Query("container"))
+	if len(streams) == 0 {
+		apierr.Write(c, apierr.NotFound("no container of application '%s' matches the request", c.Param("name")))
+		return
+	}
+	if len(streams) > maxLogStreams {
+		apierr.Write(c, apierr.Validation("the request matches %d containers, at most %d, select them with pod, container or selector", len(streams), maxLogStreams))
+		return
+	}
+
+	log.G(ctx).WithFields(log.Fields{
+		"application": c.Param("name"),
+		"cluster":     target.Cluster,
+		"containers":  len(streams),
+		"follow":      opts.Follow,
+	}).Debug("streaming application logs")
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	writePodLogs(ctx, c.Writer, client, streams, opts)
+}
+
+// ApplicationKubeEvents returns the kubernetes events of the resources of the application, the most recent first
+// query: cluster, required when the application has several targets
+// query: type (Normal, Warning)
+func ApplicationKubeEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	target, resources, err := appTargetResources(c)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	client, err := GetDestKubernetesClient(&argocdv1alpha1.Cluster{Name: target.Cluster, Server: target.Server})
+	if err != nil {
+		apierr.Write(c, apierr.Unavailable("failed to connect to cluster '%s'", target.Cluster).Wrap(err))
+		return
+	}
+
+	events, err := appKubeEvents(ctx, client, target.Namespace, resources)
+	if err != nil {
+		apierr.Write(c, apierr.Unavailable("failed to list the events of the application").Wrap(err))
+		return
+	}
+
+	matched := make([]types.KubeEvent, 0, len(events))
+	for _, event := range events {
+		if matchQuery(c, "type", event.Type) {
+			matched = append(matched, event)
+		}
+	}
+
+	c.JSON(http.StatusOK, types.ApplicationKubeEventsResponse{
+		Success:   true,
+		Message:   "application events listed successfully",
+		Cluster:   target.Cluster,
+		Namespace: target.Namespace,
+		Total:     len(matched),
+		Items:     matched,
+	})
+}
+
+// appTargetResources returns the target of the application on the cluster of the request, or its only target,
+// with the resources of its ArgoCD application. Logs and events are limited to these resources
+func appTargetResources(c *gin.Context) (*types.ApplicationTarget, []types.ApplicationResource, error) {
+	tenant := c.GetString(middleware.TenantKey)
+	appName := c.Param("name")
+	ctx := c.Request.Context()
+
+	app, err := repowriter.TenantRepo(tenant).RunAppGet(ctx, appName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get application detail: %w", err)
+	}
+
+	target, err := selectTarget(app, c.Query("cluster"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	trees, err := argocd.ResourceTrees(ctx, target.ArgoApplication)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tree, ok := trees[target.ArgoApplication]
+	if !ok {
+		return nil, nil, apierr.NotFound("application '%s' is not installed on cluster '%s'", appName, target.Cluster)
+	}
+
+	return target, treeResources(nil, tree), nil
+}
+
+// selectTarget returns the target of the application on cluster, cluster can be empty when the application has one target
+func selectTarget(app *types.Application, cluster string) (*types.ApplicationTarget, error) {
+	name := app.ApplicationInstantiation.ApplicationName
+	if cluster == "" {
+		if len(app.ApplicationTarget) != 1 {
+			return nil, apierr.Validation("application '%s' has %d targets, select one with cluster", name, len(app.ApplicationTarget))
+		}
+
+		return &app.ApplicationTarget[0], nil
+	}
+
+	for i := range app.ApplicationTarget {
+		if app.ApplicationTarget[i].Cluster == cluster {
+			return &app.ApplicationTarget[i], nil
+		}
+	}
+
+	return nil, apierr.NotFound("application '%s' has no target on cluster '%s'", name, cluster)
+}
+
+// appPods returns the pods of the resources that match the selector, and name when it is set
+func appPods(ctx context.Context, client kubernetes.Interface, resources []types.ApplicationResource, name string, selector labels.Selector) ([]corev1.Pod, error) {
+	owned := map[string]map[string]bool{}
+	for _, r := range resources {
+		if !isPod(r) || (name != "" && r.Name != name) {
+			continue
+		}
+		if owned[r.Namespace] == nil {
+			owned[r.Namespace] = map[string]bool{}
+		}
+		owned[r.Namespace][r.Name] = true
+	}
+
+	pods := []corev1.Pod{}
+	for namespace, names := range owned {
+		list, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods in namespace '%s': %w", namespace, err)
+		}
+
+		for _, pod := range list.Items {
+			if names[pod.Name] {
+				pods = append(pods, pod)
+			}
+		}
+	}
+
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		return pods[i].Name < pods[j].Name
+	})
+
+	return pods, nil
+}
+
+// podLogStreams returns the containers of the pods, only container when it is set
+func podLogStreams(pods []corev1.Pod, container string) []logStream {
+	streams := []logStream{}
+	for _, pod := range pods {
+		for _, c := range pod.Spec.Containers {
+			if container == "" || c.Name == container {
+				streams = append(streams, logStream{namespace: pod.Namespace, pod: pod.Name, container: c.Name})
+			}
+		}
+	}
+
+	return streams
+}
+
+// writePodLogs writes the logs of the containers to w as they are read, one line at a time. The logs of the
+// containers are read concurrently, so that followed streams are interleaved. A stream that fails is
+// reported in its own line, as the response is already written
+func writePodLogs(ctx context.Context, w io.Writer, client kubernetes.Interface, streams []logStream, opts *corev1.PodLogOptions) {
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	writeLine := func(s logStream, line string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		fmt.Fprintf(w, "[%s/%s] %s\n", s.pod, s.container, line)
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	for _, s := range streams {
+		wg.Add(1)
+		go func(s logStream) {
+			defer wg.Done()
+
+			containerOpts := opts.DeepCopy()
+			containerOpts.Container = s.container
+			rc, err := client.CoreV1().Pods(s.namespace).GetLogs(s.pod, containerOpts).Stream(ctx)
+			if err != nil {
+				writeLine(s, fmt.Sprintf("error: failed to read logs: %v", err))
+				return
+			}
+			defer rc.Close()
+
+			scanner := bufio.NewScanner(rc)
+			scanner.Buffer(make([]byte, 0, 64*1024), maxLogLine)
+			for scanner.Scan() {
+				writeLine(s, scanner.Text())
+			}
+			if err := scanner.Err(); err != nil && ctx.Err() == nil {
+				writeLine(s, fmt.Sprintf("error: failed to read logs: %v", err))
+			}
+		}(s)
+	}
+
+	wg.Wait()
+}
+
+// appKubeEvents returns the events of the resources, the most recent first. The events are listed in the
+// namespaces of the resources, or in namespace when no resource is namespaced
+func appKubeEvents(ctx context.Context, client kubernetes.Interface, namespace string, resources []types.ApplicationResource) ([]types.KubeEvent, error) {
+	owned := map[corev1.ObjectReference]bool{}
+	namespaces := map[string]bool{}
+	for _, r := range resources {
+		owned[corev1.ObjectReference{Kind: r.Kind, Namespace: r.Namespace, Name: r.Name}] = true
+		if r.Namespace != "" {
+			namespaces[r.Namespace] = true
+		}
+	}
+	if len(namespaces) == 0 && namespace != "" {
+		namespaces[namespace] = true
+	}
+
+	events := []types.KubeEvent{}
+	for ns := range namespaces {
+		list, err := client.CoreV1().Events(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list events in namespace '%s': %w", ns, err)
+		}
+
+		for _, e := range list.Items {
+			involved := e.InvolvedObject
+			if !owned[corev1.ObjectReference{Kind: involved.Kind, Namespace: involved.Namespace, Name: involved.Name}] {
+				continue
+			}
+
+			events = append(events, types.KubeEvent{
+				Type:          e.Type,
+				Reason:        e.Reason,
+				Message:       e.Message,
+				Kind:          involved.Kind,
+				Namespace:     involved.Namespace,
+				Name:          involved.Name,
+				Source:        eventSource(&e),
+				Count:         e.Count,
+				LastTimestamp: eventTime(&e),
+			})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].LastTimestamp.After(events[j].LastTimestamp)
+	})
+
+	return events, nil
+}
+
+// eventTime returns the last time the event occurred, events of the events.k8s.io api only set EventTime
+func eventTime(e *corev1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	default:
+		return e.CreationTimestamp.Time
+	}
+}
+
+func eventSource(e *corev1.Event) string {
+	if e.Source.Component != "" {
+		return e.Source.Component
+	}
+
+	return e.ReportingController
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/types"
+)
+
+var appResources = []types.ApplicationResource{
+	{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "web", Name: "web"},
+	{Version: "v1", Kind: "Pod", Namespace: "web", Name: "web-1"},
+	{Version: "v1", Kind: "Pod", Namespace: "web", Name: "web-2"},
+}
+
+func newAppPod(name string, podLabels map[string]string, containers ...string) *corev1.Pod {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "web", Labels: podLabels}}
+	for _, c := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: c})
+	}
+
+	return pod
+}
+
+func Test_selectTarget(t *testing.T) {
+	tests := map[string]struct {
+		targets  []types.ApplicationTarget
+		cluster  string
+		want     string
+		wantCode apierr.Code
+	}{
+		"the only target": {
+			targets: []types.ApplicationTarget{{Cluster: "dev"}},
+			want:    "dev",
+		},
+		"the target on the cluster": {
+			targets: []types.ApplicationTarget{{Cluster: "dev"}, {Cluster: "sit"}},
+			cluster: "sit",
+			want:    "sit",
+		},
+		"cluster is required with several targets": {
+			targets:  []types.ApplicationTarget{{Cluster: "dev"}, {Cluster: "sit"}},
+			wantCode: apierr.CodeValidation,
+		},
+		"no target on the cluster": {
+			targets:  []types.ApplicationTarget{{Cluster: "dev"}},
+			cluster:  "prd",
+			wantCode: apierr.CodeNotFound,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := selectTarget(&types.Application{ApplicationTarget: tt.targets}, tt.cluster)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apierr.CodeOf(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.Cluster)
+		})
+	}
+}
+
+func Test_appPods(t *testing.T) {
+	tests := map[string]struct {
+		name     string
+		selector string
+		want     []string
+	}{
+		"pods of the application": {
+			want: []string{"web-1", "web-2"},
+		},
+		"pod by name": {
+			name: "web-2",
+			want: []string{"web-2"},
+		},
+		"pods by selector": {
+			selector: "track=canary",
+			want:     []string{"web-2"},
+		},
+		"pod of another application": {
+			name: "other",
+			want: []string{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := kubefake.NewSimpleClientset(
+				newAppPod("web-1", map[string]string{"track": "stable"}, "web"),
+				newAppPod("web-2", map[string]string{"track": "canary"}, "web"),
+				newAppPod("other", map[string]string{"track": "canary"}, "other"),
+			)
+			selector, _ := labels.Parse(tt.selector)
+
+			pods, err := appPods(context.Background(), client, appResources, tt.name, selector)
+
+			assert.NoError(t, err)
+			got := []string{}
+			for _, pod := range pods {
+				got = append(got, pod.Name)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_writePodLogs(t *testing.T) {
+	pods := []corev1.Pod{*newAppPod("web-1", nil, "web", "proxy")}
+	streams := podLogStreams(pods, "")
+	assert.Equal(t, []logStream{
+		{namespace: "web", pod: "web-1", container: "web"},
+		{namespace: "web", pod: "web-1", container: "proxy"},
+	}, streams)
+	assert.Equal(t, []logStream{{namespace: "web", pod: "web-1", container: "proxy"}}, podLogStreams(pods, "proxy"))
+
+	var out bytes.Buffer
+	writePodLogs(context.Background(), &out, kubefake.NewSimpleClientset(), streams, &corev1.PodLogOptions{})
+
+	// the fake clientset answers every log request with "fake logs"
+	assert.ElementsMatch(t, []string{"[web-1/web] fake logs", "[web-1/proxy] fake logs"}, strings.Split(strings.TrimSpace(out.String()), "\n"))
+}
+
+func Test_appKubeEvents(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	newEvent := func(name, kind, object, reason string, last time.Time) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "web"},
+			InvolvedObject: corev1.ObjectReference{Kind: kind, Namespace: "web", Name: object},
+			Type:           corev1.EventTypeWarning,
+			Reason:         reason,
+			Source:         corev1.EventSource{Component: "kubelet"},
+			Count:          1,
+			LastTimestamp:  metav1.NewTime(last),
+		}
+	}
+	client := kubefake.NewSimpleClientset(
+		newEvent("e1", "Pod", "web-1", "BackOff", now.Add(-time.Minute)),
+		newEvent("e2", "Deployment", "web", "ScalingReplicaSet", now),
+		newEvent("e3", "Pod", "other", "BackOff", now),
+	)
+
+	got, err := appKubeEvents(context.Background(), client, "web", appResources)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []types.KubeEvent{
+		{Type: "Warning", Reason: "ScalingReplicaSet", Kind: "Deployment", Namespace: "web", Name: "web", Source: "kubelet", Count: 1, LastTimestamp: now},
+		{Type: "Warning", Reason: "BackOff", Kind: "Pod", Namespace: "web", Name: "web-1", Source: "kubelet", Count: 1, LastTimestamp: now.Add(-time.Minute)},
+	}, got)
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/metrics"
+	"github.com/squidflow/service/pkg/middleware"
+	"github.com/squidflow/service/pkg/operation"
+	"github.com/squidflow/service/pkg/ratelimit"
+	repowriter "github.com/squidflow/service/pkg/repo/writer"
+)
+
+// repoBusyRetryAfter is the Retry-After of requests rejected because the repository of the tenant
+// has the maximum number of writer operations in flight
+const repoBusyRetryAfter = 5 * time.Second
+
+var errAsyncDisabled = apierr.NotImplemented("asynchronous operations are not enabled")
+
+// executeOperation runs fn within the request, or submits it to the operation workers when the
+// client asks for an asynchronous response with `?async=true` or `Prefer: respond-async`.
+// The result of fn is the response body on success. Its error is the failure response, errors that
+// carry no API error get failureCode, failed renders are counted for the tenant.
+// fn holds a writer slot of the tenant repository while it runs, a request is rejected with 429
+// when no slot is free, an asynchronous operation waits for one
+func executeOperation(c *gin.Context, fn operation.Func, successCode int, failureCode apierr.Code) {
+	executeRepoOperation(c, repowriter.RepoURL(c.GetString(middleware.TenantKey)), fn, successCode, failureCode)
+}
+
+// executeMetaOperation is executeOperation for fn that write to the meta repo, it holds a writer
+// slot of the meta repo whatever the repository of the tenant
+func executeMetaOperation(c *gin.Context, fn operation.Func, successCode int, failureCode apierr.Code) {
+	executeRepoOperation(c, repowriter.MetaRepoURL(), fn, successCode, failureCode)
+}
+
+func executeRepoOperation(c *gin.Context, repo string, fn operation.Func, successCode int, failureCode apierr.Code) {
+	repos, _ := c.Value(middleware.RepoLimiterKey).(*ratelimit.RepoLimiter)
+	tenant := c.GetString(middleware.TenantKey)
+
+	if !isAsyncRequest(c) {
+		release, ok := repos.TryAcquire(repo)
+		if !ok {
+			middleware.AbortTooManyRequests(c, repoBusyRetryAfter, "too many operations in flight on the gitops repository, retry later")
+			return
+		}
+		defer release()
+
+		result, err := fn(c.Request.Context())
+		if err != nil {
+			metrics.ObserveRenderFailure(tenant, err)
+			apierr.Write(c, apierr.Ensure(err, failureCode))
+			return
+		}
+
+		c.JSON(successCode, result)
+		return
+	}
+
+	manager, ok := c.Value(middleware.OperationManagerKey).(*operation.Manager)
+	if !ok {
+		apierr.Write(c, errAsyncDisabled)
+		return
+	}
+
+	op, err := manager.Submit(c.Request.Context(), &operation.Operation{
+		Action:    middleware.AuditAction(c),
+		Target:    middleware.AuditTarget(c).Name,
+		Tenant:    tenant,
+		Actor:     c.GetString(middleware.UserNameKey),
+		RequestID: c.GetString("RequestID"),
+	}, func(ctx context.Context) (interface{}, error) {
+		release, ok := repos.TryAcquire(repo)
+		if !ok {
+			operation.Report(ctx, "waiting for a writer slot of the gitops repository")
+			var err error
+			if release, err = repos.Acquire(ctx, repo); err != nil {
+				return nil, err
+			}
+		}
+		defer release()
+
+		result, err := fn(ctx)
+		metrics.ObserveRenderFailure(tenant, err)
+		return result, apierr.Ensure(err, failureCode)
+	})
+	if err != nil {
+		if errors.Is(err, operation.ErrQueueFull) {
+			c.Header("Retry-After", "10")
+			apierr.Write(c, apierr.Unavailable("%v", err))
+			return
+		}
+		apierr.Write(c, apierr.Internal(err, "failed to submit operation"))
+		return
+	}
+
+	statusURL := "/api/v1/operations/" + op.ID
+	c.Header("Location", statusURL)
+	c.Header("Preference-Applied", "respond-async")
+	c.JSON(202, gin.H{
+		"operation_id": op.ID,
+		"phase":        op.Phase,
+		"status_url":   statusURL,
+	})
+}
+
+func isAsyncRequest(c *gin.Context) bool {
+	if c.Query("async") == "true" {
+		return true
+	}
+
+	for _, pref := range strings.Split(c.GetHeader("Prefer"), ",") {
+		if strings.TrimSpace(pref) == "respond-async" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OperationGet returns the phase, progress messages, revisions and result of an operation of the tenant
+func OperationGet(c *gin.Context) {
+	op, ok := getTenantOperation(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(200, op)
+}
+
+// OperationCancel cancels an operation of the tenant that has not finished yet
+func OperationCancel(c *gin.Context) {
+	op, ok := getTenantOperation(c)
+	if !ok {
+		return
+	}
+
+	manager := c.Value(middleware.OperationManagerKey).(*operation.Manager)
+	op, err := manager.Cancel(op.ID)
+	if err != nil {
+		if errors.Is(err, operation.ErrFinished) {
+			apierr.Write(c, apierr.Conflict("%v", err).WithDetails(map[string]interface{}{"operation": op}))
+			return
+		}
+		apierr.Write(c, apierr.Internal(err, "failed to cancel operation"))
+		return
+	}
+
+	c.JSON(202, op)
+}
+
+func getTenantOperation(c *gin.Context) (*operation.Operation, bool) {
+	manager, ok := c.Value(middleware.OperationManagerKey).(*operation.Manager)
+	if !ok {
+		apierr.Write(c, errAsyncDisabled)
+		return nil, false
+	}
+
+	op, err := manager.Get(c.Param("id"))
+	// operations of other tenants are reported as missing
+	if err != nil || op.Tenant != c.GetString(middleware.TenantKey) {
+		apierr.Write(c, apierr.NotFound("%v", operation.ErrNotFound))
+		return nil, false
+	}
+
+	return op, true
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/middleware"
+	"github.com/squidflow/service/pkg/ratelimit"
+)
+
+func Test_executeMetaOperation(t *testing.T) {
+	viper.Set("application_repo.remote_url", "https://github.com/squidflow/meta.git")
+	defer viper.Set("application_repo.remote_url", "")
+
+	tests := map[string]struct {
+		busyRepo string
+		wantCode int
+	}{
+		"Should run when the meta repo has a free slot": {
+			busyRepo: "https://github.com/squidflow/tenant1.git",
+			wantCode: 201,
+		},
+		"Should reject when the meta repo has no free slot": {
+			busyRepo: "https://github.com/squidflow/meta.git",
+			wantCode: 429,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			repos := ratelimit.NewRepoLimiter(1)
+			release, ok := repos.TryAcquire(tt.busyRepo)
+			assert.True(t, ok)
+			defer release()
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/tenants", nil)
+			c.Set(middleware.TenantKey, "tenant1")
+			c.Set(middleware.RepoLimiterKey, repos)
+
+			executeMetaOperation(c, func(ctx context.Context) (interface{}, error) {
+				return gin.H{"message": "created"}, nil
+			}, 201, apierr.CodeInternal)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	"github.com/gin-gonic/gin"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/argocd"
+	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/middleware"
+	"github.com/squidflow/service/pkg/operation"
+	repowriter "github.com/squidflow/service/pkg/repo/writer"
+	"github.com/squidflow/service/pkg/types"
+)
+
+// promotionOrder is the order of the environments that applications are promoted through,
+// the environment of a cluster is its env annotation
+var promotionOrder = []string{"DEV", "SIT", "UAT", "PRD"}
+
+// ApplicationPromote promotes the application to an environment. The revision of the gitops repo deployed
+// on the environment below is deployed to the targets of the environment, once it is Healthy and Synced there
+func ApplicationPromote(c *gin.Context) {
+	username := c.GetString(middleware.UserNameKey)
+	tenant := c.GetString(middleware.TenantKey)
+	appName := c.Param("name")
+	ctx := c.Request.Context()
+	middleware.SetAuditTarget(c, appName)
+
+	var req types.ApplicationPromoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Write(c, apierr.Validation("Invalid request body: %v", err))
+		return
+	}
+	from := promotionOrder[slices.Index(promotionOrder, req.To)-1]
+
+	app, err := repowriter.TenantRepo(tenant).RunAppGet(ctx, appName)
+	if err != nil {
+		apierr.Write(c, fmt.Errorf("failed to get application detail: %w", err))
+		return
+	}
+
+	envs, err := clusterEnvironments(ctx)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	lower, upper := targetsOfEnv(app, envs, from), targetsOfEnv(app, envs, req.To)
+	if len(lower) == 0 || len(upper) == 0 {
+		apierr.Write(c, apierr.Unprocessable("application '%s' must be deployed to '%s' and '%s' to be promoted", appName, from, req.To))
+		return
+	}
+
+	argoApps, err := listTenantArgoApplications(c, tenant)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	revision, err := verifiedRevision(from, lower, argoApps)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+	if req.Revision != "" && req.Revision != revision {
+		apierr.Write(c, apierr.Conflict("revision '%s' is no longer deployed to '%s', '%s' is", req.Revision, from, revision))
+		return
+	}
+
+	clusters := make([]string, 0, len(upper))
+	for _, target := range upper {
+		clusters = append(clusters, target.Cluster)
+	}
+
+	log.G(ctx).WithFields(log.Fields{
+		"username": username,
+		"tenant":   tenant,
+		"appName":  appName,
+		"from":     from,
+		"to":       req.To,
+		"revision": revision,
+		"clusters": clusters,
+	}).Info("promote application")
+
+	opts := &types.AppPromoteOptions{
+		AppName:  appName,
+		Clusters: clusters,
+		Promotion: types.Promotion{
+			From:       from,
+			Revision:   revision,
+			PromotedBy: username,
+		},
+	}
+	promoted := func(context.Context) ([]string, error) {
+		return clusters, nil
+	}
+	if err := checkFreeze(c, promoted); err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	if requestApproval(c, types.ActionTypePromote, appName, promoted, opts) {
+		return
+	}
+
+	executeOperation(c, func(ctx context.Context) (interface{}, error) {
+		return promoteApplication(ctx, tenant, req.To, opts)
+	}, 200, apierr.CodeInternal)
+}
+
+// promoteApplication deploys the revision of the promotion to the clusters of the environment to
+func promoteApplication(ctx context.Context, tenant, to string, opts *types.AppPromoteOptions) (*types.ApplicationPromoteResponse, error) {
+	operation.Report(ctx, "deploying revision '%s' of application '%s' to %v", opts.Promotion.Revision, opts.AppName, opts.Clusters)
+	opts.Promotion.PromotedAt = time.Now().UTC()
+	commit, err := repowriter.TenantRepo(tenant).RunAppPromote(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to promote application: %w", err)
+	}
+
+	return &types.ApplicationPromoteResponse{
+		Success:  true,
+		Message:  fmt.Sprintf("application '%s' promoted from '%s' to '%s'", opts.AppName, opts.Promotion.From, to),
+		From:     opts.Promotion.From,
+		To:       to,
+		Revision: opts.Promotion.Revision,
+		Clusters: opts.Clusters,
+		Commit:   commit,
+	}, nil
+}
+
+// ApplicationUnpin deploys the targets of the application on the clusters of an environment from the revision that
+// the gitops repo tracks again. A promotion pins the targets to the revision it verified, the later changes of the
+// application only reach them with another promotion, or once they are unpinned
+// query: env, SIT, UAT or PRD
+func ApplicationUnpin(c *gin.Context) {
+	username := c.GetString(middleware.UserNameKey)
+	tenant := c.GetString(middleware.TenantKey)
+	appName := c.Param("name")
+	ctx := c.Request.Context()
+	middleware.SetAuditTarget(c, appName)
+
+	env := c.Query("env")
+	if slices.Index(promotionOrder, env) < 1 {
+		apierr.Write(c, apierr.Validation("env must be one of %v", promotionOrder[1:]))
+		return
+	}
+
+	app, err := repowriter.TenantRepo(tenant).RunAppGet(ctx, appName)
+	if err != nil {
+		apierr.Write(c, fmt.Errorf("failed to get application detail: %w", err))
+		return
+	}
+
+	envs, err := clusterEnvironments(ctx)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	clusters := []string{}
+	for _, target := range targetsOfEnv(app, envs, env) {
+		if target.Promotion != nil {
+			clusters = append(clusters, target.Cluster)
+		}
+	}
+	if len(clusters) == 0 {
+		apierr.Write(c, apierr.Unprocessable("application '%s' is not promoted to '%s'", appName, env))
+		return
+	}
+
+	log.G(ctx).WithFields(log.Fields{
+		"username": username,
+		"tenant":   tenant,
+		"appName":  appName,
+		"env":      env,
+		"clusters": clusters,
+	}).Info("unpin application")
+
+	opts := &types.AppUnpinOptions{
+		AppName:  appName,
+		Clusters: clusters,
+	}
+	unpinned := func(context.Context) ([]string, error) {
+		return clusters, nil
+	}
+	if err := checkFreeze(c, unpinned); err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	if requestApproval(c, types.ActionTypeUnpin, appName, unpinned, opts) {
+		return
+	}
+
+	executeOperation(c, func(ctx context.Context) (interface{}, error) {
+		return unpinApplication(ctx, tenant, env, opts)
+	}, 200, apierr.CodeInternal)
+}
+
+// unpinApplication deploys the targets of the clusters of env from the revision that the gitops repo tracks
+func unpinApplication(ctx context.Context, tenant, env string, opts *types.AppUnpinOptions) (*types.ApplicationUnpinResponse, error) {
+	operation.Report(ctx, "unpinning application '%s' on %v", opts.AppName, opts.Clusters)
+	commit, err := repowriter.TenantRepo(tenant).RunAppUnpin(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpin application: %w", err)
+	}
+
+	return &types.ApplicationUnpinResponse{
+		Success:  true,
+		Message:  fmt.Sprintf("application '%s' unpinned on '%s'", opts.AppName, env),
+		Env:      env,
+		Clusters: opts.Clusters,
+		Commit:   commit,
+	}, nil
+}
+
+// clusterEnvironments returns the environment of the clusters by name
+func clusterEnvironments(ctx context.Context) (map[string]string, error) {
+	clusters, err := argocd.ListClusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	envs := make(map[string]string, len(clusters.Items))
+	for _, cluster := range clusters.Items {
+		envs[cluster.Name] = strings.ToUpper(cluster.Annotations[argocd.AnnotationKeyEnvironment])
+	}
+
+	return envs, nil
+}
+
+// targetsOfEnv returns the targets of the application on the clusters of the environment
+func targetsOfEnv(app *types.Application, envs map[string]string, env string) []types.ApplicationTarget {
+	targets := []types.ApplicationTarget{}
+	for _, target := range app.ApplicationTarget {
+		if envs[target.Cluster] == env {
+			targets = append(targets, target)
+		}
+	}
+
+	return targets
+}
+
+// verifiedRevision returns the revision of the gitops repo deployed to the targets of the environment.
+// Every target must be Healthy and Synced, and deployed from the same revision
+func verifiedRevision(env string, targets []types.ApplicationTarget, argoApps map[string]*argocdv1alpha1.Application) (string, error) {
+	var revision string
+	for _, target := range targets {
+		argoApp, ok := argoApps[target.ArgoApplication]
+		if !ok {
+			return "", apierr.Unprocessable("application is not installed on cluster '%s' of '%s'", target.Cluster, env)
+		}
+
+		if argoApp.Status.Health.Status != health.HealthStatusHealthy || argoApp.Status.Sync.Status != argocdv1alpha1.SyncStatusCodeSynced {
+			return "", apierr.Unprocessable("application is %s and %s on cluster '%s' of '%s', it must be Healthy and Synced to be promoted",
+				getAppHealth(argoApp), getAppSyncStatus(argoApp), target.Cluster, env)
+		}
+
+		if revision != "" && argoApp.Status.Sync.Revision != revision {
+			return "", apierr.Conflict("the clusters of '%s' are deployed from different revisions, '%s' and '%s'", env, revision, argoApp.Status.Sync.Revision)
+		}
+		revision = argoApp.Status.Sync.Revision
+	}
+
+	if revision == "" {
+		return "", apierr.Unprocessable("the revision deployed to '%s' is not known", env)
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"testing"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/types"
+)
+
+func newArgoApp(healthStatus health.HealthStatusCode, syncStatus argocdv1alpha1.SyncStatusCode, revision string) *argocdv1alpha1.Application {
+	return &argocdv1alpha1.Application{
+		Status: argocdv1alpha1.ApplicationStatus{
+			Health: argocdv1alpha1.HealthStatus{Status: healthStatus},
+			Sync:   argocdv1alpha1.SyncStatus{Status: syncStatus, Revision: revision},
+		},
+	}
+}
+
+func Test_targetsOfEnv(t *testing.T) {
+	app := &types.Application{ApplicationTarget: []types.ApplicationTarget{
+		{Cluster: "dev-1"}, {Cluster: "sit-1"}, {Cluster: "dev-2"}, {Cluster: "unknown"},
+	}}
+	envs := map[string]string{"dev-1": "DEV", "dev-2": "DEV", "sit-1": "SIT"}
+
+	assert.Equal(t, []types.ApplicationTarget{{Cluster: "dev-1"}, {Cluster: "dev-2"}}, targetsOfEnv(app, envs, "DEV"))
+	assert.Equal(t, []types.ApplicationTarget{}, targetsOfEnv(app, envs, "PRD"))
+}
+
+func Test_verifiedRevision(t *testing.T) {
+	targets := []types.ApplicationTarget{
+		{Cluster: "dev-1", ArgoApplication: "tenant-app"},
+		{Cluster: "dev-2", ArgoApplication: "tenant-app-dev-2"},
+	}
+	tests := map[string]struct {
+		argoApps map[string]*argocdv1alpha1.Application
+		want     string
+		wantCode apierr.Code
+	}{
+		"healthy and synced on the same revision": {
+			argoApps: map[string]*argocdv1alpha1.Application{
+				"tenant-app":       newArgoApp(health.HealthStatusHealthy, argocdv1alpha1.SyncStatusCodeSynced, "sha1"),
+				"tenant-app-dev-2": newArgoApp(health.HealthStatusHealthy, argocdv1alpha1.SyncStatusCodeSynced, "sha1"),
+			},
+			want: "sha1",
+		},
+		"not installed": {
+			argoApps: map[string]*argocdv1alpha1.Application{
+				"tenant-app": newArgoApp(health.HealthStatusHealthy, argocdv1alpha1.SyncStatusCodeSynced, "sha1"),
+			},
+			wantCode: apierr.CodeUnprocessable,
+		},
+		"degraded": {
+			argoApps: map[string]*argocdv1alpha1.Application{
+				"tenant-app":       newArgoApp(health.HealthStatusHealthy, argocdv1alpha1.SyncStatusCodeSynced, "sha1"),
+				"tenant-app-dev-2": newArgoApp(health.HealthStatusDegraded, argocdv1alpha1.SyncStatusCodeSynced, "sha1"),
+			},
+			wantCode: apierr.CodeUnprocessable,
+		},
+		"out of sync": {
+			argoApps: map[string]*argocdv1alpha1.Application{
+				"tenant-app":       newArgoApp(health.HealthStatusHealthy, argocdv1alpha1.SyncStatusCodeOutOfSync, "sha1"),
+				"tenant-app-dev-2": newArgoApp(health.HealthStatusHealthy, argocdv1alpha1.SyncStatusCodeSynced, "sha1"),
+			},
+			wantCode: apierr.CodeUnprocessable,
+		},
+		"different revisions": {
+			argoApps: map[string]*argocdv1alpha1.Application{
+				"tenant-app":       newArgoApp(health.HealthStatusHealthy, argocdv1alpha1.SyncStatusCodeSynced, "sha1"),
+				"tenant-app-dev-2": newArgoApp(health.HealthStatusHealthy, argocdv1alpha1.SyncStatusCodeSynced, "sha2"),
+			},
+			wantCode: apierr.CodeConflict,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := verifiedRevision("DEV", targets, tt.argoApps)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apierr.CodeOf(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
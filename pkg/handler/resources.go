@@ -0,0 +1,373 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	metricsclientset "k8s.io/metrics/pkg/client/clientset/versioned"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/argocd"
+	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/middleware"
+	repowriter "github.com/squidflow/service/pkg/repo/writer"
+	"github.com/squidflow/service/pkg/types"
+)
+
+// ApplicationResources returns the ArgoCD resource tree of each target of the application, with the health
+// and the sync status of the resources, and the status and the usage of the pods
+// query: cluster, only return the target on that cluster
+func ApplicationResources(c *gin.Context) {
+	tenant := c.GetString(middleware.TenantKey)
+	appName := c.Param("name")
+	ctx := c.Request.Context()
+
+	app, err := repowriter.TenantRepo(tenant).RunAppGet(ctx, appName)
+	if err != nil {
+		apierr.Write(c, fmt.Errorf("failed to get application detail: %w", err))
+		return
+	}
+
+	cluster := c.Query("cluster")
+	targets := make([]types.ApplicationTarget, 0, len(app.ApplicationTarget))
+	names := make([]string, 0, len(app.ApplicationTarget))
+	for _, target := range app.ApplicationTarget {
+		if cluster != "" && target.Cluster != cluster {
+			continue
+		}
+
+		targets = append(targets, target)
+		names = append(names, target.ArgoApplication)
+	}
+
+	if cluster != "" && len(targets) == 0 {
+		apierr.Write(c, apierr.NotFound("application '%s' has no target on cluster '%s'", appName, cluster))
+		return
+	}
+
+	argoApps, err := listTenantArgoApplications(c, tenant)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	trees, err := argocd.ResourceTrees(ctx, names...)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	clients := destClients{}
+	result := make([]types.ApplicationTargetResources, 0, len(targets))
+	for _, target := range targets {
+		result = append(result, clients.targetResources(ctx, target, argoApps[target.ArgoApplication], trees[target.ArgoApplication]))
+	}
+
+	c.JSON(200, types.ApplicationResourcesResponse{
+		Success: true,
+		Message: "application resources retrieved successfully",
+		Targets: result,
+	})
+}
+
+// resourceMetricsTimeout bounds the time spent reading the resource metrics of applications, the metrics that
+// are not read by then are left empty
+const resourceMetricsTimeout = 10 * time.Second
+
+// setResourceMetrics sets the resource metrics of the applications, summed over their targets. The metrics
+// are part of the runtime status of the applications, a target that can't be read is logged and skipped
+func setResourceMetrics(ctx context.Context, apps ...*types.Application) {
+	ctx, cancel := context.WithTimeout(ctx, resourceMetricsTimeout)
+	defer cancel()
+
+	names := []string{}
+	for _, app := range apps {
+		for _, target := range app.ApplicationTarget {
+			names = append(names, target.ArgoApplication)
+		}
+	}
+
+	trees, err := argocd.ResourceTrees(ctx, names...)
+	if err != nil {
+		log.G(ctx).WithError(err).Warn("failed to get the resource trees, applications are returned without resource metrics")
+		return
+	}
+
+	clients := destClients{}
+	for _, app := range apps {
+		total := types.ResourceMetricsInfo{}
+		for _, target := range app.ApplicationTarget {
+			tree, ok := trees[target.ArgoApplication]
+			if !ok {
+				continue
+			}
+
+			metrics, err := clients.collect(ctx, target, treeResources(nil, tree))
+			if err != nil {
+				log.G(ctx).WithError(err).WithFields(log.Fields{
+					"application": target.ArgoApplication,
+					"cluster":     target.Cluster,
+				}).Warn("failed to read the pods of the application")
+			}
+			addMetrics(&total, metrics)
+		}
+
+		app.ApplicationRuntime.ResourceMetrics = total
+	}
+}
+
+// destClient holds the clients of a destination cluster, or the error of their creation
+type destClient struct {
+	kube    kubernetes.Interface
+	metrics metricsclientset.Interface
+	err     error
+}
+
+// destClients caches the clients of the destination clusters by name for the duration of a request
+type destClients map[string]*destClient
+
+func (d destClients) get(target types.ApplicationTarget) (kubernetes.Interface, metricsclientset.Interface, error) {
+	if client, ok := d[target.Cluster]; ok {
+		return client.kube, client.metrics, client.err
+	}
+
+	client := &destClient{}
+	restConfig, err := GetDestRestConfig(&argocdv1alpha1.Cluster{Name: target.Cluster, Server: target.Server})
+	if err == nil {
+		client.kube, err = kubernetes.NewForConfig(restConfig)
+	}
+	if err == nil {
+		client.metrics, err = metricsclientset.NewForConfig(restConfig)
+	}
+	client.err = err
+	d[target.Cluster] = client
+
+	return client.kube, client.metrics, client.err
+}
+
+// targetResources returns the resource tree of the ArgoCD application of the target,
+// an application that is not installed or a cluster that can't be reached is reported in the target
+func (d destClients) targetResources(ctx context.Context, target types.ApplicationTarget, argoApp *argocdv1alpha1.Application, tree *argocdv1alpha1.ApplicationTree) types.ApplicationTargetResources {
+	result := types.ApplicationTargetResources{
+		Cluster:         target.Cluster,
+		Namespace:       target.Namespace,
+		ArgoApplication: target.ArgoApplication,
+		Health:          getAppHealth(argoApp),
+		SyncStatus:      getAppSyncStatus(argoApp),
+		Resources:       []types.ApplicationResource{},
+	}
+
+	if argoApp == nil || tree == nil {
+		result.Error = fmt.Sprintf("application '%s' is not installed in argocd", target.ArgoApplication)
+		return result
+	}
+
+	result.Resources = treeResources(argoApp, tree)
+	metrics, err := d.collect(ctx, target, result.Resources)
+	if err != nil {
+		log.G(ctx).WithError(err).WithField("cluster", target.Cluster).Warn("failed to read the pods of the application")
+		result.Error = err.Error()
+	}
+	result.Metrics = metrics
+
+	return result
+}
+
+// collect sets the status of the pods of the resources from the destination cluster of the target,
+// and returns the metrics of the resources
+func (d destClients) collect(ctx context.Context, target types.ApplicationTarget, resources []types.ApplicationResource) (types.ResourceMetricsInfo, error) {
+	metrics := types.ResourceMetricsInfo{}
+	for _, r := range resources {
+		switch {
+		case isPod(r):
+			metrics.PodCount++
+		case r.Group == "" && r.Kind == "Secret":
+			metrics.SecretCount++
+		}
+	}
+
+	if metrics.PodCount == 0 {
+		return metrics, nil
+	}
+
+	kubeClient, metricsClient, err := d.get(target)
+	if err != nil {
+		return metrics, fmt.Errorf("failed to connect to cluster '%s': %w", target.Cluster, err)
+	}
+
+	err = setPodStatus(ctx, kubeClient, metricsClient, resources, &metrics)
+
+	return metrics, err
+}
+
+// treeResources returns the nodes of the resource tree, with the sync status of the resources managed by
+// the ArgoCD application. argoApp may be nil when the sync status is not needed
+func treeResources(argoApp *argocdv1alpha1.Application, tree *argocdv1alpha1.ApplicationTree) []types.ApplicationResource {
+	syncStatus := map[argocdv1alpha1.ResourceRef]string{}
+	if argoApp != nil {
+		for _, r := range argoApp.Status.Resources {
+			syncStatus[argocdv1alpha1.ResourceRef{Group: r.Group, Kind: r.Kind, Namespace: r.Namespace, Name: r.Name}] = string(r.Status)
+		}
+	}
+
+	resources := make([]types.ApplicationResource, 0, len(tree.Nodes))
+	for _, node := range tree.Nodes {
+		r := types.ApplicationResource{
+			Group:      node.Group,
+			Version:    node.Version,
+			Kind:       node.Kind,
+			Namespace:  node.Namespace,
+			Name:       node.Name,
+			SyncStatus: syncStatus[argocdv1alpha1.ResourceRef{Group: node.Group, Kind: node.Kind, Namespace: node.Namespace, Name: node.Name}],
+		}
+		if node.Health != nil {
+			r.Health = string(node.Health.Status)
+			r.HealthMessage = node.Health.Message
+		}
+		for _, parent := range node.ParentRefs {
+			r.ParentRefs = append(r.ParentRefs, types.ResourceRef{
+				Group:     parent.Group,
+				Kind:      parent.Kind,
+				Namespace: parent.Namespace,
+				Name:      parent.Name,
+			})
+		}
+
+		resources = append(resources, r)
+	}
+
+	return resources
+}
+
+// setPodStatus sets the status and the usage of the pods of the resources, and adds them to metrics.
+// The usage is left empty when the metrics API is not available on the cluster
+func setPodStatus(ctx context.Context, kubeClient kubernetes.Interface, metricsClient metricsclientset.Interface, resources []types.ApplicationResource, metrics *types.ResourceMetricsInfo) error {
+	namespaces := map[string]bool{}
+	for _, r := range resources {
+		if isPod(r) {
+			namespaces[r.Namespace] = true
+		}
+	}
+
+	for namespace := range namespaces {
+		pods, err := kubeClient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to list pods in namespace '%s': %w", namespace, err)
+		}
+
+		byName := make(map[string]*corev1.Pod, len(pods.Items))
+		for i := range pods.Items {
+			byName[pods.Items[i].Name] = &pods.Items[i]
+		}
+
+		usage := map[string]corev1.ResourceList{}
+		podMetrics, err := metricsClient.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			log.G(ctx).WithError(err).WithField("namespace", namespace).Debug("failed to read the pod metrics")
+		} else {
+			for _, m := range podMetrics.Items {
+				total := corev1.ResourceList{}
+				for _, container := range m.Containers {
+					for name, q := range container.Usage {
+						sum := total[name]
+						sum.Add(q)
+						total[name] = sum
+					}
+				}
+				usage[m.Name] = total
+			}
+		}
+
+		for i := range resources {
+			if !isPod(resources[i]) || resources[i].Namespace != namespace {
+				continue
+			}
+
+			pod, ok := byName[resources[i].Name]
+			if !ok {
+				continue
+			}
+
+			status := podStatus(pod)
+			if u, ok := usage[pod.Name]; ok {
+				status.CPU = quantityString(u, corev1.ResourceCPU)
+				status.Memory = quantityString(u, corev1.ResourceMemory)
+			}
+			resources[i].Pod = status
+
+			metrics.Restarts += status.Restarts
+			metrics.CPU = addQuantity(metrics.CPU, status.CPU)
+			metrics.Memory = addQuantity(metrics.Memory, status.Memory)
+		}
+	}
+
+	return nil
+}
+
+func isPod(r types.ApplicationResource) bool {
+	return r.Group == "" && r.Kind == "Pod"
+}
+
+func podStatus(pod *corev1.Pod) *types.PodStatus {
+	var ready int
+	status := &types.PodStatus{
+		Phase: string(pod.Status.Phase),
+		Node:  pod.Spec.NodeName,
+	}
+	for _, container := range pod.Status.ContainerStatuses {
+		if container.Ready {
+			ready++
+		}
+		status.Restarts += container.RestartCount
+	}
+	status.Ready = fmt.Sprintf("%d/%d", ready, len(pod.Spec.Containers))
+
+	return status
+}
+
+func quantityString(list corev1.ResourceList, name corev1.ResourceName) string {
+	q, ok := list[name]
+	if !ok {
+		return ""
+	}
+
+	return q.String()
+}
+
+// addMetrics adds the metrics of a target to the metrics of the application
+func addMetrics(total *types.ResourceMetricsInfo, m types.ResourceMetricsInfo) {
+	total.PodCount += m.PodCount
+	total.SecretCount += m.SecretCount
+	total.Restarts += m.Restarts
+	total.CPU = addQuantity(total.CPU, m.CPU)
+	total.Memory = addQuantity(total.Memory, m.Memory)
+}
+
+// addQuantity adds two quantities, an empty quantity is not known and is ignored
+func addQuantity(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+
+	qa, err := resource.ParseQuantity(a)
+	if err != nil {
+		return b
+	}
+	qb, err := resource.ParseQuantity(b)
+	if err != nil {
+		return a
+	}
+	qa.Add(qb)
+
+	return qa.String()
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+
+	"github.com/squidflow/service/pkg/types"
+)
+
+func Test_treeResources(t *testing.T) {
+	deployment := argocdv1alpha1.ResourceRef{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "web", Name: "web"}
+	argoApp := &argocdv1alpha1.Application{
+		Status: argocdv1alpha1.ApplicationStatus{
+			Resources: []argocdv1alpha1.ResourceStatus{
+				{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "web", Name: "web", Status: argocdv1alpha1.SyncStatusCodeOutOfSync},
+			},
+		},
+	}
+	tree := &argocdv1alpha1.ApplicationTree{
+		Nodes: []argocdv1alpha1.ResourceNode{
+			{
+				ResourceRef: deployment,
+				Health:      &argocdv1alpha1.HealthStatus{Status: health.HealthStatusProgressing, Message: "waiting for rollout"},
+			},
+			{
+				ResourceRef: argocdv1alpha1.ResourceRef{Version: "v1", Kind: "Pod", Namespace: "web", Name: "web-1"},
+				ParentRefs:  []argocdv1alpha1.ResourceRef{deployment},
+				Health:      &argocdv1alpha1.HealthStatus{Status: health.HealthStatusHealthy},
+			},
+		},
+	}
+
+	assert.Equal(t, []types.ApplicationResource{
+		{
+			Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "web", Name: "web",
+			Health: "Progressing", HealthMessage: "waiting for rollout", SyncStatus: "OutOfSync",
+		},
+		{
+			Version: "v1", Kind: "Pod", Namespace: "web", Name: "web-1", Health: "Healthy",
+			ParentRefs: []types.ResourceRef{{Group: "apps", Kind: "Deployment", Namespace: "web", Name: "web"}},
+		},
+	}, treeResources(argoApp, tree))
+}
+
+func newPod(name string, restarts int32, ready bool) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "web"},
+		Spec: corev1.PodSpec{
+			NodeName:   "node-1",
+			Containers: []corev1.Container{{Name: "web"}},
+		},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "web", Ready: ready, RestartCount: restarts}},
+		},
+	}
+}
+
+func newPodMetrics(name, cpu, memory string) *metricsv1beta1.PodMetrics {
+	return &metricsv1beta1.PodMetrics{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "web"},
+		Containers: []metricsv1beta1.ContainerMetrics{{
+			Name: "web",
+			Usage: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(memory),
+			},
+		}},
+	}
+}
+
+func Test_setPodStatus(t *testing.T) {
+	tests := map[string]struct {
+		podMetrics []*metricsv1beta1.PodMetrics
+		wantPods   []*types.PodStatus
+		want       types.ResourceMetricsInfo
+	}{
+		"pods with their usage": {
+			podMetrics: []*metricsv1beta1.PodMetrics{
+				newPodMetrics("web-1", "100m", "64Mi"),
+				newPodMetrics("web-2", "150m", "128Mi"),
+			},
+			wantPods: []*types.PodStatus{
+				{Phase: "Running", Ready: "1/1", Restarts: 2, Node: "node-1", CPU: "100m", Memory: "64Mi"},
+				{Phase: "Running", Ready: "0/1", Restarts: 1, Node: "node-1", CPU: "150m", Memory: "128Mi"},
+				nil,
+			},
+			want: types.ResourceMetricsInfo{Restarts: 3, CPU: "250m", Memory: "192Mi"},
+		},
+		"metrics api not available": {
+			wantPods: []*types.PodStatus{
+				{Phase: "Running", Ready: "1/1", Restarts: 2, Node: "node-1"},
+				{Phase: "Running", Ready: "0/1", Restarts: 1, Node: "node-1"},
+				nil,
+			},
+			want: types.ResourceMetricsInfo{Restarts: 3},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			kubeClient := kubefake.NewSimpleClientset(newPod("web-1", 2, true), newPod("web-2", 1, false))
+			metricsClient := metricsfake.NewSimpleClientset()
+			// the fake clientset guesses the resource of pod metrics wrong, they are added to the tracker with the real one
+			for _, m := range tt.podMetrics {
+				_ = metricsClient.Tracker().Create(metricsv1beta1.SchemeGroupVersion.WithResource("pods"), m, m.Namespace)
+			}
+
+			resources := []types.ApplicationResource{
+				{Version: "v1", Kind: "Pod", Namespace: "web", Name: "web-1"},
+				{Version: "v1", Kind: "Pod", Namespace: "web", Name: "web-2"},
+				{Version: "v1", Kind: "Pod", Namespace: "web", Name: "web-3"},
+			}
+			got := types.ResourceMetricsInfo{}
+
+			err := setPodStatus(context.Background(), kubeClient, metricsClient, resources, &got)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			for i := range resources {
+				assert.Equal(t, tt.wantPods[i], resources[i].Pod)
+			}
+		})
+	}
+}
+
+func Test_addMetrics(t *testing.T) {
+	total := types.ResourceMetricsInfo{PodCount: 1, Restarts: 1, CPU: "500m"}
+
+	addMetrics(&total, types.ResourceMetricsInfo{PodCount: 2, SecretCount: 1, CPU: "1", Memory: "1Gi"})
+
+	assert.Equal(t, types.ResourceMetricsInfo{PodCount: 3, SecretCount: 1, Restarts: 1, CPU: "1500m", Memory: "1Gi"}, total)
+}
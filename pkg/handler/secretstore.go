@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
@@ -11,46 +12,50 @@ import (
 	"github.com/spf13/viper"
 	"sigs.k8s.io/yaml"
 
+	"github.com/squidflow/service/pkg/apierr"
 	"github.com/squidflow/service/pkg/fs"
 	"github.com/squidflow/service/pkg/git"
 	"github.com/squidflow/service/pkg/log"
 	"github.com/squidflow/service/pkg/middleware"
+	"github.com/squidflow/service/pkg/operation"
+	"github.com/squidflow/service/pkg/rbac"
 	repowriter "github.com/squidflow/service/pkg/repo/writer"
+	"github.com/squidflow/service/pkg/secretstore"
+	"github.com/squidflow/service/pkg/store"
 	"github.com/squidflow/service/pkg/types"
 )
 
 func SecretStoreCreate(c *gin.Context) {
 	var req types.SecretStoreCreateReq
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		apierr.Write(c, apierr.Validation("Invalid request: %v", err))
 		return
 	}
 
 	tenant := c.GetString(middleware.TenantKey)
 	if tenant == "" {
-		c.JSON(400, gin.H{"error": "Tenant is required"})
+		apierr.Write(c, apierr.Validation("Tenant is required"))
 		return
 	}
 
-	want := esv1beta1.SecretStore{}
-	err := yaml.Unmarshal([]byte(req.SecretStoreYaml), &want)
-	if err != nil {
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to unmarshal SecretStore: %v", err)})
+	if !middleware.Enforce(c, tenant, rbac.ResourceSecretStores, rbac.VerbCreate) {
 		return
 	}
 
-	if want.Spec.Provider == nil {
-		c.JSON(400, gin.H{"error": "Provider configuration is required"})
+	want := esv1beta1.SecretStore{}
+	err := yaml.Unmarshal([]byte(req.SecretStoreYaml), &want)
+	if err != nil {
+		apierr.Write(c, apierr.Validation("Failed to unmarshal SecretStore: %v", err))
 		return
 	}
 
-	if want.Spec.Provider.Vault == nil {
-		c.JSON(400, gin.H{"error": "Only Vault provider is supported"})
+	if err := secretstore.Validate(&want); err != nil {
+		apierr.Write(c, err)
 		return
 	}
 
 	if want.Annotations != nil && want.Annotations["squidflow.github.io/id"] != "" {
-		c.JSON(400, gin.H{"error": "id not allow set via client"})
+		apierr.Write(c, apierr.Validation("id not allow set via client"))
 		return
 	}
 	if want.Annotations == nil {
@@ -60,92 +65,98 @@ func SecretStoreCreate(c *gin.Context) {
 	want.Annotations["squidflow.github.io/created-at"] = time.Now().Format(time.RFC3339)
 	want.Annotations["squidflow.github.io/updated-at"] = time.Now().Format(time.RFC3339)
 	want.Annotations["squidflow.github.io/id"] = getNewId()
+	// the secret store is owned by the tenant, only the applications of the tenant use it
+	if want.Labels == nil {
+		want.Labels = make(map[string]string)
+	}
+	want.Labels[store.Default.LabelKeyTenant] = tenant
+	middleware.SetAuditTarget(c, want.Annotations["squidflow.github.io/id"])
 
-	log.G().WithFields(log.Fields{
+	log.G(c.Request.Context()).WithFields(log.Fields{
 		"id": want.Annotations["squidflow.github.io/id"],
 	}).Debug("generated id for secret store")
 
-	log.G().WithFields(log.Fields{
-		"name":          want.Name,
-		"namespace":     want.Namespace,
-		"annotations":   want.Annotations,
-		"vault_auth":    want.Spec.Provider.Vault.Auth,
-		"vault_server":  want.Spec.Provider.Vault.Server,
-		"vault_path":    want.Spec.Provider.Vault.Path,
-		"vault_version": want.Spec.Provider.Vault.Version,
-	}).Debug("Creating SecretStore with Vault provider")
-
-	cloneOpts := &git.CloneOptions{
-		Repo:     viper.GetString("application_repo.remote_url"),
-		FS:       fs.Create(memfs.New()),
-		Provider: "github",
-		Auth: git.Auth{
-			Password: viper.GetString("application_repo.access_token"),
-		},
-		CloneForWrite: true,
-	}
-	cloneOpts.Parse()
-
-	if err := repowriter.TenantRepo(tenant).SecretStoreCreate(context.Background(), &want, false); err != nil {
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to create external secret: %v", err)})
-		return
-	}
-
-	c.JSON(201, types.SecretStoreCreateResponse{
-		Name:    want.Name,
-		ID:      want.Annotations["squidflow.github.io/id"],
-		Success: true,
-		Message: "SecretStore created successfully",
-	})
+	log.G(c.Request.Context()).WithFields(log.Fields{
+		"name":        want.Name,
+		"namespace":   want.Namespace,
+		"annotations": want.Annotations,
+		"provider":    secretstore.Provider(want.Spec.Provider),
+		"type":        secretstore.Type(want.Spec.Provider),
+	}).Debug("Creating SecretStore")
+
+	executeMetaOperation(c, func(ctx context.Context) (interface{}, error) {
+		operation.Report(ctx, "writing secret store '%s' to the gitops repo", want.Name)
+		if err := repowriter.TenantRepo(tenant).SecretStoreCreate(ctx, &want, false); err != nil {
+			return nil, fmt.Errorf("Failed to create external secret: %w", err)
+		}
+
+		return types.SecretStoreCreateResponse{
+			Name:    want.Name,
+			ID:      want.Annotations["squidflow.github.io/id"],
+			Success: true,
+			Message: "SecretStore created successfully",
+		}, nil
+	}, 201, apierr.CodeInternal)
 }
 
 func SecretStoreDelete(c *gin.Context) {
 	secretStoreID := c.Param("id")
 	if secretStoreID == "" {
-		c.JSON(400, gin.H{"error": "SecretStore ID is required"})
+		apierr.Write(c, apierr.Validation("SecretStore ID is required"))
 		return
 	}
 
 	tenant := c.GetString(middleware.TenantKey)
 	if tenant == "" {
-		c.JSON(400, gin.H{"error": "Tenant is required"})
+		apierr.Write(c, apierr.Validation("Tenant is required"))
 		return
 	}
 
-	cloneOpts := &git.CloneOptions{
-		Repo:     viper.GetString("application_repo.remote_url"),
-		FS:       fs.Create(memfs.New()),
-		Provider: "github",
-		Auth: git.Auth{
-			Password: viper.GetString("application_repo.access_token"),
-		},
-		CloneForWrite: true,
-	}
-	cloneOpts.Parse()
-
-	if err := repowriter.TenantRepo(tenant).SecretStoreDelete(context.Background(), secretStoreID); err != nil {
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to delete secret store: %v", err)})
+	if !middleware.Enforce(c, tenant, rbac.ResourceSecretStores, rbac.VerbDelete) {
 		return
 	}
-
-	c.JSON(200, types.DeleteSecretStoreResponse{
-		Success: true,
-		Message: "secret store deleted successfully",
-	})
+	manageShared := middleware.Allowed(c, rbac.AllTenants, rbac.ResourceSecretStores, rbac.VerbDelete)
+
+	executeMetaOperation(c, func(ctx context.Context) (interface{}, error) {
+		secretStore, err := repowriter.TenantRepo(tenant).SecretStoreGet(ctx, secretStoreID)
+		if err != nil && apierr.CodeOf(err) != apierr.CodeNotFound {
+			return nil, fmt.Errorf("Failed to get secret store: %w", err)
+		}
+		// a secret store that does not exist is already deleted
+		if secretStore != nil {
+			if err := checkSecretStoreOwner(secretStore, secretStoreID, tenant, manageShared); err != nil {
+				return nil, err
+			}
+		}
+
+		operation.Report(ctx, "deleting secret store '%s' from the gitops repo", secretStoreID)
+		if err := repowriter.TenantRepo(tenant).SecretStoreDelete(ctx, secretStoreID); err != nil {
+			return nil, fmt.Errorf("Failed to delete secret store: %w", err)
+		}
+
+		return types.DeleteSecretStoreResponse{
+			Success: true,
+			Message: "secret store deleted successfully",
+		}, nil
+	}, 200, apierr.CodeInternal)
 }
 
 func SecretStoreDescribe(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(400, gin.H{"error": "SecretStore ID is required"})
+		apierr.Write(c, apierr.Validation("SecretStore ID is required"))
 		return
 	}
 
-	log.G().WithField("id", id).Debug("describe secret store")
+	log.G(c.Request.Context()).WithField("id", id).Debug("describe secret store")
 
 	tenant := c.GetString(middleware.TenantKey)
 	if tenant == "" {
-		c.JSON(400, gin.H{"error": "Tenant is required"})
+		apierr.Write(c, apierr.Validation("Tenant is required"))
+		return
+	}
+
+	if !middleware.Enforce(c, tenant, rbac.ResourceSecretStores, rbac.VerbRead) {
 		return
 	}
 
@@ -160,45 +171,46 @@ func SecretStoreDescribe(c *gin.Context) {
 	}
 	cloneOpts.Parse()
 
-	secretStore, err := repowriter.TenantRepo(tenant).SecretStoreGet(context.Background(), id)
+	secretStore, err := repowriter.TenantRepo(tenant).SecretStoreGet(c.Request.Context(), id)
 	if err != nil {
-		log.G().Errorf("Failed to get secret store: %v", err)
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to get secret store: %v", err)})
+		log.G(c.Request.Context()).Errorf("Failed to get secret store: %v", err)
+		apierr.Write(c, fmt.Errorf("Failed to get secret store: %w", err))
 		return
 	}
 
 	if secretStore == nil {
-		c.JSON(404, gin.H{"error": "secret store not found"})
+		apierr.Write(c, apierr.NotFound("secret store not found"))
+		return
+	}
+
+	if err := checkSecretStoreOwner(secretStore, id, tenant, true); err != nil {
+		apierr.Write(c, err)
 		return
 	}
 
 	c.JSON(200, types.DescribeSecretStoreResponse{
 		Success: true,
-		Item: types.SecretStoreDetail{
-			ID:          secretStore.Annotations["squidflow.github.io/id"],
-			Name:        secretStore.Name,
-			Provider:    "vault",
-			Status:      "Active",
-			Path:        *secretStore.Spec.Provider.Vault.Path,
-			Type:        "SecretStore",
-			Environment: []string{"sit", "uat", "prod"},
-			LastSynced:  secretStore.Annotations["squidflow.github.io/last-synced"],
-			CreatedAt:   secretStore.Annotations["squidflow.github.io/created-at"],
-			LastUpdated: secretStore.Annotations["squidflow.github.io/updated-at"],
-			Health: types.SecretStoreHealth{
-				Status:  "Healthy", // fix this with actual health check
-				Message: "Secret store is operating normally",
-			},
-		},
+		Item:    secretStoreDetail(secretStore, "Secret store is operating normally"),
 		Message: "secret store retrieved successfully",
 	})
 }
 
 // SecretStoreList returns a list of secret stores
+// query: limit, continue, sort (name, created_at), prefix
 func SecretStoreList(c *gin.Context) {
+	q, err := parseListQuery(c, "name", secretStoreSortFields)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
 	tenant := c.GetString(middleware.TenantKey)
 	if tenant == "" {
-		c.JSON(400, gin.H{"error": "Tenant is required"})
+		apierr.Write(c, apierr.Validation("Tenant is required"))
+		return
+	}
+
+	if !middleware.Enforce(c, tenant, rbac.ResourceSecretStores, rbac.VerbRead) {
 		return
 	}
 
@@ -213,96 +225,131 @@ func SecretStoreList(c *gin.Context) {
 	}
 	cloneOpts.Parse()
 
-	secretStores, err := repowriter.TenantRepo(tenant).SecretStoreList(context.Background())
+	secretStores, err := repowriter.TenantRepo(tenant).SecretStoreList(c.Request.Context())
 	if err != nil {
-		log.G().Errorf("Failed to list secret stores: %v", err)
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to list secret stores: %v", err)})
+		log.G(c.Request.Context()).Errorf("Failed to list secret stores: %v", err)
+		apierr.Write(c, fmt.Errorf("Failed to list secret stores: %w", err))
 		return
 	}
 
+	matched := make([]esv1beta1.SecretStore, 0, len(secretStores))
+	for _, secretStore := range secretStores {
+		if strings.HasPrefix(secretStore.Name, q.Prefix) && checkSecretStoreOwner(&secretStore, "", tenant, true) == nil {
+			matched = append(matched, secretStore)
+		}
+	}
+	sortItems(matched, q, secretStoreSortFields)
+	page, next := paginate(matched, q)
+
 	// simple convert to response
 	var items []types.SecretStoreDetail
-	for _, secretStore := range secretStores {
-		items = append(items, types.SecretStoreDetail{
-			ID:          secretStore.Annotations["squidflow.github.io/id"],
-			Name:        secretStore.Name,
-			Provider:    "vault",
-			Status:      "Active",
-			Path:        *secretStore.Spec.Provider.Vault.Path,
-			Type:        "SecretStore",
-			Environment: []string{"sit", "uat", "prod"},
-			LastSynced:  secretStore.Annotations["squidflow.github.io/last-synced"],
-			CreatedAt:   secretStore.Annotations["squidflow.github.io/created-at"],
-			LastUpdated: secretStore.Annotations["squidflow.github.io/updated-at"],
-			Health: types.SecretStoreHealth{
-				Status:  "Healthy", // fix this with actual health check
-				Message: "Secret store is operating normally",
-			},
-		})
+	for _, secretStore := range page {
+		items = append(items, secretStoreDetail(&secretStore, "Secret store is operating normally"))
 	}
 
 	c.JSON(200, types.ListSecretStoreResponse{
-		Success: true,
-		Total:   len(secretStores),
-		Items:   items,
-		Message: "secret stores retrieved successfully",
-		Error:   "",
+		Success:  true,
+		Total:    len(matched),
+		Items:    items,
+		Message:  "secret stores retrieved successfully",
+		Error:    "",
+		Continue: next,
 	})
 }
 
+var secretStoreSortFields = map[string]compareFunc[esv1beta1.SecretStore]{
+	"name": func(a, b esv1beta1.SecretStore) int {
+		return strings.Compare(a.Name, b.Name)
+	},
+	// created-at is RFC3339, which sorts lexically
+	"created_at": func(a, b esv1beta1.SecretStore) int {
+		return strings.Compare(a.Annotations["squidflow.github.io/created-at"], b.Annotations["squidflow.github.io/created-at"])
+	},
+}
+
 func SecretStoreUpdate(c *gin.Context) {
 	secretStoreID := c.Param("id")
 	if secretStoreID == "" {
-		c.JSON(400, gin.H{"error": "secret store ID is required"})
+		apierr.Write(c, apierr.Validation("secret store ID is required"))
 		return
 	}
 
 	var req types.SecretStoreUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		apierr.Write(c, apierr.Validation("Invalid request: %v", err))
 		return
 	}
 
 	tenant := c.GetString(middleware.TenantKey)
 	if tenant == "" {
-		c.JSON(400, gin.H{"error": "Tenant is required"})
+		apierr.Write(c, apierr.Validation("Tenant is required"))
 		return
 	}
 
-	cloneOpts := &git.CloneOptions{
-		Repo:     viper.GetString("application_repo.remote_url"),
-		FS:       fs.Create(memfs.New()),
-		Provider: "github",
-		Auth: git.Auth{
-			Password: viper.GetString("application_repo.access_token"),
-		},
-		CloneForWrite: true,
+	if !middleware.Enforce(c, tenant, rbac.ResourceSecretStores, rbac.VerbUpdate) {
+		return
 	}
-	cloneOpts.Parse()
+	manageShared := middleware.Allowed(c, rbac.AllTenants, rbac.ResourceSecretStores, rbac.VerbUpdate)
+
+	executeMetaOperation(c, func(ctx context.Context) (interface{}, error) {
+		existing, err := repowriter.TenantRepo(tenant).SecretStoreGet(ctx, secretStoreID)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get secret store: %w", err)
+		}
+		if err := checkSecretStoreOwner(existing, secretStoreID, tenant, manageShared); err != nil {
+			return nil, err
+		}
+
+		operation.Report(ctx, "writing secret store '%s' to the gitops repo", secretStoreID)
+		secretStore, err := repowriter.TenantRepo(tenant).SecretStoreUpdate(ctx, secretStoreID, &req)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to update secret store: %w", err)
+		}
+
+		return types.SecretStoreUpdateResponse{
+			Item:    secretStoreDetail(secretStore, "Secret store updated successfully"),
+			Success: true,
+			Message: "secret store updated successfully",
+		}, nil
+	}, 200, apierr.CodeInternal)
+}
 
-	secretStore, err := repowriter.TenantRepo(tenant).SecretStoreUpdate(context.Background(), secretStoreID, &req)
-	if err != nil {
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to update secret store: %v", err)})
-		return
+// checkSecretStoreOwner returns a not found error when the secret store belongs to another tenant. The secret stores
+// created before they were labelled with their tenant are shared by the tenants, they are only changed by the callers
+// that manage the secret stores of every tenant
+func checkSecretStoreOwner(ss *esv1beta1.SecretStore, id, tenant string, manageShared bool) error {
+	switch owner := ss.Labels[store.Default.LabelKeyTenant]; {
+	case owner == tenant:
+		return nil
+	case owner != "":
+		return apierr.NotFound("secret store '%s' not found", id)
+	case !manageShared:
+		return apierr.Forbidden("secret store '%s' is shared by the tenants, it is only changed by platform admins", id)
 	}
 
-	c.JSON(200, types.SecretStoreUpdateResponse{
-		Item: types.SecretStoreDetail{
-			ID:          secretStore.Annotations["squidflow.github.io/id"],
-			Name:        secretStore.Name,
-			Provider:    "vault",
-			Type:        "SecretStore",
-			Status:      "Active",
-			Path:        *secretStore.Spec.Provider.Vault.Path,
-			LastSynced:  secretStore.Annotations["squidflow.github.io/last-synced"],
-			CreatedAt:   secretStore.Annotations["squidflow.github.io/created-at"],
-			LastUpdated: secretStore.Annotations["squidflow.github.io/updated-at"],
-			Health: types.SecretStoreHealth{
-				Status:  "Healthy",
-				Message: "Secret store updated successfully",
-			},
+	return nil
+}
+
+// secretStoreDetail converts a secret store to its detail, the path is only set for the vault provider
+func secretStoreDetail(ss *esv1beta1.SecretStore, message string) types.SecretStoreDetail {
+	detail := types.SecretStoreDetail{
+		ID:          ss.Annotations["squidflow.github.io/id"],
+		Name:        ss.Name,
+		Provider:    secretstore.Provider(ss.Spec.Provider),
+		Type:        secretstore.Type(ss.Spec.Provider),
+		Status:      "Active",
+		Environment: []string{"sit", "uat", "prod"},
+		LastSynced:  ss.Annotations["squidflow.github.io/last-synced"],
+		CreatedAt:   ss.Annotations["squidflow.github.io/created-at"],
+		LastUpdated: ss.Annotations["squidflow.github.io/updated-at"],
+		Health: types.SecretStoreHealth{
+			Status:  "Healthy", // fix this with actual health check
+			Message: message,
 		},
-		Success: true,
-		Message: "secret store updated successfully",
-	})
+	}
+	if ss.Spec.Provider != nil && ss.Spec.Provider.Vault != nil && ss.Spec.Provider.Vault.Path != nil {
+		detail.Path = *ss.Spec.Provider.Vault.Path
+	}
+
+	return detail
 }
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/middleware"
+	"github.com/squidflow/service/pkg/ratelimit"
+	repowriter "github.com/squidflow/service/pkg/repo/writer"
+	"github.com/squidflow/service/pkg/store"
+	"github.com/squidflow/service/pkg/types"
+)
+
+// fakeSecretStoreRepo writes the secret stores of a tenant with a gitops repo of its own
+type fakeSecretStoreRepo struct {
+	repowriter.TenantRepoWriter
+}
+
+func (f *fakeSecretStoreRepo) SecretStoreCreate(context.Context, *esv1beta1.SecretStore, bool) error {
+	return nil
+}
+
+func (f *fakeSecretStoreRepo) SecretStoreGet(_ context.Context, id string) (*esv1beta1.SecretStore, error) {
+	return &esv1beta1.SecretStore{ObjectMeta: metav1.ObjectMeta{
+		Name:        "vault",
+		Labels:      map[string]string{store.Default.LabelKeyTenant: "ss-tenant"},
+		Annotations: map[string]string{"squidflow.github.io/id": id},
+	}}, nil
+}
+
+func (f *fakeSecretStoreRepo) SecretStoreUpdate(ctx context.Context, id string, _ *types.SecretStoreUpdateRequest) (*esv1beta1.SecretStore, error) {
+	return f.SecretStoreGet(ctx, id)
+}
+
+func (f *fakeSecretStoreRepo) SecretStoreDelete(context.Context, string) error {
+	return nil
+}
+
+func TestSecretStoreWrites_metaRepoSlot(t *testing.T) {
+	const (
+		metaRepo   = "https://github.com/squidflow/meta.git"
+		tenantRepo = "https://github.com/squidflow/ss-tenant.git"
+	)
+	viper.Set("application_repo.remote_url", metaRepo)
+	defer viper.Set("application_repo.remote_url", "")
+	repowriter.StoreTenantRepo(types.TenantInfo{Name: "ss-tenant", GitOpsRepo: tenantRepo}, &fakeSecretStoreRepo{})
+
+	createBody, _ := json.Marshal(types.SecretStoreCreateReq{SecretStoreYaml: `apiVersion: external-secrets.io/v1beta1
+kind: SecretStore
+metadata:
+  name: vault
+  namespace: app
+spec:
+  provider:
+    vault:
+      server: https://vault.example.com
+      auth:
+        tokenSecretRef:
+          name: vault-token
+          key: token
+`})
+	handlers := map[string]struct {
+		method      string
+		body        string
+		handler     gin.HandlerFunc
+		successCode int
+	}{
+		"create": {method: "POST", body: string(createBody), handler: SecretStoreCreate, successCode: 201},
+		"update": {method: "PATCH", body: `{"server": "https://vault2.example.com"}`, handler: SecretStoreUpdate, successCode: 200},
+		"delete": {method: "DELETE", handler: SecretStoreDelete, successCode: 200},
+	}
+	for name, h := range handlers {
+		busy := map[string]struct {
+			repo     string
+			wantCode int
+		}{
+			"Should reject the %s when the meta repo has no free slot":       {repo: metaRepo, wantCode: 429},
+			"Should run the %s when the repo of the tenant has no free slot": {repo: tenantRepo, wantCode: h.successCode},
+		}
+		for format, tt := range busy {
+			busyRepo, wantCode := tt.repo, tt.wantCode
+			t.Run(fmt.Sprintf(format, name), func(t *testing.T) {
+				repos := ratelimit.NewRepoLimiter(1)
+				release, ok := repos.TryAcquire(busyRepo)
+				assert.True(t, ok)
+				defer release()
+
+				w := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(w)
+				c.Request = httptest.NewRequest(h.method, "/api/v1/security/externalsecrets/secretstore/ss1", strings.NewReader(h.body))
+				c.Request.Header.Set("Content-Type", "application/json")
+				c.Params = gin.Params{{Key: "id", Value: "ss1"}}
+				c.Set(middleware.TenantKey, "ss-tenant")
+				c.Set(middleware.RepoLimiterKey, repos)
+
+				h.handler(c)
+
+				assert.Equal(t, wantCode, w.Code, w.Body.String())
+			})
+		}
+	}
+}
+
+func Test_checkSecretStoreOwner(t *testing.T) {
+	secretStore := func(owner string) *esv1beta1.SecretStore {
+		ss := &esv1beta1.SecretStore{ObjectMeta: metav1.ObjectMeta{Name: "vault"}}
+		if owner != "" {
+			ss.Labels = map[string]string{store.Default.LabelKeyTenant: owner}
+		}
+		return ss
+	}
+
+	tests := map[string]struct {
+		owner        string
+		manageShared bool
+		wantCode     apierr.Code
+	}{
+		"Should accept a secret store of the tenant": {
+			owner: "tenant1",
+		},
+		"Should not find a secret store of another tenant": {
+			owner:        "tenant2",
+			manageShared: true,
+			wantCode:     apierr.CodeNotFound,
+		},
+		"Should accept a shared secret store for the callers that manage every tenant": {
+			manageShared: true,
+		},
+		"Should forbid a shared secret store for the other callers": {
+			wantCode: apierr.CodeForbidden,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkSecretStoreOwner(secretStore(tt.owner), "ss1", "tenant1", tt.manageShared)
+			if tt.wantCode == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.Equal(t, tt.wantCode, apierr.CodeOf(err))
+		})
+	}
+}
@@ -3,15 +3,19 @@ package handler
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/spf13/viper"
 
+	"github.com/squidflow/service/pkg/apierr"
 	"github.com/squidflow/service/pkg/fs"
 	"github.com/squidflow/service/pkg/git"
 	"github.com/squidflow/service/pkg/log"
 	"github.com/squidflow/service/pkg/middleware"
+	"github.com/squidflow/service/pkg/operation"
+	"github.com/squidflow/service/pkg/rbac"
 	repowriter "github.com/squidflow/service/pkg/repo/writer"
 	"github.com/squidflow/service/pkg/types"
 )
@@ -19,10 +23,12 @@ import (
 func TenantCreate(c *gin.Context) {
 	var req types.ProjectCreateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		apierr.Write(c, apierr.Validation("Invalid request: %v", err))
 		return
 	}
 
+	middleware.SetAuditTarget(c, req.ProjectName)
+
 	if req.GitOpsRepo == "" {
 		req.GitOpsRepo = viper.GetString("application_repo.remote_url")
 	}
@@ -34,59 +40,64 @@ func TenantCreate(c *gin.Context) {
 		ProjectGitopsRepo: req.GitOpsRepo,
 	}
 
-	log.G().WithFields(log.Fields{
+	log.G(c.Request.Context()).WithFields(log.Fields{
 		"project_name":        opts.ProjectName,
 		"project_gitops_repo": opts.ProjectGitopsRepo,
 		"labels":              opts.Labels,
 		"annotations":         opts.Annotations,
 	}).Info("project create options")
 
-	err := repowriter.MetaRepo().RunProjectCreate(context.Background(), opts)
-	if err != nil {
-		log.G().Errorf("Failed to create project: %v", err)
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to create project: %v", err)})
-		return
-	}
-
-	c.JSON(201, gin.H{
-		"message": fmt.Sprintf("Project '%s' created successfully", req.ProjectName),
-		"project": req,
-	})
+	executeMetaOperation(c, func(ctx context.Context) (interface{}, error) {
+		// the freeze windows of the tenant are written as the sync windows of its project
+		clusters, err := freezeClusters(ctx)
+		if err != nil {
+			return nil, err
+		}
+		opts.Clusters = clusters
+
+		operation.Report(ctx, "writing project '%s' to the meta repo", opts.ProjectName)
+		if err := repowriter.MetaRepo().RunProjectCreate(ctx, opts); err != nil {
+			log.G(ctx).Errorf("Failed to create project: %v", err)
+			return nil, fmt.Errorf("Failed to create project: %w", err)
+		}
+
+		return gin.H{
+			"message": fmt.Sprintf("Project '%s' created successfully", req.ProjectName),
+			"project": req,
+		}, nil
+	}, 201, apierr.CodeInternal)
 }
 
 func TenantDelete(c *gin.Context) {
 	projectName := c.Param("name")
 	if projectName == "" {
-		c.JSON(400, gin.H{"error": "Project name is required"})
+		apierr.Write(c, apierr.Validation("Project name is required"))
 		return
 	}
 
-	cloneOpts := &git.CloneOptions{
-		Repo:     viper.GetString("application_repo.remote_url"),
-		FS:       fs.Create(memfs.New()),
-		Provider: "github",
-		Auth: git.Auth{
-			Password: viper.GetString("application_repo.access_token"),
-		},
-		CloneForWrite: true,
-	}
-	cloneOpts.Parse()
-
-	err := repowriter.MetaRepo().RunProjectDelete(context.Background(), projectName)
-	if err != nil {
-		log.G().Errorf("Failed to delete project: %v", err)
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to delete project: %v", err)})
+	if !middleware.Enforce(c, projectName, rbac.ResourceTenants, rbac.VerbDelete) {
 		return
 	}
 
-	c.JSON(200, gin.H{"message": fmt.Sprintf("Project '%s' deleted successfully", projectName)})
+	executeMetaOperation(c, func(ctx context.Context) (interface{}, error) {
+		operation.Report(ctx, "deleting project '%s' from the meta repo", projectName)
+		if err := repowriter.MetaRepo().RunProjectDelete(ctx, projectName); err != nil {
+			log.G(ctx).Errorf("Failed to delete project: %v", err)
+			return nil, fmt.Errorf("Failed to delete project: %w", err)
+		}
+
+		return gin.H{"message": fmt.Sprintf("Project '%s' deleted successfully", projectName)}, nil
+	}, 200, apierr.CodeInternal)
 }
 
 func TenantGet(c *gin.Context) {
 	tenant := c.GetString(middleware.TenantKey)
-	log.G().Infof("auth context info tenant: %s", tenant)
+	log.G(c.Request.Context()).Infof("auth context info tenant: %s", tenant)
 
 	projectName := c.Param("name")
+	if !middleware.Enforce(c, projectName, rbac.ResourceTenants, rbac.VerbRead) {
+		return
+	}
 
 	cloneOpts := &git.CloneOptions{
 		Repo:     viper.GetString("application_repo.remote_url"),
@@ -99,27 +110,63 @@ func TenantGet(c *gin.Context) {
 	}
 	cloneOpts.Parse()
 
-	tenantResp, err := repowriter.MetaRepo().RunProjectGet(context.Background(), projectName)
+	tenantResp, err := repowriter.MetaRepo().RunProjectGet(c.Request.Context(), projectName)
 	if err != nil {
-		log.G().Errorf("Failed to get project detail: %v", err)
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to get project detail: %v", err)})
+		log.G(c.Request.Context()).Errorf("Failed to get project detail: %v", err)
+		apierr.Write(c, fmt.Errorf("Failed to get project detail: %w", err))
 		return
 	}
 
 	c.JSON(200, tenantResp)
 }
 
+// TenantsList lists the tenants
+// query: limit, continue, sort (name), prefix
 func TenantsList(c *gin.Context) {
-	tenants, err := repowriter.MetaRepo().RunProjectList(context.Background())
+	q, err := parseListQuery(c, "name", tenantSortFields)
+	if err != nil {
+		apierr.Write(c, err)
+		return
+	}
+
+	tenants, err := repowriter.MetaRepo().RunProjectList(c.Request.Context())
 	if err != nil {
-		log.G().Errorf("failed to list tenants: %v", err)
-		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to list tenants: %v", err)})
+		log.G(c.Request.Context()).Errorf("failed to list tenants: %v", err)
+		apierr.Write(c, fmt.Errorf("failed to list tenants: %w", err))
 		return
 	}
 
-	c.JSON(200, gin.H{
+	matched := matchTenants(c, tenants, q.Prefix)
+	sortItems(matched, q, tenantSortFields)
+	page, next := paginate(matched, q)
+
+	resp := gin.H{
 		"success": true,
-		"total":   len(tenants),
-		"items":   tenants,
-	})
+		"total":   len(matched),
+		"items":   page,
+	}
+	if next != "" {
+		resp["continue"] = next
+	}
+
+	c.JSON(200, resp)
+}
+
+// matchTenants returns the tenants with the prefix that the caller is allowed to read, the tenants that the caller
+// holds a role in when the rbac policy is enforced
+func matchTenants(c *gin.Context, tenants []types.TenantInfo, prefix string) []types.TenantInfo {
+	matched := make([]types.TenantInfo, 0, len(tenants))
+	for _, tenant := range tenants {
+		if strings.HasPrefix(tenant.Name, prefix) && middleware.Allowed(c, tenant.Name, rbac.ResourceTenants, rbac.VerbRead) {
+			matched = append(matched, tenant)
+		}
+	}
+
+	return matched
+}
+
+var tenantSortFields = map[string]compareFunc[types.TenantInfo]{
+	"name": func(a, b types.TenantInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	},
 }
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/squidflow/service/pkg/auth"
+	"github.com/squidflow/service/pkg/middleware"
+	"github.com/squidflow/service/pkg/rbac"
+	"github.com/squidflow/service/pkg/types"
+)
+
+func Test_matchTenants(t *testing.T) {
+	policy, err := rbac.ParsePolicy([]byte(`
+defaultRole: viewer
+bindings:
+- role: platform-admin
+  tenant: "*"
+  groups: ["platform"]
+- role: tenant-admin
+  tenant: tenant1
+  users: ["alice"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenants := []types.TenantInfo{{Name: "tenant1"}, {Name: "tenant2"}, {Name: "team3"}}
+	names := func(tenants []types.TenantInfo) []string {
+		got := []string{}
+		for _, tenant := range tenants {
+			got = append(got, tenant.Name)
+		}
+		return got
+	}
+
+	tests := map[string]struct {
+		policy   *rbac.Policy
+		identity *auth.Identity
+		prefix   string
+		want     []string
+	}{
+		"Should list every tenant when rbac is disabled": {
+			identity: &auth.Identity{Username: "mallory"},
+			want:     []string{"tenant1", "tenant2", "team3"},
+		},
+		"Should list the tenants of the bindings of the caller": {
+			policy:   policy,
+			identity: &auth.Identity{Username: "alice"},
+			want:     []string{"tenant1"},
+		},
+		"Should list the tenants the caller is a member of with the default role": {
+			policy:   policy,
+			identity: &auth.Identity{Username: "bob", Tenants: []string{"tenant2", "team3"}},
+			want:     []string{"tenant2", "team3"},
+		},
+		"Should list every tenant for a binding in all tenants": {
+			policy:   policy,
+			identity: &auth.Identity{Username: "root", Groups: []string{"platform"}},
+			prefix:   "tenant",
+			want:     []string{"tenant1", "tenant2"},
+		},
+		"Should list no tenant without a role": {
+			policy:   policy,
+			identity: &auth.Identity{Username: "mallory"},
+			want:     []string{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			enforcer, err := rbac.NewEnforcer(tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/api/v1/tenants", nil)
+			c.Set(middleware.EnforcerKey, enforcer)
+			c.Set(middleware.IdentityKey, tt.identity)
+
+			assert.Equal(t, tt.want, names(matchTenants(c, tenants, tt.prefix)))
+		})
+	}
+}
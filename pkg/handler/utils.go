@@ -9,6 +9,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/spf13/viper"
 
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/argocd"
 	"github.com/squidflow/service/pkg/log"
 	repowriter "github.com/squidflow/service/pkg/repo/writer"
 	"github.com/squidflow/service/pkg/types"
@@ -60,6 +62,60 @@ func getAppSyncStatus(app *argocdv1alpha1.Application) string {
 	return string(app.Status.Sync.Status)
 }
 
+// resolveAppTargets resolves the server of each application target through the ArgoCD cluster list
+func resolveAppTargets(ctx context.Context, targets []types.ApplicationTarget) ([]types.ApplicationTarget, error) {
+	if len(targets) == 0 {
+		return nil, apierr.Validation("at least one application target is required")
+	}
+
+	clusters, err := argocd.ListClusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	servers := make(map[string]string, len(clusters.Items))
+	for _, cluster := range clusters.Items {
+		servers[cluster.Name] = cluster.Server
+	}
+
+	resolved := make([]types.ApplicationTarget, 0, len(targets))
+	seen := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		server, ok := servers[target.Cluster]
+		if !ok {
+			return nil, fmt.Errorf("cluster '%s' is not registered", target.Cluster)
+		}
+
+		if seen[target.Cluster] {
+			return nil, fmt.Errorf("cluster '%s' is targeted more than once", target.Cluster)
+		}
+		seen[target.Cluster] = true
+
+		resolved = append(resolved, types.ApplicationTarget{
+			Cluster:   target.Cluster,
+			Namespace: target.Namespace,
+			Server:    server,
+		})
+	}
+
+	return resolved, nil
+}
+
+// clusterEnvs returns the environment of each registered cluster, by cluster name
+func clusterEnvs(ctx context.Context) (map[string]string, error) {
+	clusters, err := argocd.ListClusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	envs := make(map[string]string, len(clusters.Items))
+	for _, cluster := range clusters.Items {
+		envs[cluster.Name] = cluster.Annotations[argocd.AnnotationKeyEnvironment]
+	}
+
+	return envs, nil
+}
+
 type projectGitOpsCache struct {
 	mu    sync.RWMutex
 	cache map[string]string // key: project name, value: gitops repo url
@@ -0,0 +1,123 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const DefaultTTL = 24 * time.Hour
+
+type (
+	// Response is the stored response of the first request with a key
+	Response struct {
+		Status int
+		Header map[string]string
+		Body   []byte
+		// Revisions are the commit SHAs or pull request URLs the first request persisted
+		Revisions []string
+	}
+
+	// Entry is the state of a key, Response is nil while the first request is in flight
+	Entry struct {
+		RequestHash string
+		Response    *Response
+		CreatedAt   time.Time
+	}
+
+	// Store keeps the responses of idempotent requests for a TTL
+	Store interface {
+		// Begin reserves key for a request with hash, if the key is already known its entry is returned instead
+		Begin(key, hash string) (*Entry, bool)
+		// Complete stores the response of the request that reserved key
+		Complete(key string, resp *Response)
+		// Release drops the reservation of key, so the request can be retried
+		Release(key string)
+	}
+
+	// MemoryStore is a Store of a single replica
+	MemoryStore struct {
+		ttl time.Duration
+		now func() time.Time
+
+		mu      sync.Mutex
+		entries map[string]*Entry
+	}
+)
+
+// NewMemoryStore returns a store that forgets keys ttl after their first request
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &MemoryStore{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]*Entry{},
+	}
+}
+
+// Start drops the expired keys periodically until ctx is done
+func (s *MemoryStore) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.ttl / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.cleanup()
+			}
+		}
+	}()
+}
+
+func (s *MemoryStore) Begin(key, hash string) (*Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok && !s.expired(entry) {
+		copied := *entry
+		return &copied, false
+	}
+
+	s.entries[key] = &Entry{
+		RequestHash: hash,
+		CreatedAt:   s.now(),
+	}
+
+	return nil, true
+}
+
+func (s *MemoryStore) Complete(key string, resp *Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok {
+		entry.Response = resp
+	}
+}
+
+func (s *MemoryStore) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+}
+
+func (s *MemoryStore) expired(entry *Entry) bool {
+	return s.now().Sub(entry.CreatedAt) > s.ttl
+}
+
+func (s *MemoryStore) cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, entry := range s.entries {
+		if s.expired(entry) {
+			delete(s.entries, key)
+		}
+	}
+}
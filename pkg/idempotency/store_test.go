@@ -0,0 +1,84 @@
+package idempotency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	tests := map[string]struct {
+		run         func(s *MemoryStore)
+		wantStarted bool
+		wantEntry   *Entry
+	}{
+		"first request reserves the key": {
+			run:         func(s *MemoryStore) {},
+			wantStarted: true,
+		},
+		"in flight request": {
+			run: func(s *MemoryStore) {
+				s.Begin("key", "hash")
+			},
+			wantEntry: &Entry{RequestHash: "hash"},
+		},
+		"completed request": {
+			run: func(s *MemoryStore) {
+				s.Begin("key", "hash")
+				s.Complete("key", &Response{Status: 201, Body: []byte(`{}`), Revisions: []string{"abc"}})
+			},
+			wantEntry: &Entry{
+				RequestHash: "hash",
+				Response:    &Response{Status: 201, Body: []byte(`{}`), Revisions: []string{"abc"}},
+			},
+		},
+		"released request can be retried": {
+			run: func(s *MemoryStore) {
+				s.Begin("key", "hash")
+				s.Release("key")
+			},
+			wantStarted: true,
+		},
+		"expired request can be retried": {
+			run: func(s *MemoryStore) {
+				s.Begin("key", "hash")
+				s.Complete("key", &Response{Status: 201})
+				s.now = func() time.Time { return time.Unix(0, 0).Add(2 * time.Hour) }
+			},
+			wantStarted: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := NewMemoryStore(time.Hour)
+			s.now = func() time.Time { return time.Unix(0, 0) }
+			tt.run(s)
+
+			entry, started := s.Begin("key", "hash")
+			assert.Equal(t, tt.wantStarted, started)
+			if tt.wantEntry == nil {
+				assert.Nil(t, entry)
+				return
+			}
+
+			assert.Equal(t, tt.wantEntry.RequestHash, entry.RequestHash)
+			assert.Equal(t, tt.wantEntry.Response, entry.Response)
+		})
+	}
+}
+
+func TestMemoryStore_cleanup(t *testing.T) {
+	s := NewMemoryStore(time.Hour)
+	now := time.Unix(0, 0)
+	s.now = func() time.Time { return now }
+
+	s.Begin("old", "hash")
+	now = now.Add(30 * time.Minute)
+	s.Begin("new", "hash")
+	now = now.Add(45 * time.Minute)
+
+	s.cleanup()
+	assert.NotContains(t, s.entries, "old")
+	assert.Contains(t, s.entries, "new")
+}
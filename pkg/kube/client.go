@@ -9,6 +9,9 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/squidflow/service/pkg/metrics"
+	"github.com/squidflow/service/pkg/tracing"
 )
 
 // defaultKubeconfig returns the default kubeconfig path
@@ -81,6 +84,8 @@ func createClusterInArgoCdClient() (*argocclient.ArgoprojV1alpha1Client, error)
 		return nil, fmt.Errorf("failed to create in-cluster config: %w", err)
 	}
 
+	config.Wrap(metrics.InstrumentArgoCDTransport)
+	config.Wrap(tracing.InstrumentTransport)
 	clientSet, err := argocclient.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create in-cluster ArgoCD client: %w", err)
@@ -98,6 +103,8 @@ func createOutOfClusterArgoCDClient(kubeconfigPath string) (*argocclient.Argopro
 		return nil, fmt.Errorf("failed to build config from kubeconfig: %w", err)
 	}
 
+	config.Wrap(metrics.InstrumentArgoCDTransport)
+	config.Wrap(tracing.InstrumentTransport)
 	clientSet, err := argocclient.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create out-of-cluster ArgoCD client: %w", err)
@@ -41,11 +41,9 @@ type Logger interface {
 	Configure() error
 }
 
+// WithLogger returns a copy of ctx that carries logger, G(ctx) returns it.
+// The default logger is not changed, use SetDefault for that
 func WithLogger(ctx context.Context, logger Logger) context.Context {
-	if L != nil {
-		L = logger
-	}
-
 	return context.WithValue(ctx, loggerKey{}, logger)
 }
 
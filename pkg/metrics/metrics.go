@@ -0,0 +1,199 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "squidflow"
+
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+var (
+	// Registry holds the metrics of the service, it is served by Handler
+	Registry = prometheus.NewRegistry()
+
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests by route, status and tenant.",
+	}, []string{"method", "route", "status", "tenant"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests by route and status.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"method", "route", "status"})
+
+	GitOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "git",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of git clone, pull, push and pull request creation.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"operation", "outcome"})
+
+	RepoCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "git",
+		Name:      "repository_cache_requests_total",
+		Help:      "Lookups of the repository cache by result, hit or miss.",
+	}, []string{"result"})
+
+	RepoCacheEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "git",
+		Name:      "repository_cache_evictions_total",
+		Help:      "Entries removed from the repository cache by reason, capacity or expired.",
+	}, []string{"reason"})
+
+	RenderDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "source",
+		Name:      "render_duration_seconds",
+		Help:      "Latency of helm and kustomize renders.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"type", "outcome"})
+
+	RenderFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "source",
+		Name:      "render_failures_total",
+		Help:      "Failed helm and kustomize renders by source type and tenant.",
+	}, []string{"type", "tenant"})
+
+	ArgoCDRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "argocd",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of ArgoCD API server and ArgoCD resource calls.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"call", "outcome"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		GitOperationDuration,
+		RepoCacheRequests,
+		RepoCacheEvictions,
+		RenderDuration,
+		RenderFailures,
+		ArgoCDRequestDuration,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Outcome returns the outcome label of an error
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeFailure
+	}
+
+	return OutcomeSuccess
+}
+
+// ObserveGit records the latency of a git operation that started at start
+func ObserveGit(operation string, start time.Time, err error) {
+	GitOperationDuration.WithLabelValues(operation, Outcome(err)).Observe(time.Since(start).Seconds())
+}
+
+// RenderError is the error of a failed render, it carries the source type to the callers that
+// know the tenant of the render
+type RenderError struct {
+	Type string
+	Err  error
+}
+
+func (e *RenderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// ObserveRender records the latency of a render of the source type that started at start, the
+// returned error is err wrapped in a RenderError
+func ObserveRender(sourceType string, start time.Time, err error) error {
+	RenderDuration.WithLabelValues(sourceType, Outcome(err)).Observe(time.Since(start).Seconds())
+	if err == nil {
+		return nil
+	}
+
+	return &RenderError{Type: sourceType, Err: err}
+}
+
+// ObserveRenderFailure counts a failed render of the tenant when err is or wraps a RenderError
+func ObserveRenderFailure(tenant string, err error) {
+	var renderErr *RenderError
+	if errors.As(err, &renderErr) {
+		RenderFailures.WithLabelValues(renderErr.Type, tenant).Inc()
+	}
+}
+
+// ObserveArgoCD records the latency of an ArgoCD call that started at start
+func ObserveArgoCD(call string, start time.Time, err error) {
+	ArgoCDRequestDuration.WithLabelValues(call, Outcome(err)).Observe(time.Since(start).Seconds())
+}
+
+// InstrumentArgoCDTransport records the latency of the kubernetes API calls on ArgoCD resources,
+// the call label is the method and the resource, e.g. "GET applications"
+func InstrumentArgoCDTransport(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := rt.RoundTrip(req)
+
+		outcome := OutcomeSuccess
+		if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+			outcome = OutcomeFailure
+		}
+		ArgoCDRequestDuration.WithLabelValues(req.Method+" "+resourceOf(req.URL.Path), outcome).Observe(time.Since(start).Seconds())
+
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// resourceOf returns the resource of a kubernetes API path, without the namespace and name
+// /apis/argoproj.io/v1alpha1/namespaces/argocd/applications/foo -> applications
+func resourceOf(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 4 || parts[0] != "apis" {
+		return "other"
+	}
+
+	parts = parts[3:]
+	if len(parts) >= 2 && parts[0] == "namespaces" {
+		parts = parts[2:]
+	}
+	if len(parts) == 0 {
+		return "other"
+	}
+
+	return parts[0]
+}
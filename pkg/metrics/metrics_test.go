@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_resourceOf(t *testing.T) {
+	tests := map[string]struct {
+		path string
+		want string
+	}{
+		"namespaced resource": {
+			path: "/apis/argoproj.io/v1alpha1/namespaces/argocd/applications",
+			want: "applications",
+		},
+		"namespaced resource with name": {
+			path: "/apis/argoproj.io/v1alpha1/namespaces/argocd/applicationsets/foo",
+			want: "applicationsets",
+		},
+		"cluster resource": {
+			path: "/apis/argoproj.io/v1alpha1/applications",
+			want: "applications",
+		},
+		"core api": {
+			path: "/api/v1/namespaces/argocd/secrets",
+			want: "other",
+		},
+		"group only": {
+			path: "/apis/argoproj.io/v1alpha1",
+			want: "other",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, resourceOf(tt.path))
+		})
+	}
+}
+
+func TestInstrumentArgoCDTransport(t *testing.T) {
+	tests := map[string]struct {
+		status  int
+		err     error
+		call    string
+		outcome string
+	}{
+		"success": {
+			status:  http.StatusOK,
+			call:    "GET appprojects",
+			outcome: OutcomeSuccess,
+		},
+		"server error": {
+			status:  http.StatusInternalServerError,
+			call:    "GET appprojects",
+			outcome: OutcomeFailure,
+		},
+		"transport error": {
+			err:     errors.New("connection refused"),
+			call:    "GET appprojects",
+			outcome: OutcomeFailure,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ArgoCDRequestDuration.Reset()
+			rt := InstrumentArgoCDTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return &http.Response{StatusCode: tt.status}, nil
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/apis/argoproj.io/v1alpha1/namespaces/argocd/appprojects", nil)
+			_, err := rt.RoundTrip(req)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, 1, testutil.CollectAndCount(ArgoCDRequestDuration))
+
+			assert.Equal(t, uint64(1), sampleCount(t, tt.call, tt.outcome))
+		})
+	}
+}
+
+func TestObserveRender(t *testing.T) {
+	RenderFailures.Reset()
+	RenderDuration.Reset()
+
+	assert.NoError(t, ObserveRender("helm", time.Now(), nil))
+	err := ObserveRender("kustomize", time.Now(), errors.New("bad kustomization"))
+	assert.EqualError(t, err, "bad kustomization")
+	assert.Equal(t, 2, testutil.CollectAndCount(RenderDuration))
+
+	ObserveRenderFailure("tenant1", fmt.Errorf("failed to render application manifests: %w", err))
+	ObserveRenderFailure("tenant1", errors.New("failed to clone application source repository"))
+	assert.Equal(t, 1, testutil.CollectAndCount(RenderFailures))
+	assert.Equal(t, float64(1), testutil.ToFloat64(RenderFailures.WithLabelValues("kustomize", "tenant1")))
+}
+
+func TestHandler(t *testing.T) {
+	HTTPRequests.WithLabelValues(http.MethodGet, "/api/v1/healthz", "200", "").Inc()
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.True(t, strings.Contains(body, "squidflow_http_requests_total"))
+	assert.True(t, strings.Contains(body, "go_goroutines"))
+}
+
+func sampleCount(t *testing.T, call, outcome string) uint64 {
+	t.Helper()
+	m := &dto.Metric{}
+	if err := ArgoCDRequestDuration.WithLabelValues(call, outcome).(prometheus.Histogram).Write(m); err != nil {
+		t.Fatal(err)
+	}
+
+	return m.GetHistogram().GetSampleCount()
+}
@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/squidflow/service/pkg/audit"
+	"github.com/squidflow/service/pkg/git"
+	"github.com/squidflow/service/pkg/log"
+)
+
+const (
+	AuditSinkKey   = "auditSink"
+	AuditTargetKey = "auditTarget"
+	// AuditOverrideKey is the reason of the override of a freeze window
+	AuditOverrideKey = "auditOverride"
+
+	// maxAuditBodySize caps the part of an error response that is kept to read the error message
+	maxAuditBodySize = 4096
+)
+
+// auditActions names the mutating routes, routes that are missing here are recorded as "<METHOD> <route>"
+var auditActions = map[string]string{
+	"POST /api/v1/clusters":                                   "cluster.register",
+	"PATCH /api/v1/clusters/:name":                            "cluster.update",
+	"DELETE /api/v1/clusters/:name":                           "cluster.deregister",
+	"POST /api/v1/deploy/applications":                        "application.create",
+	"POST /api/v1/deploy/applications/sync":                   "application.sync",
+	"PATCH /api/v1/deploy/applications/:name":                 "application.update",
+	"DELETE /api/v1/deploy/applications/:name":                "application.delete",
+	"POST /api/v1/deploy/applications/:name/rollback":         "application.rollback",
+	"POST /api/v1/deploy/applications/:name/promote":          "application.promote",
+	"DELETE /api/v1/deploy/applications/:name/promote":        "application.unpin",
+	"POST /api/v1/changerequests/:id/approve":                 "changerequest.approve",
+	"POST /api/v1/changerequests/:id/reject":                  "changerequest.reject",
+	"POST /api/v1/tenants":                                    "tenant.create",
+	"DELETE /api/v1/tenants/:name":                            "tenant.delete",
+	"POST /api/v1/appcodes":                                   "appcode.create",
+	"PATCH /api/v1/appcodes/:name":                            "appcode.update",
+	"POST /api/v1/appcodes/:name/deactivate":                  "appcode.deactivate",
+	"POST /api/v1/security/externalsecrets/secretstore":       "secretstore.create",
+	"PATCH /api/v1/security/externalsecrets/secretstore/:id":  "secretstore.update",
+	"DELETE /api/v1/security/externalsecrets/secretstore/:id": "secretstore.delete",
+	"POST /api/v1/operations/:id/cancel":                      "operation.cancel",
+	"POST /api/v1/freezewindows":                              "freezewindow.create",
+	"PUT /api/v1/freezewindows/:name":                         "freezewindow.update",
+	"DELETE /api/v1/freezewindows/:name":                      "freezewindow.delete",
+}
+
+// auditSkipped are the non-GET routes that do not change anything
+var auditSkipped = map[string]bool{
+	"POST /api/v1/deploy/applications/validate": true,
+}
+
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	if room := maxAuditBodySize - w.body.Len(); room > 0 {
+		if len(b) > room {
+			w.body.Write(b[:room])
+		} else {
+			w.body.Write(b)
+		}
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// AuditMiddleware records every mutating call to the sink, along with the
+// commit SHAs or pull request URLs the call persisted
+func AuditMiddleware(sink audit.Sink) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(AuditSinkKey, sink)
+
+		route := c.Request.Method + " " + c.FullPath()
+		if isReadOnlyMethod(c.Request.Method) || c.FullPath() == "" || auditSkipped[route] {
+			c.Next()
+			return
+		}
+
+		ctx, rec := git.WithPersistRecorder(c.Request.Context())
+		c.Request = c.Request.WithContext(ctx)
+
+		w := &auditResponseWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = w
+
+		c.Next()
+
+		action := AuditAction(c)
+
+		record := &audit.Record{
+			Timestamp: time.Now().UTC(),
+			RequestID: c.GetString("RequestID"),
+			Actor:     c.GetString(UserNameKey),
+			Tenant:    c.GetString(TenantKey),
+			Action:    action,
+			Target:    AuditTarget(c),
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Status:    w.Status(),
+			Outcome:   audit.OutcomeSuccess,
+			Revisions: rec.Revisions(),
+			Override:  c.GetString(AuditOverrideKey),
+		}
+		if record.Status >= http.StatusBadRequest {
+			record.Outcome = audit.OutcomeFailure
+			record.Error = responseError(w.body.Bytes())
+		}
+
+		if err := sink.Write(ctx, record); err != nil {
+			log.G().WithFields(log.Fields{
+				"request_id": record.RequestID,
+				"action":     record.Action,
+				"error":      err,
+			}).Error("failed to write audit record")
+		}
+	}
+}
+
+// SetAuditTarget names the resource of the audit record, handlers call it when the name is not a path parameter
+func SetAuditTarget(c *gin.Context, name string) {
+	c.Set(AuditTargetKey, name)
+}
+
+// AuditAction returns the action name of the route of the request
+func AuditAction(c *gin.Context) string {
+	route := c.Request.Method + " " + c.FullPath()
+	if action, ok := auditActions[route]; ok {
+		return action
+	}
+
+	return route
+}
+
+// AuditTarget returns the resource that the request acts on
+func AuditTarget(c *gin.Context) audit.Target {
+	target := audit.Target{}
+	if kind, _, found := strings.Cut(AuditAction(c), "."); found {
+		target.Kind = kind
+	}
+
+	switch {
+	case c.GetString(AuditTargetKey) != "":
+		target.Name = c.GetString(AuditTargetKey)
+	case c.Param("name") != "":
+		target.Name = c.Param("name")
+	case c.Param("id") != "":
+		target.Name = c.Param("id")
+	}
+
+	return target
+}
+
+func responseError(body []byte) string {
+	resp := struct {
+		Error string `json:"error"`
+	}{}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Error == "" {
+		return strings.TrimSpace(string(body))
+	}
+
+	return resp.Error
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
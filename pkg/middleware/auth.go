@@ -4,47 +4,64 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/auth"
+	"github.com/squidflow/service/pkg/log"
 )
 
 const (
 	UserNameKey = "username"
 	TenantKey   = "tenant"
+	IdentityKey = "identity"
 
 	BearerSchema = "Bearer "
+
+	// TenantHeader selects the tenant of the request when the caller is a member of several tenants
+	TenantHeader = "X-Tenant"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(authenticator auth.Authenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		auth := c.GetHeader("Authorization")
-		if auth == "" || !strings.HasPrefix(auth, BearerSchema) {
-			c.AbortWithStatusJSON(401, gin.H{
-				"error": "unauthorized: missing or invalid authorization header",
-			})
+		header := c.GetHeader("Authorization")
+		if header == "" || !strings.HasPrefix(header, BearerSchema) {
+			apierr.Abort(c, apierr.Unauthorized("unauthorized: missing or invalid authorization header"))
 			return
 		}
 
-		token := strings.TrimPrefix(auth, BearerSchema)
+		token := strings.TrimPrefix(header, BearerSchema)
 
-		parts := strings.Split(token, "@")
-		if len(parts) != 2 {
-			c.AbortWithStatusJSON(401, gin.H{
-				"error": "unauthorized: invalid token format, expected 'username@tenant'",
-			})
+		identity, err := authenticator.Authenticate(c.Request.Context(), token)
+		if err != nil {
+			log.G(c.Request.Context()).WithFields(log.Fields{
+				"path":  c.Request.URL.Path,
+				"error": err,
+			}).Debug("authentication failed")
+			apierr.Abort(c, apierr.Unauthorized("unauthorized: %v", err))
 			return
 		}
 
-		username := parts[0]
-		tenant := parts[1]
-
-		if username == "" || tenant == "" {
-			c.AbortWithStatusJSON(401, gin.H{
-				"error": "unauthorized: username and tenant cannot be empty",
-			})
+		tenant := c.GetHeader(TenantHeader)
+		switch {
+		case tenant != "":
+			if !identity.HasTenant(tenant) {
+				apierr.Abort(c, apierr.Forbidden("forbidden: user is not a member of tenant '%s'", tenant))
+				return
+			}
+		case len(identity.Tenants) == 1:
+			tenant = identity.Tenants[0]
+		default:
+			apierr.Abort(c, apierr.Validation("user is a member of several tenants, select one with the %s header", TenantHeader))
 			return
 		}
 
-		c.Set(UserNameKey, username)
+		c.Set(UserNameKey, identity.Username)
 		c.Set(TenantKey, tenant)
+		c.Set(IdentityKey, identity)
+		AddLoggerFields(c, log.Fields{
+			"user":   identity.Username,
+			"tenant": tenant,
+		})
 
 		c.Next()
 	}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/git"
+	"github.com/squidflow/service/pkg/idempotency"
+	"github.com/squidflow/service/pkg/log"
+)
+
+const (
+	IdempotencyStoreKey = "idempotencyStore"
+
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses that are replayed from the store
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// RevisionsHeader lists the commit SHAs or pull request URLs the first request persisted
+	RevisionsHeader = "X-Revisions"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// replayedHeaders are the response headers that are stored along with the status and the body
+var replayedHeaders = []string{"Content-Type", "Location", "Preference-Applied"}
+
+type idempotencyResponseWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *idempotencyResponseWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// IdempotencyMiddleware makes the idempotency store available to the Idempotent route middleware
+func IdempotencyMiddleware(store idempotency.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(IdempotencyStoreKey, store)
+		c.Next()
+	}
+}
+
+// Idempotent replays the stored response of a request with the same Idempotency-Key header.
+// A key that is reused with a different request gets 422, a key whose first request is still
+// in flight gets 409. Keys are scoped to the tenant and the user of the request.
+// Requests without the header are not affected
+func Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		store, ok := c.Value(IdempotencyStoreKey).(idempotency.Store)
+		if key == "" || !ok || store == nil {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			apierr.Abort(c, apierr.Validation("%s header is longer than %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			apierr.Abort(c, apierr.Validation("failed to read request body: %v", err))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scopedKey := strings.Join([]string{c.GetString(TenantKey), c.GetString(UserNameKey), c.Request.Method, c.FullPath(), key}, "\x00")
+		hash := requestHash(c.Request.Method, c.Request.URL.Path, body)
+
+		entry, started := store.Begin(scopedKey, hash)
+		if !started {
+			switch {
+			case entry.RequestHash != hash:
+				apierr.Abort(c, apierr.Unprocessable("%s was already used with a different request", IdempotencyKeyHeader))
+			case entry.Response == nil:
+				c.Header("Retry-After", "5")
+				apierr.Abort(c, apierr.Conflict("a request with the same %s is in progress", IdempotencyKeyHeader))
+			default:
+				replay(c, entry.Response)
+			}
+			return
+		}
+
+		completed := false
+		defer func() {
+			if !completed {
+				store.Release(scopedKey)
+			}
+		}()
+
+		w := &idempotencyResponseWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = w
+
+		c.Next()
+
+		status := w.Status()
+		if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+			// transient failures can be retried with the same key
+			return
+		}
+
+		resp := &idempotency.Response{
+			Status:    status,
+			Header:    map[string]string{},
+			Body:      w.body.Bytes(),
+			Revisions: git.PersistRecorderFrom(c.Request.Context()).Revisions(),
+		}
+		for _, name := range replayedHeaders {
+			if value := w.Header().Get(name); value != "" {
+				resp.Header[name] = value
+			}
+		}
+
+		store.Complete(scopedKey, resp)
+		completed = true
+	}
+}
+
+func replay(c *gin.Context, resp *idempotency.Response) {
+	log.G(c.Request.Context()).WithFields(log.Fields{
+		"idempotency_key": c.GetHeader(IdempotencyKeyHeader),
+		"status":          resp.Status,
+	}).Info("replaying idempotent response")
+
+	for name, value := range resp.Header {
+		c.Header(name, value)
+	}
+	if len(resp.Revisions) > 0 {
+		c.Header(RevisionsHeader, strings.Join(resp.Revisions, ","))
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+
+	c.Status(resp.Status)
+	_, _ = c.Writer.Write(resp.Body)
+	c.Abort()
+}
+
+// requestHash hashes the request, JSON bodies are hashed in their canonical form so that
+// retries that only differ in whitespace or in the order of the fields match
+func requestHash(method, path string, body []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err == nil {
+		if canonical, err := json.Marshal(v); err == nil {
+			body = canonical
+		}
+	}
+
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/tracing"
+)
+
+// LoggerMiddleware stores a logger with the request fields in the request context,
+// handlers and everything they call log through log.G(ctx)
+func LoggerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fields := log.Fields{
+			"request_id": c.GetString("RequestID"),
+			"method":     c.Request.Method,
+			"route":      c.FullPath(),
+		}
+		if traceID := tracing.TraceID(c.Request.Context()); traceID != "" {
+			fields["trace_id"] = traceID
+		}
+
+		AddLoggerFields(c, fields)
+		c.Next()
+	}
+}
+
+// AddLoggerFields adds fields to the logger of the request
+func AddLoggerFields(c *gin.Context, fields log.Fields) {
+	ctx := c.Request.Context()
+	c.Request = c.Request.WithContext(log.WithLogger(ctx, log.G(ctx).WithFields(fields)))
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/squidflow/service/pkg/operation"
+)
+
+const OperationManagerKey = "operationManager"
+
+// OperationMiddleware injects the manager of asynchronous operations into the context
+func OperationMiddleware(manager *operation.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(OperationManagerKey, manager)
+		c.Next()
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/ratelimit"
+)
+
+const RepoLimiterKey = "repoLimiter"
+
+// RateLimitMiddleware takes a token from the read or the mutation budget of the request tenant,
+// and rejects the request with 429 when the budget is exhausted. It must run after the auth middleware
+func RateLimitMiddleware(tenants *ratelimit.TenantLimiter, repos *ratelimit.RepoLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(RepoLimiterKey, repos)
+
+		tenant := c.GetString(TenantKey)
+		mutating := isMutating(c.Request.Method)
+		if ok, retryAfter := tenants.Allow(tenant, mutating); !ok {
+			log.G(c.Request.Context()).WithFields(log.Fields{
+				"tenant":      tenant,
+				"mutating":    mutating,
+				"retry_after": retryAfter,
+			}).Warn("tenant rate limit exceeded")
+
+			AbortTooManyRequests(c, retryAfter, "rate limit of tenant '"+tenant+"' exceeded")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// AbortTooManyRequests aborts the request with 429 and the Retry-After header in whole seconds
+func AbortTooManyRequests(c *gin.Context, retryAfter time.Duration, msg string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	apierr.Abort(c, apierr.TooManyRequests("%s", msg))
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
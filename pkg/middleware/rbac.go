@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/auth"
+	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/rbac"
+)
+
+const EnforcerKey = "rbacEnforcer"
+
+// RBACMiddleware injects the policy enforcer into the context
+func RBACMiddleware(enforcer *rbac.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(EnforcerKey, enforcer)
+		c.Next()
+	}
+}
+
+// RBACEnabled returns true if the rbac policy is enforced for the request
+func RBACEnabled(c *gin.Context) bool {
+	enforcer, _ := c.Value(EnforcerKey).(*rbac.Enforcer)
+	return enforcer.Enabled()
+}
+
+// Authorize checks that the caller is allowed to perform the verb on the resource in the tenant of the request
+func Authorize(resource rbac.Resource, verb rbac.Verb) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !Enforce(c, c.GetString(TenantKey), resource, verb) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Enforce checks that the caller is allowed to perform the verb on the resource in the tenant,
+// on denial it aborts the request with 403 and returns false
+func Enforce(c *gin.Context, tenant string, resource rbac.Resource, verb rbac.Verb) bool {
+	enforcer, _ := c.Value(EnforcerKey).(*rbac.Enforcer)
+	if !enforcer.Enabled() {
+		return true
+	}
+
+	err := enforcer.Enforce(subject(c), tenant, resource, verb)
+	if err == nil {
+		return true
+	}
+
+	denied := &rbac.DeniedError{}
+	if !errors.As(err, &denied) {
+		apierr.Abort(c, err)
+		return false
+	}
+
+	log.G(c.Request.Context()).WithFields(log.Fields{
+		"username": denied.Username,
+		"tenant":   denied.Tenant,
+		"resource": denied.Resource,
+		"verb":     denied.Verb,
+		"path":     c.Request.URL.Path,
+	}).Info("request denied by rbac policy")
+
+	apierr.Abort(c, apierr.Forbidden("%s", denied.Error()).WithDetails(denied.Details()))
+
+	return false
+}
+
+// Allowed returns true if the caller is allowed to perform the verb on the resource in the tenant,
+// unlike Enforce it leaves the response untouched
+func Allowed(c *gin.Context, tenant string, resource rbac.Resource, verb rbac.Verb) bool {
+	enforcer, _ := c.Value(EnforcerKey).(*rbac.Enforcer)
+	return enforcer.Enforce(subject(c), tenant, resource, verb) == nil
+}
+
+// HasRole returns true if the caller holds one of the roles in the tenant,
+// no caller does when the policy is not enforced
+func HasRole(c *gin.Context, tenant string, roles ...rbac.Role) bool {
+	enforcer, _ := c.Value(EnforcerKey).(*rbac.Enforcer)
+	if !enforcer.Enabled() {
+		return false
+	}
+
+	for _, role := range enforcer.Roles(subject(c), tenant) {
+		if slices.Contains(roles, role) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func subject(c *gin.Context) *rbac.Subject {
+	identity, ok := c.Value(IdentityKey).(*auth.Identity)
+	if !ok {
+		return &rbac.Subject{Username: c.GetString(UserNameKey)}
+	}
+
+	return &rbac.Subject{
+		Username: identity.Username,
+		Groups:   identity.Groups,
+		Tenants:  identity.Tenants,
+	}
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/squidflow/service/pkg/appstatus"
+)
+
+const AppWatcherKey = "appWatcher"
+
+// AppWatcherMiddleware injects the shared watcher of ArgoCD applications into the context
+func AppWatcherMiddleware(watcher *appstatus.Watcher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(AppWatcherKey, watcher)
+		c.Next()
+	}
+}
@@ -0,0 +1,298 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/git"
+	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/tracing"
+)
+
+const (
+	DefaultWorkers   = 4
+	DefaultQueueSize = 100
+	DefaultRetention = time.Hour
+)
+
+type (
+	// ManagerOptions configures the worker pool
+	// Workers: the number of operations that run concurrently
+	// QueueSize: the number of operations that can wait for a worker
+	// Retention: how long finished operations can be queried
+	// OnFinish: called with a snapshot of every operation that reaches a final phase
+	ManagerOptions struct {
+		Workers   int
+		QueueSize int
+		Retention time.Duration
+		OnFinish  func(op *Operation)
+	}
+
+	// Manager runs operations on a pool of workers, and keeps them for queries
+	Manager struct {
+		opts  ManagerOptions
+		mu    sync.RWMutex
+		ops   map[string]*entry
+		queue chan *entry
+		now   func() time.Time
+	}
+
+	entry struct {
+		mu     sync.Mutex
+		op     Operation
+		fn     Func
+		ctx    context.Context
+		cancel context.CancelFunc
+	}
+)
+
+// NewManager returns a manager, Start must be called to run the workers
+func NewManager(opts *ManagerOptions) *Manager {
+	o := ManagerOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Workers <= 0 {
+		o.Workers = DefaultWorkers
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = DefaultQueueSize
+	}
+	if o.Retention <= 0 {
+		o.Retention = DefaultRetention
+	}
+
+	return &Manager{
+		opts:  o,
+		ops:   map[string]*entry{},
+		queue: make(chan *entry, o.QueueSize),
+		now:   time.Now,
+	}
+}
+
+// Start runs the workers until ctx is done, running operations are cancelled with ctx
+func (m *Manager) Start(ctx context.Context) {
+	for i := 0; i < m.opts.Workers; i++ {
+		go m.work(ctx)
+	}
+
+	go func() {
+		ticker := time.NewTicker(m.opts.Retention / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.cleanup()
+			}
+		}
+	}()
+
+	log.G(ctx).WithFields(log.Fields{
+		"workers":    m.opts.Workers,
+		"queue_size": m.opts.QueueSize,
+		"retention":  m.opts.Retention,
+	}).Info("operation workers started")
+}
+
+// Submit queues fn, op describes the operation, its ID and status fields are set by the manager.
+// fn runs with the logger and the trace of ctx, but is not cancelled with ctx
+func (m *Manager) Submit(ctx context.Context, op *Operation, fn Func) (*Operation, error) {
+	id := uuid.New().String()
+	runCtx := log.WithLogger(tracing.Detach(ctx), log.G(ctx).WithField("operation", id))
+	runCtx, cancel := context.WithCancel(runCtx)
+	e := &entry{
+		op:     *op,
+		fn:     fn,
+		ctx:    runCtx,
+		cancel: cancel,
+	}
+	e.op.ID = id
+	e.op.Phase = PhasePending
+	e.op.Messages = []Message{}
+	e.op.CreatedAt = m.now().UTC()
+
+	m.mu.Lock()
+	select {
+	case m.queue <- e:
+		m.ops[e.op.ID] = e
+	default:
+		m.mu.Unlock()
+		cancel()
+		return nil, ErrQueueFull
+	}
+	m.mu.Unlock()
+
+	log.G(ctx).WithFields(log.Fields{
+		"operation": e.op.ID,
+		"action":    e.op.Action,
+		"tenant":    e.op.Tenant,
+	}).Debug("operation submitted")
+
+	return e.snapshot(), nil
+}
+
+// Get returns a snapshot of the operation
+func (m *Manager) Get(id string) (*Operation, error) {
+	m.mu.RLock()
+	e, ok := m.ops[id]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return e.snapshot(), nil
+}
+
+// Cancel cancels the context of a running operation, or drops a pending one
+func (m *Manager) Cancel(id string) (*Operation, error) {
+	m.mu.RLock()
+	e, ok := m.ops[id]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	e.mu.Lock()
+	if e.op.Finished() {
+		e.mu.Unlock()
+		return e.snapshot(), ErrFinished
+	}
+
+	pending := e.op.Phase == PhasePending
+	e.op.Messages = append(e.op.Messages, Message{Time: m.now().UTC(), Message: "cancel requested"})
+	if pending {
+		m.finishLocked(e, PhaseCancelled, nil, context.Canceled)
+	}
+	e.mu.Unlock()
+
+	e.cancel()
+	if pending {
+		m.notify(e)
+	}
+
+	return e.snapshot(), nil
+}
+
+func (m *Manager) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-m.queue:
+			m.run(ctx, e)
+		}
+	}
+}
+
+func (m *Manager) run(ctx context.Context, e *entry) {
+	e.mu.Lock()
+	if e.op.Finished() {
+		e.mu.Unlock()
+		return
+	}
+	started := m.now().UTC()
+	e.op.Phase = PhaseRunning
+	e.op.StartedAt = &started
+	e.mu.Unlock()
+
+	// stop the operation when the manager stops
+	stop := context.AfterFunc(ctx, e.cancel)
+	defer stop()
+
+	opCtx, rec := git.WithPersistRecorder(context.WithValue(e.ctx, operationKey{}, e))
+	opCtx, span := tracing.Start(opCtx, "operation "+e.op.Action)
+	result, err := call(opCtx, e.fn)
+	tracing.End(span, err)
+
+	e.mu.Lock()
+	e.op.Revisions = rec.Revisions()
+	switch {
+	case err == nil:
+		m.finishLocked(e, PhaseSucceeded, result, nil)
+	case errors.Is(err, context.Canceled) || e.ctx.Err() != nil:
+		m.finishLocked(e, PhaseCancelled, result, err)
+	default:
+		m.finishLocked(e, PhaseFailed, result, err)
+	}
+	e.mu.Unlock()
+
+	e.cancel()
+	m.notify(e)
+}
+
+// call runs fn, a panic of fn fails the operation instead of crashing the server, as the workers
+// are not covered by the recovery middleware of gin
+func call(ctx context.Context, fn Func) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.G(ctx).WithFields(log.Fields{
+				"panic": r,
+				"stack": string(debug.Stack()),
+			}).Error("operation panicked")
+			result, err = nil, apierr.Internal(nil, "operation panicked: %v", r)
+		}
+	}()
+
+	return fn(ctx)
+}
+
+func (m *Manager) finishLocked(e *entry, phase Phase, result interface{}, err error) {
+	finished := m.now().UTC()
+	e.op.Phase = phase
+	e.op.Result = result
+	e.op.FinishedAt = &finished
+	if err != nil {
+		e.op.Error = err.Error()
+	}
+	if phase == PhaseFailed {
+		e.op.ErrorCode = apierr.CodeOf(err)
+	}
+
+	log.G(e.ctx).WithFields(log.Fields{
+		"operation": e.op.ID,
+		"action":    e.op.Action,
+		"phase":     phase,
+		"error":     e.op.Error,
+	}).Info("operation finished")
+}
+
+func (m *Manager) notify(e *entry) {
+	if m.opts.OnFinish != nil {
+		m.opts.OnFinish(e.snapshot())
+	}
+}
+
+// cleanup drops the operations that finished before the retention period
+func (m *Manager) cleanup() {
+	deadline := m.now().Add(-m.opts.Retention)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, e := range m.ops {
+		e.mu.Lock()
+		expired := e.op.FinishedAt != nil && e.op.FinishedAt.Before(deadline)
+		e.mu.Unlock()
+		if expired {
+			delete(m.ops, id)
+		}
+	}
+}
+
+func (e *entry) snapshot() *Operation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	op := e.op
+	op.Messages = append([]Message{}, e.op.Messages...)
+	op.Revisions = append([]string(nil), e.op.Revisions...)
+
+	return &op
+}
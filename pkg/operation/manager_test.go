@@ -0,0 +1,195 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/squidflow/service/pkg/apierr"
+)
+
+func waitFinished(t *testing.T, m *Manager, id string) *Operation {
+	t.Helper()
+
+	var op *Operation
+	assert.Eventually(t, func() bool {
+		var err error
+		op, err = m.Get(id)
+		return err == nil && op.Finished()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return op
+}
+
+func TestManager_Submit(t *testing.T) {
+	tests := map[string]struct {
+		fn          Func
+		wantPhase   Phase
+		wantResult  interface{}
+		wantErr     string
+		wantErrCode apierr.Code
+		wantMessage string
+	}{
+		"Should succeed with the result of the operation": {
+			fn: func(ctx context.Context) (interface{}, error) {
+				Report(ctx, "cloning %s", "repo")
+				return map[string]string{"name": "app1"}, nil
+			},
+			wantPhase:   PhaseSucceeded,
+			wantResult:  map[string]string{"name": "app1"},
+			wantMessage: "cloning repo",
+		},
+		"Should fail with the error of the operation": {
+			fn: func(ctx context.Context) (interface{}, error) {
+				Report(ctx, "pushing")
+				return nil, errors.New("failed to push")
+			},
+			wantPhase:   PhaseFailed,
+			wantErr:     "failed to push",
+			wantErrCode: apierr.CodeInternal,
+			wantMessage: "pushing",
+		},
+		"Should fail with the code of the API error": {
+			fn: func(ctx context.Context) (interface{}, error) {
+				Report(ctx, "pushing")
+				return nil, apierr.UpstreamGit(errors.New("connection reset"), "failed to push to repository")
+			},
+			wantPhase:   PhaseFailed,
+			wantErr:     "failed to push to repository: connection reset",
+			wantErrCode: apierr.CodeUpstreamGit,
+			wantMessage: "pushing",
+		},
+		"Should fail when the operation panics": {
+			fn: func(ctx context.Context) (interface{}, error) {
+				Report(ctx, "writing targets")
+				var targets []string
+				return targets[0], nil
+			},
+			wantPhase:   PhaseFailed,
+			wantErr:     "operation panicked: runtime error: index out of range [0] with length 0",
+			wantErrCode: apierr.CodeInternal,
+			wantMessage: "writing targets",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var finished *Operation
+			var mu sync.Mutex
+			m := NewManager(&ManagerOptions{Workers: 1, OnFinish: func(op *Operation) {
+				mu.Lock()
+				finished = op
+				mu.Unlock()
+			}})
+			m.Start(ctx)
+
+			op, err := m.Submit(context.Background(), &Operation{Action: "application.create", Tenant: "tenant1", Actor: "alice"}, tt.fn)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.NotEmpty(t, op.ID)
+			assert.Equal(t, "tenant1", op.Tenant)
+
+			got := waitFinished(t, m, op.ID)
+			assert.Equal(t, tt.wantPhase, got.Phase)
+			assert.Equal(t, tt.wantResult, got.Result)
+			assert.Equal(t, tt.wantErr, got.Error)
+			assert.Equal(t, tt.wantErrCode, got.ErrorCode)
+			assert.NotNil(t, got.StartedAt)
+			assert.NotNil(t, got.FinishedAt)
+			if assert.Len(t, got.Messages, 1) {
+				assert.Equal(t, tt.wantMessage, got.Messages[0].Message)
+			}
+
+			assert.Eventually(t, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return finished != nil && finished.ID == op.ID
+			}, time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestManager_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := NewManager(&ManagerOptions{Workers: 1})
+	m.Start(ctx)
+
+	started := make(chan struct{})
+	running, err := m.Submit(context.Background(), &Operation{Action: "application.delete"}, func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	<-started
+
+	// the only worker is busy, so this one stays pending
+	pending, err := m.Submit(context.Background(), &Operation{Action: "application.create"}, func(ctx context.Context) (interface{}, error) {
+		t.Error("a cancelled operation must not run")
+		return nil, nil
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	got, err := m.Cancel(pending.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, PhaseCancelled, got.Phase)
+
+	_, err = m.Cancel(running.ID)
+	assert.NoError(t, err)
+	got = waitFinished(t, m, running.ID)
+	assert.Equal(t, PhaseCancelled, got.Phase)
+	assert.Equal(t, context.Canceled.Error(), got.Error)
+
+	_, err = m.Cancel(running.ID)
+	assert.ErrorIs(t, err, ErrFinished)
+
+	_, err = m.Cancel("unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestManager_QueueFull(t *testing.T) {
+	m := NewManager(&ManagerOptions{Workers: 1, QueueSize: 1})
+
+	noop := func(ctx context.Context) (interface{}, error) { return nil, nil }
+	_, err := m.Submit(context.Background(), &Operation{}, noop)
+	assert.NoError(t, err)
+
+	_, err = m.Submit(context.Background(), &Operation{}, noop)
+	assert.ErrorIs(t, err, ErrQueueFull)
+}
+
+func TestManager_Cleanup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := NewManager(&ManagerOptions{Workers: 1, Retention: time.Minute})
+	m.Start(ctx)
+
+	op, err := m.Submit(context.Background(), &Operation{}, func(ctx context.Context) (interface{}, error) { return nil, nil })
+	if !assert.NoError(t, err) {
+		return
+	}
+	waitFinished(t, m, op.ID)
+
+	m.cleanup()
+	_, err = m.Get(op.ID)
+	assert.NoError(t, err)
+
+	m.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	m.cleanup()
+	_, err = m.Get(op.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
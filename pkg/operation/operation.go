@@ -0,0 +1,84 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/squidflow/service/pkg/apierr"
+	"github.com/squidflow/service/pkg/log"
+)
+
+// Phase is the lifecycle phase of an operation
+type Phase string
+
+const (
+	PhasePending   Phase = "Pending"
+	PhaseRunning   Phase = "Running"
+	PhaseSucceeded Phase = "Succeeded"
+	PhaseFailed    Phase = "Failed"
+	PhaseCancelled Phase = "Cancelled"
+)
+
+// Errors
+var (
+	ErrNotFound  = errors.New("operation not found")
+	ErrQueueFull = errors.New("too many pending operations, try again later")
+	ErrFinished  = errors.New("operation already finished")
+)
+
+type (
+	// Func is the work of an operation, the returned value is the result of the operation
+	Func func(ctx context.Context) (interface{}, error)
+
+	// Operation is a long-running request that is executed by a worker
+	Operation struct {
+		ID         string      `json:"id"`
+		Action     string      `json:"action"`
+		Target     string      `json:"target,omitempty"`
+		Tenant     string      `json:"tenant"`
+		Actor      string      `json:"actor"`
+		RequestID  string      `json:"request_id,omitempty"`
+		Phase      Phase       `json:"phase"`
+		Messages   []Message   `json:"messages"`
+		Revisions  []string    `json:"revisions,omitempty"`
+		Result     interface{} `json:"result,omitempty"`
+		Error      string      `json:"error,omitempty"`
+		ErrorCode  apierr.Code `json:"error_code,omitempty"`
+		CreatedAt  time.Time   `json:"created_at"`
+		StartedAt  *time.Time  `json:"started_at,omitempty"`
+		FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	}
+
+	// Message is a progress message of an operation
+	Message struct {
+		Time    time.Time `json:"time"`
+		Message string    `json:"message"`
+	}
+
+	operationKey struct{}
+)
+
+// Finished returns true if the operation reached a final phase
+func (o *Operation) Finished() bool {
+	return o.Phase == PhaseSucceeded || o.Phase == PhaseFailed || o.Phase == PhaseCancelled
+}
+
+// Report adds a progress message to the operation that runs with ctx,
+// outside of an operation the message is only logged
+func Report(ctx context.Context, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+
+	e, ok := ctx.Value(operationKey{}).(*entry)
+	if !ok {
+		log.G().Debug(msg)
+		return
+	}
+
+	e.mu.Lock()
+	e.op.Messages = append(e.op.Messages, Message{Time: time.Now().UTC(), Message: msg})
+	e.mu.Unlock()
+
+	log.G().WithField("operation", e.op.ID).Debug(msg)
+}
@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+type (
+	// Budget is a token bucket, PerMinute tokens are added every minute up to Burst.
+	// A budget with PerMinute 0 is unlimited
+	Budget struct {
+		PerMinute int
+		Burst     int
+	}
+
+	// TenantLimiter limits the requests of each tenant, reads and mutations have separate budgets
+	TenantLimiter struct {
+		read   Budget
+		mutate Budget
+
+		mu       sync.Mutex
+		limiters map[limiterKey]*rate.Limiter
+	}
+
+	limiterKey struct {
+		tenant   string
+		mutating bool
+	}
+
+	// RepoLimiter caps the number of concurrent writer operations on each repository
+	RepoLimiter struct {
+		max int
+
+		mu    sync.Mutex
+		slots map[string]chan struct{}
+	}
+)
+
+// NewTenantLimiter returns a limiter with the read and mutation budgets of each tenant
+func NewTenantLimiter(read, mutate Budget) *TenantLimiter {
+	return &TenantLimiter{
+		read:     read,
+		mutate:   mutate,
+		limiters: map[limiterKey]*rate.Limiter{},
+	}
+}
+
+// Allow takes a token from the budget of the tenant, when the budget is exhausted it
+// returns false and how long until a token is available
+func (l *TenantLimiter) Allow(tenant string, mutating bool) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	budget := l.read
+	if mutating {
+		budget = l.mutate
+	}
+	if budget.PerMinute <= 0 {
+		return true, 0
+	}
+
+	limiter := l.limiter(limiterKey{tenant: tenant, mutating: mutating}, budget)
+	reservation := limiter.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return false, delay
+	}
+
+	return true, 0
+}
+
+func (l *TenantLimiter) limiter(key limiterKey, budget Budget) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter, ok := l.limiters[key]
+	if !ok {
+		burst := budget.Burst
+		if burst <= 0 {
+			burst = 1
+		}
+		limiter = rate.NewLimiter(rate.Limit(float64(budget.PerMinute)/60), burst)
+		l.limiters[key] = limiter
+	}
+
+	return limiter
+}
+
+// NewRepoLimiter returns a limiter that allows max concurrent operations on each repository,
+// max 0 is unlimited
+func NewRepoLimiter(max int) *RepoLimiter {
+	return &RepoLimiter{
+		max:   max,
+		slots: map[string]chan struct{}{},
+	}
+}
+
+// TryAcquire takes a slot of the repository without waiting, the returned func releases it
+func (l *RepoLimiter) TryAcquire(repo string) (func(), bool) {
+	if l == nil || l.max <= 0 {
+		return func() {}, true
+	}
+
+	slots := l.repoSlots(repo)
+	select {
+	case slots <- struct{}{}:
+		return releaseFunc(slots), true
+	default:
+		return nil, false
+	}
+}
+
+// Acquire waits for a slot of the repository until ctx is done, the returned func releases it
+func (l *RepoLimiter) Acquire(ctx context.Context, repo string) (func(), error) {
+	if l == nil || l.max <= 0 {
+		return func() {}, nil
+	}
+
+	slots := l.repoSlots(repo)
+	select {
+	case slots <- struct{}{}:
+		return releaseFunc(slots), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *RepoLimiter) repoSlots(repo string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	slots, ok := l.slots[repo]
+	if !ok {
+		slots = make(chan struct{}, l.max)
+		l.slots[repo] = slots
+	}
+
+	return slots
+}
+
+func releaseFunc(slots chan struct{}) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-slots })
+	}
+}
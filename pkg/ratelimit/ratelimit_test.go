@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantLimiter_Allow(t *testing.T) {
+	tests := map[string]struct {
+		read     Budget
+		mutate   Budget
+		requests []bool // mutating
+		want     []bool
+	}{
+		"burst of mutations is exhausted": {
+			read:     Budget{PerMinute: 60, Burst: 5},
+			mutate:   Budget{PerMinute: 1, Burst: 2},
+			requests: []bool{true, true, true},
+			want:     []bool{true, true, false},
+		},
+		"reads and mutations have separate budgets": {
+			read:     Budget{PerMinute: 1, Burst: 1},
+			mutate:   Budget{PerMinute: 1, Burst: 1},
+			requests: []bool{false, true, false, true},
+			want:     []bool{true, true, false, false},
+		},
+		"zero budget is unlimited": {
+			mutate:   Budget{PerMinute: 1, Burst: 1},
+			requests: []bool{false, false, false, false},
+			want:     []bool{true, true, true, true},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			l := NewTenantLimiter(tt.read, tt.mutate)
+			for i, mutating := range tt.requests {
+				ok, retryAfter := l.Allow("tenant1", mutating)
+				assert.Equal(t, tt.want[i], ok, "request %d", i)
+				if !ok {
+					assert.Greater(t, retryAfter, time.Duration(0))
+				}
+			}
+		})
+	}
+}
+
+func TestTenantLimiter_tenantsAreIsolated(t *testing.T) {
+	l := NewTenantLimiter(Budget{}, Budget{PerMinute: 1, Burst: 1})
+
+	ok, _ := l.Allow("tenant1", true)
+	assert.True(t, ok)
+	ok, _ = l.Allow("tenant1", true)
+	assert.False(t, ok)
+	ok, _ = l.Allow("tenant2", true)
+	assert.True(t, ok)
+}
+
+func TestTenantLimiter_nil(t *testing.T) {
+	var l *TenantLimiter
+	ok, _ := l.Allow("tenant1", true)
+	assert.True(t, ok)
+}
+
+func TestRepoLimiter(t *testing.T) {
+	l := NewRepoLimiter(1)
+
+	release, ok := l.TryAcquire("repo1")
+	assert.True(t, ok)
+
+	_, ok = l.TryAcquire("repo1")
+	assert.False(t, ok)
+
+	other, ok := l.TryAcquire("repo2")
+	assert.True(t, ok)
+	other()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := l.Acquire(ctx, "repo1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release()
+	release() // releasing twice frees a single slot
+
+	waited, err := l.Acquire(context.Background(), "repo1")
+	assert.NoError(t, err)
+	_, ok = l.TryAcquire("repo1")
+	assert.False(t, ok)
+	waited()
+}
+
+func TestRepoLimiter_unlimited(t *testing.T) {
+	for _, l := range []*RepoLimiter{nil, NewRepoLimiter(0)} {
+		for i := 0; i < 3; i++ {
+			_, ok := l.TryAcquire("repo1")
+			assert.True(t, ok)
+		}
+	}
+}
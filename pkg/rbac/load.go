@@ -0,0 +1,57 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultConfigMapKey is the key of the policy in the rbac ConfigMap
+const DefaultConfigMapKey = "policy.yaml"
+
+// ParsePolicy parses a YAML or JSON policy
+func ParsePolicy(data []byte) (*Policy, error) {
+	policy := &Policy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse rbac policy: %w", err)
+	}
+
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid rbac policy: %w", err)
+	}
+
+	return policy, nil
+}
+
+// LoadPolicyFile reads the policy from a file
+func LoadPolicyFile(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rbac policy file '%s': %w", path, err)
+	}
+
+	return ParsePolicy(data)
+}
+
+// LoadPolicyConfigMap reads the policy from a key of a ConfigMap
+func LoadPolicyConfigMap(ctx context.Context, cs kubernetes.Interface, namespace, name, key string) (*Policy, error) {
+	if key == "" {
+		key = DefaultConfigMapKey
+	}
+
+	cm, err := cs.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rbac policy configmap '%s/%s': %w", namespace, name, err)
+	}
+
+	data, ok := cm.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("rbac policy configmap '%s/%s' has no key '%s'", namespace, name, key)
+	}
+
+	return ParsePolicy([]byte(data))
+}
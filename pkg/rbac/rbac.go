@@ -0,0 +1,249 @@
+package rbac
+
+import (
+	"fmt"
+	"sort"
+)
+
+type (
+	// Role is a named set of permissions that is granted to subjects within a tenant
+	Role string
+
+	// Resource is the kind of object that a request acts on
+	Resource string
+
+	// Verb is the kind of action that a request performs on a resource
+	Verb string
+)
+
+const (
+	RolePlatformAdmin Role = "platform-admin"
+	RoleTenantAdmin   Role = "tenant-admin"
+	RoleDeveloper     Role = "developer"
+	RoleViewer        Role = "viewer"
+
+	ResourceTenants       Resource = "tenants"
+	ResourceClusters      Resource = "clusters"
+	ResourceApplications  Resource = "applications"
+	ResourceSecretStores  Resource = "secretstores"
+	ResourceAppCodes      Resource = "appcodes"
+	ResourceAudit         Resource = "audit"
+	ResourceFreezeWindows Resource = "freezewindows"
+
+	VerbRead   Verb = "read"
+	VerbCreate Verb = "create"
+	VerbUpdate Verb = "update"
+	VerbDelete Verb = "delete"
+	VerbSync   Verb = "sync"
+
+	// AllTenants binds a role in every tenant
+	AllTenants = "*"
+)
+
+var allVerbs = []Verb{VerbRead, VerbCreate, VerbUpdate, VerbDelete, VerbSync}
+
+// rolePermissions is the fixed permission matrix of the built-in roles,
+// clusters, tenants and freeze windows are platform wide, so only platform-admin can change them
+var rolePermissions = map[Role]map[Resource][]Verb{
+	RolePlatformAdmin: {
+		ResourceTenants:       allVerbs,
+		ResourceClusters:      allVerbs,
+		ResourceApplications:  allVerbs,
+		ResourceSecretStores:  allVerbs,
+		ResourceAppCodes:      allVerbs,
+		ResourceAudit:         {VerbRead},
+		ResourceFreezeWindows: allVerbs,
+	},
+	RoleTenantAdmin: {
+		ResourceTenants:       {VerbRead},
+		ResourceClusters:      {VerbRead},
+		ResourceApplications:  allVerbs,
+		ResourceSecretStores:  allVerbs,
+		ResourceAppCodes:      {VerbRead},
+		ResourceAudit:         {VerbRead},
+		ResourceFreezeWindows: {VerbRead},
+	},
+	RoleDeveloper: {
+		ResourceTenants:       {VerbRead},
+		ResourceClusters:      {VerbRead},
+		ResourceApplications:  {VerbRead, VerbCreate, VerbUpdate, VerbSync},
+		ResourceSecretStores:  {VerbRead},
+		ResourceAppCodes:      {VerbRead},
+		ResourceFreezeWindows: {VerbRead},
+	},
+	RoleViewer: {
+		ResourceTenants:       {VerbRead},
+		ResourceClusters:      {VerbRead},
+		ResourceApplications:  {VerbRead},
+		ResourceSecretStores:  {VerbRead},
+		ResourceAppCodes:      {VerbRead},
+		ResourceFreezeWindows: {VerbRead},
+	},
+}
+
+type (
+	// Policy binds roles to users and groups
+	// DefaultRole: the role that members of a tenant get in that tenant when no binding matches, empty for none
+	Policy struct {
+		DefaultRole Role      `json:"defaultRole,omitempty" yaml:"defaultRole,omitempty"`
+		Bindings    []Binding `json:"bindings" yaml:"bindings"`
+	}
+
+	// Binding grants a role to users and groups in a tenant, or in every tenant with "*"
+	Binding struct {
+		Role   Role     `json:"role" yaml:"role"`
+		Tenant string   `json:"tenant" yaml:"tenant"`
+		Users  []string `json:"users,omitempty" yaml:"users,omitempty"`
+		Groups []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+	}
+
+	// Subject is the caller that a request is authorized for
+	Subject struct {
+		Username string
+		Groups   []string
+		// Tenants are the tenants the caller is a member of
+		Tenants []string
+	}
+
+	// Enforcer decides whether a subject is allowed to perform a verb on a resource of a tenant
+	Enforcer struct {
+		policy *Policy
+	}
+
+	// DeniedError is returned when a subject is not allowed to perform a request
+	DeniedError struct {
+		Username string
+		Tenant   string
+		Resource Resource
+		Verb     Verb
+		Roles    []Role
+	}
+)
+
+// NewEnforcer returns an enforcer for the policy, a nil policy allows everything
+func NewEnforcer(policy *Policy) (*Enforcer, error) {
+	if policy == nil {
+		return &Enforcer{}, nil
+	}
+
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Enforcer{policy: policy}, nil
+}
+
+// Validate checks that the policy only refers to built-in roles
+func (p *Policy) Validate() error {
+	if p.DefaultRole != "" {
+		if _, ok := rolePermissions[p.DefaultRole]; !ok {
+			return fmt.Errorf("unknown default role '%s'", p.DefaultRole)
+		}
+	}
+
+	for i, b := range p.Bindings {
+		if _, ok := rolePermissions[b.Role]; !ok {
+			return fmt.Errorf("binding %d: unknown role '%s'", i, b.Role)
+		}
+
+		if b.Tenant == "" {
+			return fmt.Errorf("binding %d: tenant is required, use '%s' for all tenants", i, AllTenants)
+		}
+
+		if len(b.Users) == 0 && len(b.Groups) == 0 {
+			return fmt.Errorf("binding %d: at least one user or group is required", i)
+		}
+	}
+
+	return nil
+}
+
+// Enabled returns true if the policy is enforced
+func (e *Enforcer) Enabled() bool {
+	return e != nil && e.policy != nil
+}
+
+// Enforce returns a DeniedError if the subject is not allowed to perform the verb on the resource of the tenant
+func (e *Enforcer) Enforce(subject *Subject, tenant string, resource Resource, verb Verb) error {
+	if !e.Enabled() {
+		return nil
+	}
+
+	roles := e.Roles(subject, tenant)
+	for _, role := range roles {
+		for _, v := range rolePermissions[role][resource] {
+			if v == verb {
+				return nil
+			}
+		}
+	}
+
+	return &DeniedError{
+		Username: subject.Username,
+		Tenant:   tenant,
+		Resource: resource,
+		Verb:     verb,
+		Roles:    roles,
+	}
+}
+
+// Roles returns the roles of the subject in the tenant
+func (e *Enforcer) Roles(subject *Subject, tenant string) []Role {
+	found := map[Role]bool{}
+	for _, b := range e.policy.Bindings {
+		if b.Tenant != AllTenants && b.Tenant != tenant {
+			continue
+		}
+
+		if contains(b.Users, subject.Username) || containsAny(b.Groups, subject.Groups) {
+			found[b.Role] = true
+		}
+	}
+
+	if len(found) == 0 && e.policy.DefaultRole != "" && contains(subject.Tenants, tenant) {
+		found[e.policy.DefaultRole] = true
+	}
+
+	roles := make([]Role, 0, len(found))
+	for role := range found {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i] < roles[j] })
+
+	return roles
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("user '%s' is not allowed to %s %s in tenant '%s'", e.Username, e.Verb, e.Resource, e.Tenant)
+}
+
+// Details returns the fields of the error for the response body
+func (e *DeniedError) Details() map[string]interface{} {
+	return map[string]interface{}{
+		"username": e.Username,
+		"tenant":   e.Tenant,
+		"resource": e.Resource,
+		"verb":     e.Verb,
+		"roles":    e.Roles,
+	}
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsAny(items []string, others []string) bool {
+	for _, o := range others {
+		if contains(items, o) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,221 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testPolicy = `
+defaultRole: viewer
+bindings:
+- role: platform-admin
+  tenant: "*"
+  groups: ["platform"]
+- role: tenant-admin
+  tenant: tenant1
+  users: ["alice"]
+- role: developer
+  tenant: tenant2
+  groups: ["tenant2-devs"]
+`
+
+func TestEnforcer_Enforce(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		policy   *Policy
+		subject  *Subject
+		tenant   string
+		resource Resource
+		verb     Verb
+		wantErr  string
+	}{
+		"Should allow everything when rbac is disabled": {
+			subject:  &Subject{Username: "mallory"},
+			tenant:   "tenant1",
+			resource: ResourceTenants,
+			verb:     VerbDelete,
+		},
+		"Should allow platform-admin to delete any tenant": {
+			policy:   policy,
+			subject:  &Subject{Username: "root", Groups: []string{"platform"}},
+			tenant:   "tenant9",
+			resource: ResourceTenants,
+			verb:     VerbDelete,
+		},
+		"Should allow platform-admin to register clusters": {
+			policy:   policy,
+			subject:  &Subject{Username: "root", Groups: []string{"platform"}},
+			tenant:   "tenant1",
+			resource: ResourceClusters,
+			verb:     VerbCreate,
+		},
+		"Should deny tenant-admin to register clusters": {
+			policy:   policy,
+			subject:  &Subject{Username: "alice", Tenants: []string{"tenant1"}},
+			tenant:   "tenant1",
+			resource: ResourceClusters,
+			verb:     VerbCreate,
+			wantErr:  "user 'alice' is not allowed to create clusters in tenant 'tenant1'",
+		},
+		"Should deny tenant-admin to delete its tenant": {
+			policy:   policy,
+			subject:  &Subject{Username: "alice", Tenants: []string{"tenant1"}},
+			tenant:   "tenant1",
+			resource: ResourceTenants,
+			verb:     VerbDelete,
+			wantErr:  "not allowed to delete tenants",
+		},
+		"Should allow tenant-admin to manage secret stores of its tenant": {
+			policy:   policy,
+			subject:  &Subject{Username: "alice", Tenants: []string{"tenant1"}},
+			tenant:   "tenant1",
+			resource: ResourceSecretStores,
+			verb:     VerbDelete,
+		},
+		"Should deny tenant-admin in another tenant": {
+			policy:   policy,
+			subject:  &Subject{Username: "alice", Tenants: []string{"tenant1"}},
+			tenant:   "tenant2",
+			resource: ResourceApplications,
+			verb:     VerbRead,
+			wantErr:  "in tenant 'tenant2'",
+		},
+		"Should allow developer to sync applications": {
+			policy:   policy,
+			subject:  &Subject{Username: "bob", Groups: []string{"tenant2-devs"}, Tenants: []string{"tenant2"}},
+			tenant:   "tenant2",
+			resource: ResourceApplications,
+			verb:     VerbSync,
+		},
+		"Should deny developer to create secret stores": {
+			policy:   policy,
+			subject:  &Subject{Username: "bob", Groups: []string{"tenant2-devs"}, Tenants: []string{"tenant2"}},
+			tenant:   "tenant2",
+			resource: ResourceSecretStores,
+			verb:     VerbCreate,
+			wantErr:  "not allowed to create secretstores",
+		},
+		"Should grant the default role to tenant members": {
+			policy:   policy,
+			subject:  &Subject{Username: "carol", Tenants: []string{"tenant3"}},
+			tenant:   "tenant3",
+			resource: ResourceApplications,
+			verb:     VerbRead,
+		},
+		"Should deny writes with the default role": {
+			policy:   policy,
+			subject:  &Subject{Username: "carol", Tenants: []string{"tenant3"}},
+			tenant:   "tenant3",
+			resource: ResourceApplications,
+			verb:     VerbCreate,
+			wantErr:  "not allowed to create applications",
+		},
+		"Should deny non members without a binding": {
+			policy:   policy,
+			subject:  &Subject{Username: "carol", Tenants: []string{"tenant3"}},
+			tenant:   "tenant1",
+			resource: ResourceApplications,
+			verb:     VerbRead,
+			wantErr:  "not allowed to read applications",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := NewEnforcer(tt.policy)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			err = e.Enforce(tt.subject, tt.tenant, tt.resource, tt.verb)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.IsType(t, &DeniedError{}, err)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy  string
+		wantErr string
+	}{
+		"Should parse a valid policy": {
+			policy: testPolicy,
+		},
+		"Should fail on an unknown role": {
+			policy: `
+bindings:
+- role: owner
+  tenant: tenant1
+  users: ["alice"]
+`,
+			wantErr: "unknown role 'owner'",
+		},
+		"Should fail on an unknown default role": {
+			policy:  `defaultRole: owner`,
+			wantErr: "unknown default role 'owner'",
+		},
+		"Should fail on a binding without tenant": {
+			policy: `
+bindings:
+- role: viewer
+  users: ["alice"]
+`,
+			wantErr: "tenant is required",
+		},
+		"Should fail on a binding without subjects": {
+			policy: `
+bindings:
+- role: viewer
+  tenant: tenant1
+`,
+			wantErr: "at least one user or group is required",
+		},
+		"Should fail on an unknown field": {
+			policy:  `bindingz: []`,
+			wantErr: "failed to parse rbac policy",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParsePolicy([]byte(tt.policy))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestLoadPolicyConfigMap(t *testing.T) {
+	cs := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "squidflow-rbac", Namespace: "squidflow"},
+		Data:       map[string]string{DefaultConfigMapKey: testPolicy},
+	})
+
+	policy, err := LoadPolicyConfigMap(context.Background(), cs, "squidflow", "squidflow-rbac", "")
+	assert.NoError(t, err)
+	assert.Equal(t, RoleViewer, policy.DefaultRole)
+	assert.Len(t, policy.Bindings, 3)
+
+	_, err = LoadPolicyConfigMap(context.Background(), cs, "squidflow", "squidflow-rbac", "other.yaml")
+	assert.ErrorContains(t, err, "has no key 'other.yaml'")
+
+	_, err = LoadPolicyConfigMap(context.Background(), cs, "squidflow", "missing", "")
+	assert.ErrorContains(t, err, "failed to get rbac policy configmap")
+}
@@ -7,6 +7,8 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"reflect"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,12 +16,18 @@ import (
 	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
 	"github.com/ghodss/yaml"
 	billyUtils "github.com/go-git/go-billy/v5/util"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
 
+	"github.com/squidflow/service/pkg/apierr"
 	"github.com/squidflow/service/pkg/application"
+	"github.com/squidflow/service/pkg/freeze"
 	"github.com/squidflow/service/pkg/fs"
 	"github.com/squidflow/service/pkg/git"
 	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/secretstore"
+	reader "github.com/squidflow/service/pkg/source"
 	"github.com/squidflow/service/pkg/store"
 	"github.com/squidflow/service/pkg/types"
 	"github.com/squidflow/service/pkg/util"
@@ -32,6 +40,14 @@ var (
 	clusterResReadmeTpl []byte
 )
 
+const (
+	// annotations of the base of a flattened app, that record the source its manifests are rendered from
+	annotationKeySourceRepo       = "squidflow.github.io/source-repo"
+	annotationKeySourcePath       = "squidflow.github.io/source-path"
+	annotationKeySourceRevision   = "squidflow.github.io/source-revision"
+	annotationKeyHelmManifestPath = "squidflow.github.io/helm-manifest-path"
+)
+
 var _ MetaRepoWriter = &NativeRepoTarget{}
 
 // NativeRepoTarget implements the native GitOps repository structure
@@ -63,7 +79,7 @@ func (n *NativeRepoTarget) RunAppCreate(ctx context.Context, opts *application.A
 	if n.metaRepoCloneOpts.Repo != n.tenantRepoCloneOpts.Repo {
 		tenantRepo, appsfs, err = getRepo(ctx, n.tenantRepoCloneOpts)
 		if err != nil {
-			log.G().Errorf("failed to prepare tenant repo: %v", err)
+			log.G(ctx).Errorf("failed to prepare tenant repo: %v", err)
 			return nil, err
 		}
 	} else {
@@ -71,7 +87,7 @@ func (n *NativeRepoTarget) RunAppCreate(ctx context.Context, opts *application.A
 	}
 
 	if err = setAppOptsDefaults(ctx, metaRepofs, opts); err != nil {
-		log.G().Errorf("failed to set app opts defaults: %v", err)
+		log.G(ctx).Errorf("failed to set app opts defaults: %v", err)
 		return nil, err
 	}
 
@@ -109,12 +125,34 @@ func (n *NativeRepoTarget) RunAppCreate(ctx context.Context, opts *application.A
 		if errors.Is(err, application.ErrAppAlreadyInstalledOnProject) {
 			return nil, fmt.Errorf("application '%s' already exists in project '%s': %w", app.Name(), opts.ProjectName, err)
 		}
-		log.G().WithFields(log.Fields{
+		log.G(ctx).WithFields(log.Fields{
 			"error": err,
 		}).Error("failed to create application files")
 		return nil, err
 	}
 
+	configfs, configPath := application.ConfigPath(metaRepofs, appsfs, app.Name(), opts.ProjectName)
+	overlayPath := appsfs.Join(store.Default.AppsDir, app.Name(), store.Default.OverlaysDir, opts.ProjectName)
+	if err = application.WriteTargets(configfs, configPath, appsfs, overlayPath, opts.Targets); err != nil {
+		return nil, fmt.Errorf("failed to create application targets: %w", err)
+	}
+
+	if err = renderTargetManifests(ctx, configfs, configPath, appsfs, app.Name(), overlayPath, &opts.Source, opts.Envs); err != nil {
+		return nil, err
+	}
+
+	if len(opts.Ingress) > 0 {
+		if err = writeAppIngress(ctx, configfs, configPath, appsfs, overlayPath, app.Name(), opts.ProjectName, opts.IngressClasses, opts.Ingress); err != nil {
+			return nil, err
+		}
+	}
+
+	if externalSecret := &opts.Security.ExternalSecret; len(externalSecret.Secrets) > 0 {
+		if err = n.writeAppExternalSecrets(ctx, configfs, configPath, appsfs, overlayPath, app.Name(), opts.ProjectName, externalSecret); err != nil {
+			return nil, err
+		}
+	}
+
 	if n.metaRepoCloneOpts.Repo != n.tenantRepoCloneOpts.Repo {
 		commitMsg := genCommitMsg("chore: "+
 			types.ActionTypeCreate,
@@ -123,7 +161,7 @@ func (n *NativeRepoTarget) RunAppCreate(ctx context.Context, opts *application.A
 			opts.ProjectName,
 			appsfs,
 		)
-		log.G().WithFields(
+		log.G(ctx).WithFields(
 			log.Fields{
 				"commit msg": commitMsg,
 				"repo":       n.tenantRepoCloneOpts.Repo,
@@ -155,7 +193,7 @@ func (n *NativeRepoTarget) RunAppCreate(ctx context.Context, opts *application.A
 		opts.ProjectName,
 		metaRepofs,
 	)
-	log.G().WithFields(log.Fields{
+	log.G(ctx).WithFields(log.Fields{
 		"commit msg": commitMsg,
 		"repo":       n.metaRepoCloneOpts.Repo,
 		"path":       n.metaRepoCloneOpts.Path(),
@@ -185,21 +223,21 @@ func (n *NativeRepoTarget) RunAppCreate(ctx context.Context, opts *application.A
 func (n *NativeRepoTarget) RunAppDelete(ctx context.Context, appName string) error {
 	r, repofs, err := getRepo(ctx, n.tenantRepoCloneOpts)
 	if err != nil {
-		log.G().Errorf("failed to prepare repo: %v", err)
+		log.G(ctx).Errorf("failed to prepare repo: %v", err)
 		return err
 	}
 
 	appDir := repofs.Join(store.Default.AppsDir, appName)
 	appExists := repofs.ExistsOrDie(appDir)
 	if !appExists {
-		return fmt.Errorf("application '%s' not found", appName)
+		return apierr.NotFound("application '%s' not found", appName)
 	}
 
 	var dirToRemove string
 	commitMsg := fmt.Sprintf("chore: delete app '%s'", appName)
 
 	if n.project == "" {
-		log.G().Debug("deleting all application from all of the project")
+		log.G(ctx).Debug("deleting all application from all of the project")
 		dirToRemove = appDir
 	} else {
 		appOverlaysDir := repofs.Join(appDir, store.Default.OverlaysDir)
@@ -211,7 +249,7 @@ func (n *NativeRepoTarget) RunAppDelete(ctx context.Context, appName string) err
 		appProjectDir := repofs.Join(appOverlaysDir, n.project)
 		overlayExists := repofs.ExistsOrDie(appProjectDir)
 		if !overlayExists {
-			return fmt.Errorf("application '%s' not found in project '%s'", appName, n.project)
+			return apierr.NotFound("application '%s' not found in project '%s'", appName, n.project)
 		}
 
 		allOverlays, err := repofs.ReadDir(appOverlaysDir)
@@ -232,7 +270,7 @@ func (n *NativeRepoTarget) RunAppDelete(ctx context.Context, appName string) err
 		return fmt.Errorf("failed to delete directory '%s': %w", dirToRemove, err)
 	}
 
-	log.G().Info("committing changes to gitops repo...")
+	log.G(ctx).Info("committing changes to gitops repo...")
 	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: commitMsg}); err != nil {
 		return fmt.Errorf("failed to push to repo: %w", err)
 	}
@@ -256,7 +294,7 @@ func (n *NativeRepoTarget) RunAppList(ctx context.Context) ([]types.Application,
 		path = repofs.Join(store.Default.AppsDir, "*", n.project)
 	}
 
-	log.G().WithFields(log.Fields{
+	log.G(ctx).WithFields(log.Fields{
 		"repo": n.tenantRepoCloneOpts.Repo,
 		"path": path,
 	}).Debug("listing applications")
@@ -269,10 +307,11 @@ func (n *NativeRepoTarget) RunAppList(ctx context.Context) ([]types.Application,
 	applications := make([]types.Application, 0, len(matches))
 
 	for _, appPath := range matches {
-		conf, err := getConfigFileFromPath(repofs, appPath)
+		confs, err := getConfigsFromPath(repofs, appPath)
 		if err != nil {
 			return nil, err
 		}
+		conf := confs[0]
 
 		applications = append(applications, types.Application{
 			ApplicationSource: types.ApplicationSourceRequest{
@@ -286,12 +325,7 @@ func (n *NativeRepoTarget) RunAppList(ctx context.Context) ([]types.Application,
 				AppCode:         conf.Annotations["squidflow.github.io/appcode"],
 				Description:     conf.Annotations["squidflow.github.io/description"],
 			},
-			ApplicationTarget: []types.ApplicationTarget{
-				{
-					Cluster:   "in-cluster",
-					Namespace: conf.DestNamespace,
-				},
-			},
+			ApplicationTarget: getAppTargets(n.project, confs),
 			// note: will update later
 			ApplicationRuntime: types.ApplicationRuntime{
 				GitInfo:         []types.GitInfo{},
@@ -311,249 +345,1046 @@ func (n *NativeRepoTarget) RunAppList(ctx context.Context) ([]types.Application,
 	return applications, nil
 }
 
-// RunAppUpdate updates an application in the native GitOps repository structure
-func (n *NativeRepoTarget) RunAppUpdate(ctx context.Context, opts *types.UpdateOptions) error {
-	return nil
-}
-
-// RunAppGet gets an application from the native GitOps repository structure
-func (n *NativeRepoTarget) RunAppGet(ctx context.Context, appName string) (*types.Application, error) {
+// RunIngressHostList returns the ingress hosts committed to the overlays of the targets of every app of the repo,
+// whatever their tenant. They may not be synced to the clusters yet
+func (n *NativeRepoTarget) RunIngressHostList(ctx context.Context) ([]types.IngressHost, error) {
 	_, repofs, err := getRepo(ctx, n.tenantRepoCloneOpts)
 	if err != nil {
-		log.G().Errorf("failed to prepare repo: %v", err)
 		return nil, err
 	}
 
-	appPath := repofs.Join(store.Default.AppsDir, appName, store.Default.OverlaysDir, n.project)
-	if n.tenantRepoCloneOpts.Repo != n.metaRepoCloneOpts.Repo {
-		appPath = repofs.Join(store.Default.AppsDir, appName, n.project)
+	overlays, err := billyUtils.Glob(repofs, repofs.Join(store.Default.AppsDir, "*", store.Default.OverlaysDir, "*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list application overlays: %w", err)
 	}
 
-	log.G().WithFields(log.Fields{
-		"repo": n.tenantRepoCloneOpts.Repo,
-		"path": appPath,
-	}).Debug("getting application detail")
+	hosts := []types.IngressHost{}
+	for _, overlayPath := range overlays {
+		appDir := path.Dir(path.Dir(overlayPath))
+		appName, tenant := path.Base(appDir), path.Base(overlayPath)
 
-	conf, err := getConfigFileFromPath(repofs, appPath)
-	if err != nil {
-		log.G().Errorf("failed to get application detail: %v", err)
-		return nil, err
+		// the config of the app is next to its overlay in the meta repo
+		configDir := overlayPath
+		if n.tenantRepoCloneOpts.Repo != n.metaRepoCloneOpts.Repo {
+			configDir = repofs.Join(appDir, tenant)
+		}
+
+		confs, paths, err := application.TargetOverlays(repofs, repofs.Join(configDir, "config.json"), repofs, overlayPath)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, overlay := range paths {
+			ingress, err := application.ReadIngress(repofs, overlay)
+			if err != nil {
+				return nil, err
+			}
+			if ingress == nil {
+				continue
+			}
+
+			for _, rule := range ingress.Spec.Rules {
+				hosts = append(hosts, types.IngressHost{
+					Host:    rule.Host,
+					Tenant:  tenant,
+					AppName: appName,
+					Cluster: confs[i].DestClusterName,
+					Server:  confs[i].DestServer,
+				})
+			}
+		}
 	}
 
-	return &types.Application{
-		ApplicationSource: types.ApplicationSourceRequest{
-			Repo:           conf.SrcRepoURL,
-			Path:           conf.SrcPath,
-			TargetRevision: conf.SrcTargetRevision,
-		},
-		ApplicationInstantiation: types.ApplicationInstantiation{
-			ApplicationName: conf.UserGivenName,
-			TenantName:      n.project,
-			AppCode:         conf.Annotations["squidflow.github.io/appcode"],
-			Description:     conf.Annotations["squidflow.github.io/description"],
-		},
-		ApplicationTarget: []types.ApplicationTarget{
-			{
-				Cluster:   "in-cluster",
-				Namespace: conf.DestNamespace,
-			},
-		},
-		ApplicationRuntime: types.ApplicationRuntime{
-			GitInfo:         []types.GitInfo{},
-			ResourceMetrics: types.ResourceMetricsInfo{},
-			Status:          "unknown",
-			Health:          "unknown",
-			SyncStatus:      "unknown",
-			ArgoCDUrl:       "",
-			CreatedAt:       time.Now(),
-			CreatedBy:       "",
-			LastUpdatedAt:   time.Now(),
-			LastUpdatedBy:   "",
-		},
-	}, nil
+	return hosts, nil
 }
 
-// RunProjectCreate creates a project in the native GitOps repository structure
-func (n *NativeRepoTarget) RunProjectCreate(ctx context.Context, opts *types.ProjectCreateOptions) error {
-	var (
-		err                   error
-		installationNamespace string
-	)
-
-	r, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
+// RunAppUpdate updates an application in the native GitOps repository structure
+// the app base is rewritten when the source changes, the overlay and config.json
+// are rewritten when the destination or the annotations change
+func (n *NativeRepoTarget) RunAppUpdate(ctx context.Context, opts *types.UpdateOptions) error {
+	r, repofs, err := getRepo(ctx, n.tenantRepoCloneOpts)
 	if err != nil {
+		log.G(ctx).Errorf("failed to prepare repo: %v", err)
 		return err
 	}
 
-	installationNamespace, err = getInstallationNamespace(repofs)
+	appDir := repofs.Join(store.Default.AppsDir, opts.AppName)
+	if !repofs.ExistsOrDie(appDir) {
+		return apierr.NotFound("application '%s' not found", opts.AppName)
+	}
+
+	configDir := n.appConfigDir(repofs, opts.AppName)
+	confs, err := getConfigsFromPath(repofs, configDir)
 	if err != nil {
-		return fmt.Errorf(util.Doc("Bootstrap folder not found, please execute `<BIN> repo bootstrap --installation-path %s` command"), repofs.Root())
+		return err
 	}
+	conf := &confs[0]
 
-	projectExists := repofs.ExistsOrDie(repofs.Join(store.Default.ProjectsDir, opts.ProjectName+".yaml"))
-	if projectExists {
-		return fmt.Errorf("project '%s' already exists", opts.ProjectName)
+	req := opts.UpdateReq
+	if req == nil {
+		req = &types.ApplicationUpdateRequest{}
 	}
 
-	if opts.DestKubeServer == "" {
-		opts.DestKubeServer = store.Default.DestServer
-		if opts.DestKubeContext != "" {
-			opts.DestKubeServer, err = util.KubeContextToServer(opts.DestKubeContext)
-			if err != nil {
-				return err
-			}
+	log.G(ctx).WithFields(log.Fields{
+		"repo":   n.tenantRepoCloneOpts.Repo,
+		"path":   configDir,
+		"source": req.ApplicationSource,
+		"target": req.ApplicationTarget,
+	}).Debug("updating application")
+
+	overlayPath := repofs.Join(appDir, store.Default.OverlaysDir, n.project)
+	if len(req.ApplicationTarget) > 0 {
+		if err = updateAppNamespaces(ctx, repofs, overlayPath, confs, req.ApplicationTarget); err != nil {
+			return err
+		}
+		if namespace := req.ApplicationTarget[0].Namespace; namespace != "" {
+			conf.DestNamespace = namespace
 		}
 	}
 
-	projectYAML, appsetYAML, clusterResReadme, clusterResConf, err := generateProjectManifests(&types.GenerateProjectOptions{
-		Name:               opts.ProjectName,
-		Namespace:          installationNamespace,
-		ProjectGitopsRepo:  opts.ProjectGitopsRepo,
-		RepoURL:            n.metaRepoCloneOpts.URL(),
-		Revision:           n.metaRepoCloneOpts.Revision(),
-		InstallationPath:   n.metaRepoCloneOpts.Path(),
-		DefaultDestServer:  opts.DestKubeServer,
-		DefaultDestContext: opts.DestKubeContext,
-		Labels:             opts.Labels,
-		Annotations:        opts.Annotations,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to generate project resources: %w", err)
+	var src *types.ApplicationSourceRequest
+	if req.ApplicationSource.Repo != "" {
+		src = &req.ApplicationSource
+		if err = updateAppBase(repofs, appDir, src); err != nil {
+			return err
+		}
 	}
 
-	if opts.DryRun {
-		log.G().Printf("%s", util.JoinManifests(projectYAML, appsetYAML))
-		return nil
+	for i := range confs {
+		if confs[i].Annotations == nil {
+			confs[i].Annotations = map[string]string{}
+		}
+		for k, v := range opts.Annotations {
+			confs[i].Annotations[k] = v
+		}
 	}
 
-	bulkWrites := []fs.BulkWriteRequest{}
+	configPath := repofs.Join(configDir, "config.json")
+	if err = application.WriteConfigs(repofs, configPath, confs); err != nil {
+		return fmt.Errorf("failed to write app config.json: %w", err)
+	}
 
-	if opts.DestKubeContext != "" {
-		log.G().Infof("adding cluster: %s", opts.DestKubeContext)
-		if err = opts.AddCmd.Execute(ctx, opts.DestKubeContext); err != nil {
-			return fmt.Errorf("failed to add new cluster credentials: %w", err)
-		}
+	if err = application.WriteTargets(repofs, configPath, repofs, overlayPath, req.ApplicationTarget); err != nil {
+		return fmt.Errorf("failed to update application targets: %w", err)
+	}
 
-		if !repofs.ExistsOrDie(repofs.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir, opts.DestKubeContext)) {
-			bulkWrites = append(bulkWrites, fs.BulkWriteRequest{
-				Filename: repofs.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir, opts.DestKubeContext+".json"),
-				Data:     clusterResConf,
-				ErrMsg:   "failed to write cluster config",
-			})
+	if src != nil || len(req.ApplicationTarget) > 0 {
+		if err = renderTargetManifests(ctx, repofs, configPath, repofs, opts.AppName, overlayPath, src, opts.Envs); err != nil {
+			return err
+		}
+	}
 
-			bulkWrites = append(bulkWrites, fs.BulkWriteRequest{
-				Filename: repofs.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir, opts.DestKubeContext, "README.md"),
-				Data:     clusterResReadme,
-				ErrMsg:   "failed to write cluster resources readme",
-			})
+	ingresses := req.ApplicationInstantiation.Ingress
+	if ingresses == nil && len(req.ApplicationTarget) > 0 {
+		// the overlays of the targets are written again, they keep the ingress of the app
+		ingress, err := application.ReadIngress(repofs, overlayPath)
+		if err != nil {
+			return err
+		}
+		if ingress != nil {
+			ingresses = application.IngressConfigs(ingress)
 		}
 	}
 
-	bulkWrites = append(bulkWrites, fs.BulkWriteRequest{
-		Filename: repofs.Join(store.Default.ProjectsDir, opts.ProjectName+".yaml"),
-		Data:     util.JoinManifests(projectYAML, appsetYAML),
-		ErrMsg:   "failed to create project file",
-	})
+	if ingresses != nil {
+		if err = writeAppIngress(ctx, repofs, configPath, repofs, overlayPath, opts.AppName, n.project, opts.IngressClasses, ingresses); err != nil {
+			return err
+		}
+	}
 
-	if err = fs.BulkWrite(repofs, bulkWrites...); err != nil {
-		return err
+	externalSecret := req.ApplicationInstantiation.Security.ExternalSecret
+	if externalSecret.Secrets == nil && len(req.ApplicationTarget) > 0 {
+		// the overlays of the targets are written again, they keep the external secrets of the app
+		secrets, err := application.ReadExternalSecrets(repofs, overlayPath)
+		if err != nil {
+			return err
+		}
+		if len(secrets) > 0 {
+			externalSecret = application.ExternalSecretConfigOf(secrets)
+		}
 	}
 
-	log.G().Infof("pushing new project manifest to repo")
-	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: fmt.Sprintf("chore: added project '%s'", opts.ProjectName)}); err != nil {
-		return err
+	if externalSecret.Secrets != nil {
+		if err = n.writeAppExternalSecrets(ctx, repofs, configPath, repofs, overlayPath, opts.AppName, n.project, &externalSecret); err != nil {
+			return err
+		}
 	}
 
-	log.G().Infof("project created: '%s'", opts.ProjectName)
+	commitMsg := genCommitMsg("chore: "+
+		types.ActionTypeUpdate,
+		types.ResourceNameApp,
+		opts.AppName,
+		n.project,
+		repofs,
+	)
+	log.G(ctx).WithFields(log.Fields{
+		"commit msg": commitMsg,
+		"repo":       n.tenantRepoCloneOpts.Repo,
+	}).Debug("push to gitops repo with commit msg")
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: commitMsg}); err != nil {
+		return fmt.Errorf("failed to push to repo: %w", err)
+	}
 
 	return nil
 }
 
-func generateProjectManifests(o *types.GenerateProjectOptions) (projectYAML, appSetYAML, clusterResReadme, clusterResConfig []byte, err error) {
-	project := &argocdv1alpha1.AppProject{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       argocdv1alpha1.AppProjectSchemaGroupVersionKind.Kind,
-			APIVersion: argocdv1alpha1.AppProjectSchemaGroupVersionKind.GroupVersion().String(),
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      o.Name,
-			Namespace: o.Namespace,
-			Annotations: map[string]string{
-				"argocd.argoproj.io/sync-wave":     "-2",
-				"argocd.argoproj.io/sync-options":  "PruneLast=true",
-				store.Default.DestServerAnnotation: o.DefaultDestServer,
-			},
-		},
-		Spec: argocdv1alpha1.AppProjectSpec{
-			SourceRepos: []string{"*"},
-			Destinations: []argocdv1alpha1.ApplicationDestination{
-				{
-					Server:    "*",
-					Namespace: "*",
-				},
-			},
-			Description: fmt.Sprintf("%s project", o.Name),
-			ClusterResourceWhitelist: []metav1.GroupKind{
-				{
-					Group: "*",
-					Kind:  "*",
-				},
-			},
-			NamespaceResourceWhitelist: []metav1.GroupKind{
-				{
-					Group: "*",
-					Kind:  "*",
-				},
-			},
-		},
+// RunAppHistory returns the commits of the gitops repo that changed the application, newest first
+func (n *NativeRepoTarget) RunAppHistory(ctx context.Context, appName string, limit int) ([]types.ApplicationRevision, error) {
+	r, repofs, err := getRepo(ctx, n.tenantRepoCloneOpts)
+	if err != nil {
+		return nil, err
 	}
-	if projectYAML, err = yaml.Marshal(project); err != nil {
-		err = fmt.Errorf("failed to marshal AppProject: %w", err)
-		return
+
+	appDir := repofs.Join(store.Default.AppsDir, appName)
+	if !repofs.ExistsOrDie(appDir) {
+		return nil, apierr.NotFound("application '%s' not found", appName)
 	}
 
-	appsetRepoURL := o.ProjectGitopsRepo
-	if appsetRepoURL == "" {
-		appsetRepoURL = o.RepoURL
+	commits, err := r.History(ctx, appDir, limit)
+	if err != nil {
+		return nil, err
 	}
 
-	appSetYAML, err = createAppSet(&createAppSetOptions{
-		name:                        o.Name,
-		namespace:                   o.Namespace,
-		appName:                     fmt.Sprintf("%s-{{ userGivenName }}", o.Name),
-		appNamespace:                o.Namespace,
-		appProject:                  o.Name,
-		repoURL:                     "{{ srcRepoURL }}",
-		srcPath:                     "{{ srcPath }}",
-		revision:                    "{{ srcTargetRevision }}",
-		destServer:                  "{{ destServer }}",
-		destNamespace:               "{{ destNamespace }}",
-		prune:                       true,
-		preserveResourcesOnDeletion: false,
-		appLabels:                   getDefaultAppLabels(o.Labels),
-		appAnnotations:              o.Annotations,
-		generators: []argocdv1alpha1.ApplicationSetGenerator{
-			{
-				Git: &argocdv1alpha1.GitGenerator{
-					RepoURL:  appsetRepoURL, // use tenant's repo url
-					Revision: o.Revision,
-					Files: []argocdv1alpha1.GitFileGeneratorItem{
-						{
-							Path: path.Join(o.InstallationPath, store.Default.AppsDir, "**", o.Name, "config.json"),
-						},
-					},
-					RequeueAfterSeconds: &DefaultApplicationSetGeneratorInterval,
-				},
-			},
-			{
-				Git: &argocdv1alpha1.GitGenerator{
-					RepoURL:  appsetRepoURL, // use tenant's repo url
-					Revision: o.Revision,
-					Files: []argocdv1alpha1.GitFileGeneratorItem{
-						{
-							Path: path.Join(o.InstallationPath, store.Default.AppsDir, "**", o.Name, "config_dir.json"),
-						},
-					},
+	revisions := make([]types.ApplicationRevision, 0, len(commits))
+	for _, c := range commits {
+		files, err := r.Files(ctx, c.SHA, appDir)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, types.ApplicationRevision{
+			SHA:               c.SHA,
+			Author:            c.Author,
+			Email:             c.Email,
+			Message:           c.Message,
+			Timestamp:         c.Timestamp,
+			SrcTargetRevision: srcTargetRevisionOf(files),
+		})
+	}
+
+	return revisions, nil
+}
+
+// srcTargetRevisionOf returns the source revision of the first config.json of the app files, by path
+func srcTargetRevisionOf(files map[string][]byte) string {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		if path.Base(p) == "config.json" {
+			paths = append(paths, p)
+		}
+	}
+	slices.Sort(paths)
+
+	for _, p := range paths {
+		confs, err := application.ParseConfigs(files[p])
+		if err == nil {
+			return confs[0].SrcTargetRevision
+		}
+	}
+
+	return ""
+}
+
+// RunAppRollback restores the application directory, with its config.json, to a commit of the gitops repo.
+// It returns the revision of the new commit, or the pull request url in pull request mode
+func (n *NativeRepoTarget) RunAppRollback(ctx context.Context, appName, revision string) (string, error) {
+	r, repofs, err := getRepo(ctx, n.tenantRepoCloneOpts)
+	if err != nil {
+		return "", err
+	}
+
+	appDir := repofs.Join(store.Default.AppsDir, appName)
+	if !repofs.ExistsOrDie(appDir) {
+		return "", apierr.NotFound("application '%s' not found", appName)
+	}
+
+	// only the revisions of the app history can be restored, a revision that did not change the
+	// app would restore the app to whatever it was at that time
+	commits, err := r.History(ctx, appDir, 0)
+	if err != nil {
+		return "", err
+	}
+	if !slices.ContainsFunc(commits, func(c git.Commit) bool { return isRevisionOf(c.SHA, revision) }) {
+		return "", apierr.Validation("revision '%s' is not in the history of application '%s'", revision, appName)
+	}
+
+	files, err := r.Files(ctx, revision, appDir)
+	if err != nil {
+		return "", err
+	}
+
+	if len(files) == 0 {
+		return "", apierr.NotFound("application '%s' not found at revision '%s'", appName, revision)
+	}
+
+	if err = billyUtils.RemoveAll(repofs, appDir); err != nil {
+		return "", fmt.Errorf("failed to delete directory '%s': %w", appDir, err)
+	}
+
+	for filename, data := range files {
+		if err = billyUtils.WriteFile(repofs, filename, data, 0666); err != nil {
+			return "", fmt.Errorf("failed to restore '%s': %w", filename, err)
+		}
+	}
+
+	commitMsg := fmt.Sprintf("chore: %s %s '%s' on project '%s' to '%s'", types.ActionTypeRollback, types.ResourceNameApp, appName, n.project, revision)
+	log.G(ctx).WithFields(log.Fields{
+		"commit msg": commitMsg,
+		"repo":       n.tenantRepoCloneOpts.Repo,
+	}).Debug("push to gitops repo with commit msg")
+
+	commit, err := r.Persist(ctx, &git.PushOptions{CommitMsg: commitMsg})
+	if err != nil {
+		return "", fmt.Errorf("failed to push to repo: %w", err)
+	}
+
+	return commit, nil
+}
+
+// isRevisionOf reports whether revision is the sha, or an abbreviation of it
+func isRevisionOf(sha, revision string) bool {
+	return sha == revision || (len(revision) >= 7 && strings.HasPrefix(sha, revision))
+}
+
+// RunAppPromote deploys the targets of the application on the clusters of the promotion from its revision,
+// the revision of the other targets is unchanged
+func (n *NativeRepoTarget) RunAppPromote(ctx context.Context, opts *types.AppPromoteOptions) (string, error) {
+	r, repofs, err := getRepo(ctx, n.tenantRepoCloneOpts)
+	if err != nil {
+		return "", err
+	}
+
+	appDir := repofs.Join(store.Default.AppsDir, opts.AppName)
+	if !repofs.ExistsOrDie(appDir) {
+		return "", apierr.NotFound("application '%s' not found", opts.AppName)
+	}
+
+	configPath := repofs.Join(n.appConfigDir(repofs, opts.AppName), "config.json")
+	confs, err := application.ReadConfigs(repofs, configPath)
+	if err != nil {
+		return "", err
+	}
+
+	promoted := 0
+	for i := range confs {
+		cluster := application.TargetClusterName(&confs[i])
+		if !slices.Contains(opts.Clusters, cluster) {
+			continue
+		}
+
+		// the overlay of a target added after the revision does not exist there, ArgoCD could not deploy it
+		files, err := r.Files(ctx, opts.Promotion.Revision, confs[i].SrcPath)
+		if err != nil {
+			return "", err
+		}
+		if len(files) == 0 {
+			return "", apierr.Unprocessable("the target of application '%s' on cluster '%s' does not exist at revision '%s', '%s' is missing",
+				opts.AppName, cluster, opts.Promotion.Revision, confs[i].SrcPath)
+		}
+
+		application.Promote(&confs[i], &opts.Promotion)
+		promoted++
+	}
+	if promoted != len(opts.Clusters) {
+		return "", apierr.NotFound("application '%s' is not deployed to all of the clusters %v", opts.AppName, opts.Clusters)
+	}
+
+	if err = application.WriteConfigs(repofs, configPath, confs); err != nil {
+		return "", fmt.Errorf("failed to write app config.json: %w", err)
+	}
+
+	commitMsg := fmt.Sprintf("chore: %s %s '%s' on project '%s' from '%s' to %v at '%s'",
+		types.ActionTypePromote, types.ResourceNameApp, opts.AppName, n.project, opts.Promotion.From, opts.Clusters, opts.Promotion.Revision)
+	log.G(ctx).WithFields(log.Fields{
+		"commit msg": commitMsg,
+		"repo":       n.tenantRepoCloneOpts.Repo,
+	}).Debug("push to gitops repo with commit msg")
+
+	commit, err := r.Persist(ctx, &git.PushOptions{CommitMsg: commitMsg})
+	if err != nil {
+		return "", fmt.Errorf("failed to push to repo: %w", err)
+	}
+
+	return commit, nil
+}
+
+// RunAppUnpin deploys the targets of the application on the clusters from the revision that the gitops repo
+// tracks again, so the later changes of the app reach them
+func (n *NativeRepoTarget) RunAppUnpin(ctx context.Context, opts *types.AppUnpinOptions) (string, error) {
+	r, repofs, err := getRepo(ctx, n.tenantRepoCloneOpts)
+	if err != nil {
+		return "", err
+	}
+
+	appDir := repofs.Join(store.Default.AppsDir, opts.AppName)
+	if !repofs.ExistsOrDie(appDir) {
+		return "", apierr.NotFound("application '%s' not found", opts.AppName)
+	}
+
+	configPath := repofs.Join(n.appConfigDir(repofs, opts.AppName), "config.json")
+	confs, err := application.ReadConfigs(repofs, configPath)
+	if err != nil {
+		return "", err
+	}
+
+	unpinned := 0
+	for i := range confs {
+		if slices.Contains(opts.Clusters, application.TargetClusterName(&confs[i])) {
+			application.Unpin(&confs[i], n.tenantRepoCloneOpts.Revision())
+			unpinned++
+		}
+	}
+	if unpinned != len(opts.Clusters) {
+		return "", apierr.NotFound("application '%s' is not deployed to all of the clusters %v", opts.AppName, opts.Clusters)
+	}
+
+	if err = application.WriteConfigs(repofs, configPath, confs); err != nil {
+		return "", fmt.Errorf("failed to write app config.json: %w", err)
+	}
+
+	commitMsg := fmt.Sprintf("chore: %s %s '%s' on project '%s' on %v",
+		types.ActionTypeUnpin, types.ResourceNameApp, opts.AppName, n.project, opts.Clusters)
+	log.G(ctx).WithFields(log.Fields{
+		"commit msg": commitMsg,
+		"repo":       n.tenantRepoCloneOpts.Repo,
+	}).Debug("push to gitops repo with commit msg")
+
+	commit, err := r.Persist(ctx, &git.PushOptions{CommitMsg: commitMsg})
+	if err != nil {
+		return "", fmt.Errorf("failed to push to repo: %w", err)
+	}
+
+	return commit, nil
+}
+
+// RunAppTargetManifests returns the desired manifests of each target of the application by cluster, built from the
+// overlay of the target. A remote base is rendered with the env of the cluster of the target, envs are by cluster name
+func (n *NativeRepoTarget) RunAppTargetManifests(ctx context.Context, appName string, envs map[string]string) (map[string][]byte, error) {
+	_, repofs, err := getRepo(ctx, n.tenantRepoCloneOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	appDir := repofs.Join(store.Default.AppsDir, appName)
+	base, err := readAppBase(repofs, appName)
+	if err != nil {
+		return nil, err
+	}
+
+	configPath := repofs.Join(n.appConfigDir(repofs, appName), "config.json")
+	overlayPath := repofs.Join(appDir, store.Default.OverlaysDir, n.project)
+	confs, overlays, err := application.TargetOverlays(repofs, configPath, repofs, overlayPath)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := make(map[string][]byte, len(confs))
+	for i := range confs {
+		cluster := application.TargetClusterName(&confs[i])
+		files := map[string][]byte{}
+		if base.Resources[0] != "manifest.yaml" {
+			env := envs[cluster]
+			if env == "" {
+				env = "default"
+			}
+
+			// the overlay is built on the remote base rendered with the env of the cluster
+			rendered, err := renderRemoteBase(ctx, base.Resources[0], env)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render application manifests of env '%s': %w", env, err)
+			}
+
+			kustomization, err := yaml.Marshal(&kusttypes.Kustomization{
+				TypeMeta:  base.TypeMeta,
+				Resources: []string{"manifest.yaml"},
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			files[path.Join("base", "manifest.yaml")] = rendered
+			files[path.Join("base", "kustomization.yaml")] = kustomization
+		}
+
+		overlay := strings.TrimPrefix(overlays[i], appDir+"/")
+		if manifests[cluster], err = reader.BuildOverlay(repofs, appDir, overlay, files); err != nil {
+			return nil, fmt.Errorf("failed to build the overlay of the target on cluster '%s': %w", cluster, err)
+		}
+	}
+
+	return manifests, nil
+}
+
+// readAppManifests returns the desired manifests of the app base, see RunAppManifest
+func readAppManifests(ctx context.Context, repofs fs.FS, appName string) ([]byte, error) {
+	base, err := readAppBase(repofs, appName)
+	if err != nil {
+		return nil, err
+	}
+
+	if base.Resources[0] == "manifest.yaml" {
+		return repofs.ReadFile(repofs.Join(store.Default.AppsDir, appName, "base", "manifest.yaml"))
+	}
+
+	manifests, err := renderRemoteBase(ctx, base.Resources[0], "default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render application manifests: %w", err)
+	}
+
+	return manifests, nil
+}
+
+// readAppBase reads the kustomization of the app base, its single resource is the flattened manifests or the
+// remote base
+func readAppBase(repofs fs.FS, appName string) (*kusttypes.Kustomization, error) {
+	basePath := repofs.Join(store.Default.AppsDir, appName, "base")
+	baseKustomizationPath := repofs.Join(basePath, "kustomization.yaml")
+	if !repofs.ExistsOrDie(baseKustomizationPath) {
+		return nil, apierr.NotFound("application '%s' not found", appName)
+	}
+
+	base := &kusttypes.Kustomization{}
+	if err := repofs.ReadYamls(baseKustomizationPath, base); err != nil {
+		return nil, fmt.Errorf("failed to read app base: %w", err)
+	}
+
+	if len(base.Resources) != 1 {
+		return nil, fmt.Errorf("unexpected resources of app base '%s': %v", basePath, base.Resources)
+	}
+
+	return base, nil
+}
+
+// writeAppIngress writes the ingress of the app to the overlays of its targets, routed to the Service of the app
+// manifests. Without ingresses, the ingress of the app is removed
+func writeAppIngress(ctx context.Context, configfs fs.FS, configPath string, appsfs fs.FS, overlayPath, appName, tenant string, classes map[string]string, ingresses []types.IngressConfig) error {
+	var backend *networkingv1.IngressServiceBackend
+	if len(ingresses) > 0 {
+		manifests, err := readAppManifests(ctx, appsfs, appName)
+		if err != nil {
+			return err
+		}
+
+		backend, err = application.FindService(manifests, appName)
+		if err != nil {
+			return apierr.Validation("invalid ingress of application '%s': %v", appName, err)
+		}
+	}
+
+	if err := application.WriteTargetIngresses(configfs, configPath, appsfs, overlayPath, appName, tenant, backend, classes, ingresses); err != nil {
+		return fmt.Errorf("failed to write application ingress: %w", err)
+	}
+
+	return nil
+}
+
+// writeAppExternalSecrets writes the external secrets of the app to the overlays of its targets, filled from the
+// secret store of the tenant with the id of the config. Without secrets, the external secrets of the app are removed
+func (n *NativeRepoTarget) writeAppExternalSecrets(ctx context.Context, configfs fs.FS, configPath string, appsfs fs.FS, overlayPath, appName, tenant string, conf *types.ExternalSecretConfig) error {
+	var (
+		ss      *esv1beta1.SecretStore
+		secrets []*esv1beta1.ExternalSecret
+	)
+	if len(conf.Secrets) > 0 {
+		var err error
+		ss, err = n.SecretStoreGet(ctx, conf.SecretStoreRef.ID)
+		if apierr.CodeOf(err) == apierr.CodeNotFound {
+			return apierr.Validation("secret store '%s' of application '%s' not found", conf.SecretStoreRef.ID, appName)
+		}
+		if err != nil {
+			return err
+		}
+
+		// the secret stores without tenant were created before they were labelled, they are shared by the tenants
+		if owner := ss.Labels[store.Default.LabelKeyTenant]; owner != "" && owner != tenant {
+			return apierr.Forbidden("secret store '%s' does not belong to tenant '%s'", conf.SecretStoreRef.ID, tenant)
+		}
+
+		if secrets, err = application.GenerateExternalSecrets(appName, tenant, ss, conf); err != nil {
+			return err
+		}
+	}
+
+	if err := application.WriteTargetExternalSecrets(configfs, configPath, appsfs, overlayPath, ss, secrets); err != nil {
+		return fmt.Errorf("failed to write application external secrets: %w", err)
+	}
+
+	return nil
+}
+
+// appConfigDir returns the directory of the app's config.json for the project
+// if tenant's application save with meta repo path, use `apps/{appname}/overlays/{tenant}`
+// else use `apps/{appname}/{tenant}`
+func (n *NativeRepoTarget) appConfigDir(repofs fs.FS, appName string) string {
+	if n.tenantRepoCloneOpts.Repo != n.metaRepoCloneOpts.Repo {
+		return repofs.Join(store.Default.AppsDir, appName, n.project)
+	}
+
+	return repofs.Join(store.Default.AppsDir, appName, store.Default.OverlaysDir, n.project)
+}
+
+// updateAppBase points the remote base of the app at the new source. Flattened bases are rendered again from the new
+// source by renderTargetManifests, once the targets are written
+func updateAppBase(repofs fs.FS, appDir string, src *types.ApplicationSourceRequest) error {
+	basePath := repofs.Join(appDir, "base")
+	baseKustomizationPath := repofs.Join(basePath, "kustomization.yaml")
+	base := &kusttypes.Kustomization{}
+	if err := repofs.ReadYamls(baseKustomizationPath, base); err != nil {
+		return fmt.Errorf("failed to read app base: %w", err)
+	}
+
+	if len(base.Resources) == 1 && base.Resources[0] == "manifest.yaml" {
+		return nil
+	}
+
+	base.Resources = []string{application.BuildKustomizeResourceRef(application.ApplicationSourceOption{
+		Repo:           src.Repo,
+		Path:           src.Path,
+		TargetRevision: src.TargetRevision,
+	})}
+	if err := repofs.WriteYamls(baseKustomizationPath, base); err != nil {
+		return fmt.Errorf("failed to write app base: %w", err)
+	}
+
+	return nil
+}
+
+// appBaseSource returns the source that the flattened manifests of the app base are rendered from, recorded by the
+// annotations of the base. Apps flattened before the source was recorded have none
+func appBaseSource(base *kusttypes.Kustomization) *types.ApplicationSourceRequest {
+	if base.MetaData == nil || base.MetaData.Annotations[annotationKeySourceRepo] == "" {
+		return nil
+	}
+
+	annotations := base.MetaData.Annotations
+	return &types.ApplicationSourceRequest{
+		Repo:           annotations[annotationKeySourceRepo],
+		Path:           annotations[annotationKeySourcePath],
+		TargetRevision: annotations[annotationKeySourceRevision],
+		ApplicationSpecifier: types.ApplicationSpecifier{
+			HelmManifestPath: annotations[annotationKeyHelmManifestPath],
+		},
+	}
+}
+
+// renderTargetManifests renders the flattened manifests of the app with the env of the cluster of each target. The base
+// is rendered with the env of the primary target, every other target whose env differs gets manifests of its own.
+// A new source is recorded by the base, without one the manifests are rendered again from the recorded source.
+// Remote bases, and directory apps without a base, are left as they are
+func renderTargetManifests(ctx context.Context, configfs fs.FS, configPath string, appsfs fs.FS, appName, overlayPath string, src *types.ApplicationSourceRequest, envs map[string]string) error {
+	basePath := appsfs.Join(store.Default.AppsDir, appName, "base")
+	baseKustomizationPath := appsfs.Join(basePath, "kustomization.yaml")
+	if !appsfs.ExistsOrDie(baseKustomizationPath) {
+		return nil
+	}
+
+	base := &kusttypes.Kustomization{}
+	if err := appsfs.ReadYamls(baseKustomizationPath, base); err != nil {
+		return fmt.Errorf("failed to read app base: %w", err)
+	}
+
+	if len(base.Resources) != 1 || base.Resources[0] != "manifest.yaml" {
+		return nil
+	}
+
+	if src == nil {
+		if src = appBaseSource(base); src == nil {
+			log.G(ctx).WithField("app", appName).Warn("the source of the flattened app is not recorded, its targets use the manifests of the base")
+			return nil
+		}
+	} else {
+		if base.MetaData == nil {
+			base.MetaData = &kusttypes.ObjectMeta{}
+		}
+		if base.MetaData.Annotations == nil {
+			base.MetaData.Annotations = map[string]string{}
+		}
+		base.MetaData.Annotations[annotationKeySourceRepo] = src.Repo
+		base.MetaData.Annotations[annotationKeySourcePath] = src.Path
+		base.MetaData.Annotations[annotationKeySourceRevision] = src.TargetRevision
+		base.MetaData.Annotations[annotationKeyHelmManifestPath] = src.ApplicationSpecifier.HelmManifestPath
+		if err := appsfs.WriteYamls(baseKustomizationPath, base); err != nil {
+			return fmt.Errorf("failed to write app base: %w", err)
+		}
+	}
+
+	confs, err := application.ReadConfigs(configfs, configPath)
+	if err != nil {
+		return err
+	}
+
+	envOf := func(conf *application.Config) string {
+		if env := envs[application.TargetClusterName(conf)]; env != "" {
+			return env
+		}
+		return "default"
+	}
+
+	render := func(conf *application.Config, env string) ([]byte, error) {
+		log.G(ctx).WithFields(log.Fields{
+			"app":       conf.AppName,
+			"cluster":   application.TargetClusterName(conf),
+			"env":       env,
+			"namespace": conf.DestNamespace,
+		}).Debug("rendering application manifests")
+
+		manifests, err := renderAppManifest(ctx, src, conf.AppName, conf.DestNamespace, env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render application manifests of env '%s': %w", env, err)
+		}
+		return manifests, nil
+	}
+
+	primaryEnv := envOf(&confs[0])
+	manifests, err := render(&confs[0], primaryEnv)
+	if err != nil {
+		return err
+	}
+
+	if err = billyUtils.WriteFile(appsfs, appsfs.Join(basePath, "manifest.yaml"), manifests, 0666); err != nil {
+		return fmt.Errorf("failed to write app manifests: %w", err)
+	}
+
+	for i := range confs[1:] {
+		conf := &confs[i+1]
+		targetPath := appsfs.Join(overlayPath, application.TargetsDir, conf.DestClusterName)
+
+		var targetManifests []byte
+		if env := envOf(conf); env != primaryEnv {
+			if targetManifests, err = render(conf, env); err != nil {
+				return err
+			}
+		}
+
+		if err = application.WriteTargetManifest(appsfs, targetPath, targetManifests); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateAppNamespaces applies the namespace of each target: the namespace of the project overlay is the namespace of
+// the primary target, the overlays of the other targets are written with their namespace by WriteTargets. The namespace
+// manifest is written to the cluster resources of the cluster of every target that moves to a new namespace
+func updateAppNamespaces(ctx context.Context, repofs fs.FS, overlayPath string, confs []application.Config, targets []types.ApplicationTarget) error {
+	previous := make(map[string]string, len(confs))
+	for i := range confs {
+		previous[application.TargetClusterName(&confs[i])] = confs[i].DestNamespace
+	}
+
+	for i, target := range targets {
+		if target.Namespace == "" {
+			continue
+		}
+
+		if i == 0 && target.Namespace != confs[0].DestNamespace {
+			if err := updateAppOverlayNamespace(repofs, overlayPath, target.Namespace); err != nil {
+				return err
+			}
+		}
+
+		if namespace, ok := previous[target.Cluster]; ok && namespace == target.Namespace {
+			continue
+		}
+
+		if err := application.CreateNamespaceManifest(repofs, target.Server, target.Namespace); err != nil {
+			log.G(ctx).WithError(err).WithField("cluster", target.Cluster).Warn("failed to create namespace manifest")
+		}
+	}
+
+	return nil
+}
+
+// updateAppOverlayNamespace sets the namespace of the overlay, the same way the app was created
+func updateAppOverlayNamespace(repofs fs.FS, overlayPath, namespace string) error {
+	overlayKustomizationPath := repofs.Join(overlayPath, "kustomization.yaml")
+	if !repofs.ExistsOrDie(overlayKustomizationPath) {
+		return nil
+	}
+
+	overlay := &kusttypes.Kustomization{}
+	if err := repofs.ReadYamls(overlayKustomizationPath, overlay); err != nil {
+		return fmt.Errorf("failed to read app overlay: %w", err)
+	}
+
+	overlay.Namespace = namespace
+	if namespace == "default" {
+		overlay.Namespace = ""
+	}
+
+	if err := repofs.WriteYamls(overlayKustomizationPath, overlay); err != nil {
+		return fmt.Errorf("failed to write app overlay: %w", err)
+	}
+
+	return nil
+}
+
+// RunAppGet gets an application from the native GitOps repository structure
+func (n *NativeRepoTarget) RunAppGet(ctx context.Context, appName string) (*types.Application, error) {
+	_, repofs, err := getRepo(ctx, n.tenantRepoCloneOpts)
+	if err != nil {
+		log.G(ctx).Errorf("failed to prepare repo: %v", err)
+		return nil, err
+	}
+
+	appPath := n.appConfigDir(repofs, appName)
+
+	log.G(ctx).WithFields(log.Fields{
+		"repo": n.tenantRepoCloneOpts.Repo,
+		"path": appPath,
+	}).Debug("getting application detail")
+
+	confs, err := getConfigsFromPath(repofs, appPath)
+	if err != nil {
+		log.G(ctx).Errorf("failed to get application detail: %v", err)
+		return nil, err
+	}
+	conf := confs[0]
+
+	overlayPath := repofs.Join(store.Default.AppsDir, appName, store.Default.OverlaysDir, n.project)
+	ingress, err := application.ReadIngress(repofs, overlayPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var ingresses []types.IngressConfig
+	if ingress != nil {
+		ingresses = application.IngressConfigs(ingress)
+	}
+
+	secrets, err := application.ReadExternalSecrets(repofs, overlayPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var security types.SecurityConfig
+	if len(secrets) > 0 {
+		security.ExternalSecret = application.ExternalSecretConfigOf(secrets)
+	}
+
+	return &types.Application{
+		ApplicationSource: types.ApplicationSourceRequest{
+			Repo:           conf.SrcRepoURL,
+			Path:           conf.SrcPath,
+			TargetRevision: conf.SrcTargetRevision,
+		},
+		ApplicationInstantiation: types.ApplicationInstantiation{
+			ApplicationName: conf.UserGivenName,
+			TenantName:      n.project,
+			AppCode:         conf.Annotations["squidflow.github.io/appcode"],
+			Description:     conf.Annotations["squidflow.github.io/description"],
+			Ingress:         ingresses,
+			Security:        security,
+		},
+		ApplicationTarget: getAppTargets(n.project, confs),
+		ApplicationRuntime: types.ApplicationRuntime{
+			GitInfo:         []types.GitInfo{},
+			ResourceMetrics: types.ResourceMetricsInfo{},
+			Status:          "unknown",
+			Health:          "unknown",
+			SyncStatus:      "unknown",
+			ArgoCDUrl:       "",
+			CreatedAt:       time.Now(),
+			CreatedBy:       "",
+			LastUpdatedAt:   time.Now(),
+			LastUpdatedBy:   "",
+		},
+	}, nil
+}
+
+// RunProjectCreate creates a project in the native GitOps repository structure
+func (n *NativeRepoTarget) RunProjectCreate(ctx context.Context, opts *types.ProjectCreateOptions) error {
+	var (
+		err                   error
+		installationNamespace string
+	)
+
+	r, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
+	if err != nil {
+		return err
+	}
+
+	installationNamespace, err = getInstallationNamespace(repofs)
+	if err != nil {
+		return fmt.Errorf(util.Doc("Bootstrap folder not found, please execute `<BIN> repo bootstrap --installation-path %s` command"), repofs.Root())
+	}
+
+	projectExists := repofs.ExistsOrDie(repofs.Join(store.Default.ProjectsDir, opts.ProjectName+".yaml"))
+	if projectExists {
+		return apierr.Conflict("project '%s' already exists", opts.ProjectName)
+	}
+
+	if opts.DestKubeServer == "" {
+		opts.DestKubeServer = store.Default.DestServer
+		if opts.DestKubeContext != "" {
+			opts.DestKubeServer, err = util.KubeContextToServer(opts.DestKubeContext)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	windows, err := readFreezeWindows(repofs)
+	if err != nil {
+		return err
+	}
+
+	projectYAML, appsetYAML, clusterResReadme, clusterResConf, err := generateProjectManifests(&types.GenerateProjectOptions{
+		Name:               opts.ProjectName,
+		Namespace:          installationNamespace,
+		ProjectGitopsRepo:  opts.ProjectGitopsRepo,
+		RepoURL:            n.metaRepoCloneOpts.URL(),
+		Revision:           n.metaRepoCloneOpts.Revision(),
+		InstallationPath:   n.metaRepoCloneOpts.Path(),
+		DefaultDestServer:  opts.DestKubeServer,
+		DefaultDestContext: opts.DestKubeContext,
+		Labels:             opts.Labels,
+		Annotations:        opts.Annotations,
+		SyncWindows:        freeze.SyncWindows(windows, opts.ProjectName, opts.Clusters, time.Now()),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate project resources: %w", err)
+	}
+
+	if opts.DryRun {
+		log.G(ctx).Printf("%s", util.JoinManifests(projectYAML, appsetYAML))
+		return nil
+	}
+
+	bulkWrites := []fs.BulkWriteRequest{}
+
+	if opts.DestKubeContext != "" {
+		log.G(ctx).Infof("adding cluster: %s", opts.DestKubeContext)
+		if err = opts.AddCmd.Execute(ctx, opts.DestKubeContext); err != nil {
+			return fmt.Errorf("failed to add new cluster credentials: %w", err)
+		}
+
+		if !repofs.ExistsOrDie(repofs.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir, opts.DestKubeContext)) {
+			bulkWrites = append(bulkWrites, fs.BulkWriteRequest{
+				Filename: repofs.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir, opts.DestKubeContext+".json"),
+				Data:     clusterResConf,
+				ErrMsg:   "failed to write cluster config",
+			})
+
+			bulkWrites = append(bulkWrites, fs.BulkWriteRequest{
+				Filename: repofs.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir, opts.DestKubeContext, "README.md"),
+				Data:     clusterResReadme,
+				ErrMsg:   "failed to write cluster resources readme",
+			})
+		}
+	}
+
+	bulkWrites = append(bulkWrites, fs.BulkWriteRequest{
+		Filename: repofs.Join(store.Default.ProjectsDir, opts.ProjectName+".yaml"),
+		Data:     util.JoinManifests(projectYAML, appsetYAML),
+		ErrMsg:   "failed to create project file",
+	})
+
+	if err = fs.BulkWrite(repofs, bulkWrites...); err != nil {
+		return err
+	}
+
+	log.G(ctx).Infof("pushing new project manifest to repo")
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: fmt.Sprintf("chore: added project '%s'", opts.ProjectName)}); err != nil {
+		return err
+	}
+
+	log.G(ctx).Infof("project created: '%s'", opts.ProjectName)
+
+	return nil
+}
+
+func generateProjectManifests(o *types.GenerateProjectOptions) (projectYAML, appSetYAML, clusterResReadme, clusterResConfig []byte, err error) {
+	project := &argocdv1alpha1.AppProject{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       argocdv1alpha1.AppProjectSchemaGroupVersionKind.Kind,
+			APIVersion: argocdv1alpha1.AppProjectSchemaGroupVersionKind.GroupVersion().String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      o.Name,
+			Namespace: o.Namespace,
+			Annotations: map[string]string{
+				"argocd.argoproj.io/sync-wave":     "-2",
+				"argocd.argoproj.io/sync-options":  "PruneLast=true",
+				store.Default.DestServerAnnotation: o.DefaultDestServer,
+			},
+		},
+		Spec: argocdv1alpha1.AppProjectSpec{
+			SourceRepos: []string{"*"},
+			Destinations: []argocdv1alpha1.ApplicationDestination{
+				{
+					Server:    "*",
+					Namespace: "*",
+				},
+			},
+			Description: fmt.Sprintf("%s project", o.Name),
+			ClusterResourceWhitelist: []metav1.GroupKind{
+				{
+					Group: "*",
+					Kind:  "*",
+				},
+			},
+			NamespaceResourceWhitelist: []metav1.GroupKind{
+				{
+					Group: "*",
+					Kind:  "*",
+				},
+			},
+			SyncWindows: o.SyncWindows,
+		},
+	}
+	if projectYAML, err = yaml.Marshal(project); err != nil {
+		err = fmt.Errorf("failed to marshal AppProject: %w", err)
+		return
+	}
+
+	appsetRepoURL := o.ProjectGitopsRepo
+	if appsetRepoURL == "" {
+		appsetRepoURL = o.RepoURL
+	}
+
+	// the tenant label lets the applications of the tenant be listed with a single label selector
+	appLabels := getDefaultAppLabels(o.Labels)
+	appLabels[store.Default.LabelKeyTenant] = o.Name
+
+	appSetYAML, err = createAppSet(&createAppSetOptions{
+		name:                        o.Name,
+		namespace:                   o.Namespace,
+		appName:                     fmt.Sprintf("%s-{{ userGivenName }}", o.Name),
+		appNamespace:                o.Namespace,
+		appProject:                  o.Name,
+		repoURL:                     "{{ srcRepoURL }}",
+		srcPath:                     "{{ srcPath }}",
+		revision:                    "{{ srcTargetRevision }}",
+		destServer:                  "{{ destServer }}",
+		destNamespace:               "{{ destNamespace }}",
+		prune:                       true,
+		preserveResourcesOnDeletion: false,
+		appLabels:                   appLabels,
+		appAnnotations:              o.Annotations,
+		generators: []argocdv1alpha1.ApplicationSetGenerator{
+			{
+				Git: &argocdv1alpha1.GitGenerator{
+					RepoURL:  appsetRepoURL, // use tenant's repo url
+					Revision: o.Revision,
+					Files: []argocdv1alpha1.GitFileGeneratorItem{
+						{
+							Path: path.Join(o.InstallationPath, store.Default.AppsDir, "**", o.Name, "config.json"),
+						},
+					},
+					RequeueAfterSeconds: &DefaultApplicationSetGeneratorInterval,
+				},
+			},
+			{
+				Git: &argocdv1alpha1.GitGenerator{
+					RepoURL:  appsetRepoURL, // use tenant's repo url
+					Revision: o.Revision,
+					Files: []argocdv1alpha1.GitFileGeneratorItem{
+						{
+							Path: path.Join(o.InstallationPath, store.Default.AppsDir, "**", o.Name, "config_dir.json"),
+						},
+					},
 					RequeueAfterSeconds: &DefaultApplicationSetGeneratorInterval,
 					Template: argocdv1alpha1.ApplicationSetTemplate{
 						Spec: argocdv1alpha1.ApplicationSpec{
@@ -571,317 +1402,787 @@ func generateProjectManifests(o *types.GenerateProjectOptions) (projectYAML, app
 		},
 	})
 	if err != nil {
-		err = fmt.Errorf("failed to marshal ApplicationSet: %w", err)
-		return
+		err = fmt.Errorf("failed to marshal ApplicationSet: %w", err)
+		return
+	}
+
+	clusterResReadme = []byte(strings.ReplaceAll(string(clusterResReadmeTpl), "{CLUSTER}", o.DefaultDestServer))
+
+	clusterResConfig, err = json.Marshal(&application.ClusterResConfig{Name: o.DefaultDestContext, Server: o.DefaultDestServer})
+	if err != nil {
+		err = fmt.Errorf("failed to create cluster resources config: %w", err)
+		return
+	}
+
+	return
+}
+
+type createAppSetOptions struct {
+	name                        string
+	namespace                   string
+	appName                     string
+	appNamespace                string
+	appProject                  string
+	repoURL                     string
+	revision                    string
+	srcPath                     string
+	destServer                  string
+	destNamespace               string
+	prune                       bool
+	preserveResourcesOnDeletion bool
+	appLabels                   map[string]string
+	appAnnotations              map[string]string
+	generators                  []argocdv1alpha1.ApplicationSetGenerator
+}
+
+func createAppSet(o *createAppSetOptions) ([]byte, error) {
+	if o.destServer == "" {
+		o.destServer = store.Default.DestServer
+	}
+
+	if o.appProject == "" {
+		o.appProject = "default"
+	}
+
+	if o.appLabels == nil {
+		// default labels
+		o.appLabels = map[string]string{
+			store.Default.LabelKeyAppManagedBy: store.Default.LabelValueManagedBy,
+			"app.kubernetes.io/name":           o.appName,
+		}
+	}
+
+	appSet := &argocdv1alpha1.ApplicationSet{
+		TypeMeta: metav1.TypeMeta{
+			// do not use argocdv1alpha1.ApplicationSetSchemaGroupVersionKind.Kind because
+			// it is "Applicationset" - noticed the lowercase "s"
+			Kind:       "ApplicationSet",
+			APIVersion: argocdv1alpha1.ApplicationSetSchemaGroupVersionKind.GroupVersion().String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      o.name,
+			Namespace: o.namespace,
+			Annotations: map[string]string{
+				"argocd.argoproj.io/sync-wave": "0",
+			},
+		},
+		Spec: argocdv1alpha1.ApplicationSetSpec{
+			Generators: o.generators,
+			Template: argocdv1alpha1.ApplicationSetTemplate{
+				ApplicationSetTemplateMeta: argocdv1alpha1.ApplicationSetTemplateMeta{
+					Namespace:   o.appNamespace,
+					Name:        o.appName,
+					Labels:      o.appLabels,
+					Annotations: o.appAnnotations,
+				},
+				Spec: argocdv1alpha1.ApplicationSpec{
+					Project: o.appProject,
+					Source: &argocdv1alpha1.ApplicationSource{
+						RepoURL:        o.repoURL,
+						Path:           o.srcPath,
+						TargetRevision: o.revision,
+					},
+					Destination: argocdv1alpha1.ApplicationDestination{
+						Server:    o.destServer,
+						Namespace: o.destNamespace,
+					},
+					SyncPolicy: &argocdv1alpha1.SyncPolicy{
+						Automated: &argocdv1alpha1.SyncPolicyAutomated{
+							SelfHeal:   true,
+							Prune:      o.prune,
+							AllowEmpty: true,
+						},
+					},
+					IgnoreDifferences: []argocdv1alpha1.ResourceIgnoreDifferences{
+						{
+							Group: "argoproj.io",
+							Kind:  "Application",
+							JSONPointers: []string{
+								"/status",
+							},
+						},
+					},
+				},
+			},
+			SyncPolicy: &argocdv1alpha1.ApplicationSetSyncPolicy{
+				PreserveResourcesOnDeletion: o.preserveResourcesOnDeletion,
+			},
+		},
+	}
+
+	return yaml.Marshal(appSet)
+}
+
+func (n *NativeRepoTarget) SecretStoreList(ctx context.Context) ([]esv1beta1.SecretStore, error) {
+	_, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := billyUtils.Glob(repofs, repofs.Join(
+		store.Default.BootsrtrapDir,
+		store.Default.ClusterResourcesDir,
+		store.Default.ClusterContextName,
+		"ss-*.yaml",
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var secretStores []esv1beta1.SecretStore
+
+	for _, file := range matches {
+		log.G(ctx).WithField("file", file).Debug("Found secret store")
+
+		secretStore := &esv1beta1.SecretStore{}
+		if err := repofs.ReadYamls(file, secretStore); err != nil {
+			log.G(ctx).Warnf("Failed to read secret store from %s: %v", file, err)
+			continue
+		}
+
+		if secretStore.Kind != "SecretStore" {
+			log.G(ctx).Warnf("Skip %s: not a SecretStore", file)
+			continue
+		}
+
+		log.G(ctx).WithFields(log.Fields{
+			"id":       secretStore.Annotations["squidflow.github.io/id"],
+			"name":     secretStore.Name,
+			"provider": secretstore.Provider(secretStore.Spec.Provider),
+		}).Debug("Found secret store")
+
+		secretStores = append(secretStores, *secretStore)
+	}
+
+	return secretStores, nil
+}
+
+// WriteSecretStore2Repo the external secret to gitOps repo
+func (n *NativeRepoTarget) SecretStoreCreate(ctx context.Context, ss *esv1beta1.SecretStore, force bool) error {
+	log.G(ctx).WithFields(log.Fields{
+		"name":      ss.Name,
+		"id":        ss.Annotations["squidflow.github.io/id"],
+		"cloneOpts": n.metaRepoCloneOpts,
+		"force":     force,
+	}).Debug("clone options")
+
+	r, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
+	if err != nil {
+		log.G(ctx).WithError(err).Error("failed to prepare repo")
+		return err
+	}
+
+	ssYaml, err := yaml.Marshal(ss)
+	if err != nil {
+		log.G(ctx).WithError(err).Error("failed to marshal secret store")
+		return err
+	}
+
+	ssExists := repofs.ExistsOrDie(
+		repofs.Join(
+			store.Default.BootsrtrapDir,
+			store.Default.ClusterResourcesDir,
+			store.Default.ClusterContextName,
+			fmt.Sprintf("ss-%s.yaml", ss.Annotations["squidflow.github.io/id"]),
+		),
+	)
+	if ssExists && !force {
+		return apierr.Conflict("secret store '%s' already exists", ss.GetName())
+	}
+
+	bulkWrites := []fs.BulkWriteRequest{}
+	bulkWrites = append(bulkWrites, fs.BulkWriteRequest{
+		Filename: repofs.Join(
+			store.Default.BootsrtrapDir,
+			store.Default.ClusterResourcesDir,
+			store.Default.ClusterContextName,
+			fmt.Sprintf("ss-%s.yaml", ss.Annotations["squidflow.github.io/id"]),
+		),
+		Data:   util.JoinManifests(ssYaml),
+		ErrMsg: "failed to create secret store file",
+	})
+
+	if err = fs.BulkWrite(repofs, bulkWrites...); err != nil {
+		return err
+	}
+
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: fmt.Sprintf("chore: added secret store '%s'", ss.GetName())}); err != nil {
+		log.G(ctx).WithError(err).Error("failed to push secret store to repo")
+		return err
+	}
+
+	log.G(ctx).Infof("secret store created: '%s'", ss.GetName())
+
+	return nil
+}
+
+func (n *NativeRepoTarget) SecretStoreUpdate(ctx context.Context, id string, req *types.SecretStoreUpdateRequest) (*esv1beta1.SecretStore, error) {
+	_, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare repo: %w", err)
 	}
 
-	clusterResReadme = []byte(strings.ReplaceAll(string(clusterResReadmeTpl), "{CLUSTER}", o.DefaultDestServer))
+	secretStorePath := repofs.Join(
+		store.Default.BootsrtrapDir,
+		store.Default.ClusterResourcesDir,
+		store.Default.ClusterContextName,
+		fmt.Sprintf("ss-%s.yaml", id),
+	)
 
-	clusterResConfig, err = json.Marshal(&application.ClusterResConfig{Name: o.DefaultDestContext, Server: o.DefaultDestServer})
+	if !repofs.ExistsOrDie(secretStorePath) {
+		return nil, apierr.NotFound("secret store '%s' not found", id)
+	}
+
+	secretStore := &esv1beta1.SecretStore{}
+	if err := repofs.ReadYamls(secretStorePath, secretStore); err != nil {
+		return nil, fmt.Errorf("failed to read secret store: %w", err)
+	}
+
+	// the external secrets of the applications reference the secret store by name
+	if req.Name != "" && req.Name != secretStore.Name {
+		return nil, apierr.Validation("secret store '%s' cannot be renamed to '%s', the external secrets of the applications reference it by name", secretStore.Name, req.Name)
+	}
+
+	// Update fields
+	if req.Provider != nil {
+		secretStore.Spec.Provider = req.Provider
+	}
+	if req.Path != "" || req.Auth != nil || req.Server != "" || req.Version != "" {
+		vault := secretStore.Spec.Provider
+		if vault == nil || vault.Vault == nil {
+			return nil, apierr.Validation("path, auth, server and version only update a secret store with the vault provider")
+		}
+		if req.Path != "" {
+			vault.Vault.Path = &req.Path
+		}
+		if req.Auth != nil {
+			vault.Vault.Auth = *req.Auth
+		}
+		if req.Server != "" {
+			vault.Vault.Server = req.Server
+		}
+		if req.Version != "" {
+			vault.Vault.Version = req.Version
+		}
+	}
+
+	if err := secretstore.Validate(secretStore); err != nil {
+		return nil, err
+	}
+
+	secretStore.Annotations["squidflow.github.io/updated-at"] = time.Now().Format(time.RFC3339)
+
+	if err := n.SecretStoreCreate(ctx, secretStore, true); err != nil {
+		return nil, fmt.Errorf("failed to write secret store to repo: %w", err)
+	}
+
+	return secretStore, nil
+}
+
+func (n *NativeRepoTarget) SecretStoreDelete(ctx context.Context, secretStoreID string) error {
+	r, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
 	if err != nil {
-		err = fmt.Errorf("failed to create cluster resources config: %w", err)
-		return
+		return err
 	}
 
-	return
+	secretStorePath := repofs.Join(
+		store.Default.BootsrtrapDir,
+		store.Default.ClusterResourcesDir,
+		store.Default.ClusterContextName,
+		fmt.Sprintf("ss-%s.yaml", secretStoreID),
+	)
+
+	exists := repofs.ExistsOrDie(secretStorePath)
+	if !exists {
+		log.G(ctx).Infof("secret store %s not found, considering it as already deleted", secretStoreID)
+		return nil
+	}
+
+	if err := repofs.Remove(secretStorePath); err != nil {
+		return fmt.Errorf("failed to delete secret store file: %w", err)
+	}
+
+	if _, err = r.Persist(ctx, &git.PushOptions{
+		CommitMsg: fmt.Sprintf("chore: deleted secret store '%s'", secretStoreID),
+	}); err != nil {
+		return fmt.Errorf("failed to push secret store deletion to repo: %w", err)
+	}
+
+	log.G(ctx).Infof("secret store deleted: '%s'", secretStoreID)
+	return nil
 }
 
-type createAppSetOptions struct {
-	name                        string
-	namespace                   string
-	appName                     string
-	appNamespace                string
-	appProject                  string
-	repoURL                     string
-	revision                    string
-	srcPath                     string
-	destServer                  string
-	destNamespace               string
-	prune                       bool
-	preserveResourcesOnDeletion bool
-	appLabels                   map[string]string
-	appAnnotations              map[string]string
-	generators                  []argocdv1alpha1.ApplicationSetGenerator
+func (n *NativeRepoTarget) SecretStoreGet(ctx context.Context, id string) (*esv1beta1.SecretStore, error) {
+	_, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
+	if err != nil {
+		return nil, err
+	}
+
+	secretStorePath := repofs.Join(
+		store.Default.BootsrtrapDir,
+		store.Default.ClusterResourcesDir,
+		store.Default.ClusterContextName,
+		fmt.Sprintf("ss-%s.yaml", id),
+	)
+
+	if !repofs.ExistsOrDie(secretStorePath) {
+		return nil, apierr.NotFound("secret store '%s' not found", id)
+	}
+
+	secretStore := &esv1beta1.SecretStore{}
+	if err := repofs.ReadYamls(secretStorePath, secretStore); err != nil {
+		return nil, fmt.Errorf("failed to read secret store %s: %w", id, err)
+	}
+
+	if secretStore.Kind != "SecretStore" {
+		return nil, fmt.Errorf("invalid secret store kind: %s", secretStore.Kind)
+	}
+
+	return secretStore, nil
 }
 
-func createAppSet(o *createAppSetOptions) ([]byte, error) {
-	if o.destServer == "" {
-		o.destServer = store.Default.DestServer
+func appCodePath(repofs fs.FS, name string) string {
+	return repofs.Join(store.Default.AppCodesDir, name+".yaml")
+}
+
+// AppCodeCreate registers a new appcode in the meta repo
+func (n *NativeRepoTarget) AppCodeCreate(ctx context.Context, code *types.AppCode) error {
+	r, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
+	if err != nil {
+		return err
+	}
+
+	filename := appCodePath(repofs, code.Name)
+	if repofs.ExistsOrDie(filename) {
+		return apierr.Conflict("appcode '%s' already exists", code.Name)
+	}
+
+	if err := repofs.WriteYamls(filename, code); err != nil {
+		return fmt.Errorf("failed to write appcode '%s': %w", code.Name, err)
+	}
+
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: fmt.Sprintf("chore: added appcode '%s'", code.Name)}); err != nil {
+		return err
+	}
+
+	log.G(ctx).WithField("appcode", code.Name).Info("appcode created")
+
+	return nil
+}
+
+func (n *NativeRepoTarget) AppCodeGet(ctx context.Context, name string) (*types.AppCode, error) {
+	_, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return readAppCode(repofs, name)
+}
+
+func (n *NativeRepoTarget) AppCodeList(ctx context.Context) ([]types.AppCode, error) {
+	_, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := billyUtils.Glob(repofs, repofs.Join(store.Default.AppCodesDir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]types.AppCode, 0, len(matches))
+	for _, file := range matches {
+		code := types.AppCode{}
+		if err := repofs.ReadYamls(file, &code); err != nil {
+			log.G(ctx).WithError(err).WithField("file", file).Warn("failed to read appcode")
+			continue
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// AppCodeUpdate changes the fields set in the request, an appcode is deactivated by setting Active to false
+func (n *NativeRepoTarget) AppCodeUpdate(ctx context.Context, name string, req *types.AppCodeUpdateRequest) (*types.AppCode, error) {
+	r, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := readAppCode(repofs, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Description != nil {
+		code.Description = *req.Description
+	}
+	if req.OwnerTeam != nil {
+		code.OwnerTeam = *req.OwnerTeam
+	}
+	if req.CostCenter != nil {
+		code.CostCenter = *req.CostCenter
+	}
+	if req.Tenants != nil {
+		code.Tenants = *req.Tenants
+	}
+	if req.Active != nil {
+		code.Active = *req.Active
+	}
+
+	now := time.Now().UTC()
+	code.UpdatedBy = req.UpdatedBy
+	code.UpdatedAt = &now
+
+	if err := repofs.WriteYamls(appCodePath(repofs, name), code); err != nil {
+		return nil, fmt.Errorf("failed to write appcode '%s': %w", name, err)
+	}
+
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: fmt.Sprintf("chore: updated appcode '%s'", name)}); err != nil {
+		return nil, err
+	}
+
+	log.G(ctx).WithFields(log.Fields{
+		"appcode": name,
+		"active":  code.Active,
+	}).Info("appcode updated")
+
+	return code, nil
+}
+
+func readAppCode(repofs fs.FS, name string) (*types.AppCode, error) {
+	filename := appCodePath(repofs, name)
+	if !repofs.ExistsOrDie(filename) {
+		return nil, apierr.NotFound("appcode '%s' not found", name)
+	}
+
+	code := &types.AppCode{}
+	if err := repofs.ReadYamls(filename, code); err != nil {
+		return nil, fmt.Errorf("failed to read appcode '%s': %w", name, err)
+	}
+
+	return code, nil
+}
+
+func changeRequestPath(repofs fs.FS, tenant, id string) string {
+	return repofs.Join(store.Default.ChangeRequestsDir, tenant, id+".yaml")
+}
+
+// ChangeRequestCreate stores a new change request of the tenant in the meta repo
+func (n *NativeRepoTarget) ChangeRequestCreate(ctx context.Context, cr *types.ChangeRequest) error {
+	r, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
+	if err != nil {
+		return err
 	}
 
-	if o.appProject == "" {
-		o.appProject = "default"
+	filename := changeRequestPath(repofs, cr.Tenant, cr.ID)
+	if repofs.ExistsOrDie(filename) {
+		return apierr.Conflict("change request '%s' already exists", cr.ID)
 	}
 
-	if o.appLabels == nil {
-		// default labels
-		o.appLabels = map[string]string{
-			store.Default.LabelKeyAppManagedBy: store.Default.LabelValueManagedBy,
-			"app.kubernetes.io/name":           o.appName,
-		}
+	if err := repofs.WriteYamls(filename, cr); err != nil {
+		return fmt.Errorf("failed to write change request '%s': %w", cr.ID, err)
 	}
 
-	appSet := &argocdv1alpha1.ApplicationSet{
-		TypeMeta: metav1.TypeMeta{
-			// do not use argocdv1alpha1.ApplicationSetSchemaGroupVersionKind.Kind because
-			// it is "Applicationset" - noticed the lowercase "s"
-			Kind:       "ApplicationSet",
-			APIVersion: argocdv1alpha1.ApplicationSetSchemaGroupVersionKind.GroupVersion().String(),
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      o.name,
-			Namespace: o.namespace,
-			Annotations: map[string]string{
-				"argocd.argoproj.io/sync-wave": "0",
-			},
-		},
-		Spec: argocdv1alpha1.ApplicationSetSpec{
-			Generators: o.generators,
-			Template: argocdv1alpha1.ApplicationSetTemplate{
-				ApplicationSetTemplateMeta: argocdv1alpha1.ApplicationSetTemplateMeta{
-					Namespace:   o.appNamespace,
-					Name:        o.appName,
-					Labels:      o.appLabels,
-					Annotations: o.appAnnotations,
-				},
-				Spec: argocdv1alpha1.ApplicationSpec{
-					Project: o.appProject,
-					Source: &argocdv1alpha1.ApplicationSource{
-						RepoURL:        o.repoURL,
-						Path:           o.srcPath,
-						TargetRevision: o.revision,
-					},
-					Destination: argocdv1alpha1.ApplicationDestination{
-						Server:    o.destServer,
-						Namespace: o.destNamespace,
-					},
-					SyncPolicy: &argocdv1alpha1.SyncPolicy{
-						Automated: &argocdv1alpha1.SyncPolicyAutomated{
-							SelfHeal:   true,
-							Prune:      o.prune,
-							AllowEmpty: true,
-						},
-					},
-					IgnoreDifferences: []argocdv1alpha1.ResourceIgnoreDifferences{
-						{
-							Group: "argoproj.io",
-							Kind:  "Application",
-							JSONPointers: []string{
-								"/status",
-							},
-						},
-					},
-				},
-			},
-			SyncPolicy: &argocdv1alpha1.ApplicationSetSyncPolicy{
-				PreserveResourcesOnDeletion: o.preserveResourcesOnDeletion,
-			},
-		},
+	commitMsg := fmt.Sprintf("chore: added change request '%s' to %s app '%s' on project '%s'", cr.ID, cr.Operation, cr.Application, cr.Tenant)
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: commitMsg, Direct: true}); err != nil {
+		return err
 	}
 
-	return yaml.Marshal(appSet)
+	log.G(ctx).WithFields(log.Fields{
+		"changeRequest": cr.ID,
+		"tenant":        cr.Tenant,
+		"operation":     cr.Operation,
+		"application":   cr.Application,
+	}).Info("change request created")
+
+	return nil
 }
 
-func (n *NativeRepoTarget) SecretStoreList(ctx context.Context) ([]esv1beta1.SecretStore, error) {
+func (n *NativeRepoTarget) ChangeRequestGet(ctx context.Context, tenant, id string) (*types.ChangeRequest, error) {
 	_, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
 	if err != nil {
 		return nil, err
 	}
 
-	matches, err := billyUtils.Glob(repofs, repofs.Join(
-		store.Default.BootsrtrapDir,
-		store.Default.ClusterResourcesDir,
-		store.Default.ClusterContextName,
-		"ss-*.yaml",
-	))
+	return readChangeRequest(repofs, tenant, id)
+}
+
+// ChangeRequestList returns the change requests of the tenant
+func (n *NativeRepoTarget) ChangeRequestList(ctx context.Context, tenant string) ([]types.ChangeRequest, error) {
+	_, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
 	if err != nil {
 		return nil, err
 	}
 
-	var secretStores []esv1beta1.SecretStore
+	matches, err := billyUtils.Glob(repofs, repofs.Join(store.Default.ChangeRequestsDir, tenant, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
 
+	crs := make([]types.ChangeRequest, 0, len(matches))
 	for _, file := range matches {
-		log.G().WithField("file", file).Debug("Found secret store")
-
-		secretStore := &esv1beta1.SecretStore{}
-		if err := repofs.ReadYamls(file, secretStore); err != nil {
-			log.G().Warnf("Failed to read secret store from %s: %v", file, err)
+		cr := types.ChangeRequest{}
+		if err := repofs.ReadYamls(file, &cr); err != nil {
+			log.G(ctx).WithError(err).WithField("file", file).Warn("failed to read change request")
 			continue
 		}
 
-		if secretStore.Kind != "SecretStore" {
-			log.G().Warnf("Skip %s: not a SecretStore", file)
-			continue
-		}
-
-		log.G().WithFields(log.Fields{
-			"id":       secretStore.Annotations["squidflow.github.io/id"],
-			"name":     secretStore.Name,
-			"provider": "vault",
-		}).Debug("Found secret store")
-
-		secretStores = append(secretStores, *secretStore)
+		crs = append(crs, cr)
 	}
 
-	return secretStores, nil
+	return crs, nil
 }
 
-// WriteSecretStore2Repo the external secret to gitOps repo
-func (n *NativeRepoTarget) SecretStoreCreate(ctx context.Context, ss *esv1beta1.SecretStore, force bool) error {
-	log.G().WithFields(log.Fields{
-		"name":      ss.Name,
-		"id":        ss.Annotations["squidflow.github.io/id"],
-		"cloneOpts": n.metaRepoCloneOpts,
-		"force":     force,
-	}).Debug("clone options")
-
+// ChangeRequestUpdate writes the status, the decisions and the result of an existing change request
+func (n *NativeRepoTarget) ChangeRequestUpdate(ctx context.Context, cr *types.ChangeRequest) error {
 	r, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
 	if err != nil {
-		log.G().WithError(err).Error("failed to prepare repo")
 		return err
 	}
 
-	ssYaml, err := yaml.Marshal(ss)
+	filename := changeRequestPath(repofs, cr.Tenant, cr.ID)
+	if !repofs.ExistsOrDie(filename) {
+		return apierr.NotFound("change request '%s' not found", cr.ID)
+	}
+
+	if err := repofs.WriteYamls(filename, cr); err != nil {
+		return fmt.Errorf("failed to write change request '%s': %w", cr.ID, err)
+	}
+
+	commitMsg := fmt.Sprintf("chore: updated change request '%s' on project '%s' to '%s'", cr.ID, cr.Tenant, cr.Status)
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: commitMsg, Direct: true}); err != nil {
+		return err
+	}
+
+	log.G(ctx).WithFields(log.Fields{
+		"changeRequest": cr.ID,
+		"tenant":        cr.Tenant,
+		"status":        cr.Status,
+	}).Info("change request updated")
+
+	return nil
+}
+
+func readChangeRequest(repofs fs.FS, tenant, id string) (*types.ChangeRequest, error) {
+	filename := changeRequestPath(repofs, tenant, id)
+	if !repofs.ExistsOrDie(filename) {
+		return nil, apierr.NotFound("change request '%s' not found", id)
+	}
+
+	cr := &types.ChangeRequest{}
+	if err := repofs.ReadYamls(filename, cr); err != nil {
+		return nil, fmt.Errorf("failed to read change request '%s': %w", id, err)
+	}
+
+	return cr, nil
+}
+
+func freezeWindowPath(repofs fs.FS, name string) string {
+	return repofs.Join(store.Default.FreezeWindowsDir, name+".yaml")
+}
+
+// FreezeWindowCreate stores a new freeze window in the meta repo, and adds it to the sync windows of the projects
+func (n *NativeRepoTarget) FreezeWindowCreate(ctx context.Context, w *types.FreezeWindow, clusters []types.FreezeCluster) error {
+	r, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
 	if err != nil {
-		log.G().WithError(err).Error("failed to marshal secret store")
 		return err
 	}
 
-	ssExists := repofs.ExistsOrDie(
-		repofs.Join(
-			store.Default.BootsrtrapDir,
-			store.Default.ClusterResourcesDir,
-			store.Default.ClusterContextName,
-			fmt.Sprintf("ss-%s.yaml", ss.Annotations["squidflow.github.io/id"]),
-		),
-	)
-	if ssExists && !force {
-		return fmt.Errorf("secret store '%s' already exists", ss.GetName())
+	filename := freezeWindowPath(repofs, w.Name)
+	if repofs.ExistsOrDie(filename) {
+		return apierr.Conflict("freeze window '%s' already exists", w.Name)
 	}
 
-	bulkWrites := []fs.BulkWriteRequest{}
-	bulkWrites = append(bulkWrites, fs.BulkWriteRequest{
-		Filename: repofs.Join(
-			store.Default.BootsrtrapDir,
-			store.Default.ClusterResourcesDir,
-			store.Default.ClusterContextName,
-			fmt.Sprintf("ss-%s.yaml", ss.Annotations["squidflow.github.io/id"]),
-		),
-		Data:   util.JoinManifests(ssYaml),
-		ErrMsg: "failed to create secret store file",
-	})
+	if err := repofs.WriteYamls(filename, w); err != nil {
+		return fmt.Errorf("failed to write freeze window '%s': %w", w.Name, err)
+	}
 
-	if err = fs.BulkWrite(repofs, bulkWrites...); err != nil {
+	if _, err := writeProjectSyncWindows(repofs, clusters); err != nil {
 		return err
 	}
 
-	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: fmt.Sprintf("chore: added secret store '%s'", ss.GetName())}); err != nil {
-		log.G().WithError(err).Error("failed to push secret store to repo")
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: fmt.Sprintf("chore: added freeze window '%s'", w.Name)}); err != nil {
 		return err
 	}
 
-	log.G().Infof("secret store created: '%s'", ss.GetName())
+	log.G(ctx).WithField("freezeWindow", w.Name).Info("freeze window created")
 
 	return nil
 }
 
-func (n *NativeRepoTarget) SecretStoreUpdate(ctx context.Context, id string, req *types.SecretStoreUpdateRequest) (*esv1beta1.SecretStore, error) {
+func (n *NativeRepoTarget) FreezeWindowGet(ctx context.Context, name string) (*types.FreezeWindow, error) {
 	_, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to prepare repo: %w", err)
+		return nil, err
 	}
 
-	secretStorePath := repofs.Join(
-		store.Default.BootsrtrapDir,
-		store.Default.ClusterResourcesDir,
-		store.Default.ClusterContextName,
-		fmt.Sprintf("ss-%s.yaml", id),
-	)
+	return readFreezeWindow(repofs, name)
+}
 
-	secretStore := &esv1beta1.SecretStore{}
-	if err := repofs.ReadYamls(secretStorePath, secretStore); err != nil {
-		return nil, fmt.Errorf("failed to read secret store: %w", err)
+func (n *NativeRepoTarget) FreezeWindowList(ctx context.Context) ([]types.FreezeWindow, error) {
+	_, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
+	if err != nil {
+		return nil, err
 	}
 
-	// Update fields
-	if req.Name != "" {
-		secretStore.Name = req.Name
-	}
-	if req.Path != "" {
-		secretStore.Spec.Provider.Vault.Path = &req.Path
+	return readFreezeWindows(repofs)
+}
+
+// FreezeWindowUpdate replaces the spec of an existing freeze window, and the sync windows of the projects
+func (n *NativeRepoTarget) FreezeWindowUpdate(ctx context.Context, w *types.FreezeWindow, clusters []types.FreezeCluster) (*types.FreezeWindow, error) {
+	r, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
+	if err != nil {
+		return nil, err
 	}
-	if req.Auth != nil {
-		secretStore.Spec.Provider.Vault.Auth = *req.Auth
+
+	existing, err := readFreezeWindow(repofs, w.Name)
+	if err != nil {
+		return nil, err
 	}
-	if req.Server != "" {
-		secretStore.Spec.Provider.Vault.Server = req.Server
+
+	now := time.Now().UTC()
+	existing.FreezeWindowSpec = w.FreezeWindowSpec
+	existing.UpdatedBy = w.UpdatedBy
+	existing.UpdatedAt = &now
+
+	if err := repofs.WriteYamls(freezeWindowPath(repofs, w.Name), existing); err != nil {
+		return nil, fmt.Errorf("failed to write freeze window '%s': %w", w.Name, err)
 	}
 
-	secretStore.Annotations["squidflow.github.io/updated-at"] = time.Now().Format(time.RFC3339)
+	if _, err := writeProjectSyncWindows(repofs, clusters); err != nil {
+		return nil, err
+	}
 
-	if err := n.SecretStoreCreate(ctx, secretStore, true); err != nil {
-		return nil, fmt.Errorf("failed to write secret store to repo: %w", err)
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: fmt.Sprintf("chore: updated freeze window '%s'", w.Name)}); err != nil {
+		return nil, err
 	}
 
-	return secretStore, nil
+	log.G(ctx).WithField("freezeWindow", w.Name).Info("freeze window updated")
+
+	return existing, nil
 }
 
-func (n *NativeRepoTarget) SecretStoreDelete(ctx context.Context, secretStoreID string) error {
+// FreezeWindowDelete removes a freeze window from the meta repo and from the sync windows of the projects
+func (n *NativeRepoTarget) FreezeWindowDelete(ctx context.Context, name string, clusters []types.FreezeCluster) error {
 	r, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
 	if err != nil {
 		return err
 	}
 
-	secretStorePath := repofs.Join(
-		store.Default.BootsrtrapDir,
-		store.Default.ClusterResourcesDir,
-		store.Default.ClusterContextName,
-		fmt.Sprintf("ss-%s.yaml", secretStoreID),
-	)
+	filename := freezeWindowPath(repofs, name)
+	if !repofs.ExistsOrDie(filename) {
+		return apierr.NotFound("freeze window '%s' not found", name)
+	}
 
-	exists := repofs.ExistsOrDie(secretStorePath)
-	if !exists {
-		log.G().Infof("secret store %s not found, considering it as already deleted", secretStoreID)
-		return nil
+	if err := repofs.Remove(filename); err != nil {
+		return fmt.Errorf("failed to delete freeze window '%s': %w", name, err)
 	}
 
-	if err := repofs.Remove(secretStorePath); err != nil {
-		return fmt.Errorf("failed to delete secret store file: %v", err)
+	if _, err := writeProjectSyncWindows(repofs, clusters); err != nil {
+		return err
 	}
 
-	if _, err = r.Persist(ctx, &git.PushOptions{
-		CommitMsg: fmt.Sprintf("chore: deleted secret store '%s'", secretStoreID),
-	}); err != nil {
-		return fmt.Errorf("failed to push secret store deletion to repo: %v", err)
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: fmt.Sprintf("chore: deleted freeze window '%s'", name)}); err != nil {
+		return err
 	}
 
-	log.G().Infof("secret store deleted: '%s'", secretStoreID)
+	log.G(ctx).WithField("freezeWindow", name).Info("freeze window deleted")
+
 	return nil
 }
 
-func (n *NativeRepoTarget) SecretStoreGet(ctx context.Context, id string) (*esv1beta1.SecretStore, error) {
-	_, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
+func readFreezeWindow(repofs fs.FS, name string) (*types.FreezeWindow, error) {
+	filename := freezeWindowPath(repofs, name)
+	if !repofs.ExistsOrDie(filename) {
+		return nil, apierr.NotFound("freeze window '%s' not found", name)
+	}
+
+	w := &types.FreezeWindow{}
+	if err := repofs.ReadYamls(filename, w); err != nil {
+		return nil, fmt.Errorf("failed to read freeze window '%s': %w", name, err)
+	}
+
+	return w, nil
+}
+
+func readFreezeWindows(repofs fs.FS) ([]types.FreezeWindow, error) {
+	matches, err := billyUtils.Glob(repofs, repofs.Join(store.Default.FreezeWindowsDir, "*.yaml"))
 	if err != nil {
 		return nil, err
 	}
 
-	secretStorePath := repofs.Join(
-		store.Default.BootsrtrapDir,
-		store.Default.ClusterResourcesDir,
-		store.Default.ClusterContextName,
-		fmt.Sprintf("ss-%s.yaml", id),
-	)
+	windows := make([]types.FreezeWindow, 0, len(matches))
+	for _, file := range matches {
+		w := types.FreezeWindow{}
+		if err := repofs.ReadYamls(file, &w); err != nil {
+			return nil, fmt.Errorf("failed to read freeze window '%s': %w", file, err)
+		}
 
-	secretStore := &esv1beta1.SecretStore{}
-	if err := repofs.ReadYamls(secretStorePath, secretStore); err != nil {
-		return nil, fmt.Errorf("failed to read secret store %s: %v", id, err)
+		windows = append(windows, w)
 	}
 
-	if secretStore.Kind != "SecretStore" {
-		return nil, fmt.Errorf("invalid secret store kind: %s", secretStore.Kind)
+	return windows, nil
+}
+
+// FreezeWindowSync writes the freeze windows again as the sync windows of the projects, for the clusters
+// registered or changed since the last change of the freeze windows, and to drop the ranges that have ended.
+// It commits only when a project changed
+func (n *NativeRepoTarget) FreezeWindowSync(ctx context.Context, clusters []types.FreezeCluster) error {
+	r, repofs, err := prepareRepo(ctx, n.metaRepoCloneOpts, "")
+	if err != nil {
+		return err
 	}
 
-	return secretStore, nil
+	changed, err := writeProjectSyncWindows(repofs, clusters)
+	if err != nil || !changed {
+		return err
+	}
+
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: "chore: updated the sync windows of the freeze windows"}); err != nil {
+		return err
+	}
+
+	log.G(ctx).Info("freeze window sync windows updated")
+
+	return nil
+}
+
+// writeProjectSyncWindows writes the freeze windows of the meta repo as the sync windows of every project,
+// it returns true if a project changed
+func writeProjectSyncWindows(repofs fs.FS, clusters []types.FreezeCluster) (bool, error) {
+	windows, err := readFreezeWindows(repofs)
+	if err != nil {
+		return false, err
+	}
+
+	matches, err := billyUtils.Glob(repofs, repofs.Join(store.Default.ProjectsDir, "*.yaml"))
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	now := time.Now()
+	for _, file := range matches {
+		proj, appset, err := getProjectInfoFromFile(repofs, file)
+		if err != nil {
+			return false, fmt.Errorf("failed to read project '%s': %w", file, err)
+		}
+
+		syncWindows := freeze.SyncWindows(windows, proj.Name, clusters, now)
+		if reflect.DeepEqual(proj.Spec.SyncWindows, syncWindows) {
+			continue
+		}
+		proj.Spec.SyncWindows = syncWindows
+		if err := repofs.WriteYamls(file, proj, appset); err != nil {
+			return false, fmt.Errorf("failed to write project '%s': %w", proj.Name, err)
+		}
+		changed = true
+	}
+
+	return changed, nil
 }
 
 var getProjectInfoFromFile = func(repofs fs.FS, name string) (*argocdv1alpha1.AppProject, *argocdv1alpha1.ApplicationSet, error) {
@@ -918,7 +2219,7 @@ func (n *NativeRepoTarget) RunProjectDelete(ctx context.Context, projectName str
 		return fmt.Errorf("failed to delete project '%s': %w", projectName, err)
 	}
 
-	log.G().WithFields(log.Fields{"project": projectName}).Info("deleting project")
+	log.G(ctx).WithFields(log.Fields{"project": projectName}).Info("deleting project")
 	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: fmt.Sprintf("chore: deleted project '%s'", projectName)}); err != nil {
 		return fmt.Errorf("failed to push to repo: %w", err)
 	}
@@ -966,7 +2267,7 @@ func (n *NativeRepoTarget) RunProjectGet(ctx context.Context, projectName string
 
 	projectFile := repofs.Join(store.Default.ProjectsDir, projectName+".yaml")
 	if !repofs.ExistsOrDie(projectFile) {
-		return nil, fmt.Errorf("project %s not found", projectName)
+		return nil, apierr.NotFound("project %s not found", projectName)
 	}
 
 	proj, appset, err := getProjectInfoFromFile(repofs, projectFile)
@@ -2,6 +2,7 @@ package writer
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -9,15 +10,21 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	argocdv1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
+	esmeta "github.com/external-secrets/external-secrets/apis/meta/v1"
 	"github.com/ghodss/yaml"
 	"github.com/go-git/go-billy/v5/memfs"
 	billyUtils "github.com/go-git/go-billy/v5/util"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
 
+	"github.com/squidflow/service/pkg/apierr"
 	"github.com/squidflow/service/pkg/application"
 	"github.com/squidflow/service/pkg/fs"
 	"github.com/squidflow/service/pkg/git"
@@ -607,137 +614,1608 @@ func TestRunAppDelete(t *testing.T) {
 	}
 }
 
+func TestRunAppHistory(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	appDir := filepath.Join(store.Default.AppsDir, "app")
+	configPath := filepath.Join(appDir, store.Default.OverlaysDir, "project", "config.json")
+	tests := map[string]struct {
+		wantErr string
+		getRepo func(*testing.T) (git.Repository, fs.FS, error)
+		want    []types.ApplicationRevision
+	}{
+		"Should fail when app does not exist": {
+			wantErr: "application 'app' not found",
+			getRepo: func(*testing.T) (git.Repository, fs.FS, error) {
+				return nil, fs.Create(memfs.New()), nil
+			},
+		},
+		"Should return the commits with the source revision": {
+			getRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				repofs := fs.Create(memfs.New())
+				_ = repofs.MkdirAll(appDir, 0666)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().History(gomock.Any(), appDir, 10).Return([]git.Commit{
+					{SHA: "sha2", Author: "user", Message: "chore: update app 'app' on project 'project'", Timestamp: ts},
+					{SHA: "sha1", Author: "user", Message: "chore: create app 'app' on project 'project'", Timestamp: ts},
+				}, nil)
+				mockRepo.EXPECT().Files(gomock.Any(), "sha2", appDir).Return(map[string][]byte{
+					configPath: []byte(`{"srcTargetRevision":"v2"}`),
+				}, nil)
+				mockRepo.EXPECT().Files(gomock.Any(), "sha1", appDir).Return(map[string][]byte{
+					configPath: []byte(`[{"srcTargetRevision":"v1"},{"srcTargetRevision":"v1"}]`),
+				}, nil)
+				return mockRepo, repofs, nil
+			},
+			want: []types.ApplicationRevision{
+				{SHA: "sha2", Author: "user", Message: "chore: update app 'app' on project 'project'", Timestamp: ts, SrcTargetRevision: "v2"},
+				{SHA: "sha1", Author: "user", Message: "chore: create app 'app' on project 'project'", Timestamp: ts, SrcTargetRevision: "v1"},
+			},
+		},
+	}
+	origGetRepo := getRepo
+	defer func() { getRepo = origGetRepo }()
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			getRepo = func(_ context.Context, _ *git.CloneOptions) (git.Repository, fs.FS, error) {
+				return tt.getRepo(t)
+			}
+
+			n := &NativeRepoTarget{project: "project", tenantRepoCloneOpts: &git.CloneOptions{}}
+			got, err := n.RunAppHistory(context.Background(), "app", 10)
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRunIngressHostList(t *testing.T) {
+	ingress := func(host string) *networkingv1.Ingress {
+		return &networkingv1.Ingress{
+			TypeMeta:   v1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "Ingress"},
+			ObjectMeta: v1.ObjectMeta{Name: "app"},
+			Spec:       networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{{Host: host}}},
+		}
+	}
+
+	repofs := fs.Create(memfs.New())
+	webOverlay := filepath.Join(store.Default.AppsDir, "web", store.Default.OverlaysDir, "tenant1")
+	_ = billyUtils.WriteFile(repofs, filepath.Join(webOverlay, "config.json"), []byte(`[
+		{"appName": "web", "destServer": "https://sit:6443"},
+		{"appName": "web", "destServer": "https://prd:6443", "destClusterName": "prd"}
+	]`), 0666)
+	_ = repofs.WriteYamls(filepath.Join(webOverlay, application.IngressFile), ingress("web-sit.example.com"))
+	_ = repofs.WriteYamls(filepath.Join(webOverlay, application.TargetsDir, "prd", application.IngressFile), ingress("web.example.com"))
+	apiOverlay := filepath.Join(store.Default.AppsDir, "api", store.Default.OverlaysDir, "tenant2")
+	_ = billyUtils.WriteFile(repofs, filepath.Join(apiOverlay, "config.json"), []byte(`{"appName": "api", "destServer": "https://prd:6443"}`), 0666)
+
+	origGetRepo := getRepo
+	defer func() { getRepo = origGetRepo }()
+	getRepo = func(_ context.Context, _ *git.CloneOptions) (git.Repository, fs.FS, error) {
+		return nil, repofs, nil
+	}
+
+	n := &NativeRepoTarget{project: "tenant1", tenantRepoCloneOpts: &git.CloneOptions{}, metaRepoCloneOpts: &git.CloneOptions{}}
+	got, err := n.RunIngressHostList(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []types.IngressHost{
+		{Host: "web-sit.example.com", Tenant: "tenant1", AppName: "web", Server: "https://sit:6443"},
+		{Host: "web.example.com", Tenant: "tenant1", AppName: "web", Cluster: "prd", Server: "https://prd:6443"},
+	}, got)
+}
+
+func TestRunAppRollback(t *testing.T) {
+	appDir := filepath.Join(store.Default.AppsDir, "app")
+	configPath := filepath.Join(appDir, store.Default.OverlaysDir, "project", "config.json")
+	tests := map[string]struct {
+		wantErr  string
+		getRepo  func(*testing.T) (git.Repository, fs.FS, error)
+		assertFn func(t *testing.T, repofs fs.FS)
+	}{
+		"Should fail when app does not exist": {
+			wantErr: "application 'app' not found",
+			getRepo: func(*testing.T) (git.Repository, fs.FS, error) {
+				return nil, fs.Create(memfs.New()), nil
+			},
+		},
+		"Should fail when the revision did not change the app": {
+			wantErr: "revision 'sha1' is not in the history of application 'app'",
+			getRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				repofs := fs.Create(memfs.New())
+				_ = billyUtils.WriteFile(repofs, configPath, []byte("{}"), 0666)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().History(gomock.Any(), appDir, 0).Return([]git.Commit{{SHA: "sha2"}}, nil)
+				mockRepo.EXPECT().Files(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).Times(0)
+				return mockRepo, repofs, nil
+			},
+		},
+		"Should fail when app does not exist at the revision": {
+			wantErr: "application 'app' not found at revision 'sha1'",
+			getRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				repofs := fs.Create(memfs.New())
+				_ = billyUtils.WriteFile(repofs, configPath, []byte("{}"), 0666)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().History(gomock.Any(), appDir, 0).Return([]git.Commit{{SHA: "sha1"}}, nil)
+				mockRepo.EXPECT().Files(gomock.Any(), "sha1", appDir).Return(map[string][]byte{}, nil)
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).Times(0)
+				return mockRepo, repofs, nil
+			},
+		},
+		"Should restore the app directory": {
+			getRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				repofs := fs.Create(memfs.New())
+				_ = billyUtils.WriteFile(repofs, configPath, []byte(`{"srcTargetRevision":"v2"}`), 0666)
+				_ = billyUtils.WriteFile(repofs, filepath.Join(appDir, "base", "added.yaml"), []byte("kind: ConfigMap"), 0666)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().History(gomock.Any(), appDir, 0).Return([]git.Commit{{SHA: "sha2"}, {SHA: "sha1"}}, nil)
+				mockRepo.EXPECT().Files(gomock.Any(), "sha1", appDir).Return(map[string][]byte{
+					configPath: []byte(`{"srcTargetRevision":"v1"}`),
+				}, nil)
+				mockRepo.EXPECT().Persist(gomock.Any(), &git.PushOptions{
+					CommitMsg: "chore: rollback app 'app' on project 'project' to 'sha1'",
+				}).Return("sha3", nil)
+				return mockRepo, repofs, nil
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				b, err := repofs.ReadFile(configPath)
+				assert.NoError(t, err)
+				assert.Equal(t, `{"srcTargetRevision":"v1"}`, string(b))
+				assert.False(t, repofs.ExistsOrDie(filepath.Join(appDir, "base", "added.yaml")))
+			},
+		},
+	}
+	origGetRepo := getRepo
+	defer func() { getRepo = origGetRepo }()
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var repofs fs.FS
+			getRepo = func(_ context.Context, _ *git.CloneOptions) (git.Repository, fs.FS, error) {
+				var (
+					repo git.Repository
+					err  error
+				)
+				repo, repofs, err = tt.getRepo(t)
+				return repo, repofs, err
+			}
+
+			n := &NativeRepoTarget{project: "project", tenantRepoCloneOpts: &git.CloneOptions{}}
+			commit, err := n.RunAppRollback(context.Background(), "app", "sha1")
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.Equal(t, "sha3", commit)
+			if tt.assertFn != nil {
+				tt.assertFn(t, repofs)
+			}
+		})
+	}
+}
+
+func TestRunAppPromote(t *testing.T) {
+	appDir := filepath.Join(store.Default.AppsDir, "app")
+	configPath := filepath.Join(appDir, store.Default.OverlaysDir, "project", "config.json")
+	promotedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	overlayPath := filepath.Join(appDir, store.Default.OverlaysDir, "project")
+	sitPath := filepath.Join(overlayPath, application.TargetsDir, "sit")
+	confs := []application.Config{
+		{AppName: "app", UserGivenName: "app", DestClusterName: "dev", SrcPath: overlayPath, SrcTargetRevision: "main"},
+		{AppName: "app", UserGivenName: "app-sit", DestClusterName: "sit", SrcPath: sitPath, SrcTargetRevision: "main"},
+	}
+	tests := map[string]struct {
+		clusters []string
+		wantErr  string
+		beforeFn func(*testing.T) (git.Repository, fs.FS)
+		assertFn func(t *testing.T, repofs fs.FS)
+	}{
+		"Should fail when app does not exist": {
+			clusters: []string{"sit"},
+			wantErr:  "application 'app' not found",
+			beforeFn: func(*testing.T) (git.Repository, fs.FS) {
+				return nil, fs.Create(memfs.New())
+			},
+		},
+		"Should fail when the target does not exist at the revision": {
+			clusters: []string{"sit"},
+			wantErr:  "the target of application 'app' on cluster 'sit' does not exist at revision 'sha1', '" + sitPath + "' is missing",
+			beforeFn: func(t *testing.T) (git.Repository, fs.FS) {
+				repofs := fs.Create(memfs.New())
+				_ = application.WriteConfigs(repofs, configPath, confs)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Files(gomock.Any(), "sha1", sitPath).Return(map[string][]byte{}, nil)
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).Times(0)
+				return mockRepo, repofs
+			},
+		},
+		"Should fail when app is not deployed to the cluster": {
+			clusters: []string{"prd"},
+			wantErr:  "application 'app' is not deployed to all of the clusters [prd]",
+			beforeFn: func(t *testing.T) (git.Repository, fs.FS) {
+				repofs := fs.Create(memfs.New())
+				_ = application.WriteConfigs(repofs, configPath, confs)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).Times(0)
+				return mockRepo, repofs
+			},
+		},
+		"Should pin the targets of the clusters to the revision": {
+			clusters: []string{"sit"},
+			beforeFn: func(t *testing.T) (git.Repository, fs.FS) {
+				repofs := fs.Create(memfs.New())
+				_ = application.WriteConfigs(repofs, configPath, confs)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Files(gomock.Any(), "sha1", sitPath).Return(map[string][]byte{
+					filepath.Join(sitPath, "kustomization.yaml"): []byte("resources: [../../../../base]"),
+				}, nil)
+				mockRepo.EXPECT().Persist(gomock.Any(), &git.PushOptions{
+					CommitMsg: "chore: promote app 'app' on project 'project' from 'DEV' to [sit] at 'sha1'",
+				}).Return("sha2", nil)
+				return mockRepo, repofs
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				got, err := application.ReadConfigs(repofs, configPath)
+				assert.NoError(t, err)
+				assert.Equal(t, "main", got[0].SrcTargetRevision)
+				assert.Nil(t, application.PromotionOf(&got[0]))
+				assert.Equal(t, "sha1", got[1].SrcTargetRevision)
+				assert.Equal(t, &types.Promotion{From: "DEV", Revision: "sha1", PromotedBy: "admin", PromotedAt: promotedAt}, application.PromotionOf(&got[1]))
+			},
+		},
+	}
+	origGetRepo := getRepo
+	defer func() { getRepo = origGetRepo }()
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			repo, repofs := tt.beforeFn(t)
+			getRepo = func(_ context.Context, _ *git.CloneOptions) (git.Repository, fs.FS, error) {
+				return repo, repofs, nil
+			}
+
+			n := &NativeRepoTarget{project: "project", tenantRepoCloneOpts: &git.CloneOptions{}, metaRepoCloneOpts: &git.CloneOptions{}}
+			commit, err := n.RunAppPromote(context.Background(), &types.AppPromoteOptions{
+				AppName:  "app",
+				Clusters: tt.clusters,
+				Promotion: types.Promotion{
+					From:       "DEV",
+					Revision:   "sha1",
+					PromotedBy: "admin",
+					PromotedAt: promotedAt,
+				},
+			})
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.Equal(t, "sha2", commit)
+			if tt.assertFn != nil {
+				tt.assertFn(t, repofs)
+			}
+		})
+	}
+}
+
+func TestRunAppUnpin(t *testing.T) {
+	appDir := filepath.Join(store.Default.AppsDir, "app")
+	configPath := filepath.Join(appDir, store.Default.OverlaysDir, "project", "config.json")
+	promoted := application.Config{AppName: "app", UserGivenName: "app-sit", DestClusterName: "sit", SrcTargetRevision: "main"}
+	application.Promote(&promoted, &types.Promotion{From: "DEV", Revision: "sha1", PromotedBy: "admin"})
+	confs := []application.Config{
+		{AppName: "app", UserGivenName: "app", DestClusterName: "dev", SrcTargetRevision: "main"},
+		promoted,
+	}
+	tests := map[string]struct {
+		clusters []string
+		wantErr  string
+		persist  bool
+	}{
+		"Should fail when app is not deployed to the cluster": {
+			clusters: []string{"prd"},
+			wantErr:  "application 'app' is not deployed to all of the clusters [prd]",
+		},
+		"Should deploy the targets of the clusters from the tracked revision": {
+			clusters: []string{"sit"},
+			persist:  true,
+		},
+	}
+	origGetRepo := getRepo
+	defer func() { getRepo = origGetRepo }()
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			repofs := fs.Create(memfs.New())
+			_ = application.WriteConfigs(repofs, configPath, confs)
+			mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+			if tt.persist {
+				mockRepo.EXPECT().Persist(gomock.Any(), &git.PushOptions{
+					CommitMsg: "chore: unpin app 'app' on project 'project' on [sit]",
+				}).Return("sha2", nil)
+			}
+			getRepo = func(_ context.Context, _ *git.CloneOptions) (git.Repository, fs.FS, error) {
+				return mockRepo, repofs, nil
+			}
+
+			cloneOpts := &git.CloneOptions{Repo: "https://github.com/owner/gitops?ref=main"}
+			cloneOpts.Parse()
+			n := &NativeRepoTarget{project: "project", tenantRepoCloneOpts: cloneOpts, metaRepoCloneOpts: cloneOpts}
+			commit, err := n.RunAppUnpin(context.Background(), &types.AppUnpinOptions{AppName: "app", Clusters: tt.clusters})
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.Equal(t, "sha2", commit)
+			got, err := application.ReadConfigs(repofs, configPath)
+			assert.NoError(t, err)
+			assert.Equal(t, "main", got[1].SrcTargetRevision)
+			assert.Nil(t, application.PromotionOf(&got[1]))
+		})
+	}
+}
+
+func TestRunAppTargetManifests(t *testing.T) {
+	appDir := filepath.Join(store.Default.AppsDir, "app")
+	overlayPath := filepath.Join(appDir, store.Default.OverlaysDir, "project")
+	prepareAppFS := func(resources []string) fs.FS {
+		repofs := fs.Create(memfs.New())
+		_ = repofs.WriteYamls(filepath.Join(appDir, "base", "kustomization.yaml"), &kusttypes.Kustomization{Resources: resources})
+		_ = billyUtils.WriteFile(repofs, filepath.Join(appDir, "base", "manifest.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n"), 0666)
+		_ = repofs.WriteYamls(filepath.Join(overlayPath, "kustomization.yaml"), &kusttypes.Kustomization{
+			Namespace: "ns1",
+			Resources: []string{"../../base", "ingress.yaml"},
+		})
+		_ = billyUtils.WriteFile(repofs, filepath.Join(overlayPath, "ingress.yaml"), []byte("apiVersion: networking.k8s.io/v1\nkind: Ingress\nmetadata:\n  name: app\n"), 0666)
+		_ = repofs.WriteYamls(filepath.Join(overlayPath, application.TargetsDir, "sit", "kustomization.yaml"), &kusttypes.Kustomization{
+			Namespace: "ns2",
+			Resources: []string{"../../../../base"},
+		})
+		_ = repofs.WriteJson(filepath.Join(overlayPath, "config.json"), []application.Config{
+			{AppName: "app", DestClusterName: "dev", DestNamespace: "ns1"},
+			{AppName: "app", DestClusterName: "sit", DestNamespace: "ns2"},
+		})
+		return repofs
+	}
+	tests := map[string]struct {
+		repofs   fs.FS
+		wantErr  string
+		assertFn func(t *testing.T, manifests map[string][]byte)
+	}{
+		"Should build the overlay of each target": {
+			repofs: prepareAppFS([]string{"manifest.yaml"}),
+			assertFn: func(t *testing.T, manifests map[string][]byte) {
+				assert.Len(t, manifests, 2)
+				assert.Contains(t, string(manifests["dev"]), "kind: Ingress")
+				assert.Contains(t, string(manifests["dev"]), "namespace: ns1")
+				assert.NotContains(t, string(manifests["sit"]), "kind: Ingress")
+				assert.Contains(t, string(manifests["sit"]), "namespace: ns2")
+			},
+		},
+		"Should render the remote base with the env of the cluster of each target": {
+			repofs: prepareAppFS([]string{"github.com/owner/app/deploy?ref=v1"}),
+			assertFn: func(t *testing.T, manifests map[string][]byte) {
+				assert.Contains(t, string(manifests["dev"]), "name: app-dev")
+				assert.Contains(t, string(manifests["dev"]), "kind: Ingress")
+				assert.Contains(t, string(manifests["sit"]), "name: app-default")
+			},
+		},
+		"Should fail when app does not exist": {
+			repofs:  fs.Create(memfs.New()),
+			wantErr: "application 'app' not found",
+		},
+	}
+	origGetRepo, origRenderRemoteBase := getRepo, renderRemoteBase
+	defer func() { getRepo, renderRemoteBase = origGetRepo, origRenderRemoteBase }()
+	renderRemoteBase = func(_ context.Context, ref, env string) ([]byte, error) {
+		assert.Equal(t, "github.com/owner/app/deploy?ref=v1", ref)
+		return []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app-" + env + "\n"), nil
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			getRepo = func(_ context.Context, _ *git.CloneOptions) (git.Repository, fs.FS, error) {
+				return nil, tt.repofs, nil
+			}
+
+			n := &NativeRepoTarget{project: "project", tenantRepoCloneOpts: &git.CloneOptions{}, metaRepoCloneOpts: &git.CloneOptions{}}
+			got, err := n.RunAppTargetManifests(context.Background(), "app", map[string]string{"dev": "dev"})
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			tt.assertFn(t, got)
+		})
+	}
+}
+
+func TestRunAppUpdate(t *testing.T) {
+	prepareAppFS := func(base *kusttypes.Kustomization) fs.FS {
+		repofs := fs.Create(memfs.New())
+		appDir := filepath.Join(store.Default.AppsDir, "app")
+		_ = repofs.WriteYamls(filepath.Join(appDir, "base", "kustomization.yaml"), base)
+		_ = repofs.WriteYamls(filepath.Join(appDir, store.Default.OverlaysDir, "project", "kustomization.yaml"), &kusttypes.Kustomization{
+			Resources: []string{"../../base"},
+		})
+		_ = repofs.WriteJson(filepath.Join(appDir, store.Default.OverlaysDir, "project", "config.json"), &application.Config{
+			AppName:       "app",
+			UserGivenName: "app",
+			DestNamespace: "default",
+			DestServer:    store.Default.DestServer,
+			Annotations: map[string]string{
+				"squidflow.github.io/appcode": "0001",
+			},
+		})
+		return repofs
+	}
+	writeSecretStore := func(repofs fs.FS, tenant string) {
+		_ = repofs.WriteYamls(filepath.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir, store.Default.ClusterContextName, "ss-ss1.yaml"), &esv1beta1.SecretStore{
+			TypeMeta: v1.TypeMeta{Kind: esv1beta1.SecretStoreKind},
+			ObjectMeta: v1.ObjectMeta{
+				Name:        "vault",
+				Labels:      map[string]string{store.Default.LabelKeyTenant: tenant},
+				Annotations: map[string]string{"squidflow.github.io/id": "ss1"},
+			},
+		})
+	}
+	secretStoreConfig := types.ExternalSecretConfig{
+		SecretStoreRef:  types.SecretStoreRefConfig{ID: "ss1"},
+		RefreshInterval: "1h0m0s",
+		Secrets: []types.ExternalSecretTemplate{{
+			Name: "db",
+			Data: []types.ExternalSecretKeyData{{SecretKey: "password", RemoteKey: "app/db", Property: "password"}},
+		}},
+	}
+
+	tests := map[string]struct {
+		opts              *types.UpdateOptions
+		wantErr           string
+		getRepo           func(*testing.T) (git.Repository, fs.FS, error)
+		renderAppManifest func(t *testing.T, src *types.ApplicationSourceRequest, env string) ([]byte, error)
+		assertFn          func(*testing.T, fs.FS)
+	}{
+		"Should fail when clone fails": {
+			opts:    &types.UpdateOptions{AppName: "app"},
+			wantErr: "some error",
+			getRepo: func(_ *testing.T) (git.Repository, fs.FS, error) {
+				return nil, nil, fmt.Errorf("some error")
+			},
+		},
+		"Should fail when app does not exist": {
+			opts:    &types.UpdateOptions{AppName: "app"},
+			wantErr: "application 'app' not found",
+			getRepo: func(_ *testing.T) (git.Repository, fs.FS, error) {
+				return nil, fs.Create(memfs.New()), nil
+			},
+		},
+		"Should update the base, overlay and config": {
+			opts: &types.UpdateOptions{
+				AppName: "app",
+				UpdateReq: &types.ApplicationUpdateRequest{
+					ApplicationSource: types.ApplicationSourceRequest{
+						Repo:           "https://github.com/owner/app.git",
+						Path:           "deploy",
+						TargetRevision: "v2",
+					},
+					ApplicationTarget: []types.ApplicationTarget{
+						{Cluster: "in-cluster", Namespace: "app-ns"},
+					},
+				},
+				Annotations: map[string]string{
+					"squidflow.github.io/description": "new description",
+				},
+			},
+			getRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				repofs := prepareAppFS(&kusttypes.Kustomization{
+					Resources: []string{"github.com/owner/app/deploy?ref=v1"},
+				})
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(gomock.Any(), &git.PushOptions{
+					CommitMsg: "chore: update app 'app' on project 'project' installation-path: '/'",
+				}).
+					Times(1).
+					Return("revision", nil)
+				return mockRepo, repofs, nil
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				appDir := filepath.Join(store.Default.AppsDir, "app")
+				base := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(filepath.Join(appDir, "base", "kustomization.yaml"), base))
+				assert.Equal(t, []string{"github.com/owner/app/deploy?ref=v2"}, base.Resources)
+
+				overlay := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(filepath.Join(appDir, store.Default.OverlaysDir, "project", "kustomization.yaml"), overlay))
+				assert.Equal(t, "app-ns", overlay.Namespace)
+
+				confs, err := getConfigsFromPath(repofs, filepath.Join(appDir, store.Default.OverlaysDir, "project"))
+				assert.NoError(t, err)
+				conf := confs[0]
+				assert.Equal(t, "app-ns", conf.DestNamespace)
+				assert.Equal(t, "0001", conf.Annotations["squidflow.github.io/appcode"])
+				assert.Equal(t, "new description", conf.Annotations["squidflow.github.io/description"])
+			},
+		},
+		"Should render the manifests again for a flattened app": {
+			opts: &types.UpdateOptions{
+				AppName: "app",
+				UpdateReq: &types.ApplicationUpdateRequest{
+					ApplicationSource: types.ApplicationSourceRequest{
+						Repo:           "https://github.com/owner/app.git",
+						TargetRevision: "v2",
+					},
+				},
+			},
+			getRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				repofs := prepareAppFS(&kusttypes.Kustomization{
+					Resources: []string{"manifest.yaml"},
+				})
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return("revision", nil)
+				return mockRepo, repofs, nil
+			},
+			renderAppManifest: func(t *testing.T, src *types.ApplicationSourceRequest, env string) ([]byte, error) {
+				assert.Equal(t, "v2", src.TargetRevision)
+				assert.Equal(t, "default", env)
+				return []byte("kind: ConfigMap"), nil
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				data, err := repofs.ReadFile(filepath.Join(store.Default.AppsDir, "app", "base", "manifest.yaml"))
+				assert.NoError(t, err)
+				assert.Equal(t, "kind: ConfigMap", string(data))
+
+				base, err := readAppBase(repofs, "app")
+				assert.NoError(t, err)
+				assert.Equal(t, &types.ApplicationSourceRequest{
+					Repo:           "https://github.com/owner/app.git",
+					TargetRevision: "v2",
+				}, appBaseSource(base))
+			},
+		},
+		"Should render the manifests of new targets from the recorded source": {
+			opts: &types.UpdateOptions{
+				AppName: "app",
+				UpdateReq: &types.ApplicationUpdateRequest{
+					ApplicationTarget: []types.ApplicationTarget{
+						{Cluster: "in-cluster", Namespace: "app", Server: store.Default.DestServer},
+						{Cluster: "prod", Namespace: "app", Server: "https://prod.example.com"},
+					},
+				},
+				Envs: map[string]string{"in-cluster": "sit", "prod": "prd"},
+			},
+			getRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				repofs := prepareAppFS(&kusttypes.Kustomization{
+					MetaData: &kusttypes.ObjectMeta{Annotations: map[string]string{
+						annotationKeySourceRepo:     "https://github.com/owner/app.git",
+						annotationKeySourceRevision: "v1",
+					}},
+					Resources: []string{"manifest.yaml"},
+				})
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return("revision", nil)
+				return mockRepo, repofs, nil
+			},
+			renderAppManifest: func(t *testing.T, src *types.ApplicationSourceRequest, env string) ([]byte, error) {
+				assert.Equal(t, "https://github.com/owner/app.git", src.Repo)
+				assert.Equal(t, "v1", src.TargetRevision)
+				return []byte("env: " + env), nil
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				appDir := filepath.Join(store.Default.AppsDir, "app")
+				data, err := repofs.ReadFile(filepath.Join(appDir, "base", "manifest.yaml"))
+				assert.NoError(t, err)
+				assert.Equal(t, "env: sit", string(data))
+
+				targetPath := filepath.Join(appDir, store.Default.OverlaysDir, "project", application.TargetsDir, "prod")
+				data, err = repofs.ReadFile(filepath.Join(targetPath, application.TargetManifestFile))
+				assert.NoError(t, err)
+				assert.Equal(t, "env: prd", string(data))
+			},
+		},
+		"Should fail when rendering fails": {
+			opts: &types.UpdateOptions{
+				AppName: "app",
+				UpdateReq: &types.ApplicationUpdateRequest{
+					ApplicationSource: types.ApplicationSourceRequest{
+						Repo: "https://github.com/owner/app.git",
+					},
+				},
+			},
+			wantErr: "failed to render application manifests of env 'default': some error",
+			getRepo: func(_ *testing.T) (git.Repository, fs.FS, error) {
+				return nil, prepareAppFS(&kusttypes.Kustomization{
+					Resources: []string{"manifest.yaml"},
+				}), nil
+			},
+			renderAppManifest: func(_ *testing.T, _ *types.ApplicationSourceRequest, _ string) ([]byte, error) {
+				return nil, fmt.Errorf("some error")
+			},
+		},
+		"Should render the manifests of each target with the env of its cluster": {
+			opts: &types.UpdateOptions{
+				AppName: "app",
+				UpdateReq: &types.ApplicationUpdateRequest{
+					ApplicationSource: types.ApplicationSourceRequest{
+						Repo:           "https://github.com/owner/app.git",
+						TargetRevision: "v2",
+					},
+					ApplicationTarget: []types.ApplicationTarget{
+						{Cluster: "in-cluster", Namespace: "app", Server: store.Default.DestServer},
+						{Cluster: "uat", Namespace: "app", Server: "https://uat.example.com"},
+						{Cluster: "prod", Namespace: "app-prod", Server: "https://prod.example.com"},
+					},
+				},
+				Envs: map[string]string{"in-cluster": "sit", "uat": "sit", "prod": "prd"},
+			},
+			getRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				repofs := prepareAppFS(&kusttypes.Kustomization{
+					Resources: []string{"manifest.yaml"},
+				})
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return("revision", nil)
+				return mockRepo, repofs, nil
+			},
+			renderAppManifest: func(_ *testing.T, _ *types.ApplicationSourceRequest, env string) ([]byte, error) {
+				return []byte("env: " + env), nil
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				appDir := filepath.Join(store.Default.AppsDir, "app")
+				data, err := repofs.ReadFile(filepath.Join(appDir, "base", "manifest.yaml"))
+				assert.NoError(t, err)
+				assert.Equal(t, "env: sit", string(data))
+
+				targetsPath := filepath.Join(appDir, store.Default.OverlaysDir, "project", application.TargetsDir)
+				overlay := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(filepath.Join(targetsPath, "uat", "kustomization.yaml"), overlay))
+				assert.Equal(t, []string{"../../../../base", "namespace.yaml"}, overlay.Resources)
+				assert.False(t, repofs.ExistsOrDie(filepath.Join(targetsPath, "uat", application.TargetManifestFile)))
+
+				overlay = &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(filepath.Join(targetsPath, "prod", "kustomization.yaml"), overlay))
+				assert.Equal(t, []string{application.TargetManifestFile, "namespace.yaml"}, overlay.Resources)
+				assert.Equal(t, "app-prod", overlay.Namespace)
+				data, err = repofs.ReadFile(filepath.Join(targetsPath, "prod", application.TargetManifestFile))
+				assert.NoError(t, err)
+				assert.Equal(t, "env: prd", string(data))
+
+				overlay = &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(filepath.Join(appDir, store.Default.OverlaysDir, "project", "kustomization.yaml"), overlay))
+				assert.Equal(t, "app", overlay.Namespace)
+			},
+		},
+		"Should write the ingress of the app to the overlay": {
+			opts: &types.UpdateOptions{
+				AppName: "app",
+				UpdateReq: &types.ApplicationUpdateRequest{
+					ApplicationInstantiation: types.ApplicationInstantiation{
+						Ingress: []types.IngressConfig{{Host: "app.example.com"}},
+					},
+				},
+				IngressClasses: map[string]string{"in-cluster": "nginx"},
+			},
+			getRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				repofs := prepareAppFS(&kusttypes.Kustomization{
+					Resources: []string{"manifest.yaml"},
+				})
+				_ = billyUtils.WriteFile(repofs, filepath.Join(store.Default.AppsDir, "app", "base", "manifest.yaml"),
+					[]byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: app\nspec:\n  ports:\n  - port: 80\n"), 0666)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return("revision", nil)
+				return mockRepo, repofs, nil
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				overlayPath := filepath.Join(store.Default.AppsDir, "app", store.Default.OverlaysDir, "project")
+				overlay := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(filepath.Join(overlayPath, "kustomization.yaml"), overlay))
+				assert.Equal(t, []string{"../../base", application.IngressFile}, overlay.Resources)
+
+				ingress, err := application.ReadIngress(repofs, overlayPath)
+				assert.NoError(t, err)
+				assert.Equal(t, "nginx", *ingress.Spec.IngressClassName)
+				assert.Equal(t, "app.example.com", ingress.Spec.Rules[0].Host)
+				assert.Equal(t, "app", ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name)
+			},
+		},
+		"Should fail when the app has no Service for the ingress": {
+			opts: &types.UpdateOptions{
+				AppName: "app",
+				UpdateReq: &types.ApplicationUpdateRequest{
+					ApplicationInstantiation: types.ApplicationInstantiation{
+						Ingress: []types.IngressConfig{{Host: "app.example.com"}},
+					},
+				},
+			},
+			wantErr: "invalid ingress of application 'app': application has no Service to expose with an ingress",
+			getRepo: func(_ *testing.T) (git.Repository, fs.FS, error) {
+				repofs := prepareAppFS(&kusttypes.Kustomization{
+					Resources: []string{"manifest.yaml"},
+				})
+				_ = billyUtils.WriteFile(repofs, filepath.Join(store.Default.AppsDir, "app", "base", "manifest.yaml"), []byte("kind: ConfigMap"), 0666)
+				return nil, repofs, nil
+			},
+		},
+		"Should write the external secrets of the app to the overlay": {
+			opts: &types.UpdateOptions{
+				AppName: "app",
+				UpdateReq: &types.ApplicationUpdateRequest{
+					ApplicationInstantiation: types.ApplicationInstantiation{
+						Security: types.SecurityConfig{ExternalSecret: secretStoreConfig},
+					},
+				},
+			},
+			getRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				repofs := prepareAppFS(&kusttypes.Kustomization{})
+				writeSecretStore(repofs, "project")
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return("revision", nil)
+				return mockRepo, repofs, nil
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				overlayPath := filepath.Join(store.Default.AppsDir, "app", store.Default.OverlaysDir, "project")
+				overlay := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(filepath.Join(overlayPath, "kustomization.yaml"), overlay))
+				assert.Equal(t, []string{"../../base", application.ExternalSecretsFile}, overlay.Resources)
+
+				secrets, err := application.ReadExternalSecrets(repofs, overlayPath)
+				assert.NoError(t, err)
+				assert.Len(t, secrets, 1)
+				assert.Equal(t, "vault", secrets[0].Spec.SecretStoreRef.Name)
+				assert.Equal(t, secretStoreConfig, application.ExternalSecretConfigOf(secrets))
+			},
+		},
+		"Should write the external secrets from a secret store shared by the tenants": {
+			opts: &types.UpdateOptions{
+				AppName: "app",
+				UpdateReq: &types.ApplicationUpdateRequest{
+					ApplicationInstantiation: types.ApplicationInstantiation{
+						Security: types.SecurityConfig{ExternalSecret: secretStoreConfig},
+					},
+				},
+			},
+			getRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				repofs := prepareAppFS(&kusttypes.Kustomization{})
+				writeSecretStore(repofs, "")
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return("revision", nil)
+				return mockRepo, repofs, nil
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				overlayPath := filepath.Join(store.Default.AppsDir, "app", store.Default.OverlaysDir, "project")
+				secrets, err := application.ReadExternalSecrets(repofs, overlayPath)
+				assert.NoError(t, err)
+				assert.Equal(t, secretStoreConfig, application.ExternalSecretConfigOf(secrets))
+			},
+		},
+		"Should fail when the secret store belongs to another tenant": {
+			opts: &types.UpdateOptions{
+				AppName: "app",
+				UpdateReq: &types.ApplicationUpdateRequest{
+					ApplicationInstantiation: types.ApplicationInstantiation{
+						Security: types.SecurityConfig{ExternalSecret: secretStoreConfig},
+					},
+				},
+			},
+			wantErr: "secret store 'ss1' does not belong to tenant 'project'",
+			getRepo: func(_ *testing.T) (git.Repository, fs.FS, error) {
+				repofs := prepareAppFS(&kusttypes.Kustomization{})
+				writeSecretStore(repofs, "other")
+				return nil, repofs, nil
+			},
+		},
+		"Should fail if Persist fails": {
+			opts:    &types.UpdateOptions{AppName: "app"},
+			wantErr: "failed to push to repo: some error",
+			getRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return("", fmt.Errorf("some error"))
+				return mockRepo, prepareAppFS(&kusttypes.Kustomization{}), nil
+			},
+		},
+	}
+	origGetRepo, origPrepareRepo, origRenderAppManifest := getRepo, prepareRepo, renderAppManifest
+	defer func() {
+		getRepo = origGetRepo
+		prepareRepo = origPrepareRepo
+		renderAppManifest = origRenderAppManifest
+	}()
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var repofs fs.FS
+
+			getRepo = func(_ context.Context, _ *git.CloneOptions) (git.Repository, fs.FS, error) {
+				var (
+					repo git.Repository
+					err  error
+				)
+				repo, repofs, err = tt.getRepo(t)
+				return repo, repofs, err
+			}
+			// the meta repo is the tenant repo
+			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
+				return nil, repofs, nil
+			}
+			renderAppManifest = func(_ context.Context, src *types.ApplicationSourceRequest, _, _, env string) ([]byte, error) {
+				return tt.renderAppManifest(t, src, env)
+			}
+
+			repoWriter := NativeRepoTarget{
+				project: "project",
+				metaRepoCloneOpts: &git.CloneOptions{
+					Repo: "https://github.com/owner/name",
+				},
+			}
+			repoWriter.metaRepoCloneOpts.Parse()
+			repoWriter.tenantRepoCloneOpts = repoWriter.metaRepoCloneOpts
+			if err := repoWriter.RunAppUpdate(context.Background(), tt.opts); err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			if tt.assertFn != nil {
+				tt.assertFn(t, repofs)
+			}
+		})
+	}
+}
+
+func TestSecretStoreUpdate(t *testing.T) {
+	ssPath := filepath.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir, store.Default.ClusterContextName, "ss-ss1.yaml")
+	tests := map[string]struct {
+		req         *types.SecretStoreUpdateRequest
+		wantErr     string
+		prepareRepo func(*testing.T) (git.Repository, fs.FS, error)
+		assertFn    func(t *testing.T, repofs fs.FS)
+	}{
+		"Should update the vault server and keep the tenant of the secret store": {
+			req: &types.SecretStoreUpdateRequest{Name: "vault", Server: "https://vault2.example.com"},
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
+					CommitMsg: "chore: added secret store 'vault'",
+				}).Return("revision", nil)
+				return mockRepo, nil, nil
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				ss := &esv1beta1.SecretStore{}
+				assert.NoError(t, repofs.ReadYamls(ssPath, ss))
+				assert.Equal(t, "vault", ss.Name)
+				assert.Equal(t, "https://vault2.example.com", ss.Spec.Provider.Vault.Server)
+				assert.Equal(t, "project", ss.Labels[store.Default.LabelKeyTenant])
+			},
+		},
+		"Should fail to rename the secret store": {
+			req:     &types.SecretStoreUpdateRequest{Name: "vault2"},
+			wantErr: "secret store 'vault' cannot be renamed to 'vault2', the external secrets of the applications reference it by name",
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).Times(0)
+				return mockRepo, nil, nil
+			},
+		},
+	}
+	origPrepareRepo := prepareRepo
+	defer func() { prepareRepo = origPrepareRepo }()
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			repofs := fs.Create(memfs.New())
+			assert.NoError(t, repofs.WriteYamls(ssPath, &esv1beta1.SecretStore{
+				TypeMeta: v1.TypeMeta{APIVersion: "external-secrets.io/v1beta1", Kind: esv1beta1.SecretStoreKind},
+				ObjectMeta: v1.ObjectMeta{
+					Name:        "vault",
+					Namespace:   "app",
+					Labels:      map[string]string{store.Default.LabelKeyTenant: "project"},
+					Annotations: map[string]string{"squidflow.github.io/id": "ss1"},
+				},
+				Spec: esv1beta1.SecretStoreSpec{Provider: &esv1beta1.SecretStoreProvider{Vault: &esv1beta1.VaultProvider{
+					Server: "https://vault.example.com",
+					Auth:   esv1beta1.VaultAuth{TokenSecretRef: &esmeta.SecretKeySelector{Name: "vault-token", Key: "token"}},
+				}}},
+			}))
+			repo, _, _ := tt.prepareRepo(t)
+			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
+				return repo, repofs, nil
+			}
+
+			_, err := (&NativeRepoTarget{metaRepoCloneOpts: &git.CloneOptions{}}).SecretStoreUpdate(context.Background(), "ss1", tt.req)
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				assert.Equal(t, apierr.CodeValidation, apierr.CodeOf(err))
+				return
+			}
+
+			if tt.assertFn != nil {
+				tt.assertFn(t, repofs)
+			}
+		})
+	}
+}
+
 func TestRunProjectCreate(t *testing.T) {
 	tests := map[string]struct {
-		repoWriter               NativeRepoTarget
-		projectName              string
-		wantErr                  string
-		getInstallationNamespace func(repofs fs.FS) (string, error)
-		prepareRepo              func(*testing.T) (git.Repository, fs.FS, error)
-		assertFn                 func(t *testing.T, repo git.Repository, repofs fs.FS)
+		repoWriter               NativeRepoTarget
+		projectName              string
+		wantErr                  string
+		getInstallationNamespace func(repofs fs.FS) (string, error)
+		prepareRepo              func(*testing.T) (git.Repository, fs.FS, error)
+		assertFn                 func(t *testing.T, repo git.Repository, repofs fs.FS)
+	}{
+		"should handle failure in prepare repo": {
+			repoWriter:  NativeRepoTarget{},
+			projectName: "project",
+			prepareRepo: func(*testing.T) (git.Repository, fs.FS, error) {
+				return nil, nil, fmt.Errorf("failure clone")
+			},
+			wantErr: "failure clone",
+		},
+		"should handle failure while getting namespace": {
+			repoWriter:  NativeRepoTarget{},
+			projectName: "project",
+			prepareRepo: func(*testing.T) (git.Repository, fs.FS, error) {
+				memfs := memfs.New()
+				return nil, fs.Create(memfs), nil
+			},
+			getInstallationNamespace: func(_ fs.FS) (string, error) {
+				return "", fmt.Errorf("failure namespace")
+			},
+			wantErr: util.Doc("Bootstrap folder not found, please execute `<BIN> repo bootstrap --installation-path /` command"),
+		},
+		"should handle failure when project exists": {
+			repoWriter:  NativeRepoTarget{},
+			projectName: "project",
+			prepareRepo: func(*testing.T) (git.Repository, fs.FS, error) {
+				memfs := memfs.New()
+				_ = billyUtils.WriteFile(memfs, "projects/project.yaml", []byte{}, 0666)
+				return nil, fs.Create(memfs), nil
+			},
+			getInstallationNamespace: func(_ fs.FS) (string, error) {
+				return "namespace", nil
+			},
+			wantErr: "project 'project' already exists",
+		},
+		"should handle failure when writing project file": {
+			repoWriter: NativeRepoTarget{
+				metaRepoCloneOpts: &git.CloneOptions{
+					Repo: "http://server.org/org/name",
+				},
+			},
+			projectName: "project",
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				mockedFS := fsmocks.NewMockFS(gomock.NewController(t))
+				mockedFS.EXPECT().Join("projects", "project.yaml").
+					Times(2).
+					Return("projects/project.yaml")
+				mockedFS.EXPECT().ExistsOrDie("projects/project.yaml").Return(false)
+				mockedFS.EXPECT().Join("freezewindows", "*.yaml").Return("freezewindows/*.yaml")
+				mockedFS.EXPECT().Lstat("freezewindows").Return(nil, os.ErrNotExist)
+				mockedFS.EXPECT().OpenFile("projects/project.yaml", gomock.Any(), gomock.Any()).Return(nil, os.ErrPermission)
+				return nil, mockedFS, nil
+			},
+			getInstallationNamespace: func(_ fs.FS) (string, error) {
+				return "namespace", nil
+			},
+			wantErr: "failed to create project file: permission denied",
+		},
+		"should handle failure to persist repo": {
+			repoWriter: NativeRepoTarget{
+				metaRepoCloneOpts: &git.CloneOptions{
+					Repo: "http://server.org/org/name",
+				},
+			},
+			projectName: "project",
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				memfs := memfs.New()
+				mockedRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockedRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
+					CommitMsg: "chore: added project 'project'",
+				}).Return("", fmt.Errorf("failed to persist"))
+				return mockedRepo, fs.Create(memfs), nil
+			},
+			getInstallationNamespace: func(_ fs.FS) (string, error) {
+				return "namespace", nil
+			},
+			wantErr: "failed to persist",
+		},
+		"should persist repo when done": {
+			repoWriter: NativeRepoTarget{
+				metaRepoCloneOpts: &git.CloneOptions{
+					Repo: "http://server.org/org/name",
+				},
+			},
+			projectName: "project",
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				memfs := memfs.New()
+				mockedRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockedRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
+					CommitMsg: "chore: added project 'project'",
+				}).Return("revision", nil)
+				return mockedRepo, fs.Create(memfs), nil
+			},
+			getInstallationNamespace: func(_ fs.FS) (string, error) {
+				return "namespace", nil
+			},
+			assertFn: func(t *testing.T, _ git.Repository, repofs fs.FS) {
+				exists := repofs.ExistsOrDie("projects/project.yaml")
+				assert.True(t, exists)
+			},
+		},
+	}
+	origPrepareRepo := prepareRepo
+	origGetInstallationNamespace := getInstallationNamespace
+	defer func() {
+		prepareRepo = origPrepareRepo
+		getInstallationNamespace = origGetInstallationNamespace
+	}()
+
+	for ttName, tt := range tests {
+		t.Run(ttName, func(t *testing.T) {
+			var (
+				repo   git.Repository
+				repofs fs.FS
+			)
+
+			opts := &types.ProjectCreateOptions{
+				ProjectName: tt.projectName,
+			}
+			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
+				var err error
+				repo, repofs, err = tt.prepareRepo(t)
+				return repo, repofs, err
+			}
+			getInstallationNamespace = tt.getInstallationNamespace
+			if err := tt.repoWriter.RunProjectCreate(context.Background(), opts); err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			if tt.assertFn != nil {
+				tt.assertFn(t, repo, repofs)
+			}
+		})
+	}
+}
+
+func Test_generateProjectManifests(t *testing.T) {
+	tests := map[string]struct {
+		o                      *types.GenerateProjectOptions
+		wantName               string
+		wantNamespace          string
+		wantProjectDescription string
+		wantRepoURL            string
+		wantRevision           string
+		wantDefaultDestServer  string
+		wantProject            string
+		wantContextName        string
+		wantLabels             map[string]string
+		wantAnnotations        map[string]string
+	}{
+		"should generate project and appset with correct values": {
+			o: &types.GenerateProjectOptions{
+				Name:               "name",
+				Namespace:          "namespace",
+				DefaultDestServer:  "defaultDestServer",
+				DefaultDestContext: "some-context-name",
+				RepoURL:            "repoUrl",
+				Revision:           "revision",
+				InstallationPath:   "some/path",
+				Labels: map[string]string{
+					"some-key": "some-value",
+				},
+				Annotations: map[string]string{
+					"some-key": "some-value",
+				},
+			},
+			wantName:               "name",
+			wantNamespace:          "namespace",
+			wantProjectDescription: "name project",
+			wantRepoURL:            "repoUrl",
+			wantRevision:           "revision",
+			wantDefaultDestServer:  "defaultDestServer",
+			wantContextName:        "some-context-name",
+			wantLabels: map[string]string{
+				"some-key":                         "some-value",
+				store.Default.LabelKeyAppManagedBy: store.Default.LabelValueManagedBy,
+				store.Default.LabelKeyAppName:      "{{ appName }}",
+				store.Default.LabelKeyTenant:       "name",
+			},
+			wantAnnotations: map[string]string{
+				"some-key": "some-value",
+			},
+		},
+		"set tenant repo url": {
+			o: &types.GenerateProjectOptions{
+				Name:               "name",
+				Namespace:          "namespace",
+				DefaultDestServer:  "defaultDestServer",
+				DefaultDestContext: "some-context-name",
+				ProjectGitopsRepo:  "tenantRepoUrl",
+				RepoURL:            "repoUrl",
+				Revision:           "revision",
+				InstallationPath:   "some/path",
+				Labels: map[string]string{
+					"some-key": "some-value",
+				},
+				Annotations: map[string]string{
+					"some-key": "some-value",
+				},
+			},
+			wantName:               "name",
+			wantNamespace:          "namespace",
+			wantProjectDescription: "name project",
+			wantRepoURL:            "tenantRepoUrl",
+			wantRevision:           "revision",
+			wantDefaultDestServer:  "defaultDestServer",
+			wantContextName:        "some-context-name",
+			wantLabels: map[string]string{
+				"some-key":                         "some-value",
+				store.Default.LabelKeyAppManagedBy: store.Default.LabelValueManagedBy,
+				store.Default.LabelKeyAppName:      "{{ appName }}",
+				store.Default.LabelKeyTenant:       "name",
+			},
+			wantAnnotations: map[string]string{
+				"some-key": "some-value",
+			},
+		},
+	}
+	for ttname, tt := range tests {
+		t.Run(ttname, func(t *testing.T) {
+			assert := assert.New(t)
+			gotProject := &argocdv1alpha1.AppProject{}
+			gotAppSet := &argocdv1alpha1.ApplicationSet{}
+			gotClusterResConf := &application.ClusterResConfig{}
+			gotProjectYAML, gotAppSetYAML, _, gotClusterResConfigYAML, _ := generateProjectManifests(tt.o)
+			assert.NoError(yaml.Unmarshal(gotProjectYAML, gotProject))
+			assert.NoError(yaml.Unmarshal(gotAppSetYAML, gotAppSet))
+			assert.NoError(yaml.Unmarshal(gotClusterResConfigYAML, gotClusterResConf))
+
+			assert.Equal(tt.wantContextName, gotClusterResConf.Name)
+			assert.Equal(tt.wantDefaultDestServer, gotClusterResConf.Server)
+
+			assert.Equal(tt.wantName, gotProject.Name, "Project Name")
+			assert.Equal(tt.wantNamespace, gotProject.Namespace, "Project Namespace")
+			assert.Equal(tt.wantProjectDescription, gotProject.Spec.Description, "Project Description")
+			assert.Equal(tt.o.DefaultDestServer, gotProject.Annotations[store.Default.DestServerAnnotation], "Application Set Default Destination Server")
+
+			assert.Equal(tt.wantName, gotAppSet.Name, "Application Set Name")
+			assert.Equal(tt.wantNamespace, gotAppSet.Namespace, "Application Set Namespace")
+			assert.Equal(tt.wantRepoURL, gotAppSet.Spec.Generators[0].Git.RepoURL, "Application Set Repo URL")
+			assert.Equal(tt.wantRevision, gotAppSet.Spec.Generators[0].Git.Revision, "Application Set Revision")
+
+			assert.Equal(tt.wantNamespace, gotAppSet.Spec.Template.Namespace, "Application Set Template Namespace")
+			assert.Equal(tt.wantName, gotAppSet.Spec.Template.Spec.Project, "Application Set Template Project")
+			assert.Equal(tt.wantLabels, gotAppSet.Spec.Template.Labels, "Application Set Template Labels")
+		})
+	}
+}
+
+func Test_getInstallationNamespace(t *testing.T) {
+	tests := map[string]struct {
+		beforeFn func(*testing.T) fs.FS
+		want     string
+		wantErr  string
+	}{
+		"should return the namespace from namespace.yaml": {
+			beforeFn: func(*testing.T) fs.FS {
+				namespace := &argocdv1alpha1.Application{
+					Spec: argocdv1alpha1.ApplicationSpec{
+						Destination: argocdv1alpha1.ApplicationDestination{
+							Namespace: "namespace",
+						},
+					},
+				}
+				repofs := fs.Create(memfs.New())
+				_ = repofs.WriteYamls(filepath.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName+".yaml"), namespace)
+				return repofs
+			},
+			want: "namespace",
+		},
+		"should handle file not found": {
+			beforeFn: func(*testing.T) fs.FS {
+				return fs.Create(memfs.New())
+			},
+			wantErr: "failed to unmarshal namespace: file does not exist",
+		},
+		"should handle error during read": {
+			beforeFn: func(t *testing.T) fs.FS {
+				mfs := fsmocks.NewMockFS(gomock.NewController(t))
+				mfs.EXPECT().Join(gomock.Any()).
+					Times(1).
+					DoAndReturn(func(elem ...string) string {
+						return strings.Join(elem, "/")
+					})
+				mfs.EXPECT().ReadYamls(filepath.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName+".yaml"), gomock.Any()).
+					Times(1).
+					Return(fmt.Errorf("some error"))
+				return mfs
+			},
+			wantErr: "failed to unmarshal namespace: some error",
+		},
+		"should handle corrupted namespace.yaml file": {
+			beforeFn: func(*testing.T) fs.FS {
+				repofs := fs.Create(memfs.New())
+				_ = billyUtils.WriteFile(repofs, filepath.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName+".yaml"), []byte("some string"), 0666)
+				return repofs
+			},
+			wantErr: "failed to unmarshal namespace: error unmarshaling JSON: json: cannot unmarshal string into Go value of type v1alpha1.Application",
+		},
+	}
+	for ttName, tt := range tests {
+		t.Run(ttName, func(t *testing.T) {
+			repofs := tt.beforeFn(t)
+			got, err := getInstallationNamespace(repofs)
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("getInstallationNamespace() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getProjectInfoFromFile(t *testing.T) {
+	tests := map[string]struct {
+		name     string
+		want     *argocdv1alpha1.AppProject
+		wantErr  string
+		beforeFn func(repofs fs.FS)
+	}{
+		"should return error if project file doesn't exist": {
+			name:    "prod.yaml",
+			wantErr: os.ErrNotExist.Error(),
+		},
+		"should failed when 2 files not found": {
+			name:    "prod.yaml",
+			wantErr: "expected at least 2 manifests when reading 'prod.yaml'",
+			beforeFn: func(repofs fs.FS) {
+				_ = billyUtils.WriteFile(repofs, "prod.yaml", []byte("content"), 0666)
+			},
+		},
+		"should return AppProject": {
+			name: "prod.yaml",
+			beforeFn: func(repofs fs.FS) {
+				appProj := argocdv1alpha1.AppProject{
+					ObjectMeta: v1.ObjectMeta{
+						Name:      "prod",
+						Namespace: "ns",
+					},
+				}
+				appSet := argocdv1alpha1.ApplicationSet{}
+				_ = repofs.WriteYamls("prod.yaml", appProj, appSet)
+			},
+			want: &argocdv1alpha1.AppProject{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "prod",
+					Namespace: "ns",
+				},
+			},
+		},
+	}
+	for tName, tt := range tests {
+		t.Run(tName, func(t *testing.T) {
+			repofs := fs.Create(memfs.New())
+			if tt.beforeFn != nil {
+				tt.beforeFn(repofs)
+			}
+
+			got, _, err := getProjectInfoFromFile(repofs, tt.name)
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getProjectInfoFromFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunProjectList(t *testing.T) {
+	tests := map[string]struct {
+		repoWriter  NativeRepoTarget
+		prepareRepo func(*testing.T) (git.Repository, fs.FS, error)
+		wantErr     string
+		assertFn    func(t *testing.T, projects []types.TenantInfo)
 	}{
 		"should handle failure in prepare repo": {
-			repoWriter:  NativeRepoTarget{},
-			projectName: "project",
+			repoWriter: NativeRepoTarget{
+				metaRepoCloneOpts: &git.CloneOptions{
+					Repo: "http://server.org/org/name",
+				},
+			},
 			prepareRepo: func(*testing.T) (git.Repository, fs.FS, error) {
 				return nil, nil, fmt.Errorf("failure clone")
 			},
 			wantErr: "failure clone",
 		},
-		"should handle failure while getting namespace": {
-			repoWriter:  NativeRepoTarget{},
-			projectName: "project",
-			prepareRepo: func(*testing.T) (git.Repository, fs.FS, error) {
+		"should list projects": {
+			repoWriter: NativeRepoTarget{
+				metaRepoCloneOpts: &git.CloneOptions{
+					Repo: "http://server.org/org/name",
+				},
+			},
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				memfs := memfs.New()
+				appProj := argocdv1alpha1.AppProject{
+					ObjectMeta: v1.ObjectMeta{
+						Name:      "prod",
+						Namespace: "ns",
+						Annotations: map[string]string{
+							store.Default.DestServerAnnotation: "https://cluster.example.com",
+						},
+					},
+				}
+				appSet := argocdv1alpha1.ApplicationSet{
+					ObjectMeta: v1.ObjectMeta{
+						Name: "prod",
+					},
+					Spec: argocdv1alpha1.ApplicationSetSpec{
+						Generators: []argocdv1alpha1.ApplicationSetGenerator{
+							{
+								Git: &argocdv1alpha1.GitGenerator{
+									RepoURL: "https://example.org/org/name.git",
+								},
+							},
+						},
+					},
+				}
+				repofs := fs.Create(memfs)
+				_ = repofs.WriteYamls("projects/prod.yaml", appProj, appSet)
+				return nil, repofs, nil
+			},
+			assertFn: func(t *testing.T, projects []types.TenantInfo) {
+				assert.Len(t, projects, 1)
+				assert.Equal(t, "prod", projects[0].Name)
+				assert.Equal(t, "ns", projects[0].Namespace)
+				assert.Equal(t, "https://cluster.example.com", projects[0].DefaultCluster)
+			},
+		},
+	}
+
+	origPrepareRepo := prepareRepo
+	defer func() { prepareRepo = origPrepareRepo }()
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				repo   git.Repository
+				repofs fs.FS
+			)
+
+			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
+				var err error
+				repo, repofs, err = tt.prepareRepo(t)
+				return repo, repofs, err
+			}
+
+			projects, err := tt.repoWriter.RunProjectList(context.Background())
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			if tt.assertFn != nil {
+				tt.assertFn(t, projects)
+			}
+		})
+	}
+}
+
+func TestRunProjectDelete(t *testing.T) {
+	tests := map[string]struct {
+		repoWriter            NativeRepoTarget
+		projectNameWantDelete string
+		wantErr               string
+		prepareRepo           func(*testing.T) (git.Repository, fs.FS, error)
+		assertFn              func(t *testing.T, repo git.Repository, repofs fs.FS)
+	}{
+		"Should fail when clone fails": {
+			repoWriter: NativeRepoTarget{
+				project: "admin",
+				metaRepoCloneOpts: &git.CloneOptions{
+					Repo: "https://github.com/owner/name",
+					Auth: git.Auth{
+						Password: "password",
+					},
+				},
+			},
+			projectNameWantDelete: "project",
+			wantErr:               "some error",
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				return nil, nil, fmt.Errorf("some error")
+			},
+		},
+		"Should fail when failed to delete project.yaml file": {
+			repoWriter: NativeRepoTarget{
+				project: "admin",
+				metaRepoCloneOpts: &git.CloneOptions{
+					Repo: "https://github.com/owner/name",
+					Auth: git.Auth{
+						Password: "password",
+					},
+				},
+			},
+			projectNameWantDelete: "project",
+			wantErr:               "failed to delete project 'project': " + os.ErrNotExist.Error(),
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
 				memfs := memfs.New()
-				return nil, fs.Create(memfs), nil
+				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project"), 0666)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
+					CommitMsg: "chore: deleted project 'project'",
+				}).Times(0)
+				return mockRepo, fs.Create(memfs), nil
 			},
-			getInstallationNamespace: func(_ fs.FS) (string, error) {
-				return "", fmt.Errorf("failure namespace")
+		},
+		"Should fail when persist fails": {
+			repoWriter: NativeRepoTarget{
+				project: "admin",
+				metaRepoCloneOpts: &git.CloneOptions{
+					Repo: "https://github.com/owner/name",
+					Auth: git.Auth{
+						Password: "password",
+					},
+				},
+			},
+			projectNameWantDelete: "project",
+			wantErr:               "failed to push to repo: some error",
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				memfs := memfs.New()
+				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project"), 0666)
+				_ = billyUtils.WriteFile(memfs, filepath.Join(store.Default.ProjectsDir, "project.yaml"), []byte{}, 0666)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
+					CommitMsg: "chore: deleted project 'project'",
+				}).Return("", fmt.Errorf("some error"))
+				return mockRepo, fs.Create(memfs), nil
 			},
-			wantErr: util.Doc("Bootstrap folder not found, please execute `<BIN> repo bootstrap --installation-path /` command"),
 		},
-		"should handle failure when project exists": {
-			repoWriter:  NativeRepoTarget{},
-			projectName: "project",
-			prepareRepo: func(*testing.T) (git.Repository, fs.FS, error) {
+		"Should remove entire app folder, if it contains only one overlay": {
+			repoWriter: NativeRepoTarget{
+				project: "admin",
+				metaRepoCloneOpts: &git.CloneOptions{
+					Repo: "https://github.com/owner/name",
+					Auth: git.Auth{
+						Password: "password",
+					},
+				},
+			},
+			projectNameWantDelete: "project",
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
 				memfs := memfs.New()
-				_ = billyUtils.WriteFile(memfs, "projects/project.yaml", []byte{}, 0666)
-				return nil, fs.Create(memfs), nil
+				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project"), 0666)
+				_ = billyUtils.WriteFile(memfs, filepath.Join(store.Default.ProjectsDir, "project.yaml"), []byte{}, 0666)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
+					CommitMsg: "chore: deleted project 'project'",
+				}).Return("revision", nil)
+				return mockRepo, fs.Create(memfs), nil
 			},
-			getInstallationNamespace: func(_ fs.FS) (string, error) {
-				return "namespace", nil
+			assertFn: func(t *testing.T, _ git.Repository, repofs fs.FS) {
+				assert.False(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app1")))
 			},
-			wantErr: "project 'project' already exists",
 		},
-		"should handle failure when writing project file": {
+		"Should remove only overlay, if app contains more overlays": {
 			repoWriter: NativeRepoTarget{
+				project: "admin",
 				metaRepoCloneOpts: &git.CloneOptions{
-					Repo: "http://server.org/org/name",
+					Repo: "https://github.com/owner/name",
+					Auth: git.Auth{
+						Password: "password",
+					},
 				},
 			},
-			projectName: "project",
+			projectNameWantDelete: "project",
 			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
-				mockedFS := fsmocks.NewMockFS(gomock.NewController(t))
-				mockedFS.EXPECT().Join("projects", "project.yaml").
-					Times(2).
-					Return("projects/project.yaml")
-				mockedFS.EXPECT().ExistsOrDie("projects/project.yaml").Return(false)
-				mockedFS.EXPECT().OpenFile("projects/project.yaml", gomock.Any(), gomock.Any()).Return(nil, os.ErrPermission)
-				return nil, mockedFS, nil
+				memfs := memfs.New()
+				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project"), 0666)
+				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project2"), 0666)
+				_ = billyUtils.WriteFile(memfs, filepath.Join(store.Default.ProjectsDir, "project.yaml"), []byte{}, 0666)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
+					CommitMsg: "chore: deleted project 'project'",
+				}).Return("revision", nil)
+				return mockRepo, fs.Create(memfs), nil
 			},
-			getInstallationNamespace: func(_ fs.FS) (string, error) {
-				return "namespace", nil
+			assertFn: func(t *testing.T, _ git.Repository, repofs fs.FS) {
+				assert.True(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir)))
+				assert.False(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project")))
 			},
-			wantErr: "failed to create project file: permission denied",
 		},
-		"should handle failure to persist repo": {
+		"Should remove directory apps": {
 			repoWriter: NativeRepoTarget{
+				project: "admin",
 				metaRepoCloneOpts: &git.CloneOptions{
-					Repo: "http://server.org/org/name",
+					Repo: "https://github.com/owner/name",
+					Auth: git.Auth{
+						Password: "password",
+					},
 				},
 			},
-			projectName: "project",
+			projectNameWantDelete: "project",
 			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
 				memfs := memfs.New()
-				mockedRepo := gitmocks.NewMockRepository(gomock.NewController(t))
-				mockedRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
-					CommitMsg: "chore: added project 'project'",
-				}).Return("", fmt.Errorf("failed to persist"))
-				return mockedRepo, fs.Create(memfs), nil
+				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app1", "project"), 0666)
+				_ = billyUtils.WriteFile(memfs, filepath.Join(store.Default.ProjectsDir, "project.yaml"), []byte{}, 0666)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
+					CommitMsg: "chore: deleted project 'project'",
+				}).Return("revision", nil)
+				return mockRepo, fs.Create(memfs), nil
 			},
-			getInstallationNamespace: func(_ fs.FS) (string, error) {
-				return "namespace", nil
+			assertFn: func(t *testing.T, _ git.Repository, repofs fs.FS) {
+				assert.False(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app1")))
 			},
-			wantErr: "failed to persist",
 		},
-		"should persist repo when done": {
+		"Should handle multiple apps": {
 			repoWriter: NativeRepoTarget{
+				project: "admin",
 				metaRepoCloneOpts: &git.CloneOptions{
-					Repo: "http://server.org/org/name",
+					Repo: "https://github.com/owner/name",
+					Auth: git.Auth{
+						Password: "password",
+					},
 				},
 			},
-			projectName: "project",
+			projectNameWantDelete: "project",
 			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
 				memfs := memfs.New()
-				mockedRepo := gitmocks.NewMockRepository(gomock.NewController(t))
-				mockedRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
-					CommitMsg: "chore: added project 'project'",
+				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project"), 0666)
+				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project2"), 0666)
+				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app2", store.Default.OverlaysDir, "project"), 0666)
+				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app3", "project"), 0666)
+				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app4", "project"), 0666)
+				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app4", "project3"), 0666)
+				_ = billyUtils.WriteFile(memfs, filepath.Join(store.Default.ProjectsDir, "project.yaml"), []byte{}, 0666)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
+					CommitMsg: "chore: deleted project 'project'",
 				}).Return("revision", nil)
-				return mockedRepo, fs.Create(memfs), nil
-			},
-			getInstallationNamespace: func(_ fs.FS) (string, error) {
-				return "namespace", nil
+				return mockRepo, fs.Create(memfs), nil
 			},
 			assertFn: func(t *testing.T, _ git.Repository, repofs fs.FS) {
-				exists := repofs.ExistsOrDie("projects/project.yaml")
-				assert.True(t, exists)
+				assert.True(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir)))
+				assert.False(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project")))
+				assert.False(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app2")))
+				assert.False(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app3")))
+				assert.True(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app4")))
 			},
 		},
 	}
 	origPrepareRepo := prepareRepo
-	origGetInstallationNamespace := getInstallationNamespace
-	defer func() {
-		prepareRepo = origPrepareRepo
-		getInstallationNamespace = origGetInstallationNamespace
-	}()
-
-	for ttName, tt := range tests {
-		t.Run(ttName, func(t *testing.T) {
+	defer func() { prepareRepo = origPrepareRepo }()
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
 			var (
 				repo   git.Repository
 				repofs fs.FS
 			)
 
-			opts := &types.ProjectCreateOptions{
-				ProjectName: tt.projectName,
-			}
 			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
 				var err error
 				repo, repofs, err = tt.prepareRepo(t)
 				return repo, repofs, err
 			}
-			getInstallationNamespace = tt.getInstallationNamespace
-			if err := tt.repoWriter.RunProjectCreate(context.Background(), opts); err != nil || tt.wantErr != "" {
+			tt.repoWriter.metaRepoCloneOpts.Parse()
+
+			if err := tt.repoWriter.RunProjectDelete(context.Background(), tt.projectNameWantDelete); err != nil || tt.wantErr != "" {
 				assert.EqualError(t, err, tt.wantErr)
 				return
 			}
@@ -747,539 +2225,534 @@ func TestRunProjectCreate(t *testing.T) {
 			}
 		})
 	}
-}
-
-func Test_generateProjectManifests(t *testing.T) {
-	tests := map[string]struct {
-		o                      *types.GenerateProjectOptions
-		wantName               string
-		wantNamespace          string
-		wantProjectDescription string
-		wantRepoURL            string
-		wantRevision           string
-		wantDefaultDestServer  string
-		wantProject            string
-		wantContextName        string
-		wantLabels             map[string]string
-		wantAnnotations        map[string]string
-	}{
-		"should generate project and appset with correct values": {
-			o: &types.GenerateProjectOptions{
-				Name:               "name",
-				Namespace:          "namespace",
-				DefaultDestServer:  "defaultDestServer",
-				DefaultDestContext: "some-context-name",
-				RepoURL:            "repoUrl",
-				Revision:           "revision",
-				InstallationPath:   "some/path",
-				Labels: map[string]string{
-					"some-key": "some-value",
-				},
-				Annotations: map[string]string{
-					"some-key": "some-value",
-				},
-			},
-			wantName:               "name",
-			wantNamespace:          "namespace",
-			wantProjectDescription: "name project",
-			wantRepoURL:            "repoUrl",
-			wantRevision:           "revision",
-			wantDefaultDestServer:  "defaultDestServer",
-			wantContextName:        "some-context-name",
-			wantLabels: map[string]string{
-				"some-key":                         "some-value",
-				store.Default.LabelKeyAppManagedBy: store.Default.LabelValueManagedBy,
-				store.Default.LabelKeyAppName:      "{{ appName }}",
-			},
-			wantAnnotations: map[string]string{
-				"some-key": "some-value",
-			},
-		},
-		"set tenant repo url": {
-			o: &types.GenerateProjectOptions{
-				Name:               "name",
-				Namespace:          "namespace",
-				DefaultDestServer:  "defaultDestServer",
-				DefaultDestContext: "some-context-name",
-				ProjectGitopsRepo:  "tenantRepoUrl",
-				RepoURL:            "repoUrl",
-				Revision:           "revision",
-				InstallationPath:   "some/path",
-				Labels: map[string]string{
-					"some-key": "some-value",
-				},
-				Annotations: map[string]string{
-					"some-key": "some-value",
-				},
+}
+
+func TestAppCodeCreate(t *testing.T) {
+	tests := map[string]struct {
+		code        *types.AppCode
+		wantCode    apierr.Code
+		prepareRepo func(*testing.T) (git.Repository, fs.FS, error)
+		assertFn    func(t *testing.T, repofs fs.FS)
+	}{
+		"Should write the appcode to the meta repo": {
+			code: &types.AppCode{Name: "0001", OwnerTeam: "team1", CostCenter: "cc1", Active: true},
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
+					CommitMsg: "chore: added appcode '0001'",
+				}).Return("revision", nil)
+				return mockRepo, fs.Create(memfs.New()), nil
 			},
-			wantName:               "name",
-			wantNamespace:          "namespace",
-			wantProjectDescription: "name project",
-			wantRepoURL:            "tenantRepoUrl",
-			wantRevision:           "revision",
-			wantDefaultDestServer:  "defaultDestServer",
-			wantContextName:        "some-context-name",
-			wantLabels: map[string]string{
-				"some-key":                         "some-value",
-				store.Default.LabelKeyAppManagedBy: store.Default.LabelValueManagedBy,
-				store.Default.LabelKeyAppName:      "{{ appName }}",
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				code := &types.AppCode{}
+				assert.NoError(t, repofs.ReadYamls(filepath.Join(store.Default.AppCodesDir, "0001.yaml"), code))
+				assert.Equal(t, &types.AppCode{Name: "0001", OwnerTeam: "team1", CostCenter: "cc1", Active: true}, code)
 			},
-			wantAnnotations: map[string]string{
-				"some-key": "some-value",
+		},
+		"Should fail if the appcode exists": {
+			code:     &types.AppCode{Name: "0001"},
+			wantCode: apierr.CodeConflict,
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				repofs := fs.Create(memfs.New())
+				_ = repofs.WriteYamls(filepath.Join(store.Default.AppCodesDir, "0001.yaml"), &types.AppCode{Name: "0001"})
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).Times(0)
+				return mockRepo, repofs, nil
 			},
 		},
 	}
-	for ttname, tt := range tests {
-		t.Run(ttname, func(t *testing.T) {
-			assert := assert.New(t)
-			gotProject := &argocdv1alpha1.AppProject{}
-			gotAppSet := &argocdv1alpha1.ApplicationSet{}
-			gotClusterResConf := &application.ClusterResConfig{}
-			gotProjectYAML, gotAppSetYAML, _, gotClusterResConfigYAML, _ := generateProjectManifests(tt.o)
-			assert.NoError(yaml.Unmarshal(gotProjectYAML, gotProject))
-			assert.NoError(yaml.Unmarshal(gotAppSetYAML, gotAppSet))
-			assert.NoError(yaml.Unmarshal(gotClusterResConfigYAML, gotClusterResConf))
-
-			assert.Equal(tt.wantContextName, gotClusterResConf.Name)
-			assert.Equal(tt.wantDefaultDestServer, gotClusterResConf.Server)
-
-			assert.Equal(tt.wantName, gotProject.Name, "Project Name")
-			assert.Equal(tt.wantNamespace, gotProject.Namespace, "Project Namespace")
-			assert.Equal(tt.wantProjectDescription, gotProject.Spec.Description, "Project Description")
-			assert.Equal(tt.o.DefaultDestServer, gotProject.Annotations[store.Default.DestServerAnnotation], "Application Set Default Destination Server")
+	origPrepareRepo := prepareRepo
+	defer func() { prepareRepo = origPrepareRepo }()
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var repofs fs.FS
+			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
+				var (
+					repo git.Repository
+					err  error
+				)
+				repo, repofs, err = tt.prepareRepo(t)
+				return repo, repofs, err
+			}
 
-			assert.Equal(tt.wantName, gotAppSet.Name, "Application Set Name")
-			assert.Equal(tt.wantNamespace, gotAppSet.Namespace, "Application Set Namespace")
-			assert.Equal(tt.wantRepoURL, gotAppSet.Spec.Generators[0].Git.RepoURL, "Application Set Repo URL")
-			assert.Equal(tt.wantRevision, gotAppSet.Spec.Generators[0].Git.Revision, "Application Set Revision")
+			err := (&NativeRepoTarget{}).AppCodeCreate(context.Background(), tt.code)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apierr.CodeOf(err))
+				return
+			}
 
-			assert.Equal(tt.wantNamespace, gotAppSet.Spec.Template.Namespace, "Application Set Template Namespace")
-			assert.Equal(tt.wantName, gotAppSet.Spec.Template.Spec.Project, "Application Set Template Project")
+			assert.NoError(t, err)
+			if tt.assertFn != nil {
+				tt.assertFn(t, repofs)
+			}
 		})
 	}
 }
 
-func Test_getInstallationNamespace(t *testing.T) {
+func TestAppCodeUpdate(t *testing.T) {
+	inactive := false
+	team := "team2"
 	tests := map[string]struct {
-		beforeFn func(*testing.T) fs.FS
-		want     string
-		wantErr  string
+		name        string
+		req         *types.AppCodeUpdateRequest
+		wantCode    apierr.Code
+		prepareRepo func(*testing.T) (git.Repository, fs.FS, error)
+		assertFn    func(t *testing.T, code *types.AppCode)
 	}{
-		"should return the namespace from namespace.yaml": {
-			beforeFn: func(*testing.T) fs.FS {
-				namespace := &argocdv1alpha1.Application{
-					Spec: argocdv1alpha1.ApplicationSpec{
-						Destination: argocdv1alpha1.ApplicationDestination{
-							Namespace: "namespace",
-						},
-					},
-				}
+		"Should deactivate the appcode and keep the other fields": {
+			name: "0001",
+			req:  &types.AppCodeUpdateRequest{Active: &inactive, OwnerTeam: &team, UpdatedBy: "admin"},
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
 				repofs := fs.Create(memfs.New())
-				_ = repofs.WriteYamls(filepath.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName+".yaml"), namespace)
-				return repofs
-			},
-			want: "namespace",
-		},
-		"should handle file not found": {
-			beforeFn: func(*testing.T) fs.FS {
-				return fs.Create(memfs.New())
+				_ = repofs.WriteYamls(filepath.Join(store.Default.AppCodesDir, "0001.yaml"), &types.AppCode{
+					Name: "0001", OwnerTeam: "team1", CostCenter: "cc1", Active: true,
+				})
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
+					CommitMsg: "chore: updated appcode '0001'",
+				}).Return("revision", nil)
+				return mockRepo, repofs, nil
 			},
-			wantErr: "failed to unmarshal namespace: file does not exist",
-		},
-		"should handle error during read": {
-			beforeFn: func(t *testing.T) fs.FS {
-				mfs := fsmocks.NewMockFS(gomock.NewController(t))
-				mfs.EXPECT().Join(gomock.Any()).
-					Times(1).
-					DoAndReturn(func(elem ...string) string {
-						return strings.Join(elem, "/")
-					})
-				mfs.EXPECT().ReadYamls(filepath.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName+".yaml"), gomock.Any()).
-					Times(1).
-					Return(fmt.Errorf("some error"))
-				return mfs
+			assertFn: func(t *testing.T, code *types.AppCode) {
+				assert.False(t, code.Active)
+				assert.Equal(t, "team2", code.OwnerTeam)
+				assert.Equal(t, "cc1", code.CostCenter)
+				assert.Equal(t, "admin", code.UpdatedBy)
+				assert.NotNil(t, code.UpdatedAt)
 			},
-			wantErr: "failed to unmarshal namespace: some error",
 		},
-		"should handle corrupted namespace.yaml file": {
-			beforeFn: func(*testing.T) fs.FS {
-				repofs := fs.Create(memfs.New())
-				_ = billyUtils.WriteFile(repofs, filepath.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName+".yaml"), []byte("some string"), 0666)
-				return repofs
+		"Should fail if the appcode does not exist": {
+			name:     "0002",
+			req:      &types.AppCodeUpdateRequest{Active: &inactive},
+			wantCode: apierr.CodeNotFound,
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).Times(0)
+				return mockRepo, fs.Create(memfs.New()), nil
 			},
-			wantErr: "failed to unmarshal namespace: error unmarshaling JSON: json: cannot unmarshal string into Go value of type v1alpha1.Application",
 		},
 	}
-	for ttName, tt := range tests {
-		t.Run(ttName, func(t *testing.T) {
-			repofs := tt.beforeFn(t)
-			got, err := getInstallationNamespace(repofs)
-			if err != nil || tt.wantErr != "" {
-				assert.EqualError(t, err, tt.wantErr)
+	origPrepareRepo := prepareRepo
+	defer func() { prepareRepo = origPrepareRepo }()
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
+				return tt.prepareRepo(t)
+			}
+
+			code, err := (&NativeRepoTarget{}).AppCodeUpdate(context.Background(), tt.name, tt.req)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apierr.CodeOf(err))
 				return
 			}
 
-			if got != tt.want {
-				t.Errorf("getInstallationNamespace() = %v, want %v", got, tt.want)
+			assert.NoError(t, err)
+			if tt.assertFn != nil {
+				tt.assertFn(t, code)
 			}
 		})
 	}
 }
 
-func Test_getProjectInfoFromFile(t *testing.T) {
+func TestChangeRequestCreate(t *testing.T) {
+	cr := &types.ChangeRequest{
+		ID:          "3f2a",
+		Tenant:      "project",
+		Application: "app",
+		Operation:   "rollback",
+		Environment: "PRD",
+		Payload:     json.RawMessage(`{"revision":"3992c4a"}`),
+		RequestedBy: "bob",
+		Status:      types.ChangeRequestPending,
+		Required:    2,
+	}
 	tests := map[string]struct {
-		name     string
-		want     *argocdv1alpha1.AppProject
-		wantErr  string
-		beforeFn func(repofs fs.FS)
+		wantCode    apierr.Code
+		prepareRepo func(*testing.T) (git.Repository, fs.FS, error)
+		assertFn    func(t *testing.T, repofs fs.FS)
 	}{
-		"should return error if project file doesn't exist": {
-			name:    "prod.yaml",
-			wantErr: os.ErrNotExist.Error(),
-		},
-		"should failed when 2 files not found": {
-			name:    "prod.yaml",
-			wantErr: "expected at least 2 manifests when reading 'prod.yaml'",
-			beforeFn: func(repofs fs.FS) {
-				_ = billyUtils.WriteFile(repofs, "prod.yaml", []byte("content"), 0666)
+		"Should write the change request to the meta repo": {
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
+					CommitMsg: "chore: added change request '3f2a' to rollback app 'app' on project 'project'",
+					Direct:    true,
+				}).Return("revision", nil)
+				return mockRepo, fs.Create(memfs.New()), nil
 			},
-		},
-		"should return AppProject": {
-			name: "prod.yaml",
-			beforeFn: func(repofs fs.FS) {
-				appProj := argocdv1alpha1.AppProject{
-					ObjectMeta: v1.ObjectMeta{
-						Name:      "prod",
-						Namespace: "ns",
-					},
-				}
-				appSet := argocdv1alpha1.ApplicationSet{}
-				_ = repofs.WriteYamls("prod.yaml", appProj, appSet)
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				got, err := readChangeRequest(repofs, "project", "3f2a")
+				assert.NoError(t, err)
+				assert.Equal(t, cr, got)
 			},
-			want: &argocdv1alpha1.AppProject{
-				ObjectMeta: v1.ObjectMeta{
-					Name:      "prod",
-					Namespace: "ns",
-				},
+		},
+		"Should fail if the change request exists": {
+			wantCode: apierr.CodeConflict,
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				repofs := fs.Create(memfs.New())
+				_ = repofs.WriteYamls(filepath.Join(store.Default.ChangeRequestsDir, "project", "3f2a.yaml"), cr)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).Times(0)
+				return mockRepo, repofs, nil
 			},
 		},
 	}
-	for tName, tt := range tests {
-		t.Run(tName, func(t *testing.T) {
-			repofs := fs.Create(memfs.New())
-			if tt.beforeFn != nil {
-				tt.beforeFn(repofs)
+	origPrepareRepo := prepareRepo
+	defer func() { prepareRepo = origPrepareRepo }()
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var repofs fs.FS
+			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
+				var (
+					repo git.Repository
+					err  error
+				)
+				repo, repofs, err = tt.prepareRepo(t)
+				return repo, repofs, err
 			}
 
-			got, _, err := getProjectInfoFromFile(repofs, tt.name)
-			if err != nil || tt.wantErr != "" {
-				assert.EqualError(t, err, tt.wantErr)
+			err := (&NativeRepoTarget{}).ChangeRequestCreate(context.Background(), cr)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apierr.CodeOf(err))
 				return
 			}
 
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("getProjectInfoFromFile() = %v, want %v", got, tt.want)
+			assert.NoError(t, err)
+			if tt.assertFn != nil {
+				tt.assertFn(t, repofs)
 			}
 		})
 	}
 }
 
-func TestRunProjectList(t *testing.T) {
+func TestChangeRequestUpdate(t *testing.T) {
 	tests := map[string]struct {
-		repoWriter  NativeRepoTarget
+		cr          *types.ChangeRequest
+		wantCode    apierr.Code
 		prepareRepo func(*testing.T) (git.Repository, fs.FS, error)
-		wantErr     string
-		assertFn    func(t *testing.T, projects []types.TenantInfo)
+		assertFn    func(t *testing.T, repofs fs.FS)
 	}{
-		"should handle failure in prepare repo": {
-			repoWriter: NativeRepoTarget{
-				metaRepoCloneOpts: &git.CloneOptions{
-					Repo: "http://server.org/org/name",
-				},
+		"Should write the decisions of the change request": {
+			cr: &types.ChangeRequest{
+				ID: "3f2a", Tenant: "project", Status: types.ChangeRequestApproved, Required: 1,
+				Decisions: []types.Decision{{Approver: "alice", Decision: types.DecisionApprove, Comment: "lgtm"}},
 			},
-			prepareRepo: func(*testing.T) (git.Repository, fs.FS, error) {
-				return nil, nil, fmt.Errorf("failure clone")
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				repofs := fs.Create(memfs.New())
+				_ = repofs.WriteYamls(filepath.Join(store.Default.ChangeRequestsDir, "project", "3f2a.yaml"), &types.ChangeRequest{
+					ID: "3f2a", Tenant: "project", Status: types.ChangeRequestPending, Required: 1,
+				})
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
+					CommitMsg: "chore: updated change request '3f2a' on project 'project' to 'approved'",
+					Direct:    true,
+				}).Return("revision", nil)
+				return mockRepo, repofs, nil
 			},
-			wantErr: "failure clone",
-		},
-		"should list projects": {
-			repoWriter: NativeRepoTarget{
-				metaRepoCloneOpts: &git.CloneOptions{
-					Repo: "http://server.org/org/name",
-				},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				got, err := readChangeRequest(repofs, "project", "3f2a")
+				assert.NoError(t, err)
+				assert.Equal(t, types.ChangeRequestApproved, got.Status)
+				assert.Equal(t, 1, got.Approvals())
 			},
+		},
+		"Should fail if the change request does not exist": {
+			cr:       &types.ChangeRequest{ID: "3f2a", Tenant: "other"},
+			wantCode: apierr.CodeNotFound,
 			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
-				memfs := memfs.New()
-				appProj := argocdv1alpha1.AppProject{
-					ObjectMeta: v1.ObjectMeta{
-						Name:      "prod",
-						Namespace: "ns",
-						Annotations: map[string]string{
-							store.Default.DestServerAnnotation: "https://cluster.example.com",
-						},
-					},
-				}
-				appSet := argocdv1alpha1.ApplicationSet{
-					ObjectMeta: v1.ObjectMeta{
-						Name: "prod",
-					},
-					Spec: argocdv1alpha1.ApplicationSetSpec{
-						Generators: []argocdv1alpha1.ApplicationSetGenerator{
-							{
-								Git: &argocdv1alpha1.GitGenerator{
-									RepoURL: "https://example.org/org/name.git",
-								},
-							},
-						},
-					},
-				}
-				repofs := fs.Create(memfs)
-				_ = repofs.WriteYamls("projects/prod.yaml", appProj, appSet)
-				return nil, repofs, nil
-			},
-			assertFn: func(t *testing.T, projects []types.TenantInfo) {
-				assert.Len(t, projects, 1)
-				assert.Equal(t, "prod", projects[0].Name)
-				assert.Equal(t, "ns", projects[0].Namespace)
-				assert.Equal(t, "https://cluster.example.com", projects[0].DefaultCluster)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).Times(0)
+				return mockRepo, fs.Create(memfs.New()), nil
 			},
 		},
 	}
-
 	origPrepareRepo := prepareRepo
 	defer func() { prepareRepo = origPrepareRepo }()
-
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			var (
-				repo   git.Repository
-				repofs fs.FS
-			)
-
+			var repofs fs.FS
 			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
-				var err error
+				var (
+					repo git.Repository
+					err  error
+				)
 				repo, repofs, err = tt.prepareRepo(t)
 				return repo, repofs, err
 			}
 
-			projects, err := tt.repoWriter.RunProjectList(context.Background())
-			if err != nil || tt.wantErr != "" {
-				assert.EqualError(t, err, tt.wantErr)
+			err := (&NativeRepoTarget{}).ChangeRequestUpdate(context.Background(), tt.cr)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apierr.CodeOf(err))
 				return
 			}
 
+			assert.NoError(t, err)
 			if tt.assertFn != nil {
-				tt.assertFn(t, projects)
+				tt.assertFn(t, repofs)
 			}
 		})
 	}
 }
 
-func TestRunProjectDelete(t *testing.T) {
+func TestFreezeWindowCreate(t *testing.T) {
+	w := &types.FreezeWindow{
+		Name: "prd-weekend",
+		FreezeWindowSpec: types.FreezeWindowSpec{
+			Schedule:     "0 18 * * 5",
+			Duration:     "60h",
+			Environments: []string{"PRD"},
+		},
+		CreatedBy: "alice",
+		CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+	clusters := []types.FreezeCluster{
+		{Name: "sit-1", Server: "https://sit-1:6443", Environment: "SIT"},
+		{Name: "prd-1", Server: "https://prd-1:6443", Environment: "PRD"},
+	}
 	tests := map[string]struct {
-		repoWriter            NativeRepoTarget
-		projectNameWantDelete string
-		wantErr               string
-		prepareRepo           func(*testing.T) (git.Repository, fs.FS, error)
-		assertFn              func(t *testing.T, repo git.Repository, repofs fs.FS)
+		wantCode    apierr.Code
+		prepareRepo func(*testing.T) (git.Repository, fs.FS, error)
+		assertFn    func(t *testing.T, repofs fs.FS)
 	}{
-		"Should fail when clone fails": {
-			repoWriter: NativeRepoTarget{
-				project: "admin",
-				metaRepoCloneOpts: &git.CloneOptions{
-					Repo: "https://github.com/owner/name",
-					Auth: git.Auth{
-						Password: "password",
-					},
-				},
-			},
-			projectNameWantDelete: "project",
-			wantErr:               "some error",
-			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
-				return nil, nil, fmt.Errorf("some error")
-			},
-		},
-		"Should fail when failed to delete project.yaml file": {
-			repoWriter: NativeRepoTarget{
-				project: "admin",
-				metaRepoCloneOpts: &git.CloneOptions{
-					Repo: "https://github.com/owner/name",
-					Auth: git.Auth{
-						Password: "password",
-					},
-				},
-			},
-			projectNameWantDelete: "project",
-			wantErr:               "failed to delete project 'project': " + os.ErrNotExist.Error(),
+		"Should write the freeze window and the sync windows of the projects": {
 			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
-				memfs := memfs.New()
-				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project"), 0666)
+				repofs := fs.Create(memfs.New())
+				projectYAML, appsetYAML, _, _, err := generateProjectManifests(&types.GenerateProjectOptions{
+					Name:      "project",
+					Namespace: "argocd",
+				})
+				assert.NoError(t, err)
+				_ = billyUtils.WriteFile(repofs, filepath.Join(store.Default.ProjectsDir, "project.yaml"), util.JoinManifests(projectYAML, appsetYAML), 0666)
+
 				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
 				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
-					CommitMsg: "chore: deleted project 'project'",
-				}).Times(0)
-				return mockRepo, fs.Create(memfs), nil
+					CommitMsg: "chore: added freeze window 'prd-weekend'",
+				}).Return("revision", nil)
+				return mockRepo, repofs, nil
 			},
-		},
-		"Should fail when persist fails": {
-			repoWriter: NativeRepoTarget{
-				project: "admin",
-				metaRepoCloneOpts: &git.CloneOptions{
-					Repo: "https://github.com/owner/name",
-					Auth: git.Auth{
-						Password: "password",
-					},
-				},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				got, err := readFreezeWindow(repofs, "prd-weekend")
+				assert.NoError(t, err)
+				assert.Equal(t, w, got)
+
+				proj, appset, err := getProjectInfoFromFile(repofs, filepath.Join(store.Default.ProjectsDir, "project.yaml"))
+				assert.NoError(t, err)
+				assert.Equal(t, "project", appset.Name)
+				assert.Equal(t, argocdv1alpha1.SyncWindows{{
+					Kind:       "deny",
+					Schedule:   "0 18 * * 5",
+					Duration:   "60h",
+					Clusters:   []string{"prd-1", "https://prd-1:6443"},
+					ManualSync: true,
+					TimeZone:   "UTC",
+				}}, proj.Spec.SyncWindows)
 			},
-			projectNameWantDelete: "project",
-			wantErr:               "failed to push to repo: some error",
+		},
+		"Should fail if the freeze window exists": {
+			wantCode: apierr.CodeConflict,
 			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
-				memfs := memfs.New()
-				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project"), 0666)
-				_ = billyUtils.WriteFile(memfs, filepath.Join(store.Default.ProjectsDir, "project.yaml"), []byte{}, 0666)
+				repofs := fs.Create(memfs.New())
+				_ = repofs.WriteYamls(filepath.Join(store.Default.FreezeWindowsDir, "prd-weekend.yaml"), w)
 				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
-				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
-					CommitMsg: "chore: deleted project 'project'",
-				}).Return("", fmt.Errorf("some error"))
-				return mockRepo, fs.Create(memfs), nil
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).Times(0)
+				return mockRepo, repofs, nil
 			},
 		},
-		"Should remove entire app folder, if it contains only one overlay": {
-			repoWriter: NativeRepoTarget{
-				project: "admin",
-				metaRepoCloneOpts: &git.CloneOptions{
-					Repo: "https://github.com/owner/name",
-					Auth: git.Auth{
-						Password: "password",
-					},
-				},
-			},
-			projectNameWantDelete: "project",
+	}
+	origPrepareRepo := prepareRepo
+	defer func() { prepareRepo = origPrepareRepo }()
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var repofs fs.FS
+			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
+				var (
+					repo git.Repository
+					err  error
+				)
+				repo, repofs, err = tt.prepareRepo(t)
+				return repo, repofs, err
+			}
+
+			err := (&NativeRepoTarget{}).FreezeWindowCreate(context.Background(), w, clusters)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apierr.CodeOf(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			if tt.assertFn != nil {
+				tt.assertFn(t, repofs)
+			}
+		})
+	}
+}
+
+func TestFreezeWindowDelete(t *testing.T) {
+	tests := map[string]struct {
+		name        string
+		wantCode    apierr.Code
+		prepareRepo func(*testing.T) (git.Repository, fs.FS, error)
+		assertFn    func(t *testing.T, repofs fs.FS)
+	}{
+		"Should remove the freeze window and the sync windows of the projects": {
+			name: "holidays",
 			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
-				memfs := memfs.New()
-				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project"), 0666)
-				_ = billyUtils.WriteFile(memfs, filepath.Join(store.Default.ProjectsDir, "project.yaml"), []byte{}, 0666)
+				repofs := fs.Create(memfs.New())
+				projectYAML, appsetYAML, _, _, err := generateProjectManifests(&types.GenerateProjectOptions{
+					Name:        "project",
+					Namespace:   "argocd",
+					SyncWindows: argocdv1alpha1.SyncWindows{{Kind: "deny", Schedule: "0 0 * * *", Duration: "1h", Clusters: []string{"*"}}},
+				})
+				assert.NoError(t, err)
+				_ = billyUtils.WriteFile(repofs, filepath.Join(store.Default.ProjectsDir, "project.yaml"), util.JoinManifests(projectYAML, appsetYAML), 0666)
+				_ = repofs.WriteYamls(filepath.Join(store.Default.FreezeWindowsDir, "holidays.yaml"), &types.FreezeWindow{Name: "holidays"})
+
 				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
 				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
-					CommitMsg: "chore: deleted project 'project'",
+					CommitMsg: "chore: deleted freeze window 'holidays'",
 				}).Return("revision", nil)
-				return mockRepo, fs.Create(memfs), nil
+				return mockRepo, repofs, nil
 			},
-			assertFn: func(t *testing.T, _ git.Repository, repofs fs.FS) {
-				assert.False(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app1")))
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				assert.False(t, repofs.ExistsOrDie(filepath.Join(store.Default.FreezeWindowsDir, "holidays.yaml")))
+
+				proj, _, err := getProjectInfoFromFile(repofs, filepath.Join(store.Default.ProjectsDir, "project.yaml"))
+				assert.NoError(t, err)
+				assert.Empty(t, proj.Spec.SyncWindows)
 			},
 		},
-		"Should remove only overlay, if app contains more overlays": {
-			repoWriter: NativeRepoTarget{
-				project: "admin",
-				metaRepoCloneOpts: &git.CloneOptions{
-					Repo: "https://github.com/owner/name",
-					Auth: git.Auth{
-						Password: "password",
-					},
-				},
-			},
-			projectNameWantDelete: "project",
+		"Should fail if the freeze window does not exist": {
+			name:     "holidays",
+			wantCode: apierr.CodeNotFound,
 			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
-				memfs := memfs.New()
-				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project"), 0666)
-				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project2"), 0666)
-				_ = billyUtils.WriteFile(memfs, filepath.Join(store.Default.ProjectsDir, "project.yaml"), []byte{}, 0666)
 				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
-				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
-					CommitMsg: "chore: deleted project 'project'",
-				}).Return("revision", nil)
-				return mockRepo, fs.Create(memfs), nil
-			},
-			assertFn: func(t *testing.T, _ git.Repository, repofs fs.FS) {
-				assert.True(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir)))
-				assert.False(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project")))
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).Times(0)
+				return mockRepo, fs.Create(memfs.New()), nil
 			},
 		},
-		"Should remove directory apps": {
-			repoWriter: NativeRepoTarget{
-				project: "admin",
-				metaRepoCloneOpts: &git.CloneOptions{
-					Repo: "https://github.com/owner/name",
-					Auth: git.Auth{
-						Password: "password",
-					},
-				},
-			},
-			projectNameWantDelete: "project",
+	}
+	origPrepareRepo := prepareRepo
+	defer func() { prepareRepo = origPrepareRepo }()
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var repofs fs.FS
+			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
+				var (
+					repo git.Repository
+					err  error
+				)
+				repo, repofs, err = tt.prepareRepo(t)
+				return repo, repofs, err
+			}
+
+			err := (&NativeRepoTarget{}).FreezeWindowDelete(context.Background(), tt.name, nil)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apierr.CodeOf(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			if tt.assertFn != nil {
+				tt.assertFn(t, repofs)
+			}
+		})
+	}
+}
+
+func TestFreezeWindowSync(t *testing.T) {
+	weekend := &types.FreezeWindow{
+		Name:             "prd-weekend",
+		FreezeWindowSpec: types.FreezeWindowSpec{Schedule: "0 18 * * 5", Duration: "60h", Environments: []string{"PRD"}},
+	}
+	start, end := time.Now().Add(-48*time.Hour), time.Now().Add(-24*time.Hour)
+	ended := &types.FreezeWindow{
+		Name:             "ended",
+		FreezeWindowSpec: types.FreezeWindowSpec{Start: &start, End: &end},
+	}
+	prd := argocdv1alpha1.SyncWindows{{
+		Kind:       "deny",
+		Schedule:   "0 18 * * 5",
+		Duration:   "60h",
+		Clusters:   []string{"prd-1", "https://prd-1:6443"},
+		ManualSync: true,
+		TimeZone:   "UTC",
+	}}
+	writeProject := func(t *testing.T, repofs fs.FS, syncWindows argocdv1alpha1.SyncWindows) {
+		projectYAML, appsetYAML, _, _, err := generateProjectManifests(&types.GenerateProjectOptions{
+			Name:        "project",
+			Namespace:   "argocd",
+			SyncWindows: syncWindows,
+		})
+		assert.NoError(t, err)
+		_ = billyUtils.WriteFile(repofs, filepath.Join(store.Default.ProjectsDir, "project.yaml"), util.JoinManifests(projectYAML, appsetYAML), 0666)
+	}
+	tests := map[string]struct {
+		clusters    []types.FreezeCluster
+		prepareRepo func(*testing.T) (git.Repository, fs.FS, error)
+		want        argocdv1alpha1.SyncWindows
+	}{
+		"Should scope the sync windows to a cluster registered after the freeze window": {
+			clusters: []types.FreezeCluster{{Name: "prd-1", Server: "https://prd-1:6443", Environment: "PRD"}},
 			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
-				memfs := memfs.New()
-				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app1", "project"), 0666)
-				_ = billyUtils.WriteFile(memfs, filepath.Join(store.Default.ProjectsDir, "project.yaml"), []byte{}, 0666)
+				repofs := fs.Create(memfs.New())
+				writeProject(t, repofs, nil)
+				_ = repofs.WriteYamls(filepath.Join(store.Default.FreezeWindowsDir, "prd-weekend.yaml"), weekend)
+
 				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
 				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
-					CommitMsg: "chore: deleted project 'project'",
+					CommitMsg: "chore: updated the sync windows of the freeze windows",
 				}).Return("revision", nil)
-				return mockRepo, fs.Create(memfs), nil
-			},
-			assertFn: func(t *testing.T, _ git.Repository, repofs fs.FS) {
-				assert.False(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app1")))
+				return mockRepo, repofs, nil
 			},
+			want: prd,
 		},
-		"Should handle multiple apps": {
-			repoWriter: NativeRepoTarget{
-				project: "admin",
-				metaRepoCloneOpts: &git.CloneOptions{
-					Repo: "https://github.com/owner/name",
-					Auth: git.Auth{
-						Password: "password",
-					},
-				},
-			},
-			projectNameWantDelete: "project",
+		"Should drop the sync window of a range that has ended": {
 			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
-				memfs := memfs.New()
-				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project"), 0666)
-				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project2"), 0666)
-				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app2", store.Default.OverlaysDir, "project"), 0666)
-				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app3", "project"), 0666)
-				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app4", "project"), 0666)
-				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app4", "project3"), 0666)
-				_ = billyUtils.WriteFile(memfs, filepath.Join(store.Default.ProjectsDir, "project.yaml"), []byte{}, 0666)
+				repofs := fs.Create(memfs.New())
+				writeProject(t, repofs, argocdv1alpha1.SyncWindows{{Kind: "deny", Schedule: "0 0 * * *", Duration: "24h", Clusters: []string{"*"}}})
+				_ = repofs.WriteYamls(filepath.Join(store.Default.FreezeWindowsDir, "ended.yaml"), ended)
+
 				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
 				mockRepo.EXPECT().Persist(context.Background(), &git.PushOptions{
-					CommitMsg: "chore: deleted project 'project'",
+					CommitMsg: "chore: updated the sync windows of the freeze windows",
 				}).Return("revision", nil)
-				return mockRepo, fs.Create(memfs), nil
+				return mockRepo, repofs, nil
 			},
-			assertFn: func(t *testing.T, _ git.Repository, repofs fs.FS) {
-				assert.True(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir)))
-				assert.False(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app1", store.Default.OverlaysDir, "project")))
-				assert.False(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app2")))
-				assert.False(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app3")))
-				assert.True(t, repofs.ExistsOrDie(filepath.Join(store.Default.AppsDir, "app4")))
+		},
+		"Should not commit when the sync windows are up to date": {
+			clusters: []types.FreezeCluster{{Name: "prd-1", Server: "https://prd-1:6443", Environment: "PRD"}},
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				repofs := fs.Create(memfs.New())
+				writeProject(t, repofs, prd)
+				_ = repofs.WriteYamls(filepath.Join(store.Default.FreezeWindowsDir, "prd-weekend.yaml"), weekend)
+
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).Times(0)
+				return mockRepo, repofs, nil
 			},
+			want: prd,
 		},
 	}
 	origPrepareRepo := prepareRepo
 	defer func() { prepareRepo = origPrepareRepo }()
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			var (
-				repo   git.Repository
-				repofs fs.FS
-			)
-
+			var repofs fs.FS
 			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
-				var err error
+				var (
+					repo git.Repository
+					err  error
+				)
 				repo, repofs, err = tt.prepareRepo(t)
 				return repo, repofs, err
 			}
-			tt.repoWriter.metaRepoCloneOpts.Parse()
 
-			if err := tt.repoWriter.RunProjectDelete(context.Background(), tt.projectNameWantDelete); err != nil || tt.wantErr != "" {
-				assert.EqualError(t, err, tt.wantErr)
-				return
-			}
+			err := (&NativeRepoTarget{}).FreezeWindowSync(context.Background(), tt.clusters)
+			assert.NoError(t, err)
 
-			if tt.assertFn != nil {
-				tt.assertFn(t, repo, repofs)
-			}
+			proj, _, err := getProjectInfoFromFile(repofs, filepath.Join(store.Default.ProjectsDir, "project.yaml"))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, proj.Spec.SyncWindows)
 		})
 	}
 }
@@ -34,6 +34,30 @@ func (e *errorRepoWriter) RunAppList(ctx context.Context) ([]types.Application,
 	return nil, e.err
 }
 
+func (e *errorRepoWriter) RunAppHistory(ctx context.Context, name string, limit int) ([]types.ApplicationRevision, error) {
+	return nil, e.err
+}
+
+func (e *errorRepoWriter) RunAppRollback(ctx context.Context, name, revision string) (string, error) {
+	return "", e.err
+}
+
+func (e *errorRepoWriter) RunAppTargetManifests(ctx context.Context, name string, envs map[string]string) (map[string][]byte, error) {
+	return nil, e.err
+}
+
+func (e *errorRepoWriter) RunAppPromote(ctx context.Context, opts *types.AppPromoteOptions) (string, error) {
+	return "", e.err
+}
+
+func (e *errorRepoWriter) RunAppUnpin(ctx context.Context, opts *types.AppUnpinOptions) (string, error) {
+	return "", e.err
+}
+
+func (e *errorRepoWriter) RunIngressHostList(ctx context.Context) ([]types.IngressHost, error) {
+	return nil, e.err
+}
+
 func (e *errorRepoWriter) SecretStoreCreate(ctx context.Context, ss *esv1beta1.SecretStore, force bool) error {
 	return e.err
 }
@@ -3,12 +3,14 @@ package writer
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sync"
 
 	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/spf13/viper"
 
+	"github.com/squidflow/service/pkg/apierr"
 	"github.com/squidflow/service/pkg/application"
 	"github.com/squidflow/service/pkg/fs"
 	"github.com/squidflow/service/pkg/git"
@@ -19,6 +21,7 @@ import (
 var (
 	metarepo    MetaRepoWriter
 	tenantRepos sync.Map // key: tenant name, value: TenantRepoWriter
+	tenantURLs  sync.Map // key: tenant name, value: gitops repo url of the tenant
 	once        sync.Once
 	initErr     error
 )
@@ -191,27 +194,67 @@ func BuildTenantRepo() error {
 			return nil
 		}
 		log.G().WithField("tenant", tenant.Name).Debug("stored tenant repo writer")
-		tenantRepos.Store(tenant.Name, tenantRepoWriter)
+		StoreTenantRepo(tenant, tenantRepoWriter)
 	}
 	return nil
 }
 
+// StoreTenantRepo stores the writer of the tenant and the url of its gitops repo
+func StoreTenantRepo(tenant types.TenantInfo, w TenantRepoWriter) {
+	tenantRepos.Store(tenant.Name, w)
+	tenantURLs.Store(tenant.Name, tenant.GitOpsRepo)
+}
+
 // TenantRepo removes the TenantRepoWriter for the given tenant
 func TenantRepo(name string) TenantRepoWriter {
 	tenantRepo, ok := tenantRepos.Load(name)
 	if !ok {
 		// return a special RepoTarget, its all methods will return tenant not found error
 		log.G().WithField("tenant", name).Warn("tenant not found, return error repo writer")
-		return &errorRepoWriter{err: fmt.Errorf("tenant '%s' not found", name)}
+		return &errorRepoWriter{err: apierr.NotFound("tenant '%s' not found", name)}
 	}
 	return tenantRepo.(TenantRepoWriter)
 }
 
+// ApplicationWriters returns the writer of the meta repo and the writers of the tenants,
+// once for the tenants that share a repository
+func ApplicationWriters() []ApplicationWriter {
+	writers := []ApplicationWriter{MetaRepo()}
+	tenantRepos.Range(func(_, value interface{}) bool {
+		w, ok := value.(TenantRepoWriter)
+		if ok && w != nil && !slices.Contains(writers, ApplicationWriter(w)) {
+			writers = append(writers, w)
+		}
+		return true
+	})
+
+	return writers
+}
+
+// RepoURL returns the gitops repo url that the writer of the tenant pushes to,
+// it is the meta repo url for tenants without a gitops repo of their own
+func RepoURL(tenant string) string {
+	if url, ok := tenantURLs.Load(tenant); ok && url.(string) != "" {
+		return url.(string)
+	}
+
+	return MetaRepoURL()
+}
+
+// MetaRepoURL returns the url of the meta repo, tenants, appcodes, freeze windows and change
+// requests are written to it
+func MetaRepoURL() string {
+	return viper.GetString("application_repo.remote_url")
+}
+
 // MetaRepoWriter defines how to interact with a GitOps repository
 type MetaRepoWriter interface {
 	ApplicationWriter
 	ProjectWriter
 	SecretStoreWriter
+	AppCodeWriter
+	ChangeRequestWriter
+	FreezeWindowWriter
 }
 
 // TenantRepoWriter is a repo writer for tenant
@@ -226,6 +269,12 @@ type ApplicationWriter interface {
 	RunAppDelete(ctx context.Context, name string) error
 	RunAppUpdate(ctx context.Context, opts *types.UpdateOptions) error
 	RunAppList(ctx context.Context) ([]types.Application, error)
+	RunAppHistory(ctx context.Context, name string, limit int) ([]types.ApplicationRevision, error)
+	RunAppRollback(ctx context.Context, name, revision string) (string, error)
+	RunAppTargetManifests(ctx context.Context, name string, envs map[string]string) (map[string][]byte, error)
+	RunAppPromote(ctx context.Context, opts *types.AppPromoteOptions) (string, error)
+	RunAppUnpin(ctx context.Context, opts *types.AppUnpinOptions) (string, error)
+	RunIngressHostList(ctx context.Context) ([]types.IngressHost, error)
 }
 
 // ProjectWriter defines how to interact with a GitOps repository
@@ -243,3 +292,30 @@ type SecretStoreWriter interface {
 	SecretStoreGet(ctx context.Context, id string) (*esv1beta1.SecretStore, error)
 	SecretStoreList(ctx context.Context) ([]esv1beta1.SecretStore, error)
 }
+
+// AppCodeWriter manages the appcode registry of the meta repository
+type AppCodeWriter interface {
+	AppCodeCreate(ctx context.Context, code *types.AppCode) error
+	AppCodeGet(ctx context.Context, name string) (*types.AppCode, error)
+	AppCodeList(ctx context.Context) ([]types.AppCode, error)
+	AppCodeUpdate(ctx context.Context, name string, req *types.AppCodeUpdateRequest) (*types.AppCode, error)
+}
+
+// ChangeRequestWriter manages the change requests of the tenants that wait for approvals in the meta repository
+type ChangeRequestWriter interface {
+	ChangeRequestCreate(ctx context.Context, cr *types.ChangeRequest) error
+	ChangeRequestGet(ctx context.Context, tenant, id string) (*types.ChangeRequest, error)
+	ChangeRequestList(ctx context.Context, tenant string) ([]types.ChangeRequest, error)
+	ChangeRequestUpdate(ctx context.Context, cr *types.ChangeRequest) error
+}
+
+// FreezeWindowWriter manages the freeze windows of the meta repository. Every change of the freeze windows
+// writes them as the sync windows of the AppProjects of the tenants, scoped to the clusters
+type FreezeWindowWriter interface {
+	FreezeWindowCreate(ctx context.Context, w *types.FreezeWindow, clusters []types.FreezeCluster) error
+	FreezeWindowGet(ctx context.Context, name string) (*types.FreezeWindow, error)
+	FreezeWindowList(ctx context.Context) ([]types.FreezeWindow, error)
+	FreezeWindowUpdate(ctx context.Context, w *types.FreezeWindow, clusters []types.FreezeCluster) (*types.FreezeWindow, error)
+	FreezeWindowDelete(ctx context.Context, name string, clusters []types.FreezeCluster) error
+	FreezeWindowSync(ctx context.Context, clusters []types.FreezeCluster) error
+}
@@ -2,16 +2,16 @@ package writer
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 	"time"
 
 	argocdv1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-billy/v5/osfs"
-	"k8s.io/client-go/kubernetes"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/squidflow/service/pkg/application"
 	"github.com/squidflow/service/pkg/argocd"
@@ -21,6 +21,7 @@ import (
 	"github.com/squidflow/service/pkg/log"
 	reader "github.com/squidflow/service/pkg/source"
 	"github.com/squidflow/service/pkg/store"
+	"github.com/squidflow/service/pkg/tracing"
 	"github.com/squidflow/service/pkg/types"
 	"github.com/squidflow/service/pkg/util"
 )
@@ -73,11 +74,11 @@ var (
 		}
 
 		if opts.AppOpts.Labels == nil {
-			opts.AppOpts.Labels = opts.AppOpts.Labels
+			opts.AppOpts.Labels = opts.Labels
 		}
 
 		if opts.AppOpts.Annotations == nil {
-			opts.AppOpts.Annotations = opts.AppOpts.Annotations
+			opts.AppOpts.Annotations = opts.Annotations
 		}
 
 		if opts.AppOpts.AppType != "" {
@@ -109,6 +110,76 @@ var (
 		return nil
 	}
 
+	// renderAppManifest clones the application source and renders the manifests of the env
+	// that are written to the app base in flatten installation mode
+	renderAppManifest = func(ctx context.Context, src *types.ApplicationSourceRequest, appName, namespace, env string) (manifest []byte, err error) {
+		ctx, span := tracing.Start(ctx, "source.render",
+			attribute.String("repo", src.Repo),
+			attribute.String("path", src.Path),
+			attribute.String("env", env),
+		)
+		defer func() { tracing.End(span, err) }()
+
+		cloneOpts := &git.CloneOptions{
+			Repo: application.BuildKustomizeResourceRef(application.ApplicationSourceOption{
+				Repo:           src.Repo,
+				Path:           src.Path,
+				TargetRevision: src.TargetRevision,
+			}),
+			FS:         fs.Create(memfs.New()),
+			Submodules: true,
+		}
+		cloneOpts.Parse()
+		_, appfs, err := getRepo(ctx, cloneOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to clone application source repository: %w", err)
+		}
+
+		appSource, err := reader.NewAppSource(appfs, src.Path, src.ApplicationSpecifier.HelmManifestPath)
+		if err != nil {
+			return nil, err
+		}
+
+		// a source without environments renders the same manifests for every env
+		if !slices.Contains(appSource.DetectEnvironments(), env) {
+			env = "default"
+		}
+
+		if appSource.GetType() == reader.AppTypeHelm {
+			return reader.GenerateHelmManifest(appfs, src.Path, "/", env, namespace, appName)
+		}
+
+		return appSource.Manifest(env)
+	}
+
+	// renderRemoteBase clones the remote base of an app, a kustomize resource ref, and renders its manifests of the env
+	renderRemoteBase = func(ctx context.Context, ref, env string) (manifest []byte, err error) {
+		ctx, span := tracing.Start(ctx, "source.render", attribute.String("ref", ref))
+		defer func() { tracing.End(span, err) }()
+
+		cloneOpts := &git.CloneOptions{
+			Repo:       ref,
+			FS:         fs.Create(memfs.New()),
+			Submodules: true,
+		}
+		cloneOpts.Parse()
+		_, appfs, err := getRepo(ctx, cloneOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to clone application source repository: %w", err)
+		}
+
+		appSource, err := reader.NewAppSource(appfs, cloneOpts.Path(), "")
+		if err != nil {
+			return nil, err
+		}
+
+		if !slices.Contains(appSource.DetectEnvironments(), env) {
+			env = "default"
+		}
+
+		return appSource.Manifest(env)
+	}
+
 	parseApp = func(appOpts *application.CreateOptions, projectName, repoURL, targetRevision, repoRoot string) (application.Application, error) {
 		return appOpts.Parse(projectName, repoURL, targetRevision, repoRoot)
 	}
@@ -133,20 +204,25 @@ func genCommitMsg(action types.ActionType, targetResource types.ResourceName, ap
 	return commitMsg
 }
 
-func getConfigFileFromPath(repofs fs.FS, appPath string) (*application.Config, error) {
-	path := repofs.Join(appPath, "config.json")
-	b, err := repofs.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file '%s'", path)
-	}
+func getConfigsFromPath(repofs fs.FS, appPath string) ([]application.Config, error) {
+	return application.ReadConfigs(repofs, repofs.Join(appPath, "config.json"))
+}
 
-	conf := application.Config{}
-	err = json.Unmarshal(b, &conf)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal file '%s'", path)
+// getAppTargets returns the targets of an app from its configs
+func getAppTargets(projectName string, confs []application.Config) []types.ApplicationTarget {
+	targets := make([]types.ApplicationTarget, 0, len(confs))
+	for i := range confs {
+		targets = append(targets, types.ApplicationTarget{
+			Cluster:         application.TargetClusterName(&confs[i]),
+			Namespace:       confs[i].DestNamespace,
+			Server:          confs[i].DestServer,
+			ArgoApplication: fmt.Sprintf("%s-%s", projectName, confs[i].UserGivenName),
+			Revision:        confs[i].SrcTargetRevision,
+			Promotion:       application.PromotionOf(&confs[i]),
+		})
 	}
 
-	return &conf, nil
+	return targets
 }
 
 // TODO: Implement this function later
@@ -159,16 +235,6 @@ func getGitInfo(repofs billy.Filesystem, appPath string) (*types.GitInfo, error)
 	}, nil
 }
 
-// TODO: Implement this function later
-func getResourceMetrics(ctx context.Context, kubeClient kubernetes.Interface, namespace string) (*types.ResourceMetricsInfo, error) {
-	return &types.ResourceMetricsInfo{
-		PodCount:    5,
-		SecretCount: 12,
-		CPU:         "0.25",
-		Memory:      "200Mi",
-	}, nil
-}
-
 var getInstallationNamespace = func(repofs fs.FS) (string, error) {
 	path := repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName+".yaml")
 	a := &argocdv1alpha1.Application{}
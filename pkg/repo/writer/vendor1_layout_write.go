@@ -33,6 +33,9 @@ type Vendor1RepoTarget struct {
 	Vendor1RepoTargetSecretStore
 	Vendor1RepoTargetApp
 	Vendor1RepoTargetProject
+	Vendor1RepoTargetAppCode
+	Vendor1RepoTargetChangeRequest
+	Vendor1RepoTargetFreezeWindow
 }
 type Vendor1RepoTargetApp struct {
 }
@@ -57,6 +60,30 @@ func (v *Vendor1RepoTargetApp) RunAppUpdate(ctx context.Context, opts *types.Upd
 	return nil
 }
 
+func (v *Vendor1RepoTargetApp) RunAppHistory(ctx context.Context, name string, limit int) ([]types.ApplicationRevision, error) {
+	return nil, nil
+}
+
+func (v *Vendor1RepoTargetApp) RunAppRollback(ctx context.Context, name, revision string) (string, error) {
+	return "", nil
+}
+
+func (v *Vendor1RepoTargetApp) RunAppTargetManifests(ctx context.Context, name string, envs map[string]string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+func (v *Vendor1RepoTargetApp) RunAppPromote(ctx context.Context, opts *types.AppPromoteOptions) (string, error) {
+	return "", nil
+}
+
+func (v *Vendor1RepoTargetApp) RunAppUnpin(ctx context.Context, opts *types.AppUnpinOptions) (string, error) {
+	return "", nil
+}
+
+func (v *Vendor1RepoTargetApp) RunIngressHostList(ctx context.Context) ([]types.IngressHost, error) {
+	return nil, nil
+}
+
 type Vendor1RepoTargetSecretStore struct {
 }
 
@@ -98,3 +125,68 @@ func (v *Vendor1RepoTargetProject) RunProjectList(ctx context.Context) ([]types.
 func (v *Vendor1RepoTargetProject) RunProjectDelete(ctx context.Context, name string) error {
 	return nil
 }
+
+type Vendor1RepoTargetAppCode struct {
+}
+
+func (v *Vendor1RepoTargetAppCode) AppCodeCreate(ctx context.Context, code *types.AppCode) error {
+	return nil
+}
+
+func (v *Vendor1RepoTargetAppCode) AppCodeGet(ctx context.Context, name string) (*types.AppCode, error) {
+	return nil, nil
+}
+
+func (v *Vendor1RepoTargetAppCode) AppCodeList(ctx context.Context) ([]types.AppCode, error) {
+	return nil, nil
+}
+
+func (v *Vendor1RepoTargetAppCode) AppCodeUpdate(ctx context.Context, name string, req *types.AppCodeUpdateRequest) (*types.AppCode, error) {
+	return nil, nil
+}
+
+type Vendor1RepoTargetChangeRequest struct {
+}
+
+func (v *Vendor1RepoTargetChangeRequest) ChangeRequestCreate(ctx context.Context, cr *types.ChangeRequest) error {
+	return nil
+}
+
+func (v *Vendor1RepoTargetChangeRequest) ChangeRequestGet(ctx context.Context, tenant, id string) (*types.ChangeRequest, error) {
+	return nil, nil
+}
+
+func (v *Vendor1RepoTargetChangeRequest) ChangeRequestList(ctx context.Context, tenant string) ([]types.ChangeRequest, error) {
+	return nil, nil
+}
+
+func (v *Vendor1RepoTargetChangeRequest) ChangeRequestUpdate(ctx context.Context, cr *types.ChangeRequest) error {
+	return nil
+}
+
+type Vendor1RepoTargetFreezeWindow struct {
+}
+
+func (v *Vendor1RepoTargetFreezeWindow) FreezeWindowCreate(ctx context.Context, w *types.FreezeWindow, clusters []types.FreezeCluster) error {
+	return nil
+}
+
+func (v *Vendor1RepoTargetFreezeWindow) FreezeWindowGet(ctx context.Context, name string) (*types.FreezeWindow, error) {
+	return nil, nil
+}
+
+func (v *Vendor1RepoTargetFreezeWindow) FreezeWindowList(ctx context.Context) ([]types.FreezeWindow, error) {
+	return nil, nil
+}
+
+func (v *Vendor1RepoTargetFreezeWindow) FreezeWindowUpdate(ctx context.Context, w *types.FreezeWindow, clusters []types.FreezeCluster) (*types.FreezeWindow, error) {
+	return nil, nil
+}
+
+func (v *Vendor1RepoTargetFreezeWindow) FreezeWindowDelete(ctx context.Context, name string, clusters []types.FreezeCluster) error {
+	return nil
+}
+
+func (v *Vendor1RepoTargetFreezeWindow) FreezeWindowSync(ctx context.Context, clusters []types.FreezeCluster) error {
+	return nil
+}
@@ -0,0 +1,240 @@
+package secretstore
+
+import (
+	"reflect"
+	"strings"
+
+	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
+	esmeta "github.com/external-secrets/external-secrets/apis/meta/v1"
+
+	"github.com/squidflow/service/pkg/apierr"
+)
+
+// the providers of the secret stores of tenants
+const (
+	ProviderVault      = "vault"
+	ProviderKubernetes = "kubernetes"
+	ProviderAWS        = "aws"
+	ProviderGCP        = "gcpsm"
+	ProviderAzure      = "azurekv"
+)
+
+var supportedProviders = []string{ProviderVault, ProviderKubernetes, ProviderAWS, ProviderGCP, ProviderAzure}
+
+// Provider returns the name of the provider of the secret store, the json name of its field in the provider spec,
+// empty when no provider is set
+func Provider(spec *esv1beta1.SecretStoreProvider) string {
+	providers := providersOf(spec)
+	if len(providers) == 0 {
+		return ""
+	}
+
+	return providers[0]
+}
+
+// Type returns the secret backend of the provider: the kv engine of vault, the service of aws,
+// and the kind of secret of the other providers
+func Type(spec *esv1beta1.SecretStoreProvider) string {
+	switch {
+	case spec == nil:
+		return ""
+	case spec.Vault != nil:
+		if spec.Vault.Version == "" {
+			return "kv-" + string(esv1beta1.VaultKVStoreV2)
+		}
+		return "kv-" + string(spec.Vault.Version)
+	case spec.Kubernetes != nil:
+		return "Secret"
+	case spec.AWS != nil:
+		return string(spec.AWS.Service)
+	case spec.GCPSM != nil:
+		return "SecretManager"
+	case spec.AzureKV != nil:
+		return "KeyVault"
+	}
+
+	return ""
+}
+
+// Validate returns a validation error unless the secret store has exactly one supported provider, with the
+// fields that its auth requires. The credentials of the external secrets controller are never used for the
+// secret store of a tenant, every provider requires credentials of its own
+func Validate(ss *esv1beta1.SecretStore) error {
+	providers := providersOf(ss.Spec.Provider)
+	switch {
+	case len(providers) == 0:
+		return apierr.Validation("provider configuration is required")
+	case len(providers) > 1:
+		return apierr.Validation("secret store has several providers %v, expected one", providers)
+	}
+
+	spec := ss.Spec.Provider
+	switch {
+	case spec.Vault != nil:
+		return validateVault(spec.Vault)
+	case spec.Kubernetes != nil:
+		return validateKubernetes(spec.Kubernetes)
+	case spec.AWS != nil:
+		return validateAWS(spec.AWS)
+	case spec.GCPSM != nil:
+		return validateGCP(spec.GCPSM)
+	case spec.AzureKV != nil:
+		return validateAzure(spec.AzureKV)
+	}
+
+	return apierr.Validation("provider '%s' is not supported, supported providers are %s", providers[0], strings.Join(supportedProviders, ", "))
+}
+
+// providersOf returns the json names of the providers that are set in the spec
+func providersOf(spec *esv1beta1.SecretStoreProvider) []string {
+	if spec == nil {
+		return nil
+	}
+
+	providers := []string{}
+	v := reflect.ValueOf(spec).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.Kind() == reflect.Ptr && !f.IsNil() {
+			providers = append(providers, strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0])
+		}
+	}
+
+	return providers
+}
+
+func validateVault(p *esv1beta1.VaultProvider) error {
+	if p.Server == "" {
+		return apierr.Validation("vault provider requires the server")
+	}
+
+	auth := p.Auth
+	switch {
+	case auth.TokenSecretRef != nil:
+		return requireSecretKey("vault token auth", auth.TokenSecretRef)
+	case auth.AppRole != nil:
+		if auth.AppRole.Path == "" || (auth.AppRole.RoleID == "" && auth.AppRole.RoleRef == nil) {
+			return apierr.Validation("vault appRole auth requires the path and the role id")
+		}
+		return requireSecretKey("vault appRole auth", &auth.AppRole.SecretRef)
+	case auth.Kubernetes != nil:
+		if auth.Kubernetes.Role == "" {
+			return apierr.Validation("vault kubernetes auth requires the role")
+		}
+		return nil
+	case auth.Ldap != nil, auth.Jwt != nil, auth.Cert != nil, auth.Iam != nil, auth.UserPass != nil:
+		return nil
+	}
+
+	return apierr.Validation("vault provider requires an auth method")
+}
+
+func validateKubernetes(p *esv1beta1.KubernetesProvider) error {
+	if p.AuthRef != nil {
+		return requireSecretKey("kubernetes authRef", p.AuthRef)
+	}
+
+	auth := p.Auth
+	switch {
+	case auth.Token != nil:
+		return requireSecretKey("kubernetes token auth", &auth.Token.BearerToken)
+	case auth.Cert != nil:
+		if err := requireSecretKey("kubernetes cert auth client cert", &auth.Cert.ClientCert); err != nil {
+			return err
+		}
+		return requireSecretKey("kubernetes cert auth client key", &auth.Cert.ClientKey)
+	case auth.ServiceAccount != nil:
+		return requireServiceAccount("kubernetes serviceAccount auth", auth.ServiceAccount)
+	}
+
+	return apierr.Validation("kubernetes provider requires a token, cert or serviceAccount auth, or an authRef")
+}
+
+func validateAWS(p *esv1beta1.AWSProvider) error {
+	if p.Service != esv1beta1.AWSServiceSecretsManager && p.Service != esv1beta1.AWSServiceParameterStore {
+		return apierr.Validation("invalid aws service '%s', expected %s or %s", p.Service, esv1beta1.AWSServiceSecretsManager, esv1beta1.AWSServiceParameterStore)
+	}
+
+	if p.Region == "" {
+		return apierr.Validation("aws provider requires the region")
+	}
+
+	switch {
+	case p.Auth.SecretRef != nil:
+		if err := requireSecretKey("aws secretRef auth access key id", &p.Auth.SecretRef.AccessKeyID); err != nil {
+			return err
+		}
+		return requireSecretKey("aws secretRef auth secret access key", &p.Auth.SecretRef.SecretAccessKey)
+	case p.Auth.JWTAuth != nil:
+		return requireServiceAccount("aws jwt auth", p.Auth.JWTAuth.ServiceAccountRef)
+	}
+
+	return apierr.Validation("aws provider requires a secretRef or jwt auth")
+}
+
+func validateGCP(p *esv1beta1.GCPSMProvider) error {
+	if p.ProjectID == "" {
+		return apierr.Validation("gcpsm provider requires the projectID")
+	}
+
+	switch {
+	case p.Auth.SecretRef != nil:
+		return requireSecretKey("gcpsm secretRef auth", &p.Auth.SecretRef.SecretAccessKey)
+	case p.Auth.WorkloadIdentity != nil:
+		wi := p.Auth.WorkloadIdentity
+		if wi.ClusterLocation == "" || wi.ClusterName == "" {
+			return apierr.Validation("gcpsm workloadIdentity auth requires the clusterLocation and the clusterName")
+		}
+		return requireServiceAccount("gcpsm workloadIdentity auth", &wi.ServiceAccountRef)
+	}
+
+	return apierr.Validation("gcpsm provider requires a secretRef or workloadIdentity auth")
+}
+
+func validateAzure(p *esv1beta1.AzureKVProvider) error {
+	if p.VaultURL == nil || *p.VaultURL == "" {
+		return apierr.Validation("azurekv provider requires the vaultUrl")
+	}
+
+	authType := esv1beta1.AzureServicePrincipal
+	if p.AuthType != nil {
+		authType = *p.AuthType
+	}
+
+	switch authType {
+	case esv1beta1.AzureServicePrincipal:
+		ref := p.AuthSecretRef
+		if ref == nil {
+			return apierr.Validation("azurekv ServicePrincipal auth requires the authSecretRef")
+		}
+		if (p.TenantID == nil || *p.TenantID == "") && ref.TenantID == nil {
+			return apierr.Validation("azurekv ServicePrincipal auth requires the tenantId")
+		}
+		if err := requireSecretKey("azurekv ServicePrincipal auth client id", ref.ClientID); err != nil {
+			return err
+		}
+		if ref.ClientCertificate != nil {
+			return requireSecretKey("azurekv ServicePrincipal auth client certificate", ref.ClientCertificate)
+		}
+		return requireSecretKey("azurekv ServicePrincipal auth client secret", ref.ClientSecret)
+	case esv1beta1.AzureWorkloadIdentity:
+		return requireServiceAccount("azurekv WorkloadIdentity auth", p.ServiceAccountRef)
+	}
+
+	return apierr.Validation("azurekv auth type '%s' is not supported, expected %s or %s", authType, esv1beta1.AzureServicePrincipal, esv1beta1.AzureWorkloadIdentity)
+}
+
+func requireSecretKey(field string, ref *esmeta.SecretKeySelector) error {
+	if ref == nil || ref.Name == "" || ref.Key == "" {
+		return apierr.Validation("%s requires the name and the key of a secret", field)
+	}
+
+	return nil
+}
+
+func requireServiceAccount(field string, ref *esmeta.ServiceAccountSelector) error {
+	if ref == nil || ref.Name == "" {
+		return apierr.Validation("%s requires the name of a service account", field)
+	}
+
+	return nil
+}
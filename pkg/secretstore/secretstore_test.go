@@ -0,0 +1,270 @@
+package secretstore
+
+import (
+	"testing"
+
+	esv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+
+	"github.com/squidflow/service/pkg/apierr"
+)
+
+const header = `apiVersion: external-secrets.io/v1beta1
+kind: SecretStore
+metadata:
+  name: store
+  namespace: app
+spec:
+  provider:
+`
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		provider     string
+		wantProvider string
+		wantType     string
+		wantErr      string
+	}{
+		"Should accept vault with token auth": {
+			provider: `
+    vault:
+      server: https://vault.example.com
+      path: secret
+      version: v1
+      auth:
+        tokenSecretRef:
+          name: vault-token
+          key: token
+`,
+			wantProvider: ProviderVault,
+			wantType:     "kv-v1",
+		},
+		"Should accept kubernetes with token auth": {
+			provider: `
+    kubernetes:
+      remoteNamespace: secrets
+      server:
+        url: https://kubernetes.default
+      auth:
+        token:
+          bearerToken:
+            name: k8s-token
+            key: token
+`,
+			wantProvider: ProviderKubernetes,
+			wantType:     "Secret",
+		},
+		"Should accept kubernetes with cert auth": {
+			provider: `
+    kubernetes:
+      auth:
+        cert:
+          clientCert:
+            name: k8s-cert
+            key: tls.crt
+          clientKey:
+            name: k8s-cert
+            key: tls.key
+`,
+			wantProvider: ProviderKubernetes,
+			wantType:     "Secret",
+		},
+		"Should accept aws secrets manager with secretRef auth": {
+			provider: `
+    aws:
+      service: SecretsManager
+      region: eu-west-1
+      auth:
+        secretRef:
+          accessKeyIDSecretRef:
+            name: aws
+            key: access-key-id
+          secretAccessKeySecretRef:
+            name: aws
+            key: secret-access-key
+`,
+			wantProvider: ProviderAWS,
+			wantType:     "SecretsManager",
+		},
+		"Should accept aws parameter store with jwt auth": {
+			provider: `
+    aws:
+      service: ParameterStore
+      region: eu-west-1
+      auth:
+        jwt:
+          serviceAccountRef:
+            name: app
+`,
+			wantProvider: ProviderAWS,
+			wantType:     "ParameterStore",
+		},
+		"Should accept gcp secret manager with secretRef auth": {
+			provider: `
+    gcpsm:
+      projectID: project
+      auth:
+        secretRef:
+          secretAccessKeySecretRef:
+            name: gcp
+            key: credentials.json
+`,
+			wantProvider: ProviderGCP,
+			wantType:     "SecretManager",
+		},
+		"Should accept gcp secret manager with workload identity": {
+			provider: `
+    gcpsm:
+      projectID: project
+      auth:
+        workloadIdentity:
+          clusterLocation: europe-west1
+          clusterName: prod
+          serviceAccountRef:
+            name: app
+`,
+			wantProvider: ProviderGCP,
+			wantType:     "SecretManager",
+		},
+		"Should accept azure key vault with service principal": {
+			provider: `
+    azurekv:
+      vaultUrl: https://vault.vault.azure.net
+      tenantId: tenant
+      authSecretRef:
+        clientId:
+          name: azure
+          key: client-id
+        clientSecret:
+          name: azure
+          key: client-secret
+`,
+			wantProvider: ProviderAzure,
+			wantType:     "KeyVault",
+		},
+		"Should accept azure key vault with workload identity": {
+			provider: `
+    azurekv:
+      authType: WorkloadIdentity
+      vaultUrl: https://vault.vault.azure.net
+      serviceAccountRef:
+        name: app
+`,
+			wantProvider: ProviderAzure,
+			wantType:     "KeyVault",
+		},
+		"Should fail without provider": {
+			wantErr: "provider configuration is required",
+		},
+		"Should fail with several providers": {
+			provider: `
+    vault:
+      server: https://vault.example.com
+    gcpsm:
+      projectID: project
+`,
+			wantErr: "secret store has several providers [vault gcpsm], expected one",
+		},
+		"Should fail with an unsupported provider": {
+			provider: `
+    fake:
+      data: []
+`,
+			wantErr: "provider 'fake' is not supported, supported providers are vault, kubernetes, aws, gcpsm, azurekv",
+		},
+		"Should fail with vault without auth": {
+			provider: `
+    vault:
+      server: https://vault.example.com
+`,
+			wantErr: "vault provider requires an auth method",
+		},
+		"Should fail with kubernetes token auth without key": {
+			provider: `
+    kubernetes:
+      auth:
+        token:
+          bearerToken:
+            name: k8s-token
+`,
+			wantErr: "kubernetes token auth requires the name and the key of a secret",
+		},
+		"Should fail with aws without auth": {
+			provider: `
+    aws:
+      service: SecretsManager
+      region: eu-west-1
+`,
+			wantErr: "aws provider requires a secretRef or jwt auth",
+		},
+		"Should fail with aws without region": {
+			provider: `
+    aws:
+      service: SecretsManager
+`,
+			wantErr: "aws provider requires the region",
+		},
+		"Should fail with gcp workload identity without cluster": {
+			provider: `
+    gcpsm:
+      projectID: project
+      auth:
+        workloadIdentity:
+          serviceAccountRef:
+            name: app
+`,
+			wantErr: "gcpsm workloadIdentity auth requires the clusterLocation and the clusterName",
+		},
+		"Should fail with azure service principal without tenant": {
+			provider: `
+    azurekv:
+      vaultUrl: https://vault.vault.azure.net
+      authSecretRef:
+        clientId:
+          name: azure
+          key: client-id
+        clientSecret:
+          name: azure
+          key: client-secret
+`,
+			wantErr: "azurekv ServicePrincipal auth requires the tenantId",
+		},
+		"Should fail with azure managed identity": {
+			provider: `
+    azurekv:
+      authType: ManagedIdentity
+      vaultUrl: https://vault.vault.azure.net
+`,
+			wantErr: "azurekv auth type 'ManagedIdentity' is not supported, expected ServicePrincipal or WorkloadIdentity",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ss := &esv1beta1.SecretStore{}
+			manifest := header + tt.provider
+			if tt.provider == "" {
+				manifest += "    {}\n"
+			}
+			assert.NoError(t, yaml.Unmarshal([]byte(manifest), ss))
+
+			err := Validate(ss)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				assert.Equal(t, apierr.CodeValidation, apierr.CodeOf(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantProvider, Provider(ss.Spec.Provider))
+			assert.Equal(t, tt.wantType, Type(ss.Spec.Provider))
+
+			data, err := yaml.Marshal(ss)
+			assert.NoError(t, err)
+			written := &esv1beta1.SecretStore{}
+			assert.NoError(t, yaml.Unmarshal(data, written))
+			assert.Equal(t, ss, written)
+			assert.NoError(t, Validate(written))
+		})
+	}
+}
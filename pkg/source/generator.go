@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v3"
 	"helm.sh/helm/v3/pkg/action"
@@ -15,10 +16,13 @@ import (
 
 	"github.com/squidflow/service/pkg/fs"
 	"github.com/squidflow/service/pkg/log"
+	"github.com/squidflow/service/pkg/metrics"
 )
 
 // GenerateHelmManifest generates Helm manifests for a specific environment
-func GenerateHelmManifest(repofs fs.FS, path, manifestPath, env, namespace, name string) ([]byte, error) {
+func GenerateHelmManifest(repofs fs.FS, path, manifestPath, env, namespace, name string) (manifests []byte, err error) {
+	defer func(start time.Time) { err = metrics.ObserveRender("helm", start, err) }(time.Now())
+
 	log.G().WithFields(log.Fields{
 		"path":         path,
 		"manifestPath": manifestPath,
@@ -210,7 +214,9 @@ func copyChartFiles(repofs fs.FS, srcPath, destPath string) error {
 }
 
 // GenerateKustomizeManifest generates Kustomize manifests for a specific environment
-func GenerateKustomizeManifest(repofs fs.FS, path string, env string) ([]byte, error) {
+func GenerateKustomizeManifest(repofs fs.FS, path string, env string) (manifests []byte, err error) {
+	defer func(start time.Time) { err = metrics.ObserveRender("kustomize", start, err) }(time.Now())
+
 	log.G().WithFields(log.Fields{
 		"repo": repofs,
 		"path": path,
@@ -223,6 +229,25 @@ func GenerateKustomizeManifest(repofs fs.FS, path string, env string) ([]byte, e
 	return generateMultiEnvKustomize(repofs, path, env)
 }
 
+// BuildOverlay builds the kustomization at overlayPath of the directory dir of the repo, files are written over the
+// files of the directory, by their path relative to dir
+func BuildOverlay(repofs fs.FS, dir, overlayPath string, files map[string][]byte) (manifests []byte, err error) {
+	defer func(start time.Time) { err = metrics.ObserveRender("kustomize", start, err) }(time.Now())
+
+	memFS := filesys.MakeFsInMemory()
+	if err := copyToMemFS(repofs, dir, "/", memFS); err != nil {
+		return nil, fmt.Errorf("failed to copy files: %w", err)
+	}
+
+	for p, content := range files {
+		if err := memFS.WriteFile(filepath.Join("/", p), content); err != nil {
+			return nil, fmt.Errorf("failed to write file %s to memory fs: %w", p, err)
+		}
+	}
+
+	return buildKustomize(memFS, filepath.Join("/", overlayPath))
+}
+
 // generateSimpleKustomize handles single environment kustomize builds
 func generateSimpleKustomize(repofs fs.FS, buildPath string) ([]byte, error) {
 	// check if kustomization.yaml exists
@@ -40,21 +40,25 @@ type Store struct {
 }
 
 var Default = struct {
+	AppCodesDir          string
 	AppsDir              string
 	ArgoCDName           string
 	ArgoCDNamespace      string
 	BaseDir              string
 	BootsrtrapAppName    string
 	BootsrtrapDir        string
+	ChangeRequestsDir    string
 	ClusterContextName   string
 	ClusterResourcesDir  string
 	DestServer           string
 	DummyName            string
+	FreezeWindowsDir     string
 	DestServerAnnotation string
 	GitHubUsername       string
 	LabelKeyAppName      string
 	LabelKeyAppManagedBy string
 	LabelKeyAppPartOf    string
+	LabelKeyTenant       string
 	LabelValueManagedBy  string
 	OverlaysDir          string
 	ProjectsDir          string
@@ -64,21 +68,25 @@ var Default = struct {
 	ThirdParty           string
 	WaitInterval         time.Duration
 }{
+	AppCodesDir:          "appcodes",
 	AppsDir:              "apps",
 	ArgoCDName:           "argo-cd",
 	ArgoCDNamespace:      "argocd",
 	BaseDir:              "base",
 	BootsrtrapAppName:    "h4-bootstrap",
 	BootsrtrapDir:        "bootstrap",
+	ChangeRequestsDir:    "changerequests",
 	ClusterContextName:   "in-cluster",
 	ClusterResourcesDir:  "cluster-resources",
 	DestServer:           "https://kubernetes.default.svc",
 	DestServerAnnotation: "bootstrap.h4.io/default-dest-server",
 	DummyName:            "DUMMY",
+	FreezeWindowsDir:     "freezewindows",
 	GitHubUsername:       "username",
 	LabelKeyAppName:      "app.kubernetes.io/name",
 	LabelKeyAppManagedBy: "app.kubernetes.io/managed-by",
 	LabelKeyAppPartOf:    "app.kubernetes.io/part-of",
+	LabelKeyTenant:       "squidflow.github.io/tenant",
 	LabelValueManagedBy:  "bootstrap-h4",
 	OverlaysDir:          "overlays",
 	ProjectsDir:          "projects",
@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	return recorder
+}
+
+func TestStartEnd(t *testing.T) {
+	tests := map[string]struct {
+		err        error
+		wantStatus codes.Code
+	}{
+		"success": {
+			wantStatus: codes.Unset,
+		},
+		"failure": {
+			err:        errors.New("push rejected"),
+			wantStatus: codes.Error,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := setupRecorder(t)
+
+			ctx, parent := Start(context.Background(), "parent")
+			_, child := Start(ctx, "git.push")
+			End(child, tt.err)
+			parent.End()
+
+			spans := recorder.Ended()
+			assert.Len(t, spans, 2)
+			assert.Equal(t, "git.push", spans[0].Name())
+			assert.Equal(t, tt.wantStatus, spans[0].Status().Code)
+			assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+			assert.Equal(t, spans[1].SpanContext().TraceID().String(), TraceID(ctx))
+		})
+	}
+}
+
+func TestDetach(t *testing.T) {
+	setupRecorder(t)
+
+	parent, cancel := context.WithCancel(context.Background())
+	parent, span := Start(parent, "request")
+	defer span.End()
+
+	detached := Detach(parent)
+	cancel()
+
+	assert.NoError(t, detached.Err())
+	assert.Equal(t, TraceID(parent), TraceID(detached))
+}
+
+func TestTraceID_noSpan(t *testing.T) {
+	assert.Equal(t, "", TraceID(context.Background()))
+}
+
+func TestInstrumentTransport(t *testing.T) {
+	recorder := setupRecorder(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: InstrumentTransport(http.DefaultTransport)}
+	resp, err := client.Get(srv.URL + "/apis/argoproj.io/v1alpha1/namespaces/argocd/applications")
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "argocd GET", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+}
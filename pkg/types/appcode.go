@@ -0,0 +1,70 @@
+package types
+
+import "time"
+
+type (
+	// AppCode is an entry of the appcode registry, every application is created with a registered appcode.
+	// Tenants restricts the visibility of the appcode, it is visible to every tenant when empty.
+	// The registry is stored in the meta repo, one yaml file for each appcode
+	AppCode struct {
+		Name        string     `json:"name"`
+		Description string     `json:"description,omitempty"`
+		OwnerTeam   string     `json:"owner_team"`
+		CostCenter  string     `json:"cost_center"`
+		Tenants     []string   `json:"tenants,omitempty"`
+		Active      bool       `json:"active"`
+		CreatedBy   string     `json:"created_by"`
+		CreatedAt   time.Time  `json:"created_at"`
+		UpdatedBy   string     `json:"updated_by,omitempty"`
+		UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	}
+
+	AppCodeCreateRequest struct {
+		Name        string   `json:"name" binding:"required"`
+		Description string   `json:"description,omitempty"`
+		OwnerTeam   string   `json:"owner_team" binding:"required"`
+		CostCenter  string   `json:"cost_center" binding:"required"`
+		Tenants     []string `json:"tenants,omitempty"`
+	}
+
+	// AppCodeUpdateRequest changes the fields that are set, Tenants replaces the visibility of the appcode
+	AppCodeUpdateRequest struct {
+		Description *string   `json:"description,omitempty"`
+		OwnerTeam   *string   `json:"owner_team,omitempty"`
+		CostCenter  *string   `json:"cost_center,omitempty"`
+		Tenants     *[]string `json:"tenants,omitempty"`
+		Active      *bool     `json:"active,omitempty"`
+		UpdatedBy   string    `json:"-"`
+	}
+
+	AppCodeResponse struct {
+		Success bool    `json:"success"`
+		Message string  `json:"message"`
+		Item    AppCode `json:"item"`
+	}
+
+	// AppCodeListResponse lists the appcodes visible to the tenant, AppCodes holds their names for older clients
+	AppCodeListResponse struct {
+		Success  bool      `json:"success"`
+		Message  string    `json:"message"`
+		Total    int       `json:"total"`
+		Items    []AppCode `json:"items"`
+		AppCodes []string  `json:"appCodes"`
+		Continue string    `json:"continue,omitempty"`
+	}
+)
+
+// VisibleTo returns true if the appcode can be used by the tenant
+func (a *AppCode) VisibleTo(tenant string) bool {
+	if len(a.Tenants) == 0 {
+		return true
+	}
+
+	for _, t := range a.Tenants {
+		if t == tenant {
+			return true
+		}
+	}
+
+	return false
+}
@@ -5,6 +5,7 @@ import (
 
 	argocdv1alpha1client "github.com/argoproj/argo-cd/v2/pkg/client/clientset/versioned/typed/application/v1alpha1"
 
+	"github.com/squidflow/service/pkg/diff"
 	"github.com/squidflow/service/pkg/kube"
 )
 
@@ -12,9 +13,12 @@ import (
 type ActionType string
 
 const (
-	ActionTypeCreate ActionType = "create"
-	ActionTypeUpdate ActionType = "update"
-	ActionTypeDelete ActionType = "delete"
+	ActionTypeCreate   ActionType = "create"
+	ActionTypeUpdate   ActionType = "update"
+	ActionTypeDelete   ActionType = "delete"
+	ActionTypeRollback ActionType = "rollback"
+	ActionTypePromote  ActionType = "promote"
+	ActionTypeUnpin    ActionType = "unpin"
 )
 
 // ResourceName is the name of the resource
@@ -74,7 +78,7 @@ type (
 		ApplicationInstantiation ApplicationInstantiation `json:"application_instantiation" binding:"required"`
 
 		// where to deploy the application
-		ApplicationTarget []ApplicationTarget `json:"application_target" binding:"required"`
+		ApplicationTarget []ApplicationTarget `json:"application_target" binding:"required,min=1,dive"`
 
 		// Whether this is a dry run
 		IsDryRun bool `json:"is_dryrun"`
@@ -95,9 +99,25 @@ type (
 	}
 
 	// ApplicationTarget represents the target information of an application
+	// Server is resolved from the registered clusters, ArgoApplication is the name of
+	// the ArgoCD application that deploys the target, both are ignored in requests
+	// ApplicationTarget is a cluster that the application is deployed to. Revision is the revision of the
+	// gitops repo that the target is deployed from, Promotion is the last promotion of the target
 	ApplicationTarget struct {
-		Cluster   string `json:"cluster" binding:"required"`
-		Namespace string `json:"namespace" binding:"required"`
+		Cluster         string     `json:"cluster" binding:"required"`
+		Namespace       string     `json:"namespace" binding:"required"`
+		Server          string     `json:"server,omitempty"`
+		ArgoApplication string     `json:"argocd_application,omitempty"`
+		Revision        string     `json:"revision,omitempty"`
+		Promotion       *Promotion `json:"promotion,omitempty"`
+	}
+
+	// Promotion records the promotion of a target to Revision, the revision verified in the environment From
+	Promotion struct {
+		From       string    `json:"from"`
+		Revision   string    `json:"revision"`
+		PromotedBy string    `json:"promoted_by"`
+		PromotedAt time.Time `json:"promoted_at"`
 	}
 
 	// IngressConfig represents ingress configuration
@@ -106,6 +126,16 @@ type (
 		TLS  TLSConfig `json:"tls,omitempty"`
 	}
 
+	// IngressHost is a host of the ingress of an application, committed to the overlay of one of its targets.
+	// Cluster and Server are the destination of the target
+	IngressHost struct {
+		Host    string `json:"host"`
+		Tenant  string `json:"tenant"`
+		AppName string `json:"app_name"`
+		Cluster string `json:"cluster,omitempty"`
+		Server  string `json:"server,omitempty"`
+	}
+
 	// TLSConfig represents TLS configuration for ingress
 	TLSConfig struct {
 		Enabled    bool   `json:"enabled,omitempty"`
@@ -117,9 +147,25 @@ type (
 		ExternalSecret ExternalSecretConfig `json:"external_secret,omitempty"`
 	}
 
-	// ExternalSecretConfig represents external secret configuration
+	// ExternalSecretConfig represents external secret configuration, each of the Secrets is
+	// filled from the keys of the referenced secret store of the tenant
 	ExternalSecretConfig struct {
-		SecretStoreRef SecretStoreRefConfig `json:"secret_store_ref"`
+		SecretStoreRef  SecretStoreRefConfig     `json:"secret_store_ref"`
+		RefreshInterval string                   `json:"refresh_interval,omitempty"`
+		Secrets         []ExternalSecretTemplate `json:"secrets,omitempty"`
+	}
+
+	// ExternalSecretTemplate is a kubernetes secret of the application, Name is the name of the secret
+	ExternalSecretTemplate struct {
+		Name string                  `json:"name"`
+		Data []ExternalSecretKeyData `json:"data"`
+	}
+
+	// ExternalSecretKeyData maps the remote key, and optionally a property of it, to a key of the secret
+	ExternalSecretKeyData struct {
+		SecretKey string `json:"secret_key"`
+		RemoteKey string `json:"remote_key"`
+		Property  string `json:"property,omitempty"`
 	}
 
 	// SecretStoreRefConfig represents secret store reference configuration
@@ -141,7 +187,7 @@ type (
 	Application struct {
 		ApplicationSource        ApplicationSourceRequest `json:"application_source" binding:"required"`
 		ApplicationInstantiation ApplicationInstantiation `json:"application_instantiation" binding:"required"`
-		ApplicationTarget        []ApplicationTarget      `json:"application_target" binding:"required"`
+		ApplicationTarget        []ApplicationTarget      `json:"application_target" binding:"required,min=1,dive"`
 		ApplicationRuntime       ApplicationRuntime       `json:"application_runtime,omitempty"`
 	}
 
@@ -177,20 +223,25 @@ type (
 		LastUpdatedBy   string              `json:"last_updated_by"`
 	}
 
+	// ResourceMetricsInfo sums the pods of the application, CPU and Memory are the usage read from
+	// the metrics API, they are empty when the metrics API is not available on the destination cluster
 	ResourceMetricsInfo struct {
 		PodCount    int    `json:"pod_count"`
 		SecretCount int    `json:"secret_count"`
+		Restarts    int32  `json:"restarts"`
 		CPU         string `json:"cpu"`
 		Memory      string `json:"memory"`
 	}
 
 	// ApplicationListResponse represents the response body for listing applications
+	// Total is the number of applications that match the filters, Continue is the cursor of the next page
 	ApplicationListResponse struct {
-		Total   int64         `json:"total"`
-		Success bool          `json:"success"`
-		Message string        `json:"message"`
-		Error   string        `json:"error,omitempty"`
-		Items   []Application `json:"items"`
+		Total    int64         `json:"total"`
+		Success  bool          `json:"success"`
+		Message  string        `json:"message"`
+		Error    string        `json:"error,omitempty"`
+		Items    []Application `json:"items"`
+		Continue string        `json:"continue,omitempty"`
 	}
 )
 
@@ -207,21 +258,201 @@ type (
 		Message     string      `json:"message"`
 		Application Application `json:"application"`
 	}
+
+	// ApplicationRevision is a commit of the gitops repo that changed the application,
+	// SrcTargetRevision is the revision of the application source at that commit
+	ApplicationRevision struct {
+		SHA               string    `json:"sha"`
+		Author            string    `json:"author"`
+		Email             string    `json:"email"`
+		Message           string    `json:"message"`
+		Timestamp         time.Time `json:"timestamp"`
+		SrcTargetRevision string    `json:"src_target_revision"`
+	}
+
+	ApplicationHistoryResponse struct {
+		Success bool                  `json:"success"`
+		Message string                `json:"message"`
+		Total   int                   `json:"total"`
+		Items   []ApplicationRevision `json:"items"`
+	}
+
+	// ApplicationDiffResponse is the difference between the desired and the live state of each target of the application
+	ApplicationDiffResponse struct {
+		Success bool                    `json:"success"`
+		Message string                  `json:"message"`
+		Targets []ApplicationTargetDiff `json:"targets"`
+	}
+
+	ApplicationTargetDiff struct {
+		Cluster   string              `json:"cluster"`
+		Namespace string              `json:"namespace"`
+		Resources []diff.ResourceDiff `json:"resources"`
+		Error     string              `json:"error,omitempty"`
+	}
+
+	// ApplicationResourcesResponse is the resource tree of each target of the application
+	ApplicationResourcesResponse struct {
+		Success bool                         `json:"success"`
+		Message string                       `json:"message"`
+		Targets []ApplicationTargetResources `json:"targets"`
+	}
+
+	// ApplicationTargetResources is the resource tree of the ArgoCD application of a target,
+	// Metrics sums the pods of the tree
+	ApplicationTargetResources struct {
+		Cluster         string                `json:"cluster"`
+		Namespace       string                `json:"namespace"`
+		ArgoApplication string                `json:"argo_application"`
+		Health          string                `json:"health"`
+		SyncStatus      string                `json:"sync_status"`
+		Metrics         ResourceMetricsInfo   `json:"metrics"`
+		Resources       []ApplicationResource `json:"resources"`
+		Error           string                `json:"error,omitempty"`
+	}
+
+	// ApplicationResource is a node of the resource tree, SyncStatus is only set for the resources managed
+	// by ArgoCD, not for their children. Pod is set for pods
+	ApplicationResource struct {
+		Group         string        `json:"group"`
+		Version       string        `json:"version"`
+		Kind          string        `json:"kind"`
+		Namespace     string        `json:"namespace,omitempty"`
+		Name          string        `json:"name"`
+		Health        string        `json:"health,omitempty"`
+		HealthMessage string        `json:"health_message,omitempty"`
+		SyncStatus    string        `json:"sync_status,omitempty"`
+		ParentRefs    []ResourceRef `json:"parent_refs,omitempty"`
+		Pod           *PodStatus    `json:"pod,omitempty"`
+	}
+
+	ResourceRef struct {
+		Group     string `json:"group"`
+		Kind      string `json:"kind"`
+		Namespace string `json:"namespace,omitempty"`
+		Name      string `json:"name"`
+	}
+
+	// PodStatus is the live status of a pod, CPU and Memory are empty when the metrics API is not available
+	PodStatus struct {
+		Phase    string `json:"phase"`
+		Ready    string `json:"ready"`
+		Restarts int32  `json:"restarts"`
+		Node     string `json:"node,omitempty"`
+		CPU      string `json:"cpu,omitempty"`
+		Memory   string `json:"memory,omitempty"`
+	}
+
+	// ApplicationKubeEventsResponse lists the kubernetes events of the resources of the application on a target
+	ApplicationKubeEventsResponse struct {
+		Success   bool        `json:"success"`
+		Message   string      `json:"message"`
+		Cluster   string      `json:"cluster"`
+		Namespace string      `json:"namespace"`
+		Total     int         `json:"total"`
+		Items     []KubeEvent `json:"items"`
+	}
+
+	// KubeEvent is a kubernetes event of a resource, Kind, Namespace and Name are the resource it is about
+	KubeEvent struct {
+		Type          string    `json:"type"`
+		Reason        string    `json:"reason"`
+		Message       string    `json:"message"`
+		Kind          string    `json:"kind"`
+		Namespace     string    `json:"namespace,omitempty"`
+		Name          string    `json:"name"`
+		Source        string    `json:"source,omitempty"`
+		Count         int32     `json:"count"`
+		LastTimestamp time.Time `json:"last_timestamp"`
+	}
+
+	// ApplicationPromoteRequest promotes the application to the environment To, from the environment below it.
+	// Revision is the revision verified in the lower environment, the promotion fails if it is no longer deployed there
+	ApplicationPromoteRequest struct {
+		To       string `json:"to" binding:"required,oneof=SIT UAT PRD"`
+		Revision string `json:"revision,omitempty"`
+	}
+
+	ApplicationPromoteResponse struct {
+		Success  bool     `json:"success"`
+		Message  string   `json:"message"`
+		From     string   `json:"from"`
+		To       string   `json:"to"`
+		Revision string   `json:"revision"`
+		Clusters []string `json:"clusters"`
+		Commit   string   `json:"commit"`
+	}
+
+	ApplicationUnpinResponse struct {
+		Success  bool     `json:"success"`
+		Message  string   `json:"message"`
+		Env      string   `json:"env"`
+		Clusters []string `json:"clusters"`
+		Commit   string   `json:"commit"`
+	}
+
+	// ApplicationRollbackRequest restores the application to Revision, a commit of the gitops repo
+	ApplicationRollbackRequest struct {
+		Revision string `json:"revision" binding:"required"`
+	}
+
+	// ApplicationRollbackResponse returns the commit of the rollback, or the pull request url in pull request mode
+	ApplicationRollbackResponse struct {
+		Success  bool   `json:"success"`
+		Message  string `json:"message"`
+		Revision string `json:"revision"`
+		Commit   string `json:"commit"`
+	}
 )
 
 type (
+	// SyncApplicationRequest represents the request body for syncing applications
+	// Revision: the revision to sync to, default is the target revision of the application
+	// Resources: only sync the selected resources, default is all the resources
+	// Wait: if true, wait until the applications are synced and healthy, or until TimeoutSeconds
 	SyncApplicationRequest struct {
-		Applications []string `json:"applications" binding:"required,min=1"`
+		Applications   []string       `json:"applications" binding:"required,min=1"`
+		Prune          bool           `json:"prune,omitempty"`
+		DryRun         bool           `json:"dry_run,omitempty"`
+		Force          bool           `json:"force,omitempty"`
+		Revision       string         `json:"revision,omitempty"`
+		Resources      []SyncResource `json:"resources,omitempty" binding:"omitempty,dive"`
+		Wait           bool           `json:"wait,omitempty"`
+		TimeoutSeconds int            `json:"timeout_seconds,omitempty" binding:"omitempty,min=1,max=3600"`
+	}
+
+	// SyncResource selects a single resource of an application to sync
+	SyncResource struct {
+		Group     string `json:"group,omitempty"`
+		Kind      string `json:"kind" binding:"required"`
+		Name      string `json:"name" binding:"required"`
+		Namespace string `json:"namespace,omitempty"`
 	}
 
 	SyncApplicationResponse struct {
 		Results []SyncApplicationResult `json:"results"`
 	}
 
+	// SyncApplicationResult is the sync result of a single ArgoCD application
+	// an application deployed to several clusters has one result for each target
 	SyncApplicationResult struct {
-		Name    string `json:"name"`
-		Status  string `json:"status"`
-		Message string `json:"message,omitempty"`
+		Name            string               `json:"name"`
+		Cluster         string               `json:"cluster,omitempty"`
+		ArgoApplication string               `json:"argocd_application,omitempty"`
+		Status          string               `json:"status"`
+		Message         string               `json:"message,omitempty"`
+		Operation       *SyncOperationResult `json:"operation,omitempty"`
+	}
+
+	// SyncOperationResult is the state of the sync operation of an ArgoCD application
+	SyncOperationResult struct {
+		Phase      string     `json:"phase"`
+		Message    string     `json:"message,omitempty"`
+		Revision   string     `json:"revision,omitempty"`
+		SyncStatus string     `json:"sync_status,omitempty"`
+		Health     string     `json:"health,omitempty"`
+		StartedAt  time.Time  `json:"started_at"`
+		FinishedAt *time.Time `json:"finished_at,omitempty"`
 	}
 )
 
@@ -279,6 +510,28 @@ type (
 		UpdateReq   *ApplicationUpdateRequest
 		KubeFactory kube.Factory
 		Annotations map[string]string
+		// IngressClasses are the ingress classes of the clusters of the targets, by cluster
+		IngressClasses map[string]string
+		// Envs are the environments of the clusters of the targets, by cluster, flattened
+		// manifests are rendered with the env of the cluster of each target
+		Envs map[string]string
+	}
+)
+
+// promote
+type (
+	// AppPromoteOptions deploys the targets of the application on Clusters from the revision of the promotion
+	AppPromoteOptions struct {
+		AppName   string    `json:"app_name"`
+		Clusters  []string  `json:"clusters"`
+		Promotion Promotion `json:"promotion"`
+	}
+
+	// AppUnpinOptions deploys the targets of the application on Clusters from the revision that the gitops repo
+	// tracks again, instead of the revision of their last promotion
+	AppUnpinOptions struct {
+		AppName  string   `json:"app_name"`
+		Clusters []string `json:"clusters"`
 	}
 )
 
@@ -0,0 +1,82 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ChangeRequestPending  = "pending"
+	ChangeRequestApproved = "approved"
+	ChangeRequestRejected = "rejected"
+	ChangeRequestExecuted = "executed"
+	ChangeRequestFailed   = "failed"
+
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+)
+
+type (
+	// ChangeRequest is a change of an application that waits for the approvals required by the environment
+	// it targets. It is executed once Required approvals are given, and is never executed once rejected.
+	// Change requests are stored in the meta repo, one yaml file for each change request of a tenant
+	ChangeRequest struct {
+		ID          string `json:"id"`
+		Tenant      string `json:"tenant"`
+		Application string `json:"application"`
+		// Operation is one of create, update, delete, rollback, promote
+		Operation   ActionType `json:"operation"`
+		Environment string     `json:"environment"`
+		Clusters    []string   `json:"clusters,omitempty"`
+		// Payload is the request body of the operation
+		Payload     json.RawMessage `json:"payload,omitempty"`
+		RequestedBy string          `json:"requested_by"`
+		RequestedAt time.Time       `json:"requested_at"`
+		Status      string          `json:"status"`
+		Required    int             `json:"required"`
+		// ApproverRole is the role that approvers hold in the tenant
+		ApproverRole string     `json:"approver_role"`
+		Decisions    []Decision `json:"decisions,omitempty"`
+		// Result is the commit or the pull request of an executed change request, the error of a failed one
+		Result     string     `json:"result,omitempty"`
+		ExecutedAt *time.Time `json:"executed_at,omitempty"`
+	}
+
+	// Decision is the approval or the rejection of a change request by an approver
+	Decision struct {
+		Approver string    `json:"approver"`
+		Decision string    `json:"decision"`
+		Comment  string    `json:"comment,omitempty"`
+		At       time.Time `json:"at"`
+	}
+
+	ChangeRequestDecisionRequest struct {
+		Comment string `json:"comment,omitempty"`
+	}
+
+	ChangeRequestResponse struct {
+		Success bool          `json:"success"`
+		Message string        `json:"message"`
+		Item    ChangeRequest `json:"item"`
+	}
+
+	ChangeRequestListResponse struct {
+		Success  bool            `json:"success"`
+		Message  string          `json:"message"`
+		Total    int             `json:"total"`
+		Items    []ChangeRequest `json:"items"`
+		Continue string          `json:"continue,omitempty"`
+	}
+)
+
+// Approvals returns the number of approvals of the change request
+func (cr *ChangeRequest) Approvals() int {
+	n := 0
+	for _, d := range cr.Decisions {
+		if d.Decision == DecisionApprove {
+			n++
+		}
+	}
+
+	return n
+}
@@ -120,11 +120,12 @@ type ComponentStatus struct {
 }
 
 type ClusterListResponse struct {
-	Success bool              `json:"success"`
-	Total   int               `json:"total"`
-	Message string            `json:"message"`
-	Error   string            `json:"error,omitempty"`
-	Items   []ClusterResponse `json:"items"`
+	Success  bool              `json:"success"`
+	Total    int               `json:"total"`
+	Message  string            `json:"message"`
+	Error    string            `json:"error,omitempty"`
+	Items    []ClusterResponse `json:"items"`
+	Continue string            `json:"continue,omitempty"`
 }
 
 // UpdateClusterRequest represents the request body for cluster update
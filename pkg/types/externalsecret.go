@@ -45,19 +45,23 @@ type SecretStoreDetail struct {
 }
 
 type ListSecretStoreResponse struct {
-	Success bool                `json:"success"`
-	Total   int                 `json:"total"`
-	Items   []SecretStoreDetail `json:"items"`
-	Message string              `json:"message"`
-	Error   string              `json:"error,omitempty"`
+	Success  bool                `json:"success"`
+	Total    int                 `json:"total"`
+	Items    []SecretStoreDetail `json:"items"`
+	Message  string              `json:"message"`
+	Error    string              `json:"error,omitempty"`
+	Continue string              `json:"continue,omitempty"`
 }
 
+// SecretStoreUpdateRequest updates a secret store, the provider replaces the provider of the secret store,
+// path, auth, server and version only update a secret store with the vault provider
 type SecretStoreUpdateRequest struct {
-	Name    string                        `json:"name,omitempty"`
-	Path    string                        `json:"path,omitempty"`
-	Auth    *esv1beta1.VaultAuth          `json:"auth,omitempty"`
-	Server  string                        `json:"server,omitempty"`
-	Version esv1beta1.VaultKVStoreVersion `json:"version,omitempty"`
+	Name     string                         `json:"name,omitempty"`
+	Provider *esv1beta1.SecretStoreProvider `json:"provider,omitempty"`
+	Path     string                         `json:"path,omitempty"`
+	Auth     *esv1beta1.VaultAuth           `json:"auth,omitempty"`
+	Server   string                         `json:"server,omitempty"`
+	Version  esv1beta1.VaultKVStoreVersion  `json:"version,omitempty"`
 }
 
 type SecretStoreUpdateResponse struct {
@@ -0,0 +1,85 @@
+package types
+
+import (
+	"slices"
+	"time"
+)
+
+type (
+	// FreezeWindow is a period during which the applications can not be changed. It recurs with
+	// Schedule, a cron expression of its start, and lasts Duration, or it is the absolute range Start to End.
+	// Tenants, Environments and Clusters scope the window, empty ones match everything.
+	// Freeze windows are stored in the meta repo, one yaml file for each window
+	FreezeWindow struct {
+		Name string `json:"name"`
+		FreezeWindowSpec
+		CreatedBy string     `json:"created_by"`
+		CreatedAt time.Time  `json:"created_at"`
+		UpdatedBy string     `json:"updated_by,omitempty"`
+		UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	}
+
+	FreezeWindowSpec struct {
+		Description string `json:"description,omitempty"`
+		// Schedule is a standard cron expression, like `0 18 * * 5` for every friday at 18:00
+		Schedule string `json:"schedule,omitempty"`
+		// Duration is a duration like `60h`, required with Schedule
+		Duration string     `json:"duration,omitempty"`
+		Start    *time.Time `json:"start,omitempty"`
+		End      *time.Time `json:"end,omitempty"`
+		// TimeZone is the IANA time zone of Schedule, UTC by default
+		TimeZone     string   `json:"time_zone,omitempty"`
+		Tenants      []string `json:"tenants,omitempty"`
+		Environments []string `json:"environments,omitempty" binding:"dive,oneof=DEV SIT UAT PRD"`
+		Clusters     []string `json:"clusters,omitempty"`
+	}
+
+	// FreezeCluster is a destination cluster that freeze windows are scoped to
+	FreezeCluster struct {
+		Name        string
+		Server      string
+		Environment string
+	}
+
+	FreezeWindowCreateRequest struct {
+		Name string `json:"name" binding:"required"`
+		FreezeWindowSpec
+	}
+
+	// FreezeWindowUpdateRequest replaces the spec of the freeze window
+	FreezeWindowUpdateRequest struct {
+		FreezeWindowSpec
+	}
+
+	FreezeWindowResponse struct {
+		Success bool         `json:"success"`
+		Message string       `json:"message"`
+		Item    FreezeWindow `json:"item"`
+	}
+
+	FreezeWindowListResponse struct {
+		Success  bool           `json:"success"`
+		Message  string         `json:"message"`
+		Total    int            `json:"total"`
+		Items    []FreezeWindow `json:"items"`
+		Continue string         `json:"continue,omitempty"`
+	}
+)
+
+// ScopesTenant returns true if the window applies to the tenant
+func (w *FreezeWindow) ScopesTenant(tenant string) bool {
+	return matchScope(w.Tenants, tenant)
+}
+
+// AppliesTo returns true if the window scopes the tenant, and the cluster of the environment env
+func (w *FreezeWindow) AppliesTo(tenant, cluster, env string) bool {
+	return w.ScopesTenant(tenant) && matchScope(w.Environments, env) && matchScope(w.Clusters, cluster)
+}
+
+func matchScope(scope []string, value string) bool {
+	if len(scope) == 0 {
+		return true
+	}
+
+	return slices.Contains(scope, value)
+}
@@ -16,6 +16,8 @@ type (
 		AddCmd            argocd.AddClusterCmd
 		Labels            map[string]string
 		Annotations       map[string]string
+		// Clusters scope the freeze windows that are written as the sync windows of the project
+		Clusters []FreezeCluster
 	}
 
 	ProjectDeleteOptions struct {
@@ -33,6 +35,7 @@ type (
 		InstallationPath   string
 		Labels             map[string]string
 		Annotations        map[string]string
+		SyncWindows        argocdv1alpha1.SyncWindows
 	}
 )
 